@@ -16,7 +16,10 @@ const (
 	ReasonUnauthorized        = "Unauthorized"
 	ReasonForbidden           = "Forbidden"
 	ReasonNotFound            = "NotFound"
+	ReasonConflict            = "Conflict"
 	ReasonInternalServerError = "InternalServerError"
+	ReasonServiceUnavailable  = "ServiceUnavailable"
+	ReasonPolicyViolation     = "PolicyViolation"
 )
 
 // NewFromError 从错误创建
@@ -82,6 +85,15 @@ func NewNotFoundError(err error) *metav1.Status {
 	}
 }
 
+// NewConflictError 创建冲突错误
+func NewConflictError(err error) *metav1.Status {
+	return &metav1.Status{
+		Code:    http.StatusConflict,
+		Reason:  ReasonConflict,
+		Message: err.Error(),
+	}
+}
+
 // NewInternalServerError 创建服务内部错误结果
 func NewInternalServerError(err error) *metav1.Status {
 	return &metav1.Status{
@@ -90,3 +102,26 @@ func NewInternalServerError(err error) *metav1.Status {
 		Message: err.Error(),
 	}
 }
+
+// NewServiceUnavailableError 创建服务不可用错误
+func NewServiceUnavailableError(err error) *metav1.Status {
+	return &metav1.Status{
+		Code:    http.StatusServiceUnavailable,
+		Reason:  ReasonServiceUnavailable,
+		Message: err.Error(),
+	}
+}
+
+// NewPolicyViolationError 创建违反执行策略错误
+func NewPolicyViolationError(err error) *metav1.Status {
+	return &metav1.Status{
+		Code:    http.StatusForbidden,
+		Reason:  ReasonPolicyViolation,
+		Message: err.Error(),
+	}
+}
+
+// IsNotFound 返回错误是否是资源未找到错误
+func IsNotFound(err error) bool {
+	return err != nil && NewFromError(err).Code == http.StatusNotFound
+}
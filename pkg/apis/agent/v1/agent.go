@@ -0,0 +1,104 @@
+package v1
+
+import (
+	agentv1grpc "github.com/yhlooo/scaf/pkg/apis/agent/v1/grpc"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	metav1grpc "github.com/yhlooo/scaf/pkg/apis/meta/v1/grpc"
+)
+
+// Agent 代理
+// 长期运行在执行端，注册到服务端后等待派发给它的请求
+type Agent struct {
+	metav1.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	Spec AgentSpec `json:"spec,omitempty" yaml:"spec,omitempty"`
+}
+
+// AgentSpec 代理定义
+type AgentSpec struct {
+	// 标签
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// 除所有者外允许向代理派发请求的用户
+	Users []string `json:"users,omitempty" yaml:"users,omitempty"`
+}
+
+// AgentList 代理列表
+type AgentList struct {
+	metav1.ListMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	Items []Agent `json:"items,omitempty" yaml:"items,omitempty"`
+}
+
+// MatchLabels 返回代理是否具有 selector 中所有标签
+func (agent *Agent) MatchLabels(selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := agent.Spec.Labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// NewAgentFromGRPC 基于 *agentv1grpc.Agent 创建 *Agent
+func NewAgentFromGRPC(in *agentv1grpc.Agent) *Agent {
+	if in == nil {
+		return nil
+	}
+	meta := metav1.NewObjectMetaFromGRPC(in.GetMetadata())
+	if meta == nil {
+		meta = &metav1.ObjectMeta{}
+	}
+	return &Agent{
+		ObjectMeta: *meta,
+		Spec: AgentSpec{
+			Labels: in.GetSpec().GetLabels(),
+			Users:  in.GetSpec().GetUsers(),
+		},
+	}
+}
+
+// NewGRPCAgent 基于 *Agent 创建 *agentv1grpc.Agent
+func NewGRPCAgent(in *Agent) *agentv1grpc.Agent {
+	if in == nil {
+		return nil
+	}
+	return &agentv1grpc.Agent{
+		Metadata: metav1.NewGRPCObjectMeta(&in.ObjectMeta),
+		Spec: &agentv1grpc.AgentSpec{
+			Labels: in.Spec.Labels,
+			Users:  in.Spec.Users,
+		},
+	}
+}
+
+// NewAgentListFromGRPC 基于 *agentv1grpc.AgentList 创建 *AgentList
+func NewAgentListFromGRPC(in *agentv1grpc.AgentList) *AgentList {
+	if in == nil {
+		return nil
+	}
+	if len(in.Items) == 0 {
+		return &AgentList{}
+	}
+
+	var items []Agent
+	for _, item := range in.Items {
+		items = append(items, *NewAgentFromGRPC(item))
+	}
+	return &AgentList{
+		Items: items,
+	}
+}
+
+// NewGRPCAgentList 基于 *AgentList 创建 *agentv1grpc.AgentList
+func NewGRPCAgentList(in *AgentList) *agentv1grpc.AgentList {
+	if in == nil {
+		return nil
+	}
+	ret := &agentv1grpc.AgentList{
+		Metadata: &metav1grpc.ListMeta{},
+	}
+	for _, item := range in.Items {
+		ret.Items = append(ret.Items, NewGRPCAgent(&item))
+	}
+	return ret
+}
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	agentv1grpc "github.com/yhlooo/scaf/pkg/apis/agent/v1/grpc"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+)
+
+// Dispatch 派发给代理的请求
+// 代理收到后加入指定的流，按请求类型执行命令或传输文件
+type Dispatch struct {
+	metav1.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+
+	Spec   DispatchSpec   `json:"spec,omitempty" yaml:"spec,omitempty"`
+	Status DispatchStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// DispatchSpec 派发请求定义
+type DispatchSpec struct {
+	// 代理名
+	Agent string `json:"agent,omitempty" yaml:"agent,omitempty"`
+	// 请求类型
+	Type DispatchType `json:"type,omitempty" yaml:"type,omitempty"`
+	// 代理要加入的流
+	Stream string `json:"stream,omitempty" yaml:"stream,omitempty"`
+	// 代理端的文件路径，仅用于文件传输和文件服务
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// DispatchType 派发请求类型
+type DispatchType string
+
+const (
+	// DispatchExec 执行流中指定的命令
+	DispatchExec DispatchType = "Exec"
+	// DispatchSendFile 将代理端的文件发送到流
+	DispatchSendFile DispatchType = "SendFile"
+	// DispatchReceiveFile 从流接收文件到代理端
+	DispatchReceiveFile DispatchType = "ReceiveFile"
+	// DispatchFileServer 以代理端的路径为根目录，为流中的对端提供文件服务
+	DispatchFileServer DispatchType = "FileServer"
+	// DispatchSFTP 以代理端的路径为工作目录，通过 SFTP 协议为流中的对端提供文件服务
+	DispatchSFTP DispatchType = "SFTP"
+)
+
+// DispatchStatus 派发请求状态
+type DispatchStatus struct {
+	// 代理用于加入流的 token ，只发送给代理
+	Token string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+// NewDispatchFromGRPC 基于 *agentv1grpc.Dispatch 创建 *Dispatch
+func NewDispatchFromGRPC(in *agentv1grpc.Dispatch) *Dispatch {
+	if in == nil {
+		return nil
+	}
+	meta := metav1.NewObjectMetaFromGRPC(in.GetMetadata())
+	if meta == nil {
+		meta = &metav1.ObjectMeta{}
+	}
+	return &Dispatch{
+		ObjectMeta: *meta,
+		Spec: DispatchSpec{
+			Agent:  in.GetSpec().GetAgent(),
+			Type:   DispatchType(in.GetSpec().GetType()),
+			Stream: in.GetSpec().GetStream(),
+			Path:   in.GetSpec().GetPath(),
+		},
+		Status: DispatchStatus{
+			Token: in.GetStatus().GetToken(),
+		},
+	}
+}
+
+// NewGRPCDispatch 基于 *Dispatch 创建 *agentv1grpc.Dispatch
+func NewGRPCDispatch(in *Dispatch) *agentv1grpc.Dispatch {
+	if in == nil {
+		return nil
+	}
+	return &agentv1grpc.Dispatch{
+		Metadata: metav1.NewGRPCObjectMeta(&in.ObjectMeta),
+		Spec: &agentv1grpc.DispatchSpec{
+			Agent:  in.Spec.Agent,
+			Type:   string(in.Spec.Type),
+			Stream: in.Spec.Stream,
+			Path:   in.Spec.Path,
+		},
+		Status: &agentv1grpc.DispatchStatus{
+			Token: in.Status.Token,
+		},
+	}
+}
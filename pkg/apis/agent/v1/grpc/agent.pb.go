@@ -0,0 +1,622 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        v5.29.1
+// source: pkg/apis/agent/v1/grpc/agent.proto
+
+package grpc
+
+import (
+	grpc "github.com/yhlooo/scaf/pkg/apis/meta/v1/grpc"
+	grpc1 "github.com/yhlooo/scaf/pkg/apis/stream/v1/grpc"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ListAgentsRequest ListAgents 请求
+type ListAgentsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListAgentsRequest) Reset() {
+	*x = ListAgentsRequest{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAgentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAgentsRequest) ProtoMessage() {}
+
+func (x *ListAgentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAgentsRequest.ProtoReflect.Descriptor instead.
+func (*ListAgentsRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{0}
+}
+
+// GetAgentRequest GetAgent 请求
+type GetAgentRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *GetAgentRequest) Reset() {
+	*x = GetAgentRequest{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAgentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAgentRequest) ProtoMessage() {}
+
+func (x *GetAgentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAgentRequest.ProtoReflect.Descriptor instead.
+func (*GetAgentRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetAgentRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// Agent 代理
+type Agent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Metadata *grpc.ObjectMeta `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Spec     *AgentSpec       `protobuf:"bytes,2,opt,name=spec,proto3" json:"spec,omitempty"`
+}
+
+func (x *Agent) Reset() {
+	*x = Agent{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Agent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Agent) ProtoMessage() {}
+
+func (x *Agent) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Agent.ProtoReflect.Descriptor instead.
+func (*Agent) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Agent) GetMetadata() *grpc.ObjectMeta {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Agent) GetSpec() *AgentSpec {
+	if x != nil {
+		return x.Spec
+	}
+	return nil
+}
+
+// AgentSpec 代理定义
+type AgentSpec struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 标签
+	Labels map[string]string `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// 除所有者外允许向代理派发请求的用户
+	Users []string `protobuf:"bytes,2,rep,name=users,proto3" json:"users,omitempty"`
+}
+
+func (x *AgentSpec) Reset() {
+	*x = AgentSpec{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentSpec) ProtoMessage() {}
+
+func (x *AgentSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentSpec.ProtoReflect.Descriptor instead.
+func (*AgentSpec) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AgentSpec) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *AgentSpec) GetUsers() []string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+// AgentList 代理列表
+type AgentList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Metadata *grpc.ListMeta `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Items    []*Agent       `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *AgentList) Reset() {
+	*x = AgentList{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AgentList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentList) ProtoMessage() {}
+
+func (x *AgentList) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentList.ProtoReflect.Descriptor instead.
+func (*AgentList) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AgentList) GetMetadata() *grpc.ListMeta {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *AgentList) GetItems() []*Agent {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// Dispatch 派发给代理的请求
+type Dispatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Metadata *grpc.ObjectMeta `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Spec     *DispatchSpec    `protobuf:"bytes,2,opt,name=spec,proto3" json:"spec,omitempty"`
+	Status   *DispatchStatus  `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (x *Dispatch) Reset() {
+	*x = Dispatch{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dispatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dispatch) ProtoMessage() {}
+
+func (x *Dispatch) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dispatch.ProtoReflect.Descriptor instead.
+func (*Dispatch) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Dispatch) GetMetadata() *grpc.ObjectMeta {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Dispatch) GetSpec() *DispatchSpec {
+	if x != nil {
+		return x.Spec
+	}
+	return nil
+}
+
+func (x *Dispatch) GetStatus() *DispatchStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+// DispatchSpec 派发请求定义
+type DispatchSpec struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 代理名
+	Agent string `protobuf:"bytes,1,opt,name=agent,proto3" json:"agent,omitempty"`
+	// 请求类型
+	Type string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	// 代理要加入的流
+	Stream string `protobuf:"bytes,3,opt,name=stream,proto3" json:"stream,omitempty"`
+	// 代理端的文件路径，仅用于文件传输
+	Path string `protobuf:"bytes,4,opt,name=path,proto3" json:"path,omitempty"`
+}
+
+func (x *DispatchSpec) Reset() {
+	*x = DispatchSpec{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DispatchSpec) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DispatchSpec) ProtoMessage() {}
+
+func (x *DispatchSpec) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DispatchSpec.ProtoReflect.Descriptor instead.
+func (*DispatchSpec) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DispatchSpec) GetAgent() string {
+	if x != nil {
+		return x.Agent
+	}
+	return ""
+}
+
+func (x *DispatchSpec) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DispatchSpec) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *DispatchSpec) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+// DispatchStatus 派发请求状态
+type DispatchStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 代理用于加入流的 token
+	Token string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+}
+
+func (x *DispatchStatus) Reset() {
+	*x = DispatchStatus{}
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DispatchStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DispatchStatus) ProtoMessage() {}
+
+func (x *DispatchStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DispatchStatus.ProtoReflect.Descriptor instead.
+func (*DispatchStatus) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DispatchStatus) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+var File_pkg_apis_agent_v1_grpc_agent_proto protoreflect.FileDescriptor
+
+var file_pkg_apis_agent_v1_grpc_agent_proto_rawDesc = []byte{
+	0x0a, 0x22, 0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x2f, 0x61, 0x67, 0x65, 0x6e, 0x74,
+	0x2f, 0x76, 0x31, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x18, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x1a, 0x20,
+	0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x2f, 0x6d, 0x65, 0x74, 0x61, 0x2f, 0x76, 0x31,
+	0x2f, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x1a, 0x24, 0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x2f, 0x73, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x2f, 0x76, 0x31, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x13, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x25, 0x0a, 0x0f, 0x47,
+	0x65, 0x74, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x22, 0x81, 0x01, 0x0a, 0x05, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x12, 0x3f, 0x0a, 0x08,
+	0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23,
+	0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66,
+	0x2e, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x4d,
+	0x65, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x37, 0x0a,
+	0x04, 0x73, 0x70, 0x65, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23, 0x2e, 0x79, 0x68,
+	0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x61, 0x67,
+	0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x53, 0x70, 0x65, 0x63,
+	0x52, 0x04, 0x73, 0x70, 0x65, 0x63, 0x22, 0xa5, 0x01, 0x0a, 0x09, 0x41, 0x67, 0x65, 0x6e, 0x74,
+	0x53, 0x70, 0x65, 0x63, 0x12, 0x47, 0x0a, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x2f, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e,
+	0x41, 0x67, 0x65, 0x6e, 0x74, 0x53, 0x70, 0x65, 0x63, 0x2e, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x12, 0x14, 0x0a,
+	0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x75, 0x73,
+	0x65, 0x72, 0x73, 0x1a, 0x39, 0x0a, 0x0b, 0x4c, 0x61, 0x62, 0x65, 0x6c, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x81,
+	0x01, 0x0a, 0x09, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x3d, 0x0a, 0x08,
+	0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21,
+	0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66,
+	0x2e, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x65, 0x74,
+	0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x35, 0x0a, 0x05, 0x69,
+	0x74, 0x65, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x79, 0x68, 0x6c,
+	0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x61, 0x67, 0x65,
+	0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x05, 0x69, 0x74, 0x65,
+	0x6d, 0x73, 0x22, 0xc9, 0x01, 0x0a, 0x08, 0x44, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x12,
+	0x3f, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x23, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73,
+	0x63, 0x61, 0x66, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x6a, 0x65,
+	0x63, 0x74, 0x4d, 0x65, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x12, 0x3a, 0x0a, 0x04, 0x73, 0x70, 0x65, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x26,
+	0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66,
+	0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x70, 0x61, 0x74,
+	0x63, 0x68, 0x53, 0x70, 0x65, 0x63, 0x52, 0x04, 0x73, 0x70, 0x65, 0x63, 0x12, 0x40, 0x0a, 0x06,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x79,
+	0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x61,
+	0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x64,
+	0x0a, 0x0c, 0x44, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x53, 0x70, 0x65, 0x63, 0x12, 0x14,
+	0x0a, 0x05, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x61,
+	0x67, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x70, 0x61, 0x74, 0x68, 0x22, 0x26, 0x0a, 0x0e, 0x44, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x32, 0xf6, 0x02, 0x0a,
+	0x06, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x5e, 0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x41,
+	0x67, 0x65, 0x6e, 0x74, 0x73, 0x12, 0x2b, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63,
+	0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31,
+	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x23, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e,
+	0x73, 0x63, 0x61, 0x66, 0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x56, 0x0a, 0x08, 0x47, 0x65, 0x74, 0x41, 0x67,
+	0x65, 0x6e, 0x74, 0x12, 0x29, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x47,
+	0x65, 0x74, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f,
+	0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66,
+	0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x12,
+	0x5a, 0x0a, 0x0c, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x12,
+	0x22, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61,
+	0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61, 0x63, 0x6b,
+	0x61, 0x67, 0x65, 0x1a, 0x22, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e,
+	0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x28, 0x01, 0x30, 0x01, 0x12, 0x58, 0x0a, 0x0e, 0x43,
+	0x72, 0x65, 0x61, 0x74, 0x65, 0x44, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63, 0x68, 0x12, 0x22, 0x2e,
+	0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e,
+	0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x70, 0x61, 0x74, 0x63,
+	0x68, 0x1a, 0x22, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73,
+	0x63, 0x61, 0x66, 0x2e, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73,
+	0x70, 0x61, 0x74, 0x63, 0x68, 0x42, 0x2f, 0x5a, 0x2d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2f, 0x73, 0x63, 0x61, 0x66, 0x2f,
+	0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x2f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x2f, 0x76,
+	0x31, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pkg_apis_agent_v1_grpc_agent_proto_rawDescOnce sync.Once
+	file_pkg_apis_agent_v1_grpc_agent_proto_rawDescData = file_pkg_apis_agent_v1_grpc_agent_proto_rawDesc
+)
+
+func file_pkg_apis_agent_v1_grpc_agent_proto_rawDescGZIP() []byte {
+	file_pkg_apis_agent_v1_grpc_agent_proto_rawDescOnce.Do(func() {
+		file_pkg_apis_agent_v1_grpc_agent_proto_rawDescData = protoimpl.X.CompressGZIP(file_pkg_apis_agent_v1_grpc_agent_proto_rawDescData)
+	})
+	return file_pkg_apis_agent_v1_grpc_agent_proto_rawDescData
+}
+
+var file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_pkg_apis_agent_v1_grpc_agent_proto_goTypes = []any{
+	(*ListAgentsRequest)(nil), // 0: yhlooo.com.scaf.agent.v1.ListAgentsRequest
+	(*GetAgentRequest)(nil),   // 1: yhlooo.com.scaf.agent.v1.GetAgentRequest
+	(*Agent)(nil),             // 2: yhlooo.com.scaf.agent.v1.Agent
+	(*AgentSpec)(nil),         // 3: yhlooo.com.scaf.agent.v1.AgentSpec
+	(*AgentList)(nil),         // 4: yhlooo.com.scaf.agent.v1.AgentList
+	(*Dispatch)(nil),          // 5: yhlooo.com.scaf.agent.v1.Dispatch
+	(*DispatchSpec)(nil),      // 6: yhlooo.com.scaf.agent.v1.DispatchSpec
+	(*DispatchStatus)(nil),    // 7: yhlooo.com.scaf.agent.v1.DispatchStatus
+	nil,                       // 8: yhlooo.com.scaf.agent.v1.AgentSpec.LabelsEntry
+	(*grpc.ObjectMeta)(nil),   // 9: yhlooo.com.scaf.meta.v1.ObjectMeta
+	(*grpc.ListMeta)(nil),     // 10: yhlooo.com.scaf.meta.v1.ListMeta
+	(*grpc1.Package)(nil),     // 11: yhlooo.com.scaf.stream.v1.Package
+}
+var file_pkg_apis_agent_v1_grpc_agent_proto_depIdxs = []int32{
+	9,  // 0: yhlooo.com.scaf.agent.v1.Agent.metadata:type_name -> yhlooo.com.scaf.meta.v1.ObjectMeta
+	3,  // 1: yhlooo.com.scaf.agent.v1.Agent.spec:type_name -> yhlooo.com.scaf.agent.v1.AgentSpec
+	8,  // 2: yhlooo.com.scaf.agent.v1.AgentSpec.labels:type_name -> yhlooo.com.scaf.agent.v1.AgentSpec.LabelsEntry
+	10, // 3: yhlooo.com.scaf.agent.v1.AgentList.metadata:type_name -> yhlooo.com.scaf.meta.v1.ListMeta
+	2,  // 4: yhlooo.com.scaf.agent.v1.AgentList.items:type_name -> yhlooo.com.scaf.agent.v1.Agent
+	9,  // 5: yhlooo.com.scaf.agent.v1.Dispatch.metadata:type_name -> yhlooo.com.scaf.meta.v1.ObjectMeta
+	6,  // 6: yhlooo.com.scaf.agent.v1.Dispatch.spec:type_name -> yhlooo.com.scaf.agent.v1.DispatchSpec
+	7,  // 7: yhlooo.com.scaf.agent.v1.Dispatch.status:type_name -> yhlooo.com.scaf.agent.v1.DispatchStatus
+	0,  // 8: yhlooo.com.scaf.agent.v1.Agents.ListAgents:input_type -> yhlooo.com.scaf.agent.v1.ListAgentsRequest
+	1,  // 9: yhlooo.com.scaf.agent.v1.Agents.GetAgent:input_type -> yhlooo.com.scaf.agent.v1.GetAgentRequest
+	11, // 10: yhlooo.com.scaf.agent.v1.Agents.ConnectAgent:input_type -> yhlooo.com.scaf.stream.v1.Package
+	5,  // 11: yhlooo.com.scaf.agent.v1.Agents.CreateDispatch:input_type -> yhlooo.com.scaf.agent.v1.Dispatch
+	4,  // 12: yhlooo.com.scaf.agent.v1.Agents.ListAgents:output_type -> yhlooo.com.scaf.agent.v1.AgentList
+	2,  // 13: yhlooo.com.scaf.agent.v1.Agents.GetAgent:output_type -> yhlooo.com.scaf.agent.v1.Agent
+	11, // 14: yhlooo.com.scaf.agent.v1.Agents.ConnectAgent:output_type -> yhlooo.com.scaf.stream.v1.Package
+	5,  // 15: yhlooo.com.scaf.agent.v1.Agents.CreateDispatch:output_type -> yhlooo.com.scaf.agent.v1.Dispatch
+	12, // [12:16] is the sub-list for method output_type
+	8,  // [8:12] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_pkg_apis_agent_v1_grpc_agent_proto_init() }
+func file_pkg_apis_agent_v1_grpc_agent_proto_init() {
+	if File_pkg_apis_agent_v1_grpc_agent_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pkg_apis_agent_v1_grpc_agent_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pkg_apis_agent_v1_grpc_agent_proto_goTypes,
+		DependencyIndexes: file_pkg_apis_agent_v1_grpc_agent_proto_depIdxs,
+		MessageInfos:      file_pkg_apis_agent_v1_grpc_agent_proto_msgTypes,
+	}.Build()
+	File_pkg_apis_agent_v1_grpc_agent_proto = out.File
+	file_pkg_apis_agent_v1_grpc_agent_proto_rawDesc = nil
+	file_pkg_apis_agent_v1_grpc_agent_proto_goTypes = nil
+	file_pkg_apis_agent_v1_grpc_agent_proto_depIdxs = nil
+}
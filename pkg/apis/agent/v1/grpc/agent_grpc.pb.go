@@ -0,0 +1,235 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.1
+// source: pkg/apis/agent/v1/grpc/agent.proto
+
+package grpc
+
+import (
+	context "context"
+	grpc1 "github.com/yhlooo/scaf/pkg/apis/stream/v1/grpc"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Agents_ListAgents_FullMethodName     = "/yhlooo.com.scaf.agent.v1.Agents/ListAgents"
+	Agents_GetAgent_FullMethodName       = "/yhlooo.com.scaf.agent.v1.Agents/GetAgent"
+	Agents_ConnectAgent_FullMethodName   = "/yhlooo.com.scaf.agent.v1.Agents/ConnectAgent"
+	Agents_CreateDispatch_FullMethodName = "/yhlooo.com.scaf.agent.v1.Agents/CreateDispatch"
+)
+
+// AgentsClient is the client API for Agents service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// 代理注册和请求派发服务
+type AgentsClient interface {
+	ListAgents(ctx context.Context, in *ListAgentsRequest, opts ...grpc.CallOption) (*AgentList, error)
+	GetAgent(ctx context.Context, in *GetAgentRequest, opts ...grpc.CallOption) (*Agent, error)
+	ConnectAgent(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[grpc1.Package, grpc1.Package], error)
+	CreateDispatch(ctx context.Context, in *Dispatch, opts ...grpc.CallOption) (*Dispatch, error)
+}
+
+type agentsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentsClient(cc grpc.ClientConnInterface) AgentsClient {
+	return &agentsClient{cc}
+}
+
+func (c *agentsClient) ListAgents(ctx context.Context, in *ListAgentsRequest, opts ...grpc.CallOption) (*AgentList, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AgentList)
+	err := c.cc.Invoke(ctx, Agents_ListAgents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentsClient) GetAgent(ctx context.Context, in *GetAgentRequest, opts ...grpc.CallOption) (*Agent, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Agent)
+	err := c.cc.Invoke(ctx, Agents_GetAgent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentsClient) ConnectAgent(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[grpc1.Package, grpc1.Package], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Agents_ServiceDesc.Streams[0], Agents_ConnectAgent_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[grpc1.Package, grpc1.Package]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Agents_ConnectAgentClient = grpc.BidiStreamingClient[grpc1.Package, grpc1.Package]
+
+func (c *agentsClient) CreateDispatch(ctx context.Context, in *Dispatch, opts ...grpc.CallOption) (*Dispatch, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Dispatch)
+	err := c.cc.Invoke(ctx, Agents_CreateDispatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgentsServer is the server API for Agents service.
+// All implementations must embed UnimplementedAgentsServer
+// for forward compatibility.
+//
+// 代理注册和请求派发服务
+type AgentsServer interface {
+	ListAgents(context.Context, *ListAgentsRequest) (*AgentList, error)
+	GetAgent(context.Context, *GetAgentRequest) (*Agent, error)
+	ConnectAgent(grpc.BidiStreamingServer[grpc1.Package, grpc1.Package]) error
+	CreateDispatch(context.Context, *Dispatch) (*Dispatch, error)
+	mustEmbedUnimplementedAgentsServer()
+}
+
+// UnimplementedAgentsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAgentsServer struct{}
+
+func (UnimplementedAgentsServer) ListAgents(context.Context, *ListAgentsRequest) (*AgentList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAgents not implemented")
+}
+func (UnimplementedAgentsServer) GetAgent(context.Context, *GetAgentRequest) (*Agent, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAgent not implemented")
+}
+func (UnimplementedAgentsServer) ConnectAgent(grpc.BidiStreamingServer[grpc1.Package, grpc1.Package]) error {
+	return status.Errorf(codes.Unimplemented, "method ConnectAgent not implemented")
+}
+func (UnimplementedAgentsServer) CreateDispatch(context.Context, *Dispatch) (*Dispatch, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateDispatch not implemented")
+}
+func (UnimplementedAgentsServer) mustEmbedUnimplementedAgentsServer() {}
+func (UnimplementedAgentsServer) testEmbeddedByValue()                {}
+
+// UnsafeAgentsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentsServer will
+// result in compilation errors.
+type UnsafeAgentsServer interface {
+	mustEmbedUnimplementedAgentsServer()
+}
+
+func RegisterAgentsServer(s grpc.ServiceRegistrar, srv AgentsServer) {
+	// If the following call pancis, it indicates UnimplementedAgentsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Agents_ServiceDesc, srv)
+}
+
+func _Agents_ListAgents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAgentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentsServer).ListAgents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agents_ListAgents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentsServer).ListAgents(ctx, req.(*ListAgentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agents_GetAgent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAgentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentsServer).GetAgent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agents_GetAgent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentsServer).GetAgent(ctx, req.(*GetAgentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agents_ConnectAgent_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentsServer).ConnectAgent(&grpc.GenericServerStream[grpc1.Package, grpc1.Package]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Agents_ConnectAgentServer = grpc.BidiStreamingServer[grpc1.Package, grpc1.Package]
+
+func _Agents_CreateDispatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Dispatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentsServer).CreateDispatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Agents_CreateDispatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentsServer).CreateDispatch(ctx, req.(*Dispatch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Agents_ServiceDesc is the grpc.ServiceDesc for Agents service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Agents_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "yhlooo.com.scaf.agent.v1.Agents",
+	HandlerType: (*AgentsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListAgents",
+			Handler:    _Agents_ListAgents_Handler,
+		},
+		{
+			MethodName: "GetAgent",
+			Handler:    _Agents_GetAgent_Handler,
+		},
+		{
+			MethodName: "CreateDispatch",
+			Handler:    _Agents_CreateDispatch_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ConnectAgent",
+			Handler:       _Agents_ConnectAgent_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pkg/apis/agent/v1/grpc/agent.proto",
+}
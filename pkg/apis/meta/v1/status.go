@@ -34,10 +34,14 @@ func (s *Status) GRPCStatus() *status.Status {
 		grpcCode = codes.PermissionDenied
 	case http.StatusNotFound:
 		grpcCode = codes.NotFound
+	case http.StatusConflict:
+		grpcCode = codes.AlreadyExists
 	case http.StatusMethodNotAllowed:
 		grpcCode = codes.Unimplemented
 	case http.StatusInternalServerError:
 		grpcCode = codes.Internal
+	case http.StatusServiceUnavailable:
+		grpcCode = codes.Unavailable
 	}
 	ret, _ := status.New(grpcCode, s.Error()).WithDetails(&metav1grpc.Status{
 		Code:    int32(s.Code),
@@ -150,18 +150,79 @@ func (x *DeleteStreamRequest) GetName() string {
 	return ""
 }
 
+// SignalStreamRequest SignalStream 请求
+type SignalStreamRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// 信号名，如 SIGTERM
+	Signal string `protobuf:"bytes,2,opt,name=signal,proto3" json:"signal,omitempty"`
+}
+
+func (x *SignalStreamRequest) Reset() {
+	*x = SignalStreamRequest{}
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SignalStreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignalStreamRequest) ProtoMessage() {}
+
+func (x *SignalStreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignalStreamRequest.ProtoReflect.Descriptor instead.
+func (*SignalStreamRequest) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SignalStreamRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SignalStreamRequest) GetSignal() string {
+	if x != nil {
+		return x.Signal
+	}
+	return ""
+}
+
 // Package 流中传递的包
+//
+// 控制消息和错误消息仅在连接时协商的协议版本不低于 1 时由服务端发送
 type Package struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Content []byte `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	// Types that are assignable to Payload:
+	//	*Package_Content
+	//	*Package_Control
+	//	*Package_Error
+	Payload isPackage_Payload `protobuf_oneof:"payload"`
 }
 
 func (x *Package) Reset() {
 	*x = Package{}
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[3]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -173,7 +234,7 @@ func (x *Package) String() string {
 func (*Package) ProtoMessage() {}
 
 func (x *Package) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[3]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -186,16 +247,127 @@ func (x *Package) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Package.ProtoReflect.Descriptor instead.
 func (*Package) Descriptor() ([]byte, []int) {
-	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{3}
+	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{4}
+}
+
+func (m *Package) GetPayload() isPackage_Payload {
+	if m != nil {
+		return m.Payload
+	}
+	return nil
 }
 
 func (x *Package) GetContent() []byte {
-	if x != nil {
+	if x, ok := x.GetPayload().(*Package_Content); ok {
 		return x.Content
 	}
 	return nil
 }
 
+func (x *Package) GetControl() *Control {
+	if x, ok := x.GetPayload().(*Package_Control); ok {
+		return x.Control
+	}
+	return nil
+}
+
+func (x *Package) GetError() *grpc.Status {
+	if x, ok := x.GetPayload().(*Package_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
+type isPackage_Payload interface {
+	isPackage_Payload()
+}
+
+type Package_Content struct {
+	// 数据
+	Content []byte `protobuf:"bytes,1,opt,name=content,proto3,oneof"`
+}
+
+type Package_Control struct {
+	// 控制消息
+	Control *Control `protobuf:"bytes,2,opt,name=control,proto3,oneof"`
+}
+
+type Package_Error struct {
+	// 错误消息，发送后服务端会断开连接
+	Error *grpc.Status `protobuf:"bytes,3,opt,name=error,proto3,oneof"`
+}
+
+func (*Package_Content) isPackage_Payload() {}
+
+func (*Package_Control) isPackage_Payload() {}
+
+func (*Package_Error) isPackage_Payload() {}
+
+// Control 控制消息
+type Control struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 类型，如 PeerJoined 、 PeerLeft 、 StreamStopping 、 ServerDraining 、 WindowUpdate 、 Signal
+	Type string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	// 流控窗口增量，单位字节，仅 WindowUpdate 使用
+	WindowIncrement int64 `protobuf:"varint,2,opt,name=window_increment,json=windowIncrement,proto3" json:"window_increment,omitempty"`
+	// 信号名，如 SIGTERM ，仅 Signal 使用
+	Signal string `protobuf:"bytes,3,opt,name=signal,proto3" json:"signal,omitempty"`
+}
+
+func (x *Control) Reset() {
+	*x = Control{}
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Control) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Control) ProtoMessage() {}
+
+func (x *Control) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Control.ProtoReflect.Descriptor instead.
+func (*Control) Descriptor() ([]byte, []int) {
+	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Control) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Control) GetWindowIncrement() int64 {
+	if x != nil {
+		return x.WindowIncrement
+	}
+	return 0
+}
+
+func (x *Control) GetSignal() string {
+	if x != nil {
+		return x.Signal
+	}
+	return ""
+}
+
 // Stream 流
 type Stream struct {
 	state         protoimpl.MessageState
@@ -209,7 +381,7 @@ type Stream struct {
 
 func (x *Stream) Reset() {
 	*x = Stream{}
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[4]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -221,7 +393,7 @@ func (x *Stream) String() string {
 func (*Stream) ProtoMessage() {}
 
 func (x *Stream) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[4]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -234,7 +406,7 @@ func (x *Stream) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Stream.ProtoReflect.Descriptor instead.
 func (*Stream) Descriptor() ([]byte, []int) {
-	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{4}
+	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Stream) GetMetadata() *grpc.ObjectMeta {
@@ -270,7 +442,7 @@ type StreamSpec struct {
 
 func (x *StreamSpec) Reset() {
 	*x = StreamSpec{}
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[5]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -282,7 +454,7 @@ func (x *StreamSpec) String() string {
 func (*StreamSpec) ProtoMessage() {}
 
 func (x *StreamSpec) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[5]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -295,7 +467,7 @@ func (x *StreamSpec) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamSpec.ProtoReflect.Descriptor instead.
 func (*StreamSpec) Descriptor() ([]byte, []int) {
-	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{5}
+	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *StreamSpec) GetStopPolicy() string {
@@ -317,7 +489,7 @@ type StreamStatus struct {
 
 func (x *StreamStatus) Reset() {
 	*x = StreamStatus{}
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[6]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -329,7 +501,7 @@ func (x *StreamStatus) String() string {
 func (*StreamStatus) ProtoMessage() {}
 
 func (x *StreamStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[6]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -342,7 +514,7 @@ func (x *StreamStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamStatus.ProtoReflect.Descriptor instead.
 func (*StreamStatus) Descriptor() ([]byte, []int) {
-	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{6}
+	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *StreamStatus) GetToken() string {
@@ -364,7 +536,7 @@ type StreamList struct {
 
 func (x *StreamList) Reset() {
 	*x = StreamList{}
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[7]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -376,7 +548,7 @@ func (x *StreamList) String() string {
 func (*StreamList) ProtoMessage() {}
 
 func (x *StreamList) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[7]
+	mi := &file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -389,7 +561,7 @@ func (x *StreamList) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamList.ProtoReflect.Descriptor instead.
 func (*StreamList) Descriptor() ([]byte, []int) {
-	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{7}
+	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *StreamList) GetMetadata() *grpc.ListMeta {
@@ -424,69 +596,94 @@ var file_pkg_apis_stream_v1_grpc_stream_proto_rawDesc = []byte{
 	0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x29, 0x0a, 0x13, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53,
 	0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04,
 	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65,
-	0x22, 0x23, 0x0a, 0x07, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63,
-	0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x63, 0x6f,
-	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0xc5, 0x01, 0x0a, 0x06, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
-	0x12, 0x3f, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x23, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e,
-	0x73, 0x63, 0x61, 0x66, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x6a,
-	0x65, 0x63, 0x74, 0x4d, 0x65, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
-	0x61, 0x12, 0x39, 0x0a, 0x04, 0x73, 0x70, 0x65, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x25, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61,
-	0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x53, 0x70, 0x65, 0x63, 0x52, 0x04, 0x73, 0x70, 0x65, 0x63, 0x12, 0x3f, 0x0a, 0x06,
-	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x79,
+	0x22, 0x41, 0x0a, 0x13, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x69, 0x67, 0x6e, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x6c, 0x22, 0xa9, 0x01, 0x0a, 0x07, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x12,
+	0x1a, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c,
+	0x48, 0x00, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x3e, 0x0a, 0x07, 0x63,
+	0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x79,
 	0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73,
-	0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x53,
-	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x2d, 0x0a,
-	0x0a, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x53, 0x70, 0x65, 0x63, 0x12, 0x1f, 0x0a, 0x0b, 0x73,
-	0x74, 0x6f, 0x70, 0x5f, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0a, 0x73, 0x74, 0x6f, 0x70, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x22, 0x24, 0x0a, 0x0c,
-	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05,
-	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b,
-	0x65, 0x6e, 0x22, 0x84, 0x01, 0x0a, 0x0a, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4c, 0x69, 0x73,
-	0x74, 0x12, 0x3d, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d,
-	0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69,
-	0x73, 0x74, 0x4d, 0x65, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
-	0x12, 0x37, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32,
-	0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61,
-	0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65,
-	0x61, 0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x32, 0xdf, 0x03, 0x0a, 0x07, 0x53, 0x74,
-	0x72, 0x65, 0x61, 0x6d, 0x73, 0x12, 0x54, 0x0a, 0x0c, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53,
-	0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76,
-	0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x1a, 0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f,
-	0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61,
-	0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x5b, 0x0a, 0x09, 0x47,
-	0x65, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x2b, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c,
+	0x48, 0x00, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x12, 0x37, 0x0a, 0x05, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x79, 0x68, 0x6c,
+	0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x6d, 0x65, 0x74,
+	0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x48, 0x00, 0x52, 0x05, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x42, 0x09, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22,
+	0x60, 0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x29,
+	0x0a, 0x10, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x69, 0x6e, 0x63, 0x72, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+	0x49, 0x6e, 0x63, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x6c, 0x22, 0xc5, 0x01, 0x0a, 0x06, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x3f, 0x0a, 0x08,
+	0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x23,
+	0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66,
+	0x2e, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x4d,
+	0x65, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x39, 0x0a,
+	0x04, 0x73, 0x70, 0x65, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x79, 0x68,
+	0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x53, 0x70,
+	0x65, 0x63, 0x52, 0x04, 0x73, 0x70, 0x65, 0x63, 0x12, 0x3f, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x27, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f,
 	0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61,
-	0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76,
-	0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x63, 0x0a, 0x0b, 0x4c, 0x69, 0x73, 0x74,
-	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x12, 0x2d, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f,
-	0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d,
-	0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x52,
-	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x25, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e,
-	0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e,
-	0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x5f, 0x0a,
-	0x0c, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x2e, 0x2e,
-	0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e,
-	0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
-	0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e,
-	0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e,
-	0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x5b,
-	0x0a, 0x0d, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12,
-	0x22, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61,
-	0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61, 0x63, 0x6b,
-	0x61, 0x67, 0x65, 0x1a, 0x22, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d,
+	0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x2d, 0x0a, 0x0a, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x53, 0x70, 0x65, 0x63, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x74, 0x6f, 0x70, 0x5f,
+	0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x73, 0x74,
+	0x6f, 0x70, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x22, 0x24, 0x0a, 0x0c, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65,
+	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x84,
+	0x01, 0x0a, 0x0a, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x3d, 0x0a,
+	0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61,
+	0x66, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x65,
+	0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x37, 0x0a, 0x05,
+	0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x79, 0x68,
+	0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x05,
+	0x69, 0x74, 0x65, 0x6d, 0x73, 0x32, 0xc0, 0x04, 0x0a, 0x07, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d,
+	0x73, 0x12, 0x54, 0x0a, 0x0c, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x74, 0x72, 0x65, 0x61,
+	0x6d, 0x12, 0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73,
+	0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x1a, 0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31,
+	0x2e, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x5b, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x12, 0x2b, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f,
+	0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31,
+	0x2e, 0x47, 0x65, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x21, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73,
+	0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x12, 0x63, 0x0a, 0x0b, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x73, 0x12, 0x2d, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d,
 	0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e,
-	0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42, 0x30, 0x5a, 0x2e, 0x67,
-	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f,
-	0x2f, 0x73, 0x63, 0x61, 0x66, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x2f, 0x73,
-	0x74, 0x72, 0x65, 0x61, 0x6d, 0x2f, 0x76, 0x31, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x4c, 0x69, 0x73, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x25, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e,
+	0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x5f, 0x0a, 0x0c, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x2e, 0x2e, 0x79, 0x68, 0x6c, 0x6f,
+	0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x79, 0x68, 0x6c, 0x6f,
+	0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x6d, 0x65, 0x74, 0x61,
+	0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x5f, 0x0a, 0x0c, 0x53, 0x69,
+	0x67, 0x6e, 0x61, 0x6c, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x2e, 0x2e, 0x79, 0x68, 0x6c,
+	0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x53, 0x74, 0x72,
+	0x65, 0x61, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x79, 0x68, 0x6c,
+	0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x6d, 0x65, 0x74,
+	0x61, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x5b, 0x0a, 0x0d, 0x43,
+	0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x22, 0x2e, 0x79,
+	0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63, 0x61, 0x66, 0x2e, 0x73,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
+	0x1a, 0x22, 0x2e, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x63,
+	0x61, 0x66, 0x2e, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x61, 0x63,
+	0x6b, 0x61, 0x67, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42, 0x30, 0x5a, 0x2e, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x79, 0x68, 0x6c, 0x6f, 0x6f, 0x6f, 0x2f, 0x73, 0x63,
+	0x61, 0x66, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x61, 0x70, 0x69, 0x73, 0x2f, 0x73, 0x74, 0x72, 0x65,
+	0x61, 0x6d, 0x2f, 0x76, 0x31, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
 }
 
 var (
@@ -501,41 +698,47 @@ func file_pkg_apis_stream_v1_grpc_stream_proto_rawDescGZIP() []byte {
 	return file_pkg_apis_stream_v1_grpc_stream_proto_rawDescData
 }
 
-var file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_pkg_apis_stream_v1_grpc_stream_proto_goTypes = []any{
 	(*GetStreamRequest)(nil),    // 0: yhlooo.com.scaf.stream.v1.GetStreamRequest
 	(*ListStreamsRequest)(nil),  // 1: yhlooo.com.scaf.stream.v1.ListStreamsRequest
 	(*DeleteStreamRequest)(nil), // 2: yhlooo.com.scaf.stream.v1.DeleteStreamRequest
-	(*Package)(nil),             // 3: yhlooo.com.scaf.stream.v1.Package
-	(*Stream)(nil),              // 4: yhlooo.com.scaf.stream.v1.Stream
-	(*StreamSpec)(nil),          // 5: yhlooo.com.scaf.stream.v1.StreamSpec
-	(*StreamStatus)(nil),        // 6: yhlooo.com.scaf.stream.v1.StreamStatus
-	(*StreamList)(nil),          // 7: yhlooo.com.scaf.stream.v1.StreamList
-	(*grpc.ObjectMeta)(nil),     // 8: yhlooo.com.scaf.meta.v1.ObjectMeta
-	(*grpc.ListMeta)(nil),       // 9: yhlooo.com.scaf.meta.v1.ListMeta
+	(*SignalStreamRequest)(nil), // 3: yhlooo.com.scaf.stream.v1.SignalStreamRequest
+	(*Package)(nil),             // 4: yhlooo.com.scaf.stream.v1.Package
+	(*Control)(nil),             // 5: yhlooo.com.scaf.stream.v1.Control
+	(*Stream)(nil),              // 6: yhlooo.com.scaf.stream.v1.Stream
+	(*StreamSpec)(nil),          // 7: yhlooo.com.scaf.stream.v1.StreamSpec
+	(*StreamStatus)(nil),        // 8: yhlooo.com.scaf.stream.v1.StreamStatus
+	(*StreamList)(nil),          // 9: yhlooo.com.scaf.stream.v1.StreamList
 	(*grpc.Status)(nil),         // 10: yhlooo.com.scaf.meta.v1.Status
+	(*grpc.ObjectMeta)(nil),     // 11: yhlooo.com.scaf.meta.v1.ObjectMeta
+	(*grpc.ListMeta)(nil),       // 12: yhlooo.com.scaf.meta.v1.ListMeta
 }
 var file_pkg_apis_stream_v1_grpc_stream_proto_depIdxs = []int32{
-	8,  // 0: yhlooo.com.scaf.stream.v1.Stream.metadata:type_name -> yhlooo.com.scaf.meta.v1.ObjectMeta
-	5,  // 1: yhlooo.com.scaf.stream.v1.Stream.spec:type_name -> yhlooo.com.scaf.stream.v1.StreamSpec
-	6,  // 2: yhlooo.com.scaf.stream.v1.Stream.status:type_name -> yhlooo.com.scaf.stream.v1.StreamStatus
-	9,  // 3: yhlooo.com.scaf.stream.v1.StreamList.metadata:type_name -> yhlooo.com.scaf.meta.v1.ListMeta
-	4,  // 4: yhlooo.com.scaf.stream.v1.StreamList.items:type_name -> yhlooo.com.scaf.stream.v1.Stream
-	4,  // 5: yhlooo.com.scaf.stream.v1.Streams.CreateStream:input_type -> yhlooo.com.scaf.stream.v1.Stream
-	0,  // 6: yhlooo.com.scaf.stream.v1.Streams.GetStream:input_type -> yhlooo.com.scaf.stream.v1.GetStreamRequest
-	1,  // 7: yhlooo.com.scaf.stream.v1.Streams.ListStreams:input_type -> yhlooo.com.scaf.stream.v1.ListStreamsRequest
-	2,  // 8: yhlooo.com.scaf.stream.v1.Streams.DeleteStream:input_type -> yhlooo.com.scaf.stream.v1.DeleteStreamRequest
-	3,  // 9: yhlooo.com.scaf.stream.v1.Streams.ConnectStream:input_type -> yhlooo.com.scaf.stream.v1.Package
-	4,  // 10: yhlooo.com.scaf.stream.v1.Streams.CreateStream:output_type -> yhlooo.com.scaf.stream.v1.Stream
-	4,  // 11: yhlooo.com.scaf.stream.v1.Streams.GetStream:output_type -> yhlooo.com.scaf.stream.v1.Stream
-	7,  // 12: yhlooo.com.scaf.stream.v1.Streams.ListStreams:output_type -> yhlooo.com.scaf.stream.v1.StreamList
-	10, // 13: yhlooo.com.scaf.stream.v1.Streams.DeleteStream:output_type -> yhlooo.com.scaf.meta.v1.Status
-	3,  // 14: yhlooo.com.scaf.stream.v1.Streams.ConnectStream:output_type -> yhlooo.com.scaf.stream.v1.Package
-	10, // [10:15] is the sub-list for method output_type
-	5,  // [5:10] is the sub-list for method input_type
-	5,  // [5:5] is the sub-list for extension type_name
-	5,  // [5:5] is the sub-list for extension extendee
-	0,  // [0:5] is the sub-list for field type_name
+	5,  // 0: yhlooo.com.scaf.stream.v1.Package.control:type_name -> yhlooo.com.scaf.stream.v1.Control
+	10, // 1: yhlooo.com.scaf.stream.v1.Package.error:type_name -> yhlooo.com.scaf.meta.v1.Status
+	11, // 2: yhlooo.com.scaf.stream.v1.Stream.metadata:type_name -> yhlooo.com.scaf.meta.v1.ObjectMeta
+	7,  // 3: yhlooo.com.scaf.stream.v1.Stream.spec:type_name -> yhlooo.com.scaf.stream.v1.StreamSpec
+	8,  // 4: yhlooo.com.scaf.stream.v1.Stream.status:type_name -> yhlooo.com.scaf.stream.v1.StreamStatus
+	12, // 5: yhlooo.com.scaf.stream.v1.StreamList.metadata:type_name -> yhlooo.com.scaf.meta.v1.ListMeta
+	6,  // 6: yhlooo.com.scaf.stream.v1.StreamList.items:type_name -> yhlooo.com.scaf.stream.v1.Stream
+	6,  // 7: yhlooo.com.scaf.stream.v1.Streams.CreateStream:input_type -> yhlooo.com.scaf.stream.v1.Stream
+	0,  // 8: yhlooo.com.scaf.stream.v1.Streams.GetStream:input_type -> yhlooo.com.scaf.stream.v1.GetStreamRequest
+	1,  // 9: yhlooo.com.scaf.stream.v1.Streams.ListStreams:input_type -> yhlooo.com.scaf.stream.v1.ListStreamsRequest
+	2,  // 10: yhlooo.com.scaf.stream.v1.Streams.DeleteStream:input_type -> yhlooo.com.scaf.stream.v1.DeleteStreamRequest
+	3,  // 11: yhlooo.com.scaf.stream.v1.Streams.SignalStream:input_type -> yhlooo.com.scaf.stream.v1.SignalStreamRequest
+	4,  // 12: yhlooo.com.scaf.stream.v1.Streams.ConnectStream:input_type -> yhlooo.com.scaf.stream.v1.Package
+	6,  // 13: yhlooo.com.scaf.stream.v1.Streams.CreateStream:output_type -> yhlooo.com.scaf.stream.v1.Stream
+	6,  // 14: yhlooo.com.scaf.stream.v1.Streams.GetStream:output_type -> yhlooo.com.scaf.stream.v1.Stream
+	9,  // 15: yhlooo.com.scaf.stream.v1.Streams.ListStreams:output_type -> yhlooo.com.scaf.stream.v1.StreamList
+	10, // 16: yhlooo.com.scaf.stream.v1.Streams.DeleteStream:output_type -> yhlooo.com.scaf.meta.v1.Status
+	10, // 17: yhlooo.com.scaf.stream.v1.Streams.SignalStream:output_type -> yhlooo.com.scaf.meta.v1.Status
+	4,  // 18: yhlooo.com.scaf.stream.v1.Streams.ConnectStream:output_type -> yhlooo.com.scaf.stream.v1.Package
+	13, // [13:19] is the sub-list for method output_type
+	7,  // [7:13] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_pkg_apis_stream_v1_grpc_stream_proto_init() }
@@ -543,13 +746,18 @@ func file_pkg_apis_stream_v1_grpc_stream_proto_init() {
 	if File_pkg_apis_stream_v1_grpc_stream_proto != nil {
 		return
 	}
+	file_pkg_apis_stream_v1_grpc_stream_proto_msgTypes[4].OneofWrappers = []any{
+		(*Package_Content)(nil),
+		(*Package_Control)(nil),
+		(*Package_Error)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_pkg_apis_stream_v1_grpc_stream_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
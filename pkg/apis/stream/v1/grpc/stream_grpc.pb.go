@@ -24,6 +24,7 @@ const (
 	Streams_GetStream_FullMethodName     = "/yhlooo.com.scaf.stream.v1.Streams/GetStream"
 	Streams_ListStreams_FullMethodName   = "/yhlooo.com.scaf.stream.v1.Streams/ListStreams"
 	Streams_DeleteStream_FullMethodName  = "/yhlooo.com.scaf.stream.v1.Streams/DeleteStream"
+	Streams_SignalStream_FullMethodName  = "/yhlooo.com.scaf.stream.v1.Streams/SignalStream"
 	Streams_ConnectStream_FullMethodName = "/yhlooo.com.scaf.stream.v1.Streams/ConnectStream"
 )
 
@@ -37,6 +38,7 @@ type StreamsClient interface {
 	GetStream(ctx context.Context, in *GetStreamRequest, opts ...grpc.CallOption) (*Stream, error)
 	ListStreams(ctx context.Context, in *ListStreamsRequest, opts ...grpc.CallOption) (*StreamList, error)
 	DeleteStream(ctx context.Context, in *DeleteStreamRequest, opts ...grpc.CallOption) (*grpc1.Status, error)
+	SignalStream(ctx context.Context, in *SignalStreamRequest, opts ...grpc.CallOption) (*grpc1.Status, error)
 	ConnectStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Package, Package], error)
 }
 
@@ -88,6 +90,16 @@ func (c *streamsClient) DeleteStream(ctx context.Context, in *DeleteStreamReques
 	return out, nil
 }
 
+func (c *streamsClient) SignalStream(ctx context.Context, in *SignalStreamRequest, opts ...grpc.CallOption) (*grpc1.Status, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(grpc1.Status)
+	err := c.cc.Invoke(ctx, Streams_SignalStream_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *streamsClient) ConnectStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Package, Package], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &Streams_ServiceDesc.Streams[0], Streams_ConnectStream_FullMethodName, cOpts...)
@@ -111,6 +123,7 @@ type StreamsServer interface {
 	GetStream(context.Context, *GetStreamRequest) (*Stream, error)
 	ListStreams(context.Context, *ListStreamsRequest) (*StreamList, error)
 	DeleteStream(context.Context, *DeleteStreamRequest) (*grpc1.Status, error)
+	SignalStream(context.Context, *SignalStreamRequest) (*grpc1.Status, error)
 	ConnectStream(grpc.BidiStreamingServer[Package, Package]) error
 	mustEmbedUnimplementedStreamsServer()
 }
@@ -134,6 +147,9 @@ func (UnimplementedStreamsServer) ListStreams(context.Context, *ListStreamsReque
 func (UnimplementedStreamsServer) DeleteStream(context.Context, *DeleteStreamRequest) (*grpc1.Status, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteStream not implemented")
 }
+func (UnimplementedStreamsServer) SignalStream(context.Context, *SignalStreamRequest) (*grpc1.Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SignalStream not implemented")
+}
 func (UnimplementedStreamsServer) ConnectStream(grpc.BidiStreamingServer[Package, Package]) error {
 	return status.Errorf(codes.Unimplemented, "method ConnectStream not implemented")
 }
@@ -230,6 +246,24 @@ func _Streams_DeleteStream_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Streams_SignalStream_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignalStreamRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamsServer).SignalStream(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Streams_SignalStream_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamsServer).SignalStream(ctx, req.(*SignalStreamRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Streams_ConnectStream_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(StreamsServer).ConnectStream(&grpc.GenericServerStream[Package, Package]{ServerStream: stream})
 }
@@ -260,6 +294,10 @@ var Streams_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteStream",
 			Handler:    _Streams_DeleteStream_Handler,
 		},
+		{
+			MethodName: "SignalStream",
+			Handler:    _Streams_SignalStream_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
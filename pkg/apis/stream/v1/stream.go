@@ -38,6 +38,12 @@ type StreamStatus struct {
 	Token string `json:"token,omitempty" yaml:"token,omitempty"`
 }
 
+// StreamSignal 向流中执行命令的一端发送的信号
+type StreamSignal struct {
+	// 信号名，如 SIGTERM
+	Signal string `json:"signal" yaml:"signal"`
+}
+
 // StreamList 流列表
 type StreamList struct {
 	metav1.ListMeta `json:"metadata,omitempty" yaml:"metadata,omitempty"`
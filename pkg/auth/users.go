@@ -9,10 +9,11 @@ import (
 )
 
 const (
-	AdminUsername        = "system:admin"
-	AnonymousUsername    = "system:anonymous"
-	StreamUsernamePrefix = "system:stream:"
-	NormalUsernamePrefix = "user:"
+	AdminUsername         = "system:admin"
+	AnonymousUsername     = "system:anonymous"
+	StreamUsernamePrefix  = "system:stream:"
+	ServiceUsernamePrefix = "system:service:"
+	NormalUsernamePrefix  = "user:"
 )
 
 // IsAdmin 返回用户是否管理员
@@ -35,6 +36,11 @@ func IsStream(username string, streamName string) bool {
 	return username == StreamUsername(streamName)
 }
 
+// IsService 返回用户是否是服务
+func IsService(username string) bool {
+	return strings.HasPrefix(username, ServiceUsernamePrefix) && len(username) > len(ServiceUsernamePrefix)
+}
+
 // IsOwner 返回用户是否是指定对象所有者
 func IsOwner(username string, meta *metav1.ObjectMeta) bool {
 	if meta == nil {
@@ -48,6 +54,11 @@ func StreamUsername(streamName string) string {
 	return StreamUsernamePrefix + streamName
 }
 
+// ServiceUsername 返回指定服务用户名
+func ServiceUsername(serviceName string) string {
+	return ServiceUsernamePrefix + serviceName
+}
+
 // RandNormalUsername 生成一个随机用户名
 func RandNormalUsername() string {
 	return NormalUsernamePrefix + randutil.LowerAlphaNumeric(16)
@@ -0,0 +1,232 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	clientsexec "github.com/yhlooo/scaf/pkg/clients/exec"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = 30 * time.Second
+)
+
+// Options 代理守护进程选项
+type Options struct {
+	// 代理名
+	Name string
+	// 代理标签
+	Labels map[string]string
+	// 除自身外允许向代理派发请求的用户
+	Users []string
+	// 执行策略，为 nil 时不限制执行的命令
+	Policy *clientsexec.Policy
+	// 是否允许通过代理传输文件
+	AllowFileTransfer bool
+	// 传输文件的根目录，请求中的路径都相对该目录，且不能通过符号链接访问该目录之外的文件
+	FileRoot string
+	// 是否允许通过代理写文件，否则只能读根目录中的文件
+	AllowFileWrite bool
+}
+
+// NewDaemon 创建 Daemon
+func NewDaemon(client common.Client, opts Options) *Daemon {
+	return &Daemon{
+		c:    client,
+		opts: opts,
+	}
+}
+
+// Daemon 代理守护进程
+//
+// 注册到服务端后等待派发给它的请求，每个请求在单独的会话中并发处理
+type Daemon struct {
+	c    common.Client
+	opts Options
+	wg   sync.WaitGroup
+}
+
+// Run 注册代理并处理派发的请求，与服务端断开连接时自动重连
+// 阻塞直到 ctx 结束或注册被服务端拒绝，返回前等待所有会话结束
+func (d *Daemon) Run(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("agent", d.opts.Name)
+	ctx = logr.NewContext(ctx, logger)
+	defer d.wg.Wait()
+
+	interval := minReconnectInterval
+	for {
+		start := time.Now()
+		err := d.serve(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if isRejected(apierrors.NewFromError(err)) {
+			return fmt.Errorf("register agent error: %w", err)
+		}
+		if time.Since(start) > maxReconnectInterval {
+			// 连接保持了较长时间，从最短间隔开始重连
+			interval = minReconnectInterval
+		}
+		logger.Info(fmt.Sprintf("WARN disconnected from server: %v, reconnect in %s", err, interval))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+		interval = min(interval*2, maxReconnectInterval)
+	}
+}
+
+// serve 注册代理并接收派发的请求，直到连接断开
+func (d *Daemon) serve(ctx context.Context) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	agent := &agentv1.Agent{
+		Spec: agentv1.AgentSpec{
+			Labels: d.opts.Labels,
+			Users:  d.opts.Users,
+		},
+	}
+	agent.Name = d.opts.Name
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	conn, err := d.c.ConnectAgent(connCtx, agent)
+	if err != nil {
+		return err
+	}
+	if logger.V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	logger.Info("agent connected, waiting for dispatches")
+
+	for {
+		raw, err := conn.Receive(connCtx)
+		if err != nil {
+			return err
+		}
+		dispatch := &agentv1.Dispatch{}
+		if err := json.Unmarshal(raw, dispatch); err != nil {
+			logger.Error(err, "unmarshal dispatch error")
+			continue
+		}
+		if dispatch.Spec.Type == "" {
+			// 服务端在连接建立后拒绝注册时会发送状态
+			status := &metav1.Status{}
+			if err := json.Unmarshal(raw, status); err == nil && status.Code >= http.StatusBadRequest {
+				return status
+			}
+			logger.Info(fmt.Sprintf("WARN ignore unknown message: %s", string(raw)))
+			continue
+		}
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.handleDispatch(ctx, dispatch)
+		}()
+	}
+}
+
+// handleDispatch 处理派发的请求
+func (d *Daemon) handleDispatch(ctx context.Context, dispatch *agentv1.Dispatch) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues(
+		"dispatch", dispatch.Name,
+		"stream", dispatch.Spec.Stream,
+	)
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info(fmt.Sprintf("%s dispatch received", dispatch.Spec.Type))
+
+	client := d.c.WithToken(dispatch.Status.Token)
+	stream, err := client.GetStream(ctx, dispatch.Spec.Stream)
+	if err != nil {
+		logger.Error(err, "get stream error")
+		return
+	}
+
+	switch dispatch.Spec.Type {
+	case agentv1.DispatchExec:
+		err = clientsexec.NewAgent(client).WithPolicy(d.opts.Policy).Run(ctx, stream)
+	case agentv1.DispatchSendFile, agentv1.DispatchReceiveFile, agentv1.DispatchFileServer, agentv1.DispatchSFTP:
+		p, resolveErr := d.resolveFilePath(dispatch)
+		if resolveErr != nil {
+			logger.Info(fmt.Sprintf("WARN %v, reject", resolveErr))
+			// 删除流以免请求方一直等待
+			if err := client.DeleteStream(ctx, stream.Name); err != nil {
+				logger.Error(err, "delete stream error")
+			}
+			return
+		}
+		err = d.handleFileDispatch(ctx, clientscp.New(client), stream, dispatch.Spec.Type, p)
+	default:
+		logger.Info(fmt.Sprintf("WARN unsupported dispatch type: %q", dispatch.Spec.Type))
+		return
+	}
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("handle %s dispatch error", dispatch.Spec.Type))
+		return
+	}
+	logger.Info(fmt.Sprintf("%s dispatch done", dispatch.Spec.Type))
+}
+
+// resolveFilePath 检查是否允许处理传输文件的请求，返回请求的路径在 FileRoot 中对应的本地路径
+func (d *Daemon) resolveFilePath(dispatch *agentv1.Dispatch) (string, error) {
+	if !d.opts.AllowFileTransfer {
+		return "", fmt.Errorf("file transfer is not allowed")
+	}
+	if dispatch.Spec.Type == agentv1.DispatchReceiveFile && !d.opts.AllowFileWrite {
+		return "", fmt.Errorf("writing files is not allowed")
+	}
+	if d.opts.FileRoot == "" {
+		return "", fmt.Errorf("file root is not configured")
+	}
+	return clientscp.ResolvePath(d.opts.FileRoot, dispatch.Spec.Path)
+}
+
+// handleFileDispatch 处理传输文件的请求， p 为已限制在 FileRoot 之内的本地路径
+func (d *Daemon) handleFileDispatch(
+	ctx context.Context,
+	cpClient *clientscp.CopyFileClient,
+	stream *streamv1.Stream,
+	dispatchType agentv1.DispatchType,
+	p string,
+) error {
+	readOnly := !d.opts.AllowFileWrite
+	switch dispatchType {
+	case agentv1.DispatchSendFile:
+		return cpClient.Send(ctx, stream, p, clientscp.SendOptions{})
+	case agentv1.DispatchReceiveFile:
+		_, err := cpClient.Receive(ctx, stream, p, clientscp.ReceiveOptions{})
+		return err
+	case agentv1.DispatchFileServer:
+		return cpClient.ServeFiles(ctx, stream, clientscp.FileServerOptions{Root: p, ReadOnly: readOnly})
+	default:
+		return cpClient.ServeSFTP(ctx, stream, clientscp.SFTPServerOptions{Root: p, ReadOnly: readOnly})
+	}
+}
+
+// isRejected 返回状态是否表示注册被服务端拒绝，此时重连没有意义
+func isRejected(status *metav1.Status) bool {
+	switch status.Code {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict:
+		return true
+	}
+	return false
+}
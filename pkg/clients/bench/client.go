@@ -2,6 +2,7 @@ package bench
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"sync"
@@ -15,7 +16,22 @@ import (
 )
 
 const (
-	readWritePackageSize = 4 << 10 // 4KiB
+	// DefaultDuration 默认每项读写测试的持续时间
+	DefaultDuration = 10 * time.Second
+	// DefaultPackageSize 默认读写测试的数据包大小
+	DefaultPackageSize = 4 << 10 // 4KiB
+	// DefaultPingCount 默认测试往返时延的 Ping 次数
+	DefaultPingCount = 10
+
+	// MinPackageSize 读写测试的数据包最小大小，需要能容纳包头和发送时间
+	MinPackageSize = 64
+	// MaxPackageSize 读写测试的数据包最大大小
+	MaxPackageSize = 1 << 20 // 1MiB
+
+	pingInterval           = 200 * time.Millisecond
+	pongTimeout            = 5 * time.Second
+	connIdleTime           = 5 * time.Second
+	parallelStreamsTimeout = 30 * time.Second
 )
 
 // NewClient 创建基准测试客户端
@@ -28,190 +44,429 @@ type BenchmarkClient struct {
 	c common.Client
 }
 
+// NewDefaultRunOptions 创建默认 RunOptions
+func NewDefaultRunOptions() RunOptions {
+	return RunOptions{
+		Duration:    DefaultDuration,
+		PackageSize: DefaultPackageSize,
+		Parallel:    1,
+		PingCount:   DefaultPingCount,
+	}
+}
+
+// RunOptions 运行基准测试的选项
+type RunOptions struct {
+	// 每项读写测试的持续时间
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	// 读写测试的数据包大小
+	PackageSize uint64 `json:"packageSize" yaml:"packageSize"`
+	// 并行测试的流数量
+	Parallel int `json:"parallel" yaml:"parallel"`
+	// 测试往返时延的 Ping 次数
+	PingCount int `json:"pingCount" yaml:"pingCount"`
+}
+
+// Validate 校验选项
+func (opts RunOptions) Validate() error {
+	if opts.Duration < time.Second {
+		return fmt.Errorf("duration must be at least 1s")
+	}
+	if opts.PackageSize < MinPackageSize || opts.PackageSize > MaxPackageSize {
+		return fmt.Errorf(
+			"invalid package size: %d (must be between %d and %d)",
+			opts.PackageSize, MinPackageSize, MaxPackageSize,
+		)
+	}
+	if opts.Parallel < 1 || opts.Parallel > maxParallelStreams+1 {
+		return fmt.Errorf("invalid parallel: %d (must be between 1 and %d)", opts.Parallel, maxParallelStreams+1)
+	}
+	if opts.PingCount < 1 {
+		return fmt.Errorf("ping count must be positive")
+	}
+	return nil
+}
+
 // Report 测试报告
 type Report struct {
-	Ping      PingResult         `json:"ping,omitempty"`
-	ReadOnly  TransmissionResult `json:"readOnly,omitempty"`
-	WriteOnly TransmissionResult `json:"writeOnly,omitempty"`
-	ReadWrite ReadWriteResult    `json:"readWrite,omitempty"`
+	Options RunOptions `json:"options" yaml:"options"`
+	// 建立连接的耗时
+	Connect   LatencyStats       `json:"connect" yaml:"connect"`
+	Ping      PingResult         `json:"ping,omitempty" yaml:"ping,omitempty"`
+	ReadOnly  TransmissionResult `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+	WriteOnly TransmissionResult `json:"writeOnly,omitempty" yaml:"writeOnly,omitempty"`
+	ReadWrite ReadWriteResult    `json:"readWrite,omitempty" yaml:"readWrite,omitempty"`
+	// 与之前的测试报告的比较结果
+	Comparison []MetricComparison `json:"comparison,omitempty" yaml:"comparison,omitempty"`
 }
 
 // PingResult Ping 结果
 type PingResult struct {
 	// 往返时延
-	RoundTripTime time.Duration `json:"roundTripTime"`
+	RoundTripTime time.Duration `json:"roundTripTime" yaml:"roundTripTime"`
 	// 丢包率
-	LossRate float64 `json:"lossRate"`
+	LossRate float64 `json:"lossRate" yaml:"lossRate"`
+	// 往返时延分布
+	Latency LatencyStats `json:"latency" yaml:"latency"`
 }
 
 // ReadWriteResult 读写结果
 type ReadWriteResult struct {
-	Read  TransmissionResult `json:"read,omitempty"`
-	Write TransmissionResult `json:"write,omitempty"`
+	Read  TransmissionResult `json:"read,omitempty" yaml:"read,omitempty"`
+	Write TransmissionResult `json:"write,omitempty" yaml:"write,omitempty"`
 }
 
 // TransmissionResult 读结果
 type TransmissionResult struct {
 	// 吞吐率（单位： Bytes/s ）
-	Throughput uint64 `json:"throughput"`
+	Throughput uint64 `json:"throughput" yaml:"throughput"`
 	// 丢包率
-	LossRate float64 `json:"lossRate"`
+	LossRate float64 `json:"lossRate" yaml:"lossRate"`
+	// 成功传输的数据大小
+	Size uint64 `json:"size" yaml:"size"`
+	// 成功传输的包数量
+	Packages uint32 `json:"packages" yaml:"packages"`
+	// 单向时延分布
+	Latency LatencyStats `json:"latency" yaml:"latency"`
+}
+
+// benchWorker 在一个流上运行测试
+type benchWorker struct {
+	conn  streams.Connection
+	msgCh chan Message
+	// 测试服务端时钟减去本端时钟的估计值
+	clockOffset time.Duration
+}
+
+// transmission 一个流上的传输结果
+type transmission struct {
 	// 成功传输的数据大小
-	Size uint64 `json:"size"`
+	size uint64
 	// 成功传输的包数量
-	Packages uint32 `json:"packages"`
+	packages uint32
+	// 发送的包数量
+	sent uint32
+	// 单向时延
+	latency *Histogram
 }
 
 // Run 运行基准测试
-func (c *BenchmarkClient) Run(ctx context.Context, stream *streamv1.Stream) (*Report, error) {
+func (c *BenchmarkClient) Run(ctx context.Context, stream *streamv1.Stream, opts RunOptions) (*Report, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	logger := logr.FromContextOrDiscard(ctx)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	report := &Report{Options: opts}
+
 	// 与服务端建立连接
-	conn, err := c.c.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{
-		ConnectionName: "server",
-	})
+	primary, connectTime, err := c.connect(ctx, c.c, stream.Name)
 	if err != nil {
 		return nil, fmt.Errorf("connect to server error: %w", err)
 	}
-	if logger.V(1).Enabled() {
-		conn = streams.ConnectionWithLog{Connection: conn}
-	}
+	workers := []*benchWorker{primary}
+	connectTimes := []time.Duration{connectTime}
 	defer func() {
 		cancel()
-		_ = conn.Close(ctx)
+		for _, w := range workers {
+			_ = w.conn.Close(ctx)
+		}
 	}()
-	receiveMsgCh := make(chan Message)
-	go c.runReceiveLoop(ctx, conn, receiveMsgCh)
 
-	report := &Report{}
+	if opts.Parallel > 1 {
+		// 请求测试服务端创建更多的流用于并行测试
+		parallelStreams, err := c.requestParallelStreams(ctx, primary, opts.Parallel-1)
+		if err != nil {
+			return nil, fmt.Errorf("request parallel streams error: %w", err)
+		}
+		defer func() {
+			// 先停止接收，避免流被删除时连接关闭产生错误日志
+			cancel()
+			c.deleteParallelStreams(context.WithoutCancel(ctx), parallelStreams)
+		}()
+
+		parallelWorkers := make([]*benchWorker, len(parallelStreams))
+		parallelConnectTimes := make([]time.Duration, len(parallelStreams))
+		err = runConcurrently(len(parallelStreams), func(i int) error {
+			info := parallelStreams[i]
+			client := c.c
+			if info.Token != "" {
+				client = client.WithToken(info.Token)
+			}
+			var err error
+			parallelWorkers[i], parallelConnectTimes[i], err = c.connect(ctx, client, info.Name)
+			if err != nil {
+				return fmt.Errorf("connect to parallel stream %q error: %w", info.Name, err)
+			}
+			return nil
+		})
+		for i, w := range parallelWorkers {
+			if w != nil {
+				workers = append(workers, w)
+				connectTimes = append(connectTimes, parallelConnectTimes[i])
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	connectLatency := NewHistogram()
+	for _, d := range connectTimes {
+		connectLatency.Record(d)
+	}
+	report.Connect = connectLatency.Stats()
 
 	// 测试往返时延
-	pingRet, err := c.testPing(ctx, conn, receiveMsgCh, 5)
+	logger.Info("test ping ...")
+	rtts := make([]*Histogram, len(workers))
+	lost := make([]int, len(workers))
+	err = runConcurrently(len(workers), func(i int) error {
+		var err error
+		rtts[i], lost[i], err = c.testPing(ctx, workers[i], opts.PingCount)
+		return err
+	})
 	if err != nil {
 		return report, fmt.Errorf("ping error: %w", err)
 	}
-	report.Ping = *pingRet
+	rtt := NewHistogram()
+	totalLost := 0
+	for i := range workers {
+		rtt.Merge(rtts[i])
+		totalLost += lost[i]
+	}
+	report.Ping = PingResult{
+		LossRate: float64(totalLost) / float64(opts.PingCount*len(workers)),
+		Latency:  rtt.Stats(),
+	}
+	report.Ping.RoundTripTime = report.Ping.Latency.Mean
 
 	// 测试只读速率
 	logger.Info("test read ...")
-	readRet, _, err := c.testReadWrite(ctx, conn, receiveMsgCh, true, false, 10*time.Second)
+	readRets, _, err := c.testReadWriteOnWorkers(ctx, workers, true, false, opts)
 	if err != nil {
 		return report, fmt.Errorf("test read error: %w", err)
 	}
-	report.ReadOnly = *readRet
-	logger.Info(fmt.Sprintf("received %d packages, size: %d", readRet.Packages, readRet.Size))
+	report.ReadOnly = newTransmissionResult(readRets, opts.Duration)
+	logger.Info(fmt.Sprintf("received %d packages, size: %d", report.ReadOnly.Packages, report.ReadOnly.Size))
 
 	logger.Info(fmt.Sprintf("waiting for connection idle ..."))
-	if err := c.waitForConnIdle(ctx, receiveMsgCh, 5*time.Second); err != nil {
+	if err := c.waitForConnIdleOnWorkers(ctx, workers); err != nil {
 		return report, fmt.Errorf("wait for connection idle error: %w", err)
 	}
 
 	// 测试只写速率
 	logger.Info("test write ...")
-	_, writeRet, err := c.testReadWrite(ctx, conn, receiveMsgCh, false, true, 10*time.Second)
+	_, writeRets, err := c.testReadWriteOnWorkers(ctx, workers, false, true, opts)
 	if err != nil {
 		return report, fmt.Errorf("test write error: %w", err)
 	}
-	report.WriteOnly = *writeRet
-	logger.Info(fmt.Sprintf("sent %d packages, size: %d", writeRet.Packages, writeRet.Size))
+	report.WriteOnly = newTransmissionResult(writeRets, opts.Duration)
+	logger.Info(fmt.Sprintf("sent %d packages, size: %d", report.WriteOnly.Packages, report.WriteOnly.Size))
 
 	logger.Info(fmt.Sprintf("waiting for connection idle ..."))
-	if err := c.waitForConnIdle(ctx, receiveMsgCh, 5*time.Second); err != nil {
+	if err := c.waitForConnIdleOnWorkers(ctx, workers); err != nil {
 		return report, fmt.Errorf("wait for connection idle error: %w", err)
 	}
 
 	// 测试同时读写速率
 	logger.Info("test read and write ...")
-	readRet, writeRet, err = c.testReadWrite(ctx, conn, receiveMsgCh, true, true, 10*time.Second)
+	readRets, writeRets, err = c.testReadWriteOnWorkers(ctx, workers, true, true, opts)
 	if err != nil {
 		return report, fmt.Errorf("test read write error: %w", err)
 	}
-	report.ReadWrite.Read = *readRet
-	report.ReadWrite.Write = *writeRet
-	logger.Info(fmt.Sprintf("received %d packages, size: %d", readRet.Packages, readRet.Size))
-	logger.Info(fmt.Sprintf("sent %d packages, size: %d", writeRet.Packages, writeRet.Size))
+	report.ReadWrite.Read = newTransmissionResult(readRets, opts.Duration)
+	report.ReadWrite.Write = newTransmissionResult(writeRets, opts.Duration)
+	logger.Info(fmt.Sprintf(
+		"received %d packages, size: %d", report.ReadWrite.Read.Packages, report.ReadWrite.Read.Size,
+	))
+	logger.Info(fmt.Sprintf(
+		"sent %d packages, size: %d", report.ReadWrite.Write.Packages, report.ReadWrite.Write.Size,
+	))
 
 	return report, nil
 }
 
-// testPing 测试 Ping
-func (c *BenchmarkClient) testPing(
+// connect 连接到流并开始接收消息，返回建立连接的耗时
+func (c *BenchmarkClient) connect(
 	ctx context.Context,
-	conn streams.Connection,
-	msgCh <-chan Message,
+	client common.Client,
+	name string,
+) (*benchWorker, time.Duration, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	startTime := time.Now()
+	conn, err := client.ConnectStream(ctx, name, common.ConnectStreamOptions{
+		ConnectionName: "server",
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	d := time.Since(startTime)
+	if logger.V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+
+	w := &benchWorker{
+		conn:  conn,
+		msgCh: make(chan Message),
+	}
+	go c.runReceiveLoop(ctx, conn, w.msgCh)
+	return w, d, nil
+}
+
+// requestParallelStreams 请求测试服务端创建 n 个用于并行测试的流
+func (c *BenchmarkClient) requestParallelStreams(
+	ctx context.Context,
+	w *benchWorker,
 	n int,
-) (*PingResult, error) {
+) ([]ParallelStream, error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
-	if n <= 0 {
-		return &PingResult{}, nil
+	if err := w.conn.Send(ctx, RequestParallelStreams{Count: uint32(n)}.Raw()); err != nil {
+		return nil, fmt.Errorf("send request parallel streams message error: %w", err)
 	}
 
-	totalDuration := time.Duration(0)
-	cnt := 0
+	timer := time.NewTimer(parallelStreamsTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			return nil, fmt.Errorf("wait for parallel streams timeout, the benchmark server may not support it")
+		case msg, ok := <-w.msgCh:
+			if !ok {
+				return nil, fmt.Errorf("receive message channel closed")
+			}
+			ret, isParallelStreams := msg.(ParallelStreams)
+			if !isParallelStreams {
+				logger.Info(fmt.Sprintf("WARN received wrong message type: %s", msg.Type()))
+				continue
+			}
+			if ret.Error != "" {
+				return nil, fmt.Errorf("benchmark server error: %s", ret.Error)
+			}
+			if len(ret.Streams) != n {
+				c.deleteParallelStreams(ctx, ret.Streams)
+				return nil, fmt.Errorf("expected %d parallel streams, got %d", n, len(ret.Streams))
+			}
+			return ret.Streams, nil
+		}
+	}
+}
+
+// deleteParallelStreams 删除用于并行测试的流
+func (c *BenchmarkClient) deleteParallelStreams(ctx context.Context, parallelStreams []ParallelStream) {
+	logger := logr.FromContextOrDiscard(ctx)
+	for _, info := range parallelStreams {
+		client := c.c
+		if info.Token != "" {
+			client = client.WithToken(info.Token)
+		}
+		if err := client.DeleteStream(ctx, info.Name); err != nil {
+			logger.Error(err, fmt.Sprintf("delete parallel stream %q error", info.Name))
+		}
+	}
+}
+
+// testPing 测试 Ping ，返回往返时延和丢失的 Ping 数量
+// 同时根据往返时延最小的一次 Ping 估计测试服务端与本端的时钟偏差
+func (c *BenchmarkClient) testPing(
+	ctx context.Context,
+	w *benchWorker,
+	n int,
+) (*Histogram, int, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	rtt := NewHistogram()
+	lost := 0
+	minRTT := time.Duration(-1)
 mainLoop:
 	for i := 0; i < n; i++ {
 		if i != 0 {
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(500 * time.Millisecond):
+				return nil, 0, ctx.Err()
+			case <-time.After(pingInterval):
 			}
 		}
 
 		startTime := time.Now()
-		if err := conn.Send(ctx, Ping(uint32(i)).Raw()); err != nil {
+		if err := w.conn.Send(ctx, Ping(uint32(i)).Raw()); err != nil {
 			logger.Error(err, fmt.Sprintf("send ping %d error", i))
+			lost++
 			continue
 		}
+		var pong Pong
 		for {
 			select {
 			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(5 * time.Second):
+				return nil, 0, ctx.Err()
+			case <-time.After(pongTimeout):
 				logger.Info(fmt.Sprintf("WARN wait pong message %d timeout", i))
+				lost++
 				continue mainLoop
-			case msg, ok := <-msgCh:
+			case msg, ok := <-w.msgCh:
 				if !ok {
-					return nil, fmt.Errorf("receive message channel closed")
+					return nil, 0, fmt.Errorf("receive message channel closed")
 				}
-				switch typedMsg := msg.(type) {
-				case Pong:
-					if int(typedMsg) != i {
-						logger.Info(fmt.Sprintf(
-							"WARN received pong with wron seq: %d (expected: %d)",
-							typedMsg, i,
-						))
-						continue
-					}
-				default:
+				typedMsg, isPong := msg.(Pong)
+				if !isPong {
 					logger.Info(fmt.Sprintf("WARN received wrong message type: %s", msg.Type()))
 					continue
 				}
+				if typedMsg.Seq != uint32(i) {
+					logger.Info(fmt.Sprintf(
+						"WARN received pong with wron seq: %d (expected: %d)",
+						typedMsg.Seq, i,
+					))
+					continue
+				}
+				pong = typedMsg
 			}
 			break
 		}
 		d := time.Since(startTime)
 		logger.Info(fmt.Sprintf("ping %d: %s", i, d))
-		totalDuration += d
-		cnt++
+		rtt.Record(d)
+		if !pong.Time.IsZero() && (minRTT < 0 || d < minRTT) {
+			// 假设往返路径对称，对端回复时本端的时间为发送和接收的中间时刻
+			minRTT = d
+			w.clockOffset = pong.Time.Sub(startTime.Add(d / 2))
+		}
 	}
-	return &PingResult{
-		RoundTripTime: totalDuration / time.Duration(cnt),
-		LossRate:      float64(n-cnt) / float64(n),
-	}, nil
+	return rtt, lost, nil
+}
+
+// testReadWriteOnWorkers 在每个流上并发测试读写
+func (c *BenchmarkClient) testReadWriteOnWorkers(
+	ctx context.Context,
+	workers []*benchWorker,
+	read, write bool,
+	opts RunOptions,
+) (readResults, writeResults []*transmission, err error) {
+	readResults = make([]*transmission, len(workers))
+	writeResults = make([]*transmission, len(workers))
+	err = runConcurrently(len(workers), func(i int) error {
+		var err error
+		readResults[i], writeResults[i], err = c.testReadWrite(
+			ctx, workers[i], read, write, opts.Duration, opts.PackageSize,
+		)
+		return err
+	})
+	return readResults, writeResults, err
 }
 
 // testReadWrite 测试读写
 func (c *BenchmarkClient) testReadWrite(
 	ctx context.Context,
-	conn streams.Connection,
-	msgCh <-chan Message,
+	w *benchWorker,
 	read, write bool,
 	d time.Duration,
-) (readResult, writeResult *TransmissionResult, err error) {
+	pkgSize uint64,
+) (readResult, writeResult *transmission, err error) {
 	logger := logr.FromContextOrDiscard(ctx)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -224,7 +479,12 @@ func (c *BenchmarkClient) testReadWrite(
 	if write {
 		mode |= WriteMode
 	}
-	if err := conn.Send(ctx, StartReadWrite{Mode: mode, ReadPackageSize: readWritePackageSize}.Raw()); err != nil {
+	startMsg := StartReadWrite{
+		Mode:            mode,
+		ReadPackageSize: pkgSize,
+		ClockOffset:     w.clockOffset,
+	}
+	if err := w.conn.Send(ctx, startMsg.Raw()); err != nil {
 		return nil, nil, fmt.Errorf("send start read write message error: %w", err)
 	}
 
@@ -245,7 +505,7 @@ func (c *BenchmarkClient) testReadWrite(
 				}
 				sendLock.Lock()
 				sendSeq++
-				if err := conn.Send(ctx, NewRandData(sendSeq, readWritePackageSize).Raw()); err != nil {
+				if err := w.conn.Send(ctx, NewRandData(sendSeq, pkgSize).Raw()); err != nil {
 					logger.Error(err, fmt.Sprintf("send data %d error", sendSeq))
 				}
 				sendLock.Unlock()
@@ -254,8 +514,7 @@ func (c *BenchmarkClient) testReadWrite(
 	}
 
 	// 接收数据
-	received := uint32(0)
-	receivedSize := uint64(0)
+	readResult = &transmission{latency: NewHistogram()}
 	lastSeq := uint32(0)
 	timer := time.NewTimer(d)
 readDataLoop:
@@ -268,7 +527,7 @@ readDataLoop:
 		case <-timer.C:
 			// 时间到了
 			break readDataLoop
-		case msg, ok = <-msgCh:
+		case msg, ok = <-w.msgCh:
 			if !ok {
 				return nil, nil, fmt.Errorf("receive message channel closed")
 			}
@@ -290,33 +549,27 @@ readDataLoop:
 				logger.Info(fmt.Sprintf("invalid data checksum: %d (expected: %d)", sum, typedMsg.Checksum()))
 				continue
 			}
-			received++
-			receivedSize += uint64(len(typedMsg))
+			readResult.packages++
+			readResult.size += uint64(len(typedMsg))
 			lastSeq = typedMsg.Seq()
+			if sendTime := typedMsg.SendTime(); !sendTime.IsZero() {
+				// clockOffset 为对端时钟减去本端时钟
+				readResult.latency.Record(time.Since(sendTime) + w.clockOffset)
+			}
 		default:
 			logger.Info(fmt.Sprintf("WARN received wrong message type: %s", msg.Type()))
 		}
 	}
+	readResult.sent = lastSeq
 
 	// 发送结束指令
 	sendLock.Lock()
 	sendPkgs := sendSeq
-	if err := conn.Send(ctx, StopReadWrite{}.Raw()); err != nil {
+	if err := w.conn.Send(ctx, StopReadWrite{}.Raw()); err != nil {
 		logger.Error(err, fmt.Sprintf("send stop read write message error"))
 	}
 	sendLock.Unlock()
 
-	readLossRate := float64(1)
-	if lastSeq > 0 {
-		readLossRate = float64(lastSeq-received) / float64(lastSeq)
-	}
-	readResult = &TransmissionResult{
-		Throughput: receivedSize * 1000 / uint64(d.Milliseconds()),
-		LossRate:   readLossRate,
-		Size:       receivedSize,
-		Packages:   received,
-	}
-
 	if write {
 		// 等待写结果
 		close(sendDone)
@@ -329,7 +582,7 @@ readDataLoop:
 				return readResult, nil, ctx.Err()
 			case <-timer.C:
 				return readResult, nil, fmt.Errorf("wait for write result timeout")
-			case msg, ok = <-msgCh:
+			case msg, ok = <-w.msgCh:
 				if !ok {
 					return readResult, nil, fmt.Errorf("receive message channel closed")
 				}
@@ -343,16 +596,11 @@ readDataLoop:
 				// 刚结束还有可能会收到一些数据，忽略
 				continue
 			case WriteResult:
-				writeLossRate := float64(1)
-				if sendPkgs > 0 {
-					writeLossRate = float64(sendPkgs-typedMsg.ReceivedPackageCount) / float64(sendPkgs)
-				}
-				writeResult = &TransmissionResult{
-					Throughput: uint64(typedMsg.ReceivedPackageCount) * readWritePackageSize * 1000 /
-						uint64(d.Milliseconds()),
-					LossRate: writeLossRate,
-					Size:     uint64(typedMsg.ReceivedPackageCount) * readWritePackageSize,
-					Packages: typedMsg.ReceivedPackageCount,
+				writeResult = &transmission{
+					size:     uint64(typedMsg.ReceivedPackageCount) * pkgSize,
+					packages: typedMsg.ReceivedPackageCount,
+					sent:     sendPkgs,
+					latency:  typedMsg.Latency,
 				}
 				return readResult, writeResult, nil
 			default:
@@ -364,6 +612,42 @@ readDataLoop:
 	return readResult, nil, nil
 }
 
+// newTransmissionResult 汇总各个流上的传输结果
+func newTransmissionResult(results []*transmission, d time.Duration) TransmissionResult {
+	size := uint64(0)
+	packages := uint32(0)
+	sent := uint32(0)
+	latency := NewHistogram()
+	for _, ret := range results {
+		if ret == nil {
+			continue
+		}
+		size += ret.size
+		packages += ret.packages
+		sent += ret.sent
+		latency.Merge(ret.latency)
+	}
+
+	lossRate := float64(1)
+	if sent > 0 && sent >= packages {
+		lossRate = float64(sent-packages) / float64(sent)
+	}
+	return TransmissionResult{
+		Throughput: size * 1000 / uint64(d.Milliseconds()),
+		LossRate:   lossRate,
+		Size:       size,
+		Packages:   packages,
+		Latency:    latency.Stats(),
+	}
+}
+
+// waitForConnIdleOnWorkers 等待所有流的连接空闲
+func (c *BenchmarkClient) waitForConnIdleOnWorkers(ctx context.Context, workers []*benchWorker) error {
+	return runConcurrently(len(workers), func(i int) error {
+		return c.waitForConnIdle(ctx, workers[i].msgCh, connIdleTime)
+	})
+}
+
 // waitForConnIdle 等待连接空闲
 func (c *BenchmarkClient) waitForConnIdle(ctx context.Context, msgCh <-chan Message, idleTime time.Duration) error {
 	for {
@@ -398,6 +682,9 @@ func (c *BenchmarkClient) runReceiveLoop(ctx context.Context, conn streams.Conne
 			default:
 			}
 			logger.Error(err, "receive message error")
+			if errors.Is(err, streams.ErrConnectionClosed) {
+				return
+			}
 			continue
 		}
 		msg, err := ParseMessage(raw)
@@ -413,3 +700,18 @@ func (c *BenchmarkClient) runReceiveLoop(ctx context.Context, conn streams.Conne
 		}
 	}
 }
+
+// runConcurrently 并发运行 fn(0) 到 fn(n-1) ，等待全部结束后返回所有错误
+func runConcurrently(n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
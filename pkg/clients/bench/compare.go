@@ -0,0 +1,119 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultRegressionThreshold 默认判定为性能退化的相对变化阈值
+const DefaultRegressionThreshold = 0.1
+
+const (
+	// 时延增加不超过该值（单位：毫秒）时不认为是退化，避免很小的时延的波动被认为是退化
+	latencyRegressionFloor = 0.1
+	// 丢包率增加不超过该值（单位：百分比）时不认为是退化
+	lossRateRegressionFloor = 1
+)
+
+// MetricUnit 指标单位
+type MetricUnit string
+
+const (
+	// MetricUnitBytesPerSecond 字节每秒，值越大越好
+	MetricUnitBytesPerSecond MetricUnit = "B/s"
+	// MetricUnitMilliseconds 毫秒，值越小越好
+	MetricUnitMilliseconds MetricUnit = "ms"
+	// MetricUnitPercent 百分比，值越小越好
+	MetricUnitPercent MetricUnit = "%"
+)
+
+// MetricComparison 指标比较结果
+type MetricComparison struct {
+	// 指标名
+	Metric string `json:"metric" yaml:"metric"`
+	// 单位
+	Unit MetricUnit `json:"unit" yaml:"unit"`
+	// 之前的值
+	Previous float64 `json:"previous" yaml:"previous"`
+	// 当前的值
+	Current float64 `json:"current" yaml:"current"`
+	// 相对变化，即 (Current - Previous) / Previous ，之前的值为 0 时为 0
+	Change float64 `json:"change" yaml:"change"`
+	// 是否退化
+	Regression bool `json:"regression" yaml:"regression"`
+}
+
+// ReadReport 从文件读取测试报告，支持 JSON 和 YAML 格式
+func ReadReport(path string) (*Report, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{}
+	if json.Valid(raw) {
+		err = json.Unmarshal(raw, report)
+	} else {
+		err = yaml.Unmarshal(raw, report)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse report %q error: %w", path, err)
+	}
+	return report, nil
+}
+
+// Compare 比较两次测试的报告，相对变化超过 threshold 且变差的指标标记为退化
+func Compare(previous, current *Report, threshold float64) []MetricComparison {
+	var ret []MetricComparison
+	add := func(metric string, unit MetricUnit, prev, cur float64) {
+		if unit == MetricUnitMilliseconds && (prev == 0 || cur == 0) {
+			// 没有时延数据
+			return
+		}
+		c := MetricComparison{
+			Metric:   metric,
+			Unit:     unit,
+			Previous: prev,
+			Current:  cur,
+		}
+		if prev != 0 {
+			c.Change = (cur - prev) / prev
+		}
+		switch unit {
+		case MetricUnitBytesPerSecond:
+			c.Regression = prev > 0 && cur < prev*(1-threshold)
+		case MetricUnitMilliseconds:
+			c.Regression = cur > prev*(1+threshold) && cur-prev > latencyRegressionFloor
+		case MetricUnitPercent:
+			c.Regression = cur > prev*(1+threshold) && cur-prev > lossRateRegressionFloor
+		}
+		ret = append(ret, c)
+	}
+	addLatency := func(metric string, prev, cur LatencyStats) {
+		add(metric+".p50", MetricUnitMilliseconds, milliseconds(prev.P50), milliseconds(cur.P50))
+		add(metric+".p99", MetricUnitMilliseconds, milliseconds(prev.P99), milliseconds(cur.P99))
+	}
+	addTransmission := func(metric string, prev, cur TransmissionResult) {
+		add(metric+".throughput", MetricUnitBytesPerSecond, float64(prev.Throughput), float64(cur.Throughput))
+		add(metric+".lossRate", MetricUnitPercent, prev.LossRate*100, cur.LossRate*100)
+		addLatency(metric+".latency", prev.Latency, cur.Latency)
+	}
+
+	add("connect.mean", MetricUnitMilliseconds, milliseconds(previous.Connect.Mean), milliseconds(current.Connect.Mean))
+	add("ping.lossRate", MetricUnitPercent, previous.Ping.LossRate*100, current.Ping.LossRate*100)
+	addLatency("ping.latency", previous.Ping.Latency, current.Ping.Latency)
+	addTransmission("readOnly", previous.ReadOnly, current.ReadOnly)
+	addTransmission("writeOnly", previous.WriteOnly, current.WriteOnly)
+	addTransmission("readWrite.read", previous.ReadWrite.Read, current.ReadWrite.Read)
+	addTransmission("readWrite.write", previous.ReadWrite.Write, current.ReadWrite.Write)
+
+	return ret
+}
+
+// milliseconds 返回以毫秒为单位的时长
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
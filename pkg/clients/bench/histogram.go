@@ -0,0 +1,185 @@
+package bench
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+const (
+	// histogramSubBucketBits 每个 2 的幂区间分桶数的位数
+	// 每个区间分为 32 个桶，记录的值相对误差不超过约 3%
+	histogramSubBucketBits = 5
+	histogramSubBuckets    = 1 << histogramSubBucketBits
+)
+
+// NewHistogram 创建 Histogram
+func NewHistogram() *Histogram {
+	return &Histogram{Buckets: map[int]uint64{}}
+}
+
+// Histogram 时延直方图
+//
+// 使用对数分桶，占用空间与样本数量无关，可以合并多个直方图，也可以序列化后在两端之间传递
+type Histogram struct {
+	// 每个桶的样本数
+	Buckets map[int]uint64 `json:"buckets,omitempty"`
+	// 样本数
+	Count uint64 `json:"count"`
+	// 样本值之和
+	Sum time.Duration `json:"sum"`
+	// 最小值
+	Min time.Duration `json:"min"`
+	// 最大值
+	Max time.Duration `json:"max"`
+	// 相邻两个样本差的绝对值之和
+	JitterSum time.Duration `json:"jitterSum"`
+	// 相邻两个样本差的个数
+	JitterCount uint64 `json:"jitterCount"`
+
+	// 上一个样本
+	last time.Duration
+}
+
+// LatencyStats 时延统计
+type LatencyStats struct {
+	// 样本数
+	Count uint64 `json:"count"`
+	// 最小值
+	Min time.Duration `json:"min"`
+	// 平均值
+	Mean time.Duration `json:"mean"`
+	// 50 分位
+	P50 time.Duration `json:"p50"`
+	// 90 分位
+	P90 time.Duration `json:"p90"`
+	// 99 分位
+	P99 time.Duration `json:"p99"`
+	// 最大值
+	Max time.Duration `json:"max"`
+	// 抖动，即相邻两个样本差的绝对值的平均值
+	Jitter time.Duration `json:"jitter"`
+}
+
+// Record 记录一个样本，小于 0 的值按 0 记录
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if h.Buckets == nil {
+		h.Buckets = map[int]uint64{}
+	}
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	if h.Count > 0 {
+		diff := d - h.last
+		if diff < 0 {
+			diff = -diff
+		}
+		h.JitterSum += diff
+		h.JitterCount++
+	}
+	h.last = d
+	h.Buckets[histogramBucketIndex(uint64(d))]++
+	h.Count++
+	h.Sum += d
+}
+
+// Merge 将另一个直方图的样本合并到该直方图
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+	if h.Buckets == nil {
+		h.Buckets = map[int]uint64{}
+	}
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+	for i, n := range other.Buckets {
+		h.Buckets[i] += n
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+	h.JitterSum += other.JitterSum
+	h.JitterCount += other.JitterCount
+}
+
+// Percentile 返回 q 分位的值， q 取值范围为 [0, 1]
+func (h *Histogram) Percentile(q float64) time.Duration {
+	if h == nil || h.Count == 0 {
+		return 0
+	}
+	if q >= 1 {
+		return h.Max
+	}
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	if rank == 0 {
+		return h.Min
+	}
+
+	indexes := make([]int, 0, len(h.Buckets))
+	for i := range h.Buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	cnt := uint64(0)
+	for _, i := range indexes {
+		cnt += h.Buckets[i]
+		if cnt >= rank {
+			v := histogramBucketValue(i)
+			return min(max(v, h.Min), h.Max)
+		}
+	}
+	return h.Max
+}
+
+// Stats 返回时延统计
+func (h *Histogram) Stats() LatencyStats {
+	if h == nil || h.Count == 0 {
+		return LatencyStats{}
+	}
+	stats := LatencyStats{
+		Count: h.Count,
+		Min:   h.Min,
+		Mean:  h.Sum / time.Duration(h.Count),
+		P50:   h.Percentile(0.5),
+		P90:   h.Percentile(0.9),
+		P99:   h.Percentile(0.99),
+		Max:   h.Max,
+	}
+	if h.JitterCount > 0 {
+		stats.Jitter = h.JitterSum / time.Duration(h.JitterCount)
+	}
+	return stats
+}
+
+// histogramBucketIndex 返回值 v 所在桶的序号
+func histogramBucketIndex(v uint64) int {
+	if v < 2*histogramSubBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - 1
+	sub := v >> (exp - histogramSubBucketBits)
+	return (exp-histogramSubBucketBits)*histogramSubBuckets + int(sub)
+}
+
+// histogramBucketValue 返回序号为 i 的桶的代表值（桶的中间值）
+func histogramBucketValue(i int) time.Duration {
+	if i < 2*histogramSubBuckets {
+		return time.Duration(i)
+	}
+	exp := i/histogramSubBuckets + histogramSubBucketBits - 1
+	sub := uint64(i%histogramSubBuckets + histogramSubBuckets)
+	width := uint64(1) << (exp - histogramSubBucketBits)
+	return time.Duration(sub*width + width/2)
+}
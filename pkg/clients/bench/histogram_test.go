@@ -0,0 +1,80 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHistogram 测试时延直方图
+func TestHistogram(t *testing.T) {
+	a := assert.New(t)
+
+	h1 := NewHistogram()
+	h2 := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		d := time.Duration(i) * time.Millisecond
+		if i%2 == 0 {
+			h1.Record(d)
+		} else {
+			h2.Record(d)
+		}
+	}
+	h1.Merge(h2)
+
+	// 合并后通过消息传递
+	got, err := ParseMessage(WriteResult{ReceivedPackageCount: 1000, Latency: h1}.Raw())
+	if !a.NoError(err) {
+		return
+	}
+	stats := got.(WriteResult).Latency.Stats()
+	a.Equal(uint64(1000), stats.Count)
+	a.Equal(time.Millisecond, stats.Min)
+	a.Equal(time.Second, stats.Max)
+	a.Equal(500500*time.Microsecond, stats.Mean)
+	a.InEpsilon(float64(500*time.Millisecond), float64(stats.P50), 0.03)
+	a.InEpsilon(float64(900*time.Millisecond), float64(stats.P90), 0.03)
+	a.InEpsilon(float64(990*time.Millisecond), float64(stats.P99), 0.03)
+	a.Equal(2*time.Millisecond, stats.Jitter)
+
+	// 桶序号与值对应
+	for _, v := range []uint64{0, 1, 63, 64, 65, 127, 128, 1 << 20, 1<<40 + 12345} {
+		i := histogramBucketIndex(v)
+		a.Equal(i, histogramBucketIndex(uint64(histogramBucketValue(i))), v)
+	}
+
+	a.Equal(LatencyStats{}, NewHistogram().Stats())
+}
+
+// TestCompare 测试比较测试报告
+func TestCompare(t *testing.T) {
+	a := assert.New(t)
+
+	previous := &Report{
+		ReadOnly: TransmissionResult{
+			Throughput: 100 << 20,
+			Latency:    LatencyStats{P50: 10 * time.Millisecond, P99: 20 * time.Millisecond},
+		},
+		WriteOnly: TransmissionResult{Throughput: 100 << 20},
+	}
+	current := &Report{
+		ReadOnly: TransmissionResult{
+			Throughput: 80 << 20,
+			Latency:    LatencyStats{P50: 10 * time.Millisecond, P99: 30 * time.Millisecond},
+		},
+		WriteOnly: TransmissionResult{Throughput: 95 << 20, LossRate: 0.05},
+	}
+
+	regressions := map[string]bool{}
+	for _, c := range Compare(previous, current, DefaultRegressionThreshold) {
+		if c.Regression {
+			regressions[c.Metric] = true
+		}
+	}
+	a.Equal(map[string]bool{
+		"readOnly.throughput":  true,
+		"readOnly.latency.p99": true,
+		"writeOnly.lossRate":   true,
+	}, regressions)
+}
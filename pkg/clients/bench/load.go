@@ -0,0 +1,336 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+const (
+	// DefaultLoadStreams 默认负载测试创建的流数量
+	DefaultLoadStreams = 1000
+	// DefaultLoadConcurrency 默认负载测试的并发数
+	DefaultLoadConcurrency = 100
+
+	loadMessageTimeout = 30 * time.Second
+)
+
+// NewDefaultLoadOptions 创建默认 LoadOptions
+func NewDefaultLoadOptions() LoadOptions {
+	return LoadOptions{
+		Streams:     DefaultLoadStreams,
+		Concurrency: DefaultLoadConcurrency,
+	}
+}
+
+// LoadOptions 负载测试选项
+type LoadOptions struct {
+	// 创建的流数量
+	Streams int `json:"streams" yaml:"streams"`
+	// 同时创建流的并发数
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+	// 是否连接每个流的两端并通过流往返一条消息
+	Connect bool `json:"connect,omitempty" yaml:"connect,omitempty"`
+	// 服务端 pprof 服务地址（如 http://127.0.0.1:6060 ），用于统计服务端内存占用，为空时不统计
+	ServerPprofURL string `json:"serverPprofURL,omitempty" yaml:"serverPprofURL,omitempty"`
+}
+
+// Validate 校验选项
+func (opts LoadOptions) Validate() error {
+	if opts.Streams < 1 {
+		return fmt.Errorf("number of streams must be positive")
+	}
+	if opts.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+	return nil
+}
+
+// LoadReport 负载测试报告
+type LoadReport struct {
+	Options LoadOptions `json:"options" yaml:"options"`
+	// 成功创建（且连接）的流数量
+	Succeeded int `json:"succeeded" yaml:"succeeded"`
+	// 失败的流数量
+	Failed int `json:"failed" yaml:"failed"`
+	// 第一个失败的原因
+	FirstError string `json:"firstError,omitempty" yaml:"firstError,omitempty"`
+	// 总耗时
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	// 每秒成功创建的流数量
+	StreamsPerSecond float64 `json:"streamsPerSecond" yaml:"streamsPerSecond"`
+	// 创建流的耗时
+	Create LatencyStats `json:"create" yaml:"create"`
+	// 连接流的耗时
+	Connect LatencyStats `json:"connect,omitempty" yaml:"connect,omitempty"`
+	// 消息通过流往返一次的耗时
+	RoundTrip LatencyStats `json:"roundTrip,omitempty" yaml:"roundTrip,omitempty"`
+	// 服务端内存占用
+	Memory *LoadMemory `json:"memory,omitempty" yaml:"memory,omitempty"`
+}
+
+// LoadMemory 负载测试前后服务端的内存占用
+type LoadMemory struct {
+	// 创建流之前的内存占用
+	Before uint64 `json:"before" yaml:"before"`
+	// 创建流之后的内存占用
+	After uint64 `json:"after" yaml:"after"`
+	// 平均每个流的内存占用
+	PerStream uint64 `json:"perStream" yaml:"perStream"`
+}
+
+// loadStream 负载测试中创建的流
+type loadStream struct {
+	name  string
+	conns []streams.Connection
+}
+
+// loadWorkerResult 一个负载测试并发任务的结果
+type loadWorkerResult struct {
+	create    *Histogram
+	connect   *Histogram
+	roundTrip *Histogram
+	streams   []loadStream
+	failed    int
+	firstErr  error
+}
+
+// RunLoad 运行负载测试
+//
+// 并发创建大量的流，统计创建和连接流的耗时，测试结束后删除创建的流。
+// 指定服务端 pprof 服务地址时，在创建流前后读取服务端的内存占用，估计每个流的内存占用
+func (c *BenchmarkClient) RunLoad(ctx context.Context, opts LoadOptions) (*LoadReport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	logger := logr.FromContextOrDiscard(ctx)
+
+	report := &LoadReport{Options: opts}
+
+	var memBefore uint64
+	if opts.ServerPprofURL != "" {
+		var err error
+		memBefore, err = getServerMemory(ctx, opts.ServerPprofURL)
+		if err != nil {
+			return nil, fmt.Errorf("get server memory error: %w", err)
+		}
+	}
+
+	// 并发创建流
+	logger.Info(fmt.Sprintf("creating %d streams with concurrency %d ...", opts.Streams, opts.Concurrency))
+	jobs := make(chan int, opts.Streams)
+	for i := 0; i < opts.Streams; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	results := make([]*loadWorkerResult, min(opts.Concurrency, opts.Streams))
+	startTime := time.Now()
+	_ = runConcurrently(len(results), func(i int) error {
+		results[i] = c.runLoadWorker(ctx, jobs, opts.Connect)
+		return nil
+	})
+	report.Duration = time.Since(startTime)
+
+	var created []loadStream
+	create := NewHistogram()
+	connect := NewHistogram()
+	roundTrip := NewHistogram()
+	for _, ret := range results {
+		created = append(created, ret.streams...)
+		create.Merge(ret.create)
+		connect.Merge(ret.connect)
+		roundTrip.Merge(ret.roundTrip)
+		report.Failed += ret.failed
+		if ret.firstErr != nil && report.FirstError == "" {
+			report.FirstError = ret.firstErr.Error()
+		}
+	}
+	defer c.deleteLoadStreams(context.WithoutCancel(ctx), created, opts.Concurrency)
+
+	report.Succeeded = len(created)
+	report.StreamsPerSecond = float64(report.Succeeded) / report.Duration.Seconds()
+	report.Create = create.Stats()
+	report.Connect = connect.Stats()
+	report.RoundTrip = roundTrip.Stats()
+	logger.Info(fmt.Sprintf("created %d streams, %d failed", report.Succeeded, report.Failed))
+
+	if opts.ServerPprofURL != "" {
+		memAfter, err := getServerMemory(ctx, opts.ServerPprofURL)
+		if err != nil {
+			return report, fmt.Errorf("get server memory error: %w", err)
+		}
+		report.Memory = &LoadMemory{Before: memBefore, After: memAfter}
+		if memAfter > memBefore && report.Succeeded > 0 {
+			report.Memory.PerStream = (memAfter - memBefore) / uint64(report.Succeeded)
+		}
+	}
+
+	return report, ctx.Err()
+}
+
+// runLoadWorker 从 jobs 获取任务并创建流，直到 jobs 关闭或 ctx 结束
+func (c *BenchmarkClient) runLoadWorker(ctx context.Context, jobs <-chan int, connect bool) *loadWorkerResult {
+	ret := &loadWorkerResult{
+		create:    NewHistogram(),
+		connect:   NewHistogram(),
+		roundTrip: NewHistogram(),
+	}
+	for range jobs {
+		if ctx.Err() != nil {
+			return ret
+		}
+
+		startTime := time.Now()
+		stream, err := c.c.CreateStream(ctx, &streamv1.Stream{})
+		if err != nil {
+			ret.fail(fmt.Errorf("create stream error: %w", err))
+			continue
+		}
+		ret.create.Record(time.Since(startTime))
+		s := loadStream{name: stream.Name}
+		if connect {
+			s.conns, err = c.connectLoadStream(ctx, stream, ret)
+		}
+		// 连接失败时也需要删除流
+		ret.streams = append(ret.streams, s)
+		if err != nil {
+			ret.fail(err)
+		}
+	}
+	return ret
+}
+
+// connectLoadStream 连接流的两端，并通过流往返一条消息
+func (c *BenchmarkClient) connectLoadStream(
+	ctx context.Context,
+	stream *streamv1.Stream,
+	ret *loadWorkerResult,
+) ([]streams.Connection, error) {
+	client := c.c
+	if stream.Status.Token != "" {
+		client = client.WithToken(stream.Status.Token)
+	}
+
+	var conns []streams.Connection
+	for _, name := range []string{"a", "b"} {
+		startTime := time.Now()
+		conn, err := client.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: name})
+		if err != nil {
+			return conns, fmt.Errorf("connect to stream error: %w", err)
+		}
+		ret.connect.Record(time.Since(startTime))
+		conns = append(conns, conn)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, loadMessageTimeout)
+	defer cancel()
+	startTime := time.Now()
+	for _, pair := range [][2]streams.Connection{{conns[0], conns[1]}, {conns[1], conns[0]}} {
+		if err := pair[0].Send(ctx, Ping(0).Raw()); err != nil {
+			return conns, fmt.Errorf("send message error: %w", err)
+		}
+		if _, err := pair[1].Receive(ctx); err != nil {
+			return conns, fmt.Errorf("receive message error: %w", err)
+		}
+	}
+	ret.roundTrip.Record(time.Since(startTime))
+
+	return conns, nil
+}
+
+// fail 记录一个失败的流
+func (ret *loadWorkerResult) fail(err error) {
+	ret.failed++
+	if ret.firstErr == nil {
+		ret.firstErr = err
+	}
+}
+
+// deleteLoadStreams 关闭连接并删除负载测试创建的流
+func (c *BenchmarkClient) deleteLoadStreams(ctx context.Context, created []loadStream, concurrency int) {
+	logger := logr.FromContextOrDiscard(ctx)
+	logger.Info(fmt.Sprintf("deleting %d streams ...", len(created)))
+
+	jobs := make(chan loadStream, len(created))
+	for _, s := range created {
+		jobs <- s
+	}
+	close(jobs)
+	lock := sync.Mutex{}
+	failed := 0
+	_ = runConcurrently(min(concurrency, len(created)), func(int) error {
+		for s := range jobs {
+			for _, conn := range s.conns {
+				_ = conn.Close(ctx)
+			}
+			if err := c.c.DeleteStream(ctx, s.name); err != nil {
+				lock.Lock()
+				failed++
+				lock.Unlock()
+				logger.V(1).Info(fmt.Sprintf("delete stream %q error: %v", s.name, err))
+			}
+		}
+		return nil
+	})
+	if failed > 0 {
+		logger.Info(fmt.Sprintf("WARN failed to delete %d streams", failed))
+	}
+}
+
+// getServerMemory 通过服务端 pprof 服务获取服务端垃圾回收后的内存占用（堆和栈）
+func getServerMemory(ctx context.Context, pprofURL string) (uint64, error) {
+	pprofURL = strings.TrimSuffix(pprofURL, "/")
+
+	// 获取堆信息前先触发垃圾回收，使结果只包含存活的对象
+	if _, err := httpGet(ctx, pprofURL+"/debug/pprof/heap?gc=1"); err != nil {
+		return 0, err
+	}
+	raw, err := httpGet(ctx, pprofURL+"/debug/vars")
+	if err != nil {
+		return 0, err
+	}
+	vars := struct {
+		MemStats struct {
+			HeapAlloc  uint64
+			StackInuse uint64
+		} `json:"memstats"`
+	}{}
+	if err := json.Unmarshal(raw, &vars); err != nil {
+		return 0, fmt.Errorf("unmarshal memstats error: %w", err)
+	}
+	return vars.MemStats.HeapAlloc + vars.MemStats.StackInuse, nil
+}
+
+// httpGet 发送 GET 请求并返回响应体
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %q error: %s", url, resp.Status)
+	}
+	return body, nil
+}
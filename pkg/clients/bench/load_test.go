@@ -0,0 +1,68 @@
+package bench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/server"
+)
+
+// TestBenchmarkClient_RunLoad 测试负载测试创建、连接并删除流，报告流数量和耗时
+func TestBenchmarkClient_RunLoad(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s := server.NewServer(server.Options{ListenAddr: "127.0.0.1:0"})
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start server error: %v", err)
+	}
+	defer func() {
+		_ = s.Stop(context.Background())
+	}()
+	token, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+
+	for _, scheme := range []string{"grpc", "http"} {
+		client, err := common.NewClient(common.ClientOptions{
+			Server: scheme + "://" + s.Address().String(),
+			Token:  token,
+		})
+		if !a.NoError(err, scheme) {
+			continue
+		}
+
+		opts := LoadOptions{Streams: 5, Concurrency: 2, Connect: true}
+		report, err := NewClient(client).RunLoad(ctx, opts)
+		if !a.NoError(err, scheme) {
+			continue
+		}
+		a.Equal(opts, report.Options, scheme)
+		a.Equal(5, report.Succeeded, scheme)
+		a.Equal(0, report.Failed, scheme)
+		a.Empty(report.FirstError, scheme)
+		a.Positive(report.Duration, scheme)
+		a.Positive(report.StreamsPerSecond, scheme)
+		a.Equal(uint64(5), report.Create.Count, scheme)
+		a.Positive(report.Create.Max, scheme)
+		// 每个流连接两端，往返一条消息
+		a.Equal(uint64(10), report.Connect.Count, scheme)
+		a.Positive(report.Connect.Max, scheme)
+		a.Equal(uint64(5), report.RoundTrip.Count, scheme)
+		a.Positive(report.RoundTrip.Max, scheme)
+		a.LessOrEqual(report.RoundTrip.Min, report.RoundTrip.Max, scheme)
+		a.Nil(report.Memory, scheme)
+
+		// 测试结束后删除创建的流
+		list, err := client.ListStreams(ctx)
+		if a.NoError(err, scheme) {
+			a.Empty(list.Items, scheme)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package bench
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"hash/crc32"
 	"math/rand"
+	"time"
 )
 
 // ParseMessage 解析消息
@@ -25,25 +27,54 @@ func ParseMessage(raw []byte) (Message, error) {
 		}
 		return Ping(binary.BigEndian.Uint32(raw[1:])), nil
 	case PongFlag:
-		if len(raw) != 5 {
-			return nil, fmt.Errorf("invalid pong message: %v (must be 5 bytes)", raw)
+		switch len(raw) {
+		case 5:
+			return Pong{Seq: binary.BigEndian.Uint32(raw[1:])}, nil
+		case 13:
+			return Pong{
+				Seq:  binary.BigEndian.Uint32(raw[1:5]),
+				Time: time.Unix(0, int64(binary.BigEndian.Uint64(raw[5:]))),
+			}, nil
+		default:
+			return nil, fmt.Errorf("invalid pong message: %v (must be 5 or 13 bytes)", raw)
 		}
-		return Pong(binary.BigEndian.Uint32(raw[1:])), nil
 	case StartReadWriteFlag:
-		if len(raw) != 10 {
-			return nil, fmt.Errorf("invalid start read write message: %v (must be 10 bytes)", raw)
+		if len(raw) != 10 && len(raw) != 18 {
+			return nil, fmt.Errorf("invalid start read write message: %v (must be 10 or 18 bytes)", raw)
 		}
-		return StartReadWrite{
+		msg := StartReadWrite{
 			Mode:            ReadWriteMode(raw[1]),
-			ReadPackageSize: binary.BigEndian.Uint64(raw[2:]),
-		}, nil
+			ReadPackageSize: binary.BigEndian.Uint64(raw[2:10]),
+		}
+		if len(raw) == 18 {
+			msg.ClockOffset = time.Duration(binary.BigEndian.Uint64(raw[10:]))
+		}
+		return msg, nil
 	case StopReadWriteFlag:
 		return StopReadWrite{}, nil
 	case WriteResultFlag:
+		if len(raw) < 5 {
+			return nil, fmt.Errorf("invalid write result message: %v (at least 5 bytes)", raw)
+		}
+		msg := WriteResult{ReceivedPackageCount: binary.BigEndian.Uint32(raw[1:5])}
+		if len(raw) > 5 {
+			msg.Latency = &Histogram{}
+			if err := json.Unmarshal(raw[5:], msg.Latency); err != nil {
+				return nil, fmt.Errorf("invalid write result latency: %w", err)
+			}
+		}
+		return msg, nil
+	case RequestParallelStreamsFlag:
 		if len(raw) != 5 {
-			return nil, fmt.Errorf("invalid write result message: %v (must be 5 bytes)", raw)
+			return nil, fmt.Errorf("invalid request parallel streams message: %v (must be 5 bytes)", raw)
 		}
-		return WriteResult{ReceivedPackageCount: binary.BigEndian.Uint32(raw[1:])}, nil
+		return RequestParallelStreams{Count: binary.BigEndian.Uint32(raw[1:])}, nil
+	case ParallelStreamsFlag:
+		msg := ParallelStreams{}
+		if err := json.Unmarshal(raw[1:], &msg); err != nil {
+			return nil, fmt.Errorf("invalid parallel streams message: %w", err)
+		}
+		return msg, nil
 	default:
 		return nil, fmt.Errorf("unknown data flag: %d, raw: %v", raw[0], raw)
 	}
@@ -67,6 +98,9 @@ const (
 	StartReadWriteType MessageType = "StartReadWrite"
 	StopReadWriteType  MessageType = "StopReadWrite"
 	WriteResultType    MessageType = "WriteResult"
+
+	RequestParallelStreamsType MessageType = "RequestParallelStreams"
+	ParallelStreamsType        MessageType = "ParallelStreams"
 )
 
 const (
@@ -76,6 +110,8 @@ const (
 	StartReadWriteFlag
 	StopReadWriteFlag
 	WriteResultFlag
+	RequestParallelStreamsFlag
+	ParallelStreamsFlag
 )
 
 // NewRandData 创建随机数据
@@ -89,6 +125,7 @@ func NewRandData(seq uint32, size uint64) Data {
 }
 
 // RenewRandData 重新生成随机数据
+// 内容足够长时，内容的前 8 字节为发送时间，用于计算单向时延
 func RenewRandData(data Data, seq uint32) {
 	content := data[9:]
 	contentLen := len(content)
@@ -96,6 +133,9 @@ func RenewRandData(data Data, seq uint32) {
 	for i := 0; i < contentLen-7; i += 8 {
 		binary.BigEndian.PutUint64(content[i:], randData)
 	}
+	if contentLen >= 8 {
+		binary.BigEndian.PutUint64(content, uint64(time.Now().UnixNano()))
+	}
 	data[0] = DataFlag
 	binary.BigEndian.PutUint32(data[1:5], seq)
 	binary.BigEndian.PutUint32(data[5:9], crc32.ChecksumIEEE(content))
@@ -132,6 +172,15 @@ func (d Data) Content() []byte {
 	return d[9:]
 }
 
+// SendTime 返回发送时间（发送端的时钟），内容不足 8 字节时返回零值
+func (d Data) SendTime() time.Time {
+	content := d.Content()
+	if len(content) < 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(content)))
+}
+
 // Ping ping 消息
 type Ping uint32
 
@@ -151,10 +200,15 @@ func (m Ping) Raw() []byte {
 	return raw
 }
 
-// Pong ping 消息
-type Pong uint32
+// Pong pong 消息
+type Pong struct {
+	// 对应的 Ping 的序号
+	Seq uint32
+	// 回复时回复端的时间，用于估计两端的时钟偏差
+	Time time.Time
+}
 
-var _ Message = Pong(0)
+var _ Message = Pong{}
 
 // Type 返回消息类型
 func (m Pong) Type() MessageType {
@@ -162,11 +216,12 @@ func (m Pong) Type() MessageType {
 }
 
 // Raw 返回消息原始数据
-// 格式： 2(byte) useless([3]byte) seq(uint32)
+// 格式： 2(byte) seq(uint32) time(int64)
 func (m Pong) Raw() []byte {
-	raw := make([]byte, 5)
+	raw := make([]byte, 13)
 	raw[0] = PongFlag
-	binary.BigEndian.PutUint32(raw[1:], uint32(m))
+	binary.BigEndian.PutUint32(raw[1:5], m.Seq)
+	binary.BigEndian.PutUint64(raw[5:], uint64(m.Time.UnixNano()))
 	return raw
 }
 
@@ -182,6 +237,8 @@ const (
 type StartReadWrite struct {
 	Mode            ReadWriteMode
 	ReadPackageSize uint64
+	// 接收端时钟减去发送端时钟的估计值，用于计算单向时延
+	ClockOffset time.Duration
 }
 
 var _ Message = StartReadWrite{}
@@ -192,12 +249,13 @@ func (m StartReadWrite) Type() MessageType {
 }
 
 // Raw 返回消息原始数据
-// 格式： 3(byte) mode(byte) readPackageSize(uint64)
+// 格式： 3(byte) mode(byte) readPackageSize(uint64) clockOffset(int64)
 func (m StartReadWrite) Raw() []byte {
-	raw := make([]byte, 10)
+	raw := make([]byte, 18)
 	raw[0] = StartReadWriteFlag
 	raw[1] = byte(m.Mode)
-	binary.BigEndian.PutUint64(raw[2:], m.ReadPackageSize)
+	binary.BigEndian.PutUint64(raw[2:10], m.ReadPackageSize)
+	binary.BigEndian.PutUint64(raw[10:], uint64(m.ClockOffset))
 	return raw
 }
 
@@ -219,17 +277,78 @@ func (m StopReadWrite) Raw() []byte {
 // WriteResult 写结果信息
 type WriteResult struct {
 	ReceivedPackageCount uint32
+	// 接收到的数据的单向时延
+	Latency *Histogram
 }
 
+var _ Message = WriteResult{}
+
+// Type 返回消息类型
 func (m WriteResult) Type() MessageType {
 	return WriteResultType
 }
 
 // Raw 返回消息原始数据
-// 格式： 5(byte) receivedPackageCount(uint32)
+// 格式： 5(byte) receivedPackageCount(uint32) latency(json)
 func (m WriteResult) Raw() []byte {
 	raw := make([]byte, 5)
 	raw[0] = WriteResultFlag
 	binary.BigEndian.PutUint32(raw[1:], m.ReceivedPackageCount)
+	if m.Latency != nil {
+		latency, _ := json.Marshal(m.Latency)
+		raw = append(raw, latency...)
+	}
 	return raw
 }
+
+// RequestParallelStreams 请求测试服务端创建用于并行测试的流
+type RequestParallelStreams struct {
+	// 流数量
+	Count uint32
+}
+
+var _ Message = RequestParallelStreams{}
+
+// Type 返回消息类型
+func (m RequestParallelStreams) Type() MessageType {
+	return RequestParallelStreamsType
+}
+
+// Raw 返回消息原始数据
+// 格式： 6(byte) count(uint32)
+func (m RequestParallelStreams) Raw() []byte {
+	raw := make([]byte, 5)
+	raw[0] = RequestParallelStreamsFlag
+	binary.BigEndian.PutUint32(raw[1:], m.Count)
+	return raw
+}
+
+// ParallelStreams 测试服务端创建的用于并行测试的流
+type ParallelStreams struct {
+	// 流
+	Streams []ParallelStream `json:"streams,omitempty"`
+	// 创建失败时的错误信息
+	Error string `json:"error,omitempty"`
+}
+
+// ParallelStream 用于并行测试的流
+type ParallelStream struct {
+	// 流名
+	Name string `json:"name"`
+	// 用于连接流的 Token
+	Token string `json:"token,omitempty"`
+}
+
+var _ Message = ParallelStreams{}
+
+// Type 返回消息类型
+func (m ParallelStreams) Type() MessageType {
+	return ParallelStreamsType
+}
+
+// Raw 返回消息原始数据
+// 格式： 7(byte) streams(json)
+func (m ParallelStreams) Raw() []byte {
+	raw, _ := json.Marshal(m)
+	return append([]byte{ParallelStreamsFlag}, raw...)
+}
@@ -2,8 +2,11 @@ package bench
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hash/crc32"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 
@@ -12,6 +15,9 @@ import (
 	"github.com/yhlooo/scaf/pkg/streams"
 )
 
+// maxParallelStreams 一次最多可以请求创建的并行测试流数量
+const maxParallelStreams = 64
+
 // NewServer 创建基准测试服务端
 func NewServer(client common.Client) *BenchmarkServer {
 	return &BenchmarkServer{c: client}
@@ -20,6 +26,10 @@ func NewServer(client common.Client) *BenchmarkServer {
 // BenchmarkServer 基准测试服务端
 type BenchmarkServer struct {
 	c common.Client
+
+	lock sync.Mutex
+	// 为并行测试创建的流
+	parallelStreams []ParallelStream
 }
 
 // Serve 运行测试服务
@@ -38,6 +48,7 @@ func (c *BenchmarkServer) Serve(ctx context.Context, stream *streamv1.Stream) er
 	}
 	defer func() {
 		_ = conn.Close(ctx)
+		c.deleteParallelStreams(context.Background())
 	}()
 
 	logger.Info("start serve benchmark")
@@ -54,7 +65,12 @@ func (c *BenchmarkServer) handleBenchmarkRequests(ctx context.Context, conn stre
 	for {
 		raw, err := conn.Receive(ctx)
 		if err != nil {
-			if ctx.Err() == nil {
+			switch {
+			case ctx.Err() != nil:
+			case errors.Is(err, streams.ErrConnectionClosed):
+				// 用于并行测试的流被删除时连接被关闭
+				logger.Info(fmt.Sprintf("connection closed: %v", err))
+			default:
 				logger.Error(err, "receive message error")
 			}
 			return
@@ -68,19 +84,109 @@ func (c *BenchmarkServer) handleBenchmarkRequests(ctx context.Context, conn stre
 
 		switch typedMsg := msg.(type) {
 		case Ping:
-			if err := conn.Send(ctx, Pong(typedMsg).Raw()); err != nil {
+			if err := conn.Send(ctx, Pong{Seq: uint32(typedMsg), Time: time.Now()}.Raw()); err != nil {
 				logger.Error(err, fmt.Sprintf("send pong %d error", typedMsg))
 			}
 		case StartReadWrite:
 			if err := c.handleReadWriteRequest(ctx, conn, typedMsg); err != nil {
 				logger.Error(err, "handle read/write test request error")
 			}
+		case RequestParallelStreams:
+			if err := conn.Send(ctx, c.createParallelStreams(ctx, typedMsg.Count).Raw()); err != nil {
+				logger.Error(err, "send parallel streams error")
+			}
 		default:
 			logger.Info(fmt.Sprintf("ignore %s message", msg.Type()))
 		}
 	}
 }
 
+// createParallelStreams 创建 n 个用于并行测试的流，并在这些流上运行测试服务
+func (c *BenchmarkServer) createParallelStreams(ctx context.Context, n uint32) ParallelStreams {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if n > maxParallelStreams {
+		return ParallelStreams{
+			Error: fmt.Sprintf("too many parallel streams: %d (at most %d)", n, maxParallelStreams),
+		}
+	}
+
+	// 删除之前的测试创建的流
+	c.deleteParallelStreams(ctx)
+
+	ret := ParallelStreams{}
+	for i := uint32(0); i < n; i++ {
+		stream, err := c.c.CreateStream(ctx, &streamv1.Stream{})
+		if err != nil {
+			logger.Error(err, "create parallel stream error")
+			ret.Error = fmt.Sprintf("create stream error: %v", err)
+			break
+		}
+		info := ParallelStream{Name: stream.Name, Token: stream.Status.Token}
+		c.lock.Lock()
+		c.parallelStreams = append(c.parallelStreams, info)
+		c.lock.Unlock()
+		ret.Streams = append(ret.Streams, info)
+	}
+	if ret.Error != "" {
+		c.deleteParallelStreams(ctx)
+		ret.Streams = nil
+		return ret
+	}
+
+	for _, info := range ret.Streams {
+		go c.serveParallelStream(ctx, info)
+	}
+	return ret
+}
+
+// serveParallelStream 在用于并行测试的流上运行测试服务，直到流被删除
+func (c *BenchmarkServer) serveParallelStream(ctx context.Context, info ParallelStream) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("stream", info.Name)
+	ctx = logr.NewContext(ctx, logger)
+
+	client := c.c
+	if info.Token != "" {
+		client = client.WithToken(info.Token)
+	}
+	conn, err := client.ConnectStream(ctx, info.Name, common.ConnectStreamOptions{
+		ConnectionName: "server",
+	})
+	if err != nil {
+		logger.Error(err, "connect to parallel stream error")
+		return
+	}
+	if logger.V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+
+	c.handleBenchmarkRequests(ctx, conn)
+}
+
+// deleteParallelStreams 删除为并行测试创建的流
+func (c *BenchmarkServer) deleteParallelStreams(ctx context.Context) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	c.lock.Lock()
+	parallelStreams := c.parallelStreams
+	c.parallelStreams = nil
+	c.lock.Unlock()
+
+	for _, info := range parallelStreams {
+		client := c.c
+		if info.Token != "" {
+			client = client.WithToken(info.Token)
+		}
+		// 流可能已经被测试客户端删除，忽略错误
+		if err := client.DeleteStream(ctx, info.Name); err != nil {
+			logger.V(1).Info(fmt.Sprintf("delete parallel stream %q error: %v", info.Name, err))
+		}
+	}
+}
+
 // handleReadWriteRequest 处理测试读写请求
 func (c *BenchmarkServer) handleReadWriteRequest(
 	ctx context.Context,
@@ -119,6 +225,7 @@ func (c *BenchmarkServer) handleReadWriteRequest(
 	// 接收数据
 	received := uint32(0)
 	lastSeq := uint32(0)
+	latency := NewHistogram()
 	for {
 		raw, err := conn.Receive(ctx)
 		if err != nil {
@@ -147,10 +254,15 @@ func (c *BenchmarkServer) handleReadWriteRequest(
 			}
 			received++
 			lastSeq = typedMsg.Seq()
+			if sendTime := typedMsg.SendTime(); !sendTime.IsZero() {
+				// ClockOffset 为本端时钟减去对端时钟
+				latency.Record(time.Since(sendTime) - startMsg.ClockOffset)
+			}
 		case StopReadWrite:
 			if write {
 				// 返回成功接收的包数量
-				if err := conn.Send(ctx, WriteResult{ReceivedPackageCount: received}.Raw()); err != nil {
+				result := WriteResult{ReceivedPackageCount: received, Latency: latency}
+				if err := conn.Send(ctx, result.Raw()); err != nil {
 					return fmt.Errorf("send write result message error: %w", err)
 				}
 			}
@@ -2,7 +2,10 @@ package common
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -10,9 +13,11 @@ import (
 
 	"github.com/go-logr/logr"
 
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
 	authnv1 "github.com/yhlooo/scaf/pkg/apis/authn/v1"
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
 	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/utils/quicutil"
 )
 
 // Client 客户端
@@ -21,8 +26,13 @@ type Client interface {
 	Token() string
 	// WithToken 返回使用指定 Token 的客户端
 	WithToken(token string) Client
+	// WithForwardedToken 返回代替指定 Token 的用户发起请求的客户端
+	// 仅当客户端自身使用服务 Token 时有效
+	WithForwardedToken(token string) Client
 	// Login 登陆获取用户身份返回登陆后的客户端
 	Login(ctx context.Context, opts LoginOptions) (Client, error)
+	// CreateToken 创建 Token
+	CreateToken(ctx context.Context, req *authnv1.TokenRequest) (*authnv1.TokenRequest, error)
 	// CreateSelfSubjectReview 检查自身身份
 	CreateSelfSubjectReview(ctx context.Context, review *authnv1.SelfSubjectReview) (*authnv1.SelfSubjectReview, error)
 	// CreateStream 创建流
@@ -33,8 +43,18 @@ type Client interface {
 	ListStreams(ctx context.Context) (*streamv1.StreamList, error)
 	// DeleteStream 删除流
 	DeleteStream(ctx context.Context, name string) error
+	// SignalStream 向流中执行命令的一端发送信号
+	SignalStream(ctx context.Context, name string, signal string) error
 	// ConnectStream 连接到流
 	ConnectStream(ctx context.Context, name string, opts ConnectStreamOptions) (streams.Connection, error)
+	// ListAgents 列出代理
+	ListAgents(ctx context.Context) (*agentv1.AgentList, error)
+	// GetAgent 获取代理
+	GetAgent(ctx context.Context, name string) (*agentv1.Agent, error)
+	// ConnectAgent 注册代理并建立接收派发请求的连接，连接断开时代理被注销
+	ConnectAgent(ctx context.Context, agent *agentv1.Agent) (streams.Connection, error)
+	// CreateDispatch 向代理派发请求
+	CreateDispatch(ctx context.Context, dispatch *agentv1.Dispatch) (*agentv1.Dispatch, error)
 }
 
 // LoginOptions 登陆选项
@@ -56,10 +76,28 @@ type ClientOptions struct {
 	Token string
 	// 对传输数据进行压缩
 	Compress bool
+	// 用于校验服务端证书的 CA 证书文件，目前仅用于 QUIC ，为空时使用系统的 CA 证书
+	CAFile string
+	// 不校验服务端证书，目前仅用于 QUIC ，连接可能被中间人攻击
+	Insecure bool
+	// 连接保活选项，为 nil 时使用默认选项
+	Keepalive *streams.KeepaliveOptions
+}
+
+// Complete 将选项补充完整
+func (opts *ClientOptions) Complete() {
+	if opts.Keepalive == nil {
+		keepalive := streams.NewDefaultKeepaliveOptions()
+		opts.Keepalive = &keepalive
+	}
 }
 
 // NewClient 创建客户端
 func NewClient(opts ClientOptions) (Client, error) {
+	opts.Complete()
+	if err := opts.Keepalive.Validate(); err != nil {
+		return nil, err
+	}
 	urlObj, err := url.Parse(opts.Server)
 	if err != nil {
 		return nil, fmt.Errorf("invalid server url %q: %w", opts.Server, err)
@@ -71,12 +109,48 @@ func NewClient(opts ClientOptions) (Client, error) {
 		client, err = NewHTTPClient(HTTPClientOptions{
 			ServerURL: opts.Server,
 			Token:     opts.Token,
+			Keepalive: *opts.Keepalive,
+		})
+	case "tcp", "unix":
+		// 基于 HTTP 处理请求，基于原始字节流连接到流
+		network, address, host := urlObj.Scheme, urlObj.Host, urlObj.Host
+		if network == "unix" {
+			// unix:///path/to/scaf.sock 或 unix:path/to/scaf.sock
+			address, host = urlObj.Path, "unix"
+			if address == "" {
+				address = urlObj.Opaque
+			}
+		}
+		client, err = NewHTTPClient(HTTPClientOptions{
+			ServerURL: "http://" + host,
+			Token:     opts.Token,
+			Dial: func(ctx context.Context) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, address)
+			},
+			RawStream: true,
+			Keepalive: *opts.Keepalive,
+		})
+	case "quic":
+		// 每个 HTTP 连接和流连接使用同一个 QUIC 连接上的不同 QUIC 流
+		var tlsConf *tls.Config
+		tlsConf, err = newTLSConfig(opts.CAFile, opts.Insecure, urlObj.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		client, err = NewHTTPClient(HTTPClientOptions{
+			ServerURL: "http://" + urlObj.Host,
+			Token:     opts.Token,
+			Dial:      quicutil.NewDialer(urlObj.Host, tlsConf).DialContext,
+			RawStream: true,
+			Keepalive: *opts.Keepalive,
 		})
 	case "grpc":
 		client, err = NewGRPCClient(GRPCClientOptions{
 			ServerAddress: urlObj.Host,
 			Token:         opts.Token,
 			Compress:      opts.Compress,
+			Keepalive:     *opts.Keepalive,
 		})
 	default:
 		return nil, fmt.Errorf("invalid server url %q: unsupported scheme %q", opts.Server, urlObj.Scheme)
@@ -96,6 +170,29 @@ func NewClient(opts ClientOptions) (Client, error) {
 	return NewWithPersistentTokenClient(client, tokenFile), nil
 }
 
+// newTLSConfig 创建客户端 TLS 配置
+// caFile 为空时使用系统的 CA 证书校验服务端证书， insecure 为 true 时不校验
+func newTLSConfig(caFile string, insecure bool, serverName string) (*tls.Config, error) {
+	if insecure {
+		if caFile != "" {
+			return nil, fmt.Errorf("ca file and insecure can not be specified at the same time")
+		}
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+	if caFile == "" {
+		return &tls.Config{ServerName: serverName}, nil
+	}
+	caRaw, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file %q error: %w", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caRaw) {
+		return nil, fmt.Errorf("no valid certificate found in ca file %q", caFile)
+	}
+	return &tls.Config{RootCAs: pool, ServerName: serverName}, nil
+}
+
 // NewWithPersistentTokenClient 创建带持久化 Token 的客户端
 func NewWithPersistentTokenClient(client Client, tokenFile string) Client {
 	return &WithPersistentTokenClient{
@@ -2,15 +2,20 @@ package common
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/go-logr/logr"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 
 	"github.com/yhlooo/scaf/pkg/apierrors"
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	agentv1grpc "github.com/yhlooo/scaf/pkg/apis/agent/v1/grpc"
 	authnv1 "github.com/yhlooo/scaf/pkg/apis/authn/v1"
 	authnv1grpc "github.com/yhlooo/scaf/pkg/apis/authn/v1/grpc"
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
@@ -31,6 +36,8 @@ type GRPCClientOptions struct {
 	Token string
 	// 对传输数据进行压缩
 	Compress bool
+	// 连接保活选项，通过 HTTP/2 Ping 实现， gRPC 限制发送间隔不小于 10s
+	Keepalive streams.KeepaliveOptions
 }
 
 // Complete 将选项补充完整
@@ -43,13 +50,22 @@ func (opts *GRPCClientOptions) Complete() {
 // NewGRPCClient 创建基于 gRPC 的客户端
 func NewGRPCClient(opts GRPCClientOptions) (Client, error) {
 	opts.Complete()
-	conn, err := grpc.NewClient(opts.ServerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if opts.Keepalive.Enabled() {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                opts.Keepalive.Interval,
+			Timeout:             opts.Keepalive.Timeout,
+			PermitWithoutStream: true,
+		}))
+	}
+	conn, err := grpc.NewClient(opts.ServerAddress, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return &grpcClient{
 		authnClient:   authnv1grpc.NewAuthenticationClient(conn),
 		streamsClient: streamv1grpc.NewStreamsClient(conn),
+		agentsClient:  agentv1grpc.NewAgentsClient(conn),
 		token:         opts.Token,
 		compress:      opts.Compress,
 	}, nil
@@ -57,10 +73,12 @@ func NewGRPCClient(opts GRPCClientOptions) (Client, error) {
 
 // grpcClient 基于 gRPC 的客户端
 type grpcClient struct {
-	authnClient   authnv1grpc.AuthenticationClient
-	streamsClient streamv1grpc.StreamsClient
-	token         string
-	compress      bool
+	authnClient    authnv1grpc.AuthenticationClient
+	streamsClient  streamv1grpc.StreamsClient
+	agentsClient   agentv1grpc.AgentsClient
+	token          string
+	forwardedToken string
+	compress       bool
 }
 
 var _ Client = (*grpcClient)(nil)
@@ -75,7 +93,21 @@ func (c *grpcClient) WithToken(token string) Client {
 	return &grpcClient{
 		authnClient:   c.authnClient,
 		streamsClient: c.streamsClient,
+		agentsClient:  c.agentsClient,
 		token:         token,
+		compress:      c.compress,
+	}
+}
+
+// WithForwardedToken 返回代替指定 Token 的用户发起请求的客户端
+func (c *grpcClient) WithForwardedToken(token string) Client {
+	return &grpcClient{
+		authnClient:    c.authnClient,
+		streamsClient:  c.streamsClient,
+		agentsClient:   c.agentsClient,
+		token:          c.token,
+		forwardedToken: token,
+		compress:       c.compress,
 	}
 }
 
@@ -99,6 +131,16 @@ func (c *grpcClient) Login(ctx context.Context, opts LoginOptions) (Client, erro
 	return c.WithToken(ret.GetStatus().GetToken()), nil
 }
 
+// CreateToken 创建 Token
+func (c *grpcClient) CreateToken(ctx context.Context, req *authnv1.TokenRequest) (*authnv1.TokenRequest, error) {
+	ctx = c.newContext(ctx)
+	ret, err := c.authnClient.CreateToken(ctx, authnv1.NewGRPCTokenRequest(req))
+	if err != nil {
+		return nil, apierrors.NewFromError(err)
+	}
+	return authnv1.NewTokenRequestFromGRPC(ret), nil
+}
+
 // CreateSelfSubjectReview 检查自身身份
 func (c *grpcClient) CreateSelfSubjectReview(
 	ctx context.Context,
@@ -152,6 +194,16 @@ func (c *grpcClient) DeleteStream(ctx context.Context, name string) error {
 	return nil
 }
 
+// SignalStream 向流中执行命令的一端发送信号
+func (c *grpcClient) SignalStream(ctx context.Context, name string, signal string) error {
+	ctx = c.newContext(ctx)
+	_, err := c.streamsClient.SignalStream(ctx, &streamv1grpc.SignalStreamRequest{Name: name, Signal: signal})
+	if err != nil {
+		return apierrors.NewFromError(err)
+	}
+	return nil
+}
+
 // ConnectStream 连接到流
 func (c *grpcClient) ConnectStream(
 	ctx context.Context,
@@ -162,6 +214,7 @@ func (c *grpcClient) ConnectStream(
 		ctx,
 		servergrpc.MetadataKeyStreamName, name,
 		servergrpc.MetadataKeyConnectionName, opts.ConnectionName,
+		servergrpc.MetadataKeyProtocolVersion, strconv.Itoa(streams.ProtocolVersion),
 	)
 	var callOpts []grpc.CallOption
 	if c.compress {
@@ -171,7 +224,67 @@ func (c *grpcClient) ConnectStream(
 	if err != nil {
 		return nil, apierrors.NewFromError(err)
 	}
-	return streams.NewGRPCStreamClientConnection(opts.ConnectionName, streamClient), nil
+	conn := streams.NewGRPCStreamClientConnection(opts.ConnectionName, streamClient)
+	return streams.NewControlAwareConnection(conn, func() int {
+		// 服务端在响应头 metadata 中确认协商的版本，旧服务端不会设置
+		md, err := streamClient.Header()
+		if err != nil {
+			return 0
+		}
+		values := md.Get(servergrpc.MetadataKeyProtocolVersion)
+		if len(values) == 0 {
+			return 0
+		}
+		return streams.NegotiateProtocolVersion(values[0])
+	}), nil
+}
+
+// ListAgents 列出代理
+func (c *grpcClient) ListAgents(ctx context.Context) (*agentv1.AgentList, error) {
+	ctx = c.newContext(ctx)
+	ret, err := c.agentsClient.ListAgents(ctx, &agentv1grpc.ListAgentsRequest{})
+	if err != nil {
+		return nil, apierrors.NewFromError(err)
+	}
+	return agentv1.NewAgentListFromGRPC(ret), nil
+}
+
+// GetAgent 获取代理
+func (c *grpcClient) GetAgent(ctx context.Context, name string) (*agentv1.Agent, error) {
+	ctx = c.newContext(ctx)
+	ret, err := c.agentsClient.GetAgent(ctx, &agentv1grpc.GetAgentRequest{Name: name})
+	if err != nil {
+		return nil, apierrors.NewFromError(err)
+	}
+	return agentv1.NewAgentFromGRPC(ret), nil
+}
+
+// ConnectAgent 注册代理并建立接收派发请求的连接
+func (c *grpcClient) ConnectAgent(ctx context.Context, agent *agentv1.Agent) (streams.Connection, error) {
+	spec, err := json.Marshal(agent.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal agent spec to json error: %w", err)
+	}
+	ctx = c.newContext(
+		ctx,
+		servergrpc.MetadataKeyAgentName, agent.Name,
+		servergrpc.MetadataKeyAgentSpec, string(spec),
+	)
+	agentClient, err := c.agentsClient.ConnectAgent(ctx)
+	if err != nil {
+		return nil, apierrors.NewFromError(err)
+	}
+	return streams.NewGRPCStreamClientConnection(agent.Name, agentClient), nil
+}
+
+// CreateDispatch 向代理派发请求
+func (c *grpcClient) CreateDispatch(ctx context.Context, dispatch *agentv1.Dispatch) (*agentv1.Dispatch, error) {
+	ctx = c.newContext(ctx)
+	ret, err := c.agentsClient.CreateDispatch(ctx, agentv1.NewGRPCDispatch(dispatch))
+	if err != nil {
+		return nil, apierrors.NewFromError(err)
+	}
+	return agentv1.NewDispatchFromGRPC(ret), nil
 }
 
 // newContext 创建请求上下文
@@ -181,6 +294,9 @@ func (c *grpcClient) newContext(ctx context.Context, metadataKeyValues ...string
 	if c.token != "" {
 		md[servergrpc.MetadataKeyToken] = []string{c.token}
 	}
+	if c.forwardedToken != "" {
+		md[servergrpc.MetadataKeyForwardedToken] = []string{c.forwardedToken}
+	}
 	for i := 0; i < len(metadataKeyValues)-1; i += 2 {
 		md[metadataKeyValues[i]] = []string{metadataKeyValues[i+1]}
 	}
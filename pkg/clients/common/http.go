@@ -1,18 +1,22 @@
 package common
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/gorilla/websocket"
 
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
 	authnv1 "github.com/yhlooo/scaf/pkg/apis/authn/v1"
 	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
@@ -30,6 +34,14 @@ type HTTPClientOptions struct {
 	ServerURL string
 	// 用于认证的 Token
 	Token string
+	// 转发的 Token
+	ForwardedToken string
+	// 建立到服务端的底层连接的方法，为 nil 时按 ServerURL 建立 TCP 连接
+	Dial func(ctx context.Context) (net.Conn, error)
+	// 使用 scaf-raw 协议而非 WebSocket 连接到流
+	RawStream bool
+	// 流连接保活选项
+	Keepalive streams.KeepaliveOptions
 }
 
 // Complete 将选项补充完整
@@ -60,11 +72,19 @@ func NewHTTPClient(opts HTTPClientOptions) (Client, error) {
 	if err := opts.Validate(); err != nil {
 		return nil, err
 	}
-	return &httpClient{
+	c := &httpClient{
 		opts:       opts,
 		httpClient: http.DefaultClient,
 		wsDialer:   websocket.DefaultDialer,
-	}, nil
+	}
+	if opts.Dial != nil {
+		dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return opts.Dial(ctx)
+		}
+		c.httpClient = &http.Client{Transport: &http.Transport{DialContext: dial}}
+		c.wsDialer = &websocket.Dialer{NetDialContext: dial}
+	}
+	return c, nil
 }
 
 // httpClient 基于 HTTP 的客户端
@@ -92,6 +112,17 @@ func (c *httpClient) WithToken(token string) Client {
 	}
 }
 
+// WithForwardedToken 返回代替指定 Token 的用户发起请求的客户端
+func (c *httpClient) WithForwardedToken(token string) Client {
+	opts := c.opts
+	opts.ForwardedToken = token
+	return &httpClient{
+		opts:       opts,
+		httpClient: c.httpClient,
+		wsDialer:   c.wsDialer,
+	}
+}
+
 // Login 登陆获取用户身份返回登陆后的客户端
 func (c *httpClient) Login(ctx context.Context, opts LoginOptions) (Client, error) {
 	logger := logr.FromContextOrDiscard(ctx)
@@ -103,8 +134,7 @@ func (c *httpClient) Login(ctx context.Context, opts LoginOptions) (Client, erro
 			return c, nil
 		}
 	}
-	ret := &authnv1.TokenRequest{}
-	err := c.request(ctx, http.MethodPost, "/v1/tokens", &authnv1.TokenRequest{}, ret)
+	ret, err := c.CreateToken(ctx, &authnv1.TokenRequest{})
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +143,16 @@ func (c *httpClient) Login(ctx context.Context, opts LoginOptions) (Client, erro
 	return c.WithToken(ret.Status.Token), nil
 }
 
+// CreateToken 创建 Token
+func (c *httpClient) CreateToken(ctx context.Context, req *authnv1.TokenRequest) (*authnv1.TokenRequest, error) {
+	ret := &authnv1.TokenRequest{}
+	err := c.request(ctx, http.MethodPost, "/v1/tokens", req, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
 // CreateSelfSubjectReview 检查自身身份
 func (c *httpClient) CreateSelfSubjectReview(
 	ctx context.Context,
@@ -175,27 +215,115 @@ func (c *httpClient) DeleteStream(ctx context.Context, name string) error {
 	return nil
 }
 
+// SignalStream 向流中执行命令的一端发送信号
+func (c *httpClient) SignalStream(ctx context.Context, name string, signal string) error {
+	if name == "" {
+		return fmt.Errorf("stream name must not be empty")
+	}
+	ret := &metav1.Status{}
+	err := c.request(ctx, http.MethodPost, "/v1/streams/"+name+"/signals", &streamv1.StreamSignal{Signal: signal}, ret)
+	if err != nil {
+		return err
+	}
+	if ret.Code != http.StatusOK {
+		return ret
+	}
+	return nil
+}
+
 // ConnectStream 连接到流
 func (c *httpClient) ConnectStream(
 	ctx context.Context,
 	name string,
 	opts ConnectStreamOptions,
 ) (streams.Connection, error) {
-	server := c.opts.ServerURL
-	server = strings.Replace(server, "https://", "wss://", 1)
-	server = strings.Replace(server, "http://", "ws://", 1)
-	header := map[string][]string{
-		serverhttp.ConnectionNameHeader: {opts.ConnectionName},
+	conn, respHeader, err := c.connect(ctx, "/v1/streams/"+name, opts.ConnectionName, http.Header{
+		serverhttp.ConnectionNameHeader:  {opts.ConnectionName},
+		serverhttp.ProtocolVersionHeader: {strconv.Itoa(streams.ProtocolVersion)},
+	})
+	if err != nil {
+		return nil, err
 	}
+	// 旧服务端不会在升级响应中确认协商的版本
+	version := streams.NegotiateProtocolVersion(respHeader.Get(serverhttp.ProtocolVersionHeader))
+	return streams.NewControlAwareConnection(conn, func() int { return version }), nil
+}
+
+// ListAgents 列出代理
+func (c *httpClient) ListAgents(ctx context.Context) (*agentv1.AgentList, error) {
+	ret := &agentv1.AgentList{}
+	err := c.request(ctx, http.MethodGet, "/v1/agents", nil, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetAgent 获取代理
+func (c *httpClient) GetAgent(ctx context.Context, name string) (*agentv1.Agent, error) {
+	if name == "" {
+		return nil, fmt.Errorf("agent name must not be empty")
+	}
+	ret := &agentv1.Agent{}
+	err := c.request(ctx, http.MethodGet, "/v1/agents/"+name, nil, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ConnectAgent 注册代理并建立接收派发请求的连接
+func (c *httpClient) ConnectAgent(ctx context.Context, agent *agentv1.Agent) (streams.Connection, error) {
+	if agent.Name == "" {
+		return nil, fmt.Errorf("agent name must not be empty")
+	}
+	spec, err := json.Marshal(agent.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal agent spec to json error: %w", err)
+	}
+	conn, _, err := c.connect(ctx, "/v1/agents/"+agent.Name, agent.Name, http.Header{
+		serverhttp.AgentSpecHeader:       {string(spec)},
+		serverhttp.ProtocolVersionHeader: {strconv.Itoa(streams.ProtocolVersion)},
+	})
+	return conn, err
+}
+
+// CreateDispatch 向代理派发请求
+func (c *httpClient) CreateDispatch(ctx context.Context, dispatch *agentv1.Dispatch) (*agentv1.Dispatch, error) {
+	ret := &agentv1.Dispatch{}
+	err := c.request(ctx, http.MethodPost, "/v1/dispatches", dispatch, ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// connect 升级连接到指定 uri ，返回连接和升级响应头
+func (c *httpClient) connect(
+	ctx context.Context,
+	uri string,
+	connName string,
+	header http.Header,
+) (streams.Connection, http.Header, error) {
 	if c.opts.Token != "" {
-		header["Authorization"] = []string{"Bearer " + c.opts.Token}
+		header.Set("Authorization", "Bearer "+c.opts.Token)
 	}
-	conn, resp, connErr := websocket.DefaultDialer.DialContext(ctx, server+"/v1/streams/"+name, header)
+	if c.opts.ForwardedToken != "" {
+		header.Set(serverhttp.ForwardedTokenHeader, c.opts.ForwardedToken)
+	}
+	if c.opts.RawStream {
+		return c.connectRaw(ctx, uri, connName, header)
+	}
+
+	server := c.opts.ServerURL
+	server = strings.Replace(server, "https://", "wss://", 1)
+	server = strings.Replace(server, "http://", "ws://", 1)
+	conn, resp, connErr := c.wsDialer.DialContext(ctx, server+uri, header)
 	if connErr == nil {
-		return streams.NewWebSocketConnection(opts.ConnectionName, conn), nil
+		return streams.NewWebSocketConnection(connName, conn, c.opts.Keepalive), resp.Header, nil
 	}
 	if resp == nil {
-		return nil, connErr
+		return nil, nil, connErr
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -203,18 +331,82 @@ func (c *httpClient) ConnectStream(
 
 	respBodyRaw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		return nil, fmt.Errorf("connect error: %w, status code: %d", connErr, resp.StatusCode)
+		return nil, nil, fmt.Errorf("connect error: %w, status code: %d", connErr, resp.StatusCode)
 	}
 
 	s := &metav1.Status{}
 	if err := json.Unmarshal(respBodyRaw, s); err != nil {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"connect error: %w, status code: %d, body: %s",
 			connErr, resp.StatusCode, string(respBodyRaw),
 		)
 	}
 
-	return nil, s
+	return nil, nil, s
+}
+
+// connectRaw 使用 scaf-raw 协议升级连接到指定 uri ，返回连接和升级响应头
+func (c *httpClient) connectRaw(
+	ctx context.Context,
+	uri string,
+	connName string,
+	header http.Header,
+) (streams.Connection, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.opts.ServerURL+uri, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("make request error: %w", err)
+	}
+	req.Header = header
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", serverhttp.RawUpgradeProtocol)
+
+	var conn net.Conn
+	if c.opts.Dial != nil {
+		conn, err = c.opts.Dial(ctx)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", req.URL.Host)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("dial error: %w", err)
+	}
+
+	// 握手过程中 ctx 被取消时中断连接
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+	defer stop()
+
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("send upgrade request error: %w", err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, fmt.Errorf("read upgrade response error: %w", err)
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		if !stop() {
+			return nil, nil, ctx.Err()
+		}
+		return streams.NewRawConnection(connName, conn, r, c.opts.Keepalive), resp.Header, nil
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+		_ = conn.Close()
+	}()
+	respBodyRaw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unexpected response status code: %d", resp.StatusCode)
+	}
+	s := &metav1.Status{}
+	if err := json.Unmarshal(respBodyRaw, s); err != nil {
+		return nil, nil, fmt.Errorf("unexpected response status code: %d, body: %s", resp.StatusCode, string(respBodyRaw))
+	}
+	return nil, nil, s
 }
 
 // request 进行一次请求
@@ -235,6 +427,9 @@ func (c *httpClient) request(ctx context.Context, method, uri string, body, resu
 	if c.opts.Token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
 	}
+	if c.opts.ForwardedToken != "" {
+		req.Header.Set(serverhttp.ForwardedTokenHeader, c.opts.ForwardedToken)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
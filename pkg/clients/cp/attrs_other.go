@@ -0,0 +1,29 @@
+//go:build !linux && !darwin
+
+package cp
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// fileOwner 返回文件所有者的用户 ID 和组 ID
+// 仅在 Linux 和 macOS 上支持
+func fileOwner(_ fs.FileInfo) (int, int) {
+	return 0, 0
+}
+
+// readXattrs 读取文件（不跟随符号链接）的扩展属性
+// 仅在 Linux 和 macOS 上支持
+func readXattrs(_ string) (map[string]string, error) {
+	return nil, nil
+}
+
+// writeXattrs 设置文件（不跟随符号链接）的扩展属性
+// 仅在 Linux 和 macOS 上支持
+func writeXattrs(_ string, xattrs map[string]string) error {
+	if len(xattrs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("extended attributes are only supported on linux and macos")
+}
@@ -0,0 +1,69 @@
+//go:build linux || darwin
+
+package cp
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// fileOwner 返回文件所有者的用户 ID 和组 ID
+func fileOwner(info fs.FileInfo) (int, int) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return int(stat.Uid), int(stat.Gid)
+}
+
+// readXattrs 读取文件（不跟随符号链接）的扩展属性
+func readXattrs(p string) (map[string]string, error) {
+	size, err := unix.Llistxattr(p, nil)
+	if err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	buf := make([]byte, size)
+	size, err = unix.Llistxattr(p, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]string{}
+	for _, name := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		size, err := unix.Lgetxattr(p, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, size)
+		size, err = unix.Lgetxattr(p, name, value)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = string(value[:size])
+	}
+	return ret, nil
+}
+
+// writeXattrs 设置文件（不跟随符号链接）的扩展属性
+func writeXattrs(p string, xattrs map[string]string) error {
+	var errs []error
+	for name, value := range xattrs {
+		if err := unix.Lsetxattr(p, name, []byte(value), 0); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
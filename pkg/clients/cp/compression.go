@@ -0,0 +1,108 @@
+package cp
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression 传输时使用的压缩算法
+type Compression string
+
+const (
+	// CompressionNone 不压缩
+	CompressionNone Compression = "none"
+	// CompressionGzip gzip 压缩
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd zstd 压缩
+	CompressionZstd Compression = "zstd"
+	// CompressionAuto 自动选择接收端支持的压缩算法，仅用于发送端选项
+	CompressionAuto Compression = "auto"
+)
+
+// SupportedCompressions 支持的压缩算法，按优先级从高到低排序
+var SupportedCompressions = []Compression{CompressionZstd, CompressionGzip, CompressionNone}
+
+// Validate 校验压缩算法
+func (c Compression) Validate() error {
+	switch c {
+	case "", CompressionNone, CompressionGzip, CompressionZstd, CompressionAuto:
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression %q, must be one of none, gzip, zstd or auto", c)
+	}
+}
+
+// NegotiateCompression 根据发送端期望的压缩算法和接收端支持的压缩算法选择使用的压缩算法
+//
+// 接收端不支持期望的压缩算法时不压缩
+func NegotiateCompression(want Compression, receiverSupported []Compression) Compression {
+	supported := func(c Compression) bool {
+		for _, s := range receiverSupported {
+			if s == c {
+				return true
+			}
+		}
+		return false
+	}
+	switch want {
+	case "", CompressionNone:
+		return CompressionNone
+	case CompressionAuto:
+		for _, c := range SupportedCompressions {
+			if supported(c) {
+				return c
+			}
+		}
+		return CompressionNone
+	default:
+		if supported(want) {
+			return want
+		}
+		return CompressionNone
+	}
+}
+
+// nopWriteCloser 关闭时什么也不做的 io.WriteCloser
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close 关闭
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// NewCompressWriter 创建压缩数据并写到 w 的 io.WriteCloser ，关闭时不会关闭 w
+func NewCompressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case "", CompressionNone:
+		return nopWriteCloser{Writer: w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+}
+
+// NewDecompressReader 创建从 r 读取并解压数据的 io.ReadCloser ，关闭时不会关闭 r
+func NewDecompressReader(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case "", CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", c)
+	}
+}
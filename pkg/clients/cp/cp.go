@@ -6,24 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
-	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
 	"github.com/yhlooo/scaf/pkg/clients/common"
 	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/utils/progress"
 )
 
-const (
-	maxReadSize  = 16 << 10
-	startRecvMsg = "StartReceiving"
-	sendDoneMsg  = "SendCompleted"
-	recvDoneMsg  = "ReceivingCompleted"
-)
+const maxReadSize = 16 << 10
 
 // New 创建 CopyFileClient
 func New(client common.Client) *CopyFileClient {
@@ -47,10 +41,31 @@ func (c *CopyFileClient) WithClient(client common.Client) *CopyFileClient {
 	}
 }
 
+// SendOptions 发送选项
+type SendOptions struct {
+	// 压缩算法，接收端不支持时不压缩
+	Compression Compression
+	// 过滤选项
+	Filter FilterOptions
+	// 同步选项，不为空时使用同步模式，只发送有变化的文件
+	Sync *SyncOptions
+	// 处理发送进度，为空时不报告进度
+	Progress progress.Handler
+}
+
 // Send 发送文件或目录
-func (c *CopyFileClient) Send(ctx context.Context, stream *streamv1.Stream, path string) error {
+func (c *CopyFileClient) Send(
+	ctx context.Context,
+	stream *streamv1.Stream,
+	path string,
+	opts SendOptions,
+) (retErr error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
+	if err := opts.Compression.Validate(); err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -68,42 +83,105 @@ func (c *CopyFileClient) Send(ctx context.Context, stream *streamv1.Stream, path
 		_ = conn.Close(ctx)
 	}()
 
-	// 等待接收端
+	// 等待接收端，接收端准备好接收后发送接收端信息
+	// 服务端支持控制消息时先等待接收端加入，否则直接等待接收端信息
 	logger.Info("waiting for receiver ...")
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+	if err := streams.WaitForPeer(ctx, conn); err != nil && !errors.Is(err, streams.ErrControlNotSupported) {
+		return fmt.Errorf("wait for receiver error: %w", err)
+	}
+	receiverInfo, err := waitForReceiver(ctx, conn)
+	if err != nil {
+		return err
+	}
+	// 旧版本的接收端不发送接收端信息帧，发送不带帧类型的数据
+	legacy := receiverInfo == nil
+	if legacy {
+		logger.Info("WARN receiver is an old version, send with the old handshake")
+		receiverInfo = &ReceiverInfo{}
+	}
+
+	// 协商压缩算法和同步模式
+	compression := NegotiateCompression(opts.Compression, receiverInfo.Compressions)
+	if opts.Compression != "" && opts.Compression != CompressionAuto && compression != opts.Compression {
+		logger.Info(fmt.Sprintf("WARN receiver does not support compression %q, send without compression", opts.Compression))
+	}
+	_, name, err := transferName(path)
+	if err != nil {
+		return err
+	}
+	info := &SenderInfo{Compression: compression, Name: name}
+	if opts.Sync != nil {
+		if receiverInfo.Sync {
+			info.Sync = opts.Sync
+		} else {
+			logger.Info("WARN receiver does not support sync, send all files")
 		}
-		msg, err := conn.Receive(ctx)
-		if err != nil {
-			return fmt.Errorf("receive message error: %w", err)
+	}
+	if !legacy {
+		if err := sendTransferInfo(ctx, conn, info); err != nil {
+			return err
 		}
-		if len(msg) == len(startRecvMsg) && string(msg) == startRecvMsg {
-			// 开始传输
-			break
+	}
+	var sync *syncTransfer
+	var totals *TransferTotals
+	if info.Sync != nil {
+		if sync, err = c.prepareSyncSend(ctx, conn, path, opts); err != nil {
+			return err
+		}
+		totals = &sync.totals
+	} else if totals, err = countFiles(ctx, path, opts.Filter); err != nil {
+		return err
+	}
+	// 发送要发送的文件总数和总字节数
+	if !legacy {
+		if err := sendTransferInfo(ctx, conn, totals); err != nil {
+			return err
 		}
 	}
-	logger.Info("start send")
+	logger.Info(fmt.Sprintf("start send (compression: %s)", compression))
+
+	tracker := progress.NewTracker(opts.Progress)
+	tracker.SetTotal(totals.Bytes, totals.Files)
+	tracker.Start(ctx)
+	defer func() {
+		tracker.Stop(retErr)
+	}()
 
 	pipeR, pipeW := io.Pipe()
 	defer func() {
 		_ = pipeR.Close()
 	}()
 
-	// 打包文件
-	tarW := tar.NewWriter(pipeW)
+	// 打包并压缩文件
+	compressW, err := NewCompressWriter(pipeW, compression)
+	if err != nil {
+		return err
+	}
 	go func() {
-		if err := c.tarFiles(ctx, path, tarW); err != nil {
-			logger.Error(err, "tar files error")
+		tarW := &tarWriter{
+			Writer:   tar.NewWriter(compressW),
+			xattrs:   receiverInfo.Xattrs,
+			progress: tracker,
 		}
-		_ = tarW.Close()
-		_ = pipeW.Close()
+		var err error
+		if sync != nil {
+			err = c.tarSyncFiles(ctx, sync, tarW)
+		} else {
+			err = c.tarFiles(ctx, path, opts.Filter, tarW)
+		}
+		if err == nil {
+			err = tarW.Close()
+		}
+		if err == nil {
+			err = compressW.Close()
+		}
+		_ = pipeW.CloseWithError(err)
 	}()
 
 	// 转发到服务端
-	tmp := make([]byte, maxReadSize)
+	// 旧版本的接收端不解析帧类型，数据前不加帧类型
+	buf := make([]byte, 1+maxReadSize)
+	buf[0] = byte(transferFrameData)
 	for {
 		select {
 		case <-ctx.Done():
@@ -111,52 +189,125 @@ func (c *CopyFileClient) Send(ctx context.Context, stream *streamv1.Stream, path
 		default:
 		}
 
-		n, err := pipeR.Read(tmp)
+		n, err := pipeR.Read(buf[1:])
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
+			err = fmt.Errorf("tar files error: %w", err)
+			// 通知接收端终止传输
+			if !legacy {
+				_ = sendTransferFrame(ctx, conn, transferFrameError, []byte(err.Error()))
+			}
 			return err
 		}
-		if err := conn.Send(ctx, tmp[:n]); err != nil {
+		frame := buf[:1+n]
+		if legacy {
+			frame = buf[1 : 1+n]
+		}
+		if err := conn.Send(ctx, frame); err != nil {
 			return fmt.Errorf("send to server error: %w", err)
 		}
 	}
 
-	// 发送结束消息
-	if err := conn.Send(ctx, []byte(sendDoneMsg)); err != nil {
-		return fmt.Errorf("send to server error: %w", err)
+	if legacy {
+		return sendLegacyDone(ctx, conn, tracker)
+	}
+
+	// 发送结束帧
+	if err := sendTransferFrame(ctx, conn, transferFrameEOF, nil); err != nil {
+		return err
 	}
-	// 等待接收端结束
+	tracker.Stop(nil)
+	// 等待接收端解包完成
 	logger.Info("send completed, waiting for receiver...")
+	t, payload, err := receiveTransferFrame(ctx, conn)
+	if err != nil {
+		if errors.Is(err, streams.ErrPeerLeft) {
+			return fmt.Errorf("receiver left before receiving completed")
+		}
+		return fmt.Errorf("wait for receiver error: %w", err)
+	}
+	switch t {
+	case transferFrameEOF:
+	case transferFrameError:
+		return fmt.Errorf("receiver error: %s", payload)
+	default:
+		return fmt.Errorf("unexpected frame %q from receiver", t)
+	}
+	logger.Info("receive completed")
+
+	return nil
+}
+
+// waitForReceiver 等待接收端准备好接收
+//
+// 返回接收端发送的接收端信息，接收端是旧版本、发送 legacyStartRecvMsg 指令时返回 nil
+func waitForReceiver(ctx context.Context, conn streams.Connection) (*ReceiverInfo, error) {
+	logger := logr.FromContextOrDiscard(ctx)
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		msg, err := streams.ReceiveData(ctx, conn)
+		if err != nil {
+			if errors.Is(err, streams.ErrPeerLeft) {
+				return nil, fmt.Errorf("receiver left before transfer started")
+			}
+			return nil, fmt.Errorf("receive message error: %w", err)
+		}
+		if string(msg) == legacyStartRecvMsg {
+			return nil, nil
 		}
-		msg, err := conn.Receive(ctx)
+		info := &ReceiverInfo{}
+		ok, err := decodeTransferInfo(msg, info)
 		if err != nil {
-			if errors.Is(err, streams.ErrConnectionClosed) {
-				// 接收结束
+			return nil, err
+		}
+		if ok {
+			return info, nil
+		}
+		// 旧版本的接收端在开始指令之前可能发送旧格式的接收端信息，忽略
+		logger.V(1).Info(fmt.Sprintf("skip unexpected message from receiver: %q", msg))
+	}
+}
+
+// sendLegacyDone 向旧版本的接收端发送结束指令，并等待接收端接收完成
+func sendLegacyDone(ctx context.Context, conn streams.Connection, tracker *progress.Tracker) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if err := conn.Send(ctx, []byte(legacySendDoneMsg)); err != nil {
+		return fmt.Errorf("send to server error: %w", err)
+	}
+	tracker.Stop(nil)
+	logger.Info("send completed, waiting for receiver...")
+	for {
+		msg, err := streams.ReceiveData(ctx, conn)
+		if err != nil {
+			if errors.Is(err, streams.ErrPeerLeft) || errors.Is(err, streams.ErrConnectionClosed) {
+				// 旧版本的接收端接收完成后可能直接断开连接
 				break
 			}
-			return fmt.Errorf("receive message error: %w", err)
+			return fmt.Errorf("wait for receiver error: %w", err)
 		}
-		if len(msg) == len(recvDoneMsg) && string(msg) == recvDoneMsg {
-			// 接收结束
+		if string(msg) == legacyRecvDoneMsg {
 			break
 		}
 	}
 	logger.Info("receive completed")
-
 	return nil
 }
 
 // Receive 接收文件或目录
-func (c *CopyFileClient) Receive(ctx context.Context, stream *streamv1.Stream, path string) (string, error) {
+func (c *CopyFileClient) Receive(
+	ctx context.Context,
+	stream *streamv1.Stream,
+	path string,
+	opts ReceiveOptions,
+) (_ string, retErr error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
+	if err := opts.Overwrite.Validate(); err != nil {
+		return "", err
+	}
+
 	trimName := false
 	stat, err := os.Stat(path)
 	if err != nil {
@@ -169,6 +320,10 @@ func (c *CopyFileClient) Receive(ctx context.Context, stream *streamv1.Stream, p
 		// 是个文件
 		return "", fmt.Errorf("path %s is a file", path)
 	}
+	e, err := newExtractor(ctx, path, trimName, opts)
+	if err != nil {
+		return "", err
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -187,155 +342,233 @@ func (c *CopyFileClient) Receive(ctx context.Context, stream *streamv1.Stream, p
 		_ = conn.Close(ctx)
 	}()
 
-	// 发送开始传输指令
-	if err := conn.Send(ctx, []byte(startRecvMsg)); err != nil {
-		return "", fmt.Errorf("send start message error: %w", err)
+	// 发送接收端信息，发送端收到后开始传输
+	if err := sendTransferInfo(ctx, conn, &ReceiverInfo{
+		Compressions: SupportedCompressions,
+		Sync:         true,
+		Xattrs:       opts.PreserveXattrs,
+	}); err != nil {
+		return "", err
+	}
+
+	senderInfo := &SenderInfo{}
+	if err := receiveTransferInfo(ctx, conn, senderInfo); err != nil {
+		return "", err
+	}
+	if senderInfo.Name != "" {
+		if err := e.setName(senderInfo.Name); err != nil {
+			return "", err
+		}
+	}
+	if senderInfo.Sync != nil {
+		if err := c.prepareSyncReceive(ctx, conn, e, senderInfo.Sync); err != nil {
+			return "", err
+		}
+	}
+	// 发送端发送数据前发送文件总数和总字节数
+	totals := &TransferTotals{}
+	if err := receiveTransferInfo(ctx, conn, totals); err != nil {
+		return "", err
 	}
+	e.progress.SetTotal(totals.Bytes, totals.Files)
+	logger.Info(fmt.Sprintf("start receive (compression: %s)", senderInfo.Compression))
+
+	e.progress.Start(ctx)
+	defer func() {
+		e.progress.Stop(retErr)
+	}()
 
 	pipeR, pipeW := io.Pipe()
 	defer func() {
 		_ = pipeW.Close()
 	}()
 
-	// 解包文件
-	tarR := tar.NewReader(pipeR)
+	// 解压并解包文件
 	untarDone := make(chan struct{})
 	var untarErr error
 	untarTarget := ""
 	go func() {
-		untarTarget, untarErr = c.untarFiles(ctx, path, tarR, trimName)
-		_ = pipeR.Close()
-		close(untarDone)
-	}()
-
-	// 从服务端读
-	for {
-		select {
-		case <-ctx.Done():
-			return untarTarget, ctx.Err()
-		default:
-		}
-
-		data, err := conn.Receive(ctx)
+		defer close(untarDone)
+		r, err := NewDecompressReader(pipeR, senderInfo.Compression)
 		if err != nil {
-			return untarTarget, fmt.Errorf("receive from server error: %w", err)
-		}
-
-		if len(data) == len(sendDoneMsg) && string(data) == sendDoneMsg {
-			// 读完了
-			_ = pipeW.Close()
-			break
+			untarErr = err
+			_ = pipeR.CloseWithError(err)
+			return
 		}
-		if _, err := pipeW.Write(data); err != nil {
-			return untarTarget, err
+		untarTarget, untarErr = c.untarFiles(ctx, e, tar.NewReader(r))
+		if untarErr == nil {
+			// 读完 tar 结束标记之后的剩余数据（如压缩格式的校验和）
+			_, _ = io.Copy(io.Discard, r)
 		}
-	}
+		_ = r.Close()
+		_ = pipeR.Close()
+	}()
 
-	if err := conn.Send(ctx, []byte(recvDoneMsg)); err != nil {
-		logger.Error(err, "send to server error")
+	// 从服务端读，出错时通知发送端终止传输
+	if err := c.receiveTarData(ctx, conn, pipeW); err != nil {
+		_ = pipeW.CloseWithError(err)
+		<-untarDone
+		if untarErr != nil {
+			err = untarErr
+		}
+		_ = sendTransferFrame(ctx, conn, transferFrameError, []byte(err.Error()))
+		return untarTarget, err
 	}
+	_ = pipeW.Close()
 
-	// 等待解包完成
+	// 等待解包完成后通知发送端
 	<-untarDone
-
-	return untarTarget, untarErr
-}
-
-// tarFiles 打包文件
-func (c *CopyFileClient) tarFiles(ctx context.Context, root string, w *tar.Writer) error {
-	logger := logr.FromContextOrDiscard(ctx)
-
-	absRoot, err := filepath.Abs(root)
-	if err != nil {
-		return fmt.Errorf("get abs path for %q err: %v", root, err)
+	if untarErr != nil {
+		_ = sendTransferFrame(ctx, conn, transferFrameError, []byte(untarErr.Error()))
+		return untarTarget, untarErr
 	}
-	name := filepath.Base(absRoot)
-	if name == "/" {
-		name = "rootfs"
+	if err := sendTransferFrame(ctx, conn, transferFrameEOF, nil); err != nil {
+		logger.Error(err, "send to server error")
 	}
-	return filepath.Walk(absRoot, func(path string, info fs.FileInfo, err error) error {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
 
-		nameInTar := filepath.Join(name, strings.TrimPrefix(path, absRoot))
+	return untarTarget, nil
+}
 
-		var header *tar.Header
-		mode := info.Mode()
-		switch {
-		case mode.IsDir():
-			header = &tar.Header{
-				Typeflag: tar.TypeDir,
-				Name:     nameInTar,
-				Mode:     int64(mode.Perm()),
-			}
-			logger.V(1).Info(fmt.Sprintf("dir  %s (%s)", nameInTar, mode.Perm().String()))
-		case mode&fs.ModeSymlink != 0:
-			link, err := os.Readlink(path)
-			if err != nil {
-				return fmt.Errorf("read symlink %q err: %v", path, err)
-			}
-			header = &tar.Header{
-				Typeflag: tar.TypeSymlink,
-				Name:     nameInTar,
-				Linkname: link,
+// receiveTarData 接收发送端发送的数据写到 w ，直到收到结束帧
+func (c *CopyFileClient) receiveTarData(ctx context.Context, conn streams.Connection, w io.Writer) error {
+	for {
+		t, payload, err := receiveTransferFrame(ctx, conn)
+		if err != nil {
+			if errors.Is(err, streams.ErrPeerLeft) {
+				return fmt.Errorf("sender left before sending completed")
 			}
-			logger.V(1).Info(fmt.Sprintf("link %s -> %s", nameInTar, link))
-		case mode.IsRegular():
-			header = &tar.Header{
-				Typeflag: tar.TypeReg,
-				Name:     nameInTar,
-				Size:     info.Size(),
-				Mode:     int64(mode.Perm()),
+			return fmt.Errorf("receive from server error: %w", err)
+		}
+		switch t {
+		case transferFrameData:
+			if _, err := w.Write(payload); err != nil {
+				return err
 			}
-			logger.V(1).Info(fmt.Sprintf("file %s (%s)", nameInTar, mode.Perm().String()))
-		default:
-			logger.V(1).Info(fmt.Sprintf("skip %s", nameInTar))
+		case transferFrameEOF:
 			return nil
+		case transferFrameError:
+			return fmt.Errorf("sender error: %s", payload)
+		default:
+			return fmt.Errorf("unexpected frame %q from sender", t)
 		}
+	}
+}
 
-		// 写文件头
-		if err := w.WriteHeader(header); err != nil {
-			return fmt.Errorf("write header %q to tar error: %w", nameInTar, err)
-		}
-
-		if !mode.IsRegular() {
-			return nil
-		}
+// tarWriter 打包文件的 tar.Writer
+type tarWriter struct {
+	*tar.Writer
+	// 是否写入扩展属性
+	xattrs bool
+	// 发送进度
+	progress *progress.Tracker
+}
 
-		// 写文件
-		f, err := os.Open(path)
+// header 返回文件的 tar 文件头
+func (w *tarWriter) header(entry FileEntry) (*tar.Header, error) {
+	header := &tar.Header{
+		Name: entry.Name,
+		Mode: int64(entry.Mode),
+		// tar 格式的修改时间精度为秒
+		ModTime: entry.ModTime.Truncate(time.Second),
+		Uid:     entry.Uid,
+		Gid:     entry.Gid,
+	}
+	switch entry.Type {
+	case FileTypeDir:
+		header.Typeflag = tar.TypeDir
+	case FileTypeSymlink:
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = entry.Linkname
+	case FileTypeFile:
+		header.Typeflag = tar.TypeReg
+		header.Size = entry.Size
+	}
+	if w.xattrs {
+		xattrs, err := readXattrs(entry.Path)
 		if err != nil {
-			return fmt.Errorf("open file %q error: %w", path, err)
+			return nil, fmt.Errorf("read xattrs of %q error: %w", entry.Path, err)
 		}
-		defer func() {
-			_ = f.Close()
-		}()
-		if _, err := io.Copy(w, f); err != nil {
-			return fmt.Errorf("write file %q to %q in tar error: %w", path, nameInTar, err)
+		for k, v := range xattrs {
+			if header.PAXRecords == nil {
+				header.PAXRecords = map[string]string{}
+			}
+			header.PAXRecords[paxXattrPrefix+k] = v
 		}
-		return nil
+	}
+	return header, nil
+}
+
+// tarFiles 打包文件
+func (c *CopyFileClient) tarFiles(ctx context.Context, root string, filter FilterOptions, w *tarWriter) error {
+	return WalkFiles(ctx, root, filter, func(entry FileEntry) error {
+		return c.writeTarEntry(ctx, w, entry)
 	})
 }
 
-// untarFiles 解包文件
-func (c *CopyFileClient) untarFiles(ctx context.Context, root string, r *tar.Reader, trimName bool) (string, error) {
+// writeTarEntry 将文件写到 tar
+func (c *CopyFileClient) writeTarEntry(ctx context.Context, w *tarWriter, entry FileEntry) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
-	absRoot, err := filepath.Abs(root)
+	header, err := w.header(entry)
 	if err != nil {
-		return "", fmt.Errorf("get abs path for %q err: %v", root, err)
+		return err
+	}
+	switch entry.Type {
+	case FileTypeDir:
+		logger.V(1).Info(fmt.Sprintf("dir  %s (%s)", entry.Name, entry.Mode))
+	case FileTypeSymlink:
+		logger.V(1).Info(fmt.Sprintf("link %s -> %s", entry.Name, entry.Linkname))
+	case FileTypeFile:
+		logger.V(1).Info(fmt.Sprintf("file %s (%s)", entry.Name, entry.Mode))
 	}
 
-	first := true
-	name := ""
-	target := ""
+	// 写文件头
+	if err := w.WriteHeader(header); err != nil {
+		return fmt.Errorf("write header %q to tar error: %w", entry.Name, err)
+	}
+
+	if entry.Type == FileTypeDir {
+		return nil
+	}
+	w.progress.StartFile(entry.Name)
+	defer w.progress.FinishFile()
+	if entry.Type != FileTypeFile {
+		return nil
+	}
+
+	// 写文件
+	f, err := os.Open(entry.Path)
+	if err != nil {
+		return fmt.Errorf("open file %q error: %w", entry.Path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	// 文件在遍历后可能发生变化，只写入文件头中声明的大小
+	if _, err := io.CopyN(w, w.progress.Reader(f), entry.Size); err != nil {
+		return fmt.Errorf("write file %q to %q in tar error: %w", entry.Path, entry.Name, err)
+	}
+	return nil
+}
+
+// countFiles 统计要发送的文件总数和总字节数
+func countFiles(ctx context.Context, root string, filter FilterOptions) (*TransferTotals, error) {
+	totals := &TransferTotals{}
+	if err := WalkFiles(ctx, root, filter, func(entry FileEntry) error {
+		totals.add(entry)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// untarFiles 解包文件，返回接收的文件或目录的路径
+func (c *CopyFileClient) untarFiles(ctx context.Context, e *extractor, r *tar.Reader) (string, error) {
 	for {
 		select {
 		case <-ctx.Done():
-			return target, ctx.Err()
+			return e.target, ctx.Err()
 		default:
 		}
 
@@ -344,50 +577,12 @@ func (c *CopyFileClient) untarFiles(ctx context.Context, root string, r *tar.Rea
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			return target, err
+			return e.target, err
 		}
-		if first {
-			name = hdr.Name
-		}
-		var path string
-		if trimName {
-			path = filepath.Join(absRoot, strings.TrimPrefix(hdr.Name, name))
-		} else {
-			path = filepath.Join(absRoot, hdr.Name)
-		}
-		if first {
-			first = false
-			target = path
-		}
-
-		switch hdr.Typeflag {
-		case tar.TypeDir:
-			logger.V(1).Info(fmt.Sprintf("dir  %s (%s)", path, hdr.FileInfo().Mode()))
-			if err := os.MkdirAll(path, hdr.FileInfo().Mode()); err != nil {
-				return target, fmt.Errorf("mkdir %q error: %w", path, err)
-			}
-		case tar.TypeSymlink:
-			logger.V(1).Info(fmt.Sprintf("link %s -> %s", path, hdr.Linkname))
-			if err := os.Symlink(path, hdr.Linkname); err != nil {
-				return target, fmt.Errorf("symlink %q error: %w", path, err)
-			}
-		case tar.TypeReg:
-			logger.V(1).Info(fmt.Sprintf("file %s (%s)", path, hdr.FileInfo().Mode()))
-			f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, hdr.FileInfo().Mode())
-			if err != nil {
-				_ = f.Close()
-				return target, fmt.Errorf("open file %q error: %w", path, err)
-			}
-			if _, err := io.Copy(f, r); err != nil {
-				_ = f.Close()
-				return target, fmt.Errorf("write file %q error: %w", path, err)
-			}
-			_ = f.Close()
-		default:
-			logger.V(1).Info(fmt.Sprintf("skip %s", hdr.Name))
-			continue
+		if err := e.extract(hdr, r); err != nil {
+			return e.target, err
 		}
 	}
 
-	return target, nil
+	return e.target, e.finish()
 }
@@ -0,0 +1,278 @@
+package cp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	// DefaultDeltaMinSize 默认使用块差异传输的最小文件大小
+	DefaultDeltaMinSize = 1 << 20
+
+	minDeltaBlockSize = 2 << 10
+	maxDeltaBlockSize = 128 << 10
+	// 差异中一段字面数据的最大长度
+	maxDeltaLiteralSize = 1 << 20
+	strongChecksumSize  = 16
+
+	deltaOpCopy    byte = 'C'
+	deltaOpLiteral byte = 'L'
+)
+
+// FileSignature 文件的块签名
+type FileSignature struct {
+	// 文件大小
+	Size int64 `json:"size"`
+	// 块大小
+	BlockSize int `json:"blockSize"`
+	// 每个块的签名，最后一个块可能小于块大小
+	Blocks []BlockSignature `json:"blocks,omitempty"`
+}
+
+// BlockSignature 块签名
+type BlockSignature struct {
+	// 弱校验和（滚动校验和）
+	Weak uint32 `json:"weak"`
+	// 强校验和
+	Strong []byte `json:"strong"`
+}
+
+// deltaBlockSize 返回大小为 size 的文件使用的块大小
+//
+// 与 rsync 相同，块大小约为文件大小的平方根
+func deltaBlockSize(size int64) int {
+	blockSize := int(math.Sqrt(float64(size)))
+	blockSize = (blockSize + 1023) &^ 1023
+	return min(max(blockSize, minDeltaBlockSize), maxDeltaBlockSize)
+}
+
+// computeSignature 计算文件的块签名
+func computeSignature(r io.Reader, size int64) (*FileSignature, error) {
+	sig := &FileSignature{BlockSize: deltaBlockSize(size)}
+	buf := make([]byte, sig.BlockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		sig.Size += int64(n)
+		if n > 0 {
+			sig.Blocks = append(sig.Blocks, BlockSignature{
+				Weak:   weakChecksum(buf[:n]),
+				Strong: strongChecksum(buf[:n]),
+			})
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return sig, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// weakChecksum 计算数据的弱校验和（ rsync 的滚动校验和）
+func weakChecksum(data []byte) uint32 {
+	var a, b uint32
+	l := uint32(len(data))
+	for i, c := range data {
+		a += uint32(c)
+		b += (l - uint32(i)) * uint32(c)
+	}
+	return a&0xffff | b<<16
+}
+
+// rollChecksum 窗口大小为 blockSize 的弱校验和从窗口中移出 out 并移入 in 后的值
+func rollChecksum(sum uint32, blockSize int, out, in byte) uint32 {
+	a := sum & 0xffff
+	b := sum >> 16
+	a = (a - uint32(out) + uint32(in)) & 0xffff
+	b = (b - uint32(blockSize)*uint32(out) + a) & 0xffff
+	return a | b<<16
+}
+
+// strongChecksum 计算数据的强校验和
+func strongChecksum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:strongChecksumSize]
+}
+
+// writeDelta 根据基础文件的签名计算新文件 r 相对基础文件的差异，写到 w
+//
+// 差异由一系列操作组成：
+// 复制基础文件的块 C <uvarint 起始块序号> <uvarint 块数> ，写字面数据 L <uvarint 长度> <数据>
+func writeDelta(w io.Writer, r io.Reader, sig *FileSignature) error {
+	blockSize := sig.BlockSize
+	blocks := make(map[uint32][]int, len(sig.Blocks))
+	for i, block := range sig.Blocks {
+		// 只匹配完整的块
+		if int64(i+1)*int64(blockSize) > sig.Size {
+			break
+		}
+		blocks[block.Weak] = append(blocks[block.Weak], i)
+	}
+
+	bw := bufio.NewWriter(w)
+	tmp := make([]byte, binary.MaxVarintLen64)
+	copyStart, copyCount := -1, 0
+	flushCopy := func() error {
+		if copyCount == 0 {
+			return nil
+		}
+		_ = bw.WriteByte(deltaOpCopy)
+		_, _ = bw.Write(tmp[:binary.PutUvarint(tmp, uint64(copyStart))])
+		_, err := bw.Write(tmp[:binary.PutUvarint(tmp, uint64(copyCount))])
+		copyStart, copyCount = -1, 0
+		return err
+	}
+	writeCopy := func(i int) error {
+		if copyCount > 0 && copyStart+copyCount == i {
+			copyCount++
+			return nil
+		}
+		if err := flushCopy(); err != nil {
+			return err
+		}
+		copyStart, copyCount = i, 1
+		return nil
+	}
+	writeLiteral := func(data []byte) error {
+		if len(data) == 0 {
+			return nil
+		}
+		if err := flushCopy(); err != nil {
+			return err
+		}
+		_ = bw.WriteByte(deltaOpLiteral)
+		_, _ = bw.Write(tmp[:binary.PutUvarint(tmp, uint64(len(data)))])
+		_, err := bw.Write(data)
+		return err
+	}
+
+	// buf[:p] 是还未写入的字面数据， buf[p:p+blockSize] 是当前窗口
+	// 字面数据达到 maxDeltaLiteralSize 时写出，因此 buf 不会超过容量
+	buf := make([]byte, 0, maxDeltaLiteralSize+2*blockSize)
+	eof := false
+	fill := func(n int) error {
+		for len(buf) < n && !eof {
+			m, err := r.Read(buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+m]
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					eof = true
+					break
+				}
+				return err
+			}
+		}
+		return nil
+	}
+
+	p := 0
+	var sum uint32
+	sumValid := false
+	for {
+		if err := fill(p + blockSize + 1); err != nil {
+			return err
+		}
+		if len(buf)-p < blockSize {
+			// 剩余的数据不足一个块
+			break
+		}
+
+		window := buf[p : p+blockSize]
+		if !sumValid {
+			sum = weakChecksum(window)
+			sumValid = true
+		}
+		matched := -1
+		if candidates, ok := blocks[sum]; ok {
+			strong := strongChecksum(window)
+			for _, i := range candidates {
+				if bytes.Equal(sig.Blocks[i].Strong, strong) {
+					matched = i
+					break
+				}
+			}
+		}
+		if matched >= 0 {
+			if err := writeLiteral(buf[:p]); err != nil {
+				return err
+			}
+			if err := writeCopy(matched); err != nil {
+				return err
+			}
+			buf = buf[:copy(buf, buf[p+blockSize:])]
+			p = 0
+			sumValid = false
+			continue
+		}
+
+		if p+blockSize < len(buf) {
+			sum = rollChecksum(sum, blockSize, buf[p], buf[p+blockSize])
+		} else {
+			sumValid = false
+		}
+		p++
+		if p >= maxDeltaLiteralSize {
+			if err := writeLiteral(buf[:p]); err != nil {
+				return err
+			}
+			buf = buf[:copy(buf, buf[p:])]
+			p = 0
+		}
+	}
+	if err := writeLiteral(buf); err != nil {
+		return err
+	}
+	if err := flushCopy(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// applyDelta 将差异 delta 应用到基础文件 basis ，结果写到 w
+func applyDelta(w io.Writer, basis io.ReaderAt, blockSize int, delta io.Reader) error {
+	r := bufio.NewReader(delta)
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		switch op {
+		case deltaOpCopy:
+			start, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("read delta copy op error: %w", err)
+			}
+			count, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("read delta copy op error: %w", err)
+			}
+			section := io.NewSectionReader(basis, int64(start)*int64(blockSize), int64(count)*int64(blockSize))
+			n, err := io.Copy(w, section)
+			if err != nil {
+				return fmt.Errorf("copy blocks from basis file error: %w", err)
+			}
+			if n != int64(count)*int64(blockSize) {
+				return fmt.Errorf("copy blocks from basis file error: blocks %d-%d out of range", start, start+count)
+			}
+		case deltaOpLiteral:
+			n, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("read delta literal op error: %w", err)
+			}
+			if _, err := io.CopyN(w, r, int64(n)); err != nil {
+				return fmt.Errorf("read delta literal error: %w", err)
+			}
+		default:
+			return fmt.Errorf("invalid delta op %q", op)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package cp
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/yhlooo/scaf/pkg/utils/progress"
+)
+
+// paxXattrPrefix 记录扩展属性的 PAX 记录的前缀
+const paxXattrPrefix = "SCHILY.xattr."
+
+// OverwritePolicy 接收端已有文件的覆盖策略
+type OverwritePolicy string
+
+const (
+	// OverwriteNever 不覆盖已有文件
+	OverwriteNever OverwritePolicy = "never"
+	// OverwriteNewer 只在接收的文件比已有文件新时覆盖
+	OverwriteNewer OverwritePolicy = "newer"
+	// OverwriteAlways 总是覆盖已有文件
+	OverwriteAlways OverwritePolicy = "always"
+)
+
+// Validate 校验覆盖策略
+func (p OverwritePolicy) Validate() error {
+	switch p {
+	case "", OverwriteNever, OverwriteNewer, OverwriteAlways:
+		return nil
+	default:
+		return fmt.Errorf("unsupported overwrite policy %q, must be one of never, newer or always", p)
+	}
+}
+
+// ReceiveOptions 接收选项
+//
+// 接收时总是拒绝写到接收的目录之外的文件，符号链接默认只能指向接收的目录之内
+type ReceiveOptions struct {
+	// 已有文件的覆盖策略，默认总是覆盖
+	Overwrite OverwritePolicy
+	// 允许符号链接指向接收的目录之外（包括绝对路径）
+	AllowExternalSymlinks bool
+	// 保留文件所有者，通常需要 root 权限
+	PreserveOwner bool
+	// 保留文件扩展属性
+	PreserveXattrs bool
+	// 处理接收进度，为空时不报告进度
+	Progress progress.Handler
+}
+
+// extractor 将 tar 中的文件安全地解包到目标路径
+type extractor struct {
+	logger logr.Logger
+	opts   ReceiveOptions
+
+	// 接收到的路径
+	root string
+	// 是否使用 root 作为接收的文件或目录的路径，否则接收到 root 下的同名文件或目录
+	trimName bool
+	// 发送的文件或目录名
+	name string
+	// 接收的文件或目录的路径
+	target string
+	// target 解析符号链接后的路径
+	realTarget string
+
+	// 已创建的目录，解包完成后设置元数据
+	dirs []*tar.Header
+	// 因覆盖策略跳过的文件数量
+	skipped int
+	// 接收进度
+	progress *progress.Tracker
+	// 是否已输出过设置所有者或扩展属性失败的警告
+	ownerWarned  bool
+	xattrsWarned bool
+}
+
+// newExtractor 创建 extractor
+func newExtractor(ctx context.Context, root string, trimName bool, opts ReceiveOptions) (*extractor, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("get abs path for %q err: %v", root, err)
+	}
+	if opts.Overwrite == "" {
+		opts.Overwrite = OverwriteAlways
+	}
+	return &extractor{
+		logger:   logr.FromContextOrDiscard(ctx),
+		opts:     opts,
+		root:     absRoot,
+		trimName: trimName,
+		progress: progress.NewTracker(opts.Progress),
+	}, nil
+}
+
+// setName 设置发送的文件或目录名
+func (e *extractor) setName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid name %q", name)
+	}
+	e.name = name
+	e.target = e.root
+	if !e.trimName {
+		e.target = filepath.Join(e.root, name)
+	}
+	return nil
+}
+
+// resolve 返回相对接收的文件或目录的路径 rel 对应的本地路径
+//
+// 拒绝指向接收的文件或目录之外的路径，包括通过已存在的符号链接指向之外的路径
+func (e *extractor) resolve(rel string) (string, error) {
+	if rel == "" {
+		return e.target, nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(rel)) || path.Clean(rel) != rel {
+		return "", fmt.Errorf("invalid path %q: outside of %q", rel, e.target)
+	}
+	p := filepath.Join(e.target, filepath.FromSlash(rel))
+
+	// 找到最深的已存在的父目录，检查其解析符号链接后是否仍在目标路径之内
+	dir := filepath.Dir(p)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		if dir == e.target {
+			// 目标路径还不存在
+			return p, nil
+		}
+		dir = filepath.Dir(dir)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if e.realTarget == "" {
+		if e.realTarget, err = filepath.EvalSymlinks(e.target); err != nil {
+			return "", err
+		}
+	}
+	if realDir != e.realTarget && !strings.HasPrefix(realDir, e.realTarget+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %q: outside of %q through symlink", rel, e.target)
+	}
+	return p, nil
+}
+
+// extract 解包一个文件
+func (e *extractor) extract(hdr *tar.Header, r io.Reader) error {
+	name, rel, _ := strings.Cut(strings.TrimSuffix(hdr.Name, "/"), "/")
+	if e.name == "" {
+		if err := e.setName(name); err != nil {
+			return err
+		}
+	}
+	if name != e.name {
+		return fmt.Errorf("invalid path %q: not in %q", hdr.Name, e.name)
+	}
+	p, err := e.resolve(rel)
+	if err != nil {
+		return err
+	}
+	if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeReg {
+		e.progress.StartFile(strings.TrimSuffix(hdr.Name, "/"))
+		defer e.progress.FinishFile()
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		e.logger.V(1).Info(fmt.Sprintf("dir  %s (%s)", p, hdr.FileInfo().Mode()))
+		return e.extractDir(p, hdr)
+	case tar.TypeSymlink:
+		e.logger.V(1).Info(fmt.Sprintf("link %s -> %s", p, hdr.Linkname))
+		return e.extractSymlink(p, rel, hdr)
+	case tar.TypeReg:
+		if _, ok := hdr.PAXRecords[paxDeltaSHA256]; ok {
+			// 同步模式下相对已有文件的差异
+			e.logger.V(1).Info(fmt.Sprintf("delta %s (%s)", p, hdr.FileInfo().Mode()))
+			return e.extractDelta(p, hdr, r)
+		}
+		e.logger.V(1).Info(fmt.Sprintf("file %s (%s)", p, hdr.FileInfo().Mode()))
+		return e.extractFile(p, hdr, r)
+	default:
+		e.logger.V(1).Info(fmt.Sprintf("skip %s", hdr.Name))
+		return nil
+	}
+}
+
+// extractDir 创建目录，权限和修改时间在解包完成后设置
+func (e *extractor) extractDir(p string, hdr *tar.Header) error {
+	info, err := os.Lstat(p)
+	switch {
+	case err == nil && info.IsDir():
+		if e.opts.Overwrite != OverwriteAlways {
+			// 不修改已有目录的元数据
+			return nil
+		}
+	case err == nil:
+		if e.opts.Overwrite != OverwriteAlways {
+			return fmt.Errorf("%q already exists and is not a directory", p)
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("remove %q error: %w", p, err)
+		}
+		fallthrough
+	case os.IsNotExist(err):
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			return fmt.Errorf("mkdir %q error: %w", p, err)
+		}
+	default:
+		return err
+	}
+	e.dirs = append(e.dirs, hdr)
+	return nil
+}
+
+// extractSymlink 创建符号链接
+func (e *extractor) extractSymlink(p, rel string, hdr *tar.Header) error {
+	if !e.opts.AllowExternalSymlinks && !symlinkInside(rel, hdr.Linkname) {
+		e.logger.Info(fmt.Sprintf("WARN skip symlink %s -> %s: target is outside of %s", p, hdr.Linkname, e.target))
+		return nil
+	}
+	ok, err := e.shouldOverwrite(p, hdr)
+	if err != nil || !ok {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return fmt.Errorf("mkdir %q error: %w", filepath.Dir(p), err)
+	}
+
+	// 先创建临时的符号链接再重命名，原子地替换已有文件
+	tmp := fmt.Sprintf("%s.scaf-%s", p, strconv.FormatInt(time.Now().UnixNano(), 36))
+	if err := os.Symlink(hdr.Linkname, tmp); err != nil {
+		return fmt.Errorf("symlink %q error: %w", p, err)
+	}
+	e.setOwner(tmp, hdr)
+	if err := os.Rename(tmp, p); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("rename %q to %q error: %w", tmp, p, err)
+	}
+	return nil
+}
+
+// extractFile 写普通文件
+func (e *extractor) extractFile(p string, hdr *tar.Header, r io.Reader) error {
+	ok, err := e.shouldOverwrite(p, hdr)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// 跳过的文件也计入进度
+		e.progress.AddBytes(hdr.Size)
+		return nil
+	}
+	return e.writeFile(p, hdr, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
+
+// extractDelta 将差异应用到已有文件
+func (e *extractor) extractDelta(p string, hdr *tar.Header, r io.Reader) error {
+	blockSize, err := strconv.Atoi(hdr.PAXRecords[paxDeltaBlockSize])
+	if err != nil || blockSize <= 0 {
+		return fmt.Errorf("invalid delta block size %q", hdr.PAXRecords[paxDeltaBlockSize])
+	}
+	ok, err := e.shouldOverwrite(p, hdr)
+	if err != nil || !ok {
+		return err
+	}
+
+	basis, err := os.Open(p)
+	if err != nil {
+		return fmt.Errorf("open basis file %q error: %w", p, err)
+	}
+	defer func() {
+		_ = basis.Close()
+	}()
+	return e.writeFile(p, hdr, func(w io.Writer) error {
+		h := sha256.New()
+		if err := applyDelta(io.MultiWriter(w, h), basis, blockSize, r); err != nil {
+			return err
+		}
+		if hex.EncodeToString(h.Sum(nil)) != hdr.PAXRecords[paxDeltaSHA256] {
+			return fmt.Errorf("checksum mismatch")
+		}
+		return nil
+	})
+}
+
+// writeFile 先写到同目录下的临时文件，设置元数据后重命名为 p
+func (e *extractor) writeFile(p string, hdr *tar.Header, write func(w io.Writer) error) error {
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("mkdir %q error: %w", dir, err)
+	}
+	if info, err := os.Lstat(p); err == nil && info.IsDir() {
+		// 已有同名目录
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("remove %q error: %w", p, err)
+		}
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(p)+".scaf-*")
+	if err != nil {
+		return fmt.Errorf("create temp file for %q error: %w", p, err)
+	}
+	renamed := false
+	defer func() {
+		_ = f.Close()
+		if !renamed {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err := write(e.progress.Writer(f)); err != nil {
+		return fmt.Errorf("write file %q error: %w", p, err)
+	}
+	if err := f.Chmod(hdr.FileInfo().Mode().Perm()); err != nil {
+		return fmt.Errorf("chmod %q error: %w", p, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write file %q error: %w", p, err)
+	}
+	e.setOwner(f.Name(), hdr)
+	e.setXattrs(f.Name(), hdr)
+	if err := os.Chtimes(f.Name(), hdr.ModTime, hdr.ModTime); err != nil {
+		return fmt.Errorf("set mtime of %q error: %w", p, err)
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return fmt.Errorf("rename %q to %q error: %w", f.Name(), p, err)
+	}
+	renamed = true
+	return nil
+}
+
+// shouldOverwrite 根据覆盖策略返回是否写文件 p
+func (e *extractor) shouldOverwrite(p string, hdr *tar.Header) (bool, error) {
+	info, err := os.Lstat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	overwrite := false
+	switch e.opts.Overwrite {
+	case OverwriteAlways:
+		overwrite = true
+	case OverwriteNewer:
+		overwrite = hdr.ModTime.After(info.ModTime())
+	}
+	if !overwrite {
+		e.logger.V(1).Info(fmt.Sprintf("skip existing %s", p))
+		e.skipped++
+	}
+	return overwrite, nil
+}
+
+// setOwner 设置文件所有者
+func (e *extractor) setOwner(p string, hdr *tar.Header) {
+	if !e.opts.PreserveOwner {
+		return
+	}
+	if err := os.Lchown(p, hdr.Uid, hdr.Gid); err != nil && !e.ownerWarned {
+		e.ownerWarned = true
+		e.logger.Info(fmt.Sprintf("WARN set owner of %s error: %v", p, err))
+	}
+}
+
+// setXattrs 设置文件扩展属性
+func (e *extractor) setXattrs(p string, hdr *tar.Header) {
+	if !e.opts.PreserveXattrs {
+		return
+	}
+	xattrs := map[string]string{}
+	for k, v := range hdr.PAXRecords {
+		if strings.HasPrefix(k, paxXattrPrefix) {
+			xattrs[strings.TrimPrefix(k, paxXattrPrefix)] = v
+		}
+	}
+	if err := writeXattrs(p, xattrs); err != nil && !e.xattrsWarned {
+		e.xattrsWarned = true
+		e.logger.Info(fmt.Sprintf("WARN set xattrs of %s error: %v", p, err))
+	}
+}
+
+// finish 解包完成后设置目录的元数据
+func (e *extractor) finish() error {
+	var errs []error
+	// 从深到浅设置，避免设置子目录时修改父目录的修改时间
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		hdr := e.dirs[i]
+		_, rel, _ := strings.Cut(strings.TrimSuffix(hdr.Name, "/"), "/")
+		p, err := e.resolve(rel)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		e.setOwner(p, hdr)
+		e.setXattrs(p, hdr)
+		if err := os.Chmod(p, hdr.FileInfo().Mode().Perm()); err != nil {
+			errs = append(errs, fmt.Errorf("chmod %q error: %w", p, err))
+		}
+		if err := os.Chtimes(p, hdr.ModTime, hdr.ModTime); err != nil {
+			errs = append(errs, fmt.Errorf("set mtime of %q error: %w", p, err))
+		}
+	}
+	if e.skipped > 0 {
+		e.logger.Info(fmt.Sprintf("WARN skipped %d existing files (overwrite policy: %s)", e.skipped, e.opts.Overwrite))
+	}
+	return errors.Join(errs...)
+}
+
+// symlinkInside 返回位于 rel 的指向 linkname 的符号链接是否指向接收的目录之内
+func symlinkInside(rel, linkname string) bool {
+	if rel == "" || linkname == "" || path.IsAbs(linkname) || filepath.IsAbs(linkname) {
+		return false
+	}
+	resolved := path.Join(path.Dir(rel), filepath.ToSlash(linkname))
+	return resolved == "." || filepath.IsLocal(filepath.FromSlash(resolved))
+}
@@ -0,0 +1,129 @@
+package cp
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testTarEntry 测试用的 tar 中的文件
+type testTarEntry struct {
+	name     string
+	typ      byte
+	content  string
+	linkname string
+	mtime    time.Time
+}
+
+// extractTestTar 解包由 entries 组成的 tar 到 root
+func extractTestTar(root string, opts ReceiveOptions, entries ...testTarEntry) error {
+	buf := &bytes.Buffer{}
+	w := tar.NewWriter(buf)
+	for _, entry := range entries {
+		hdr := &tar.Header{
+			Typeflag: entry.typ,
+			Name:     entry.name,
+			Linkname: entry.linkname,
+			Mode:     0o640,
+			Size:     int64(len(entry.content)),
+			ModTime:  entry.mtime,
+		}
+		if entry.typ == tar.TypeDir {
+			hdr.Mode = 0o750
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(entry.content)); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	e, err := newExtractor(context.Background(), root, false, opts)
+	if err != nil {
+		return err
+	}
+	_, err = (&CopyFileClient{}).untarFiles(context.Background(), e, tar.NewReader(buf))
+	return err
+}
+
+// TestExtract 测试安全地解包文件
+func TestExtract(t *testing.T) {
+	a := assert.New(t)
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	dir := testTarEntry{name: "d/", typ: tar.TypeDir, mtime: mtime}
+
+	// 路径不能在接收的目录之外
+	for _, name := range []string{"d/../../evil", "/evil", "evil"} {
+		a.Error(extractTestTar(root, ReceiveOptions{}, dir, testTarEntry{name: name, typ: tar.TypeReg}), name)
+	}
+	a.NoFileExists(filepath.Join(filepath.Dir(root), "evil"))
+
+	// 指向接收的目录之外的符号链接被跳过，不能通过已有的符号链接写到接收的目录之外
+	a.NoError(extractTestTar(root, ReceiveOptions{}, dir,
+		testTarEntry{name: "d/in", typ: tar.TypeSymlink, linkname: "sub/../f"},
+		testTarEntry{name: "d/out", typ: tar.TypeSymlink, linkname: "../../x"},
+		testTarEntry{name: "d/abs", typ: tar.TypeSymlink, linkname: outside},
+	))
+	link, err := os.Readlink(filepath.Join(root, "d", "in"))
+	a.NoError(err)
+	a.Equal("sub/../f", link)
+	a.NoFileExists(filepath.Join(root, "d", "out"))
+	a.NoFileExists(filepath.Join(root, "d", "abs"))
+	a.NoError(os.Symlink(outside, filepath.Join(root, "d", "abs")))
+	a.Error(extractTestTar(root, ReceiveOptions{}, dir, testTarEntry{name: "d/abs/f", typ: tar.TypeReg}))
+	a.NoFileExists(filepath.Join(outside, "f"))
+
+	// 覆盖已有文件时不保留旧内容，保留修改时间和权限
+	f := filepath.Join(root, "d", "f")
+	a.NoError(extractTestTar(root, ReceiveOptions{}, dir,
+		testTarEntry{name: "d/f", typ: tar.TypeReg, content: "long content", mtime: mtime}))
+	a.NoError(extractTestTar(root, ReceiveOptions{}, dir,
+		testTarEntry{name: "d/f", typ: tar.TypeReg, content: "short", mtime: mtime}))
+	content, _ := os.ReadFile(f)
+	a.Equal("short", string(content))
+	info, err := os.Stat(f)
+	if a.NoError(err) {
+		a.Equal(os.FileMode(0o640), info.Mode().Perm())
+		a.True(info.ModTime().Equal(mtime))
+	}
+	info, err = os.Stat(filepath.Join(root, "d"))
+	if a.NoError(err) {
+		a.Equal(os.FileMode(0o750), info.Mode().Perm())
+		a.True(info.ModTime().Equal(mtime))
+	}
+
+	// 覆盖策略
+	for _, c := range []struct {
+		policy OverwritePolicy
+		mtime  time.Time
+		expect string
+	}{
+		{policy: OverwriteNever, mtime: mtime.Add(time.Hour), expect: "short"},
+		{policy: OverwriteNewer, mtime: mtime.Add(-time.Hour), expect: "short"},
+		{policy: OverwriteNewer, mtime: mtime.Add(time.Hour), expect: "newer"},
+		{policy: OverwriteAlways, mtime: mtime, expect: "always"},
+	} {
+		a.NoError(extractTestTar(root, ReceiveOptions{Overwrite: c.policy}, dir,
+			testTarEntry{name: "d/f", typ: tar.TypeReg, content: string(c.policy), mtime: c.mtime}))
+		content, _ := os.ReadFile(f)
+		a.Equal(c.expect, string(content), c.policy)
+	}
+
+	// 不留下临时文件
+	entries, err := os.ReadDir(filepath.Join(root, "d"))
+	a.NoError(err)
+	a.Len(entries, 3)
+}
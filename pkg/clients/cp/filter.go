@@ -0,0 +1,227 @@
+package cp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ScafIgnoreFile 默认读取的忽略文件名
+const ScafIgnoreFile = ".scafignore"
+
+// FilterOptions 发送文件时的过滤选项
+//
+// 模式的语法与 .gitignore 相同，匹配相对发送的根目录的路径（使用 / 分隔）。
+// 不包含 / 的模式匹配任意层级的文件名，以 / 结尾的模式只匹配目录，支持 * 、 ? 、 [...] 和 ** 。
+type FilterOptions struct {
+	// 排除匹配这些模式的文件或目录，在忽略文件之后生效
+	Excludes []string
+	// 不为空时只发送匹配这些模式的文件或匹配的目录中的文件
+	Includes []string
+	// 读取每个目录下这些名字的忽略文件（如 .gitignore 、 .scafignore ），排除匹配其中模式的文件或目录
+	IgnoreFiles []string
+}
+
+// pattern 路径匹配模式
+type pattern struct {
+	// 是否是取反的模式（以 ! 开头）
+	negate bool
+	// 是否只匹配目录（以 / 结尾）
+	dirOnly bool
+	// 模式所在的目录，相对于根目录，命令行指定的模式为空
+	base string
+	re   *regexp.Regexp
+}
+
+// parsePattern 解析 .gitignore 语法的一行模式，空行和注释返回 nil
+func parsePattern(base, line string) (*pattern, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	p := &pattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// \! 或 \# 开头表示字面量
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	// 模式中间包含 / 时相对于模式所在目录匹配，否则匹配任意层级
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return nil, nil
+	}
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", line, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// globToRegexp 将 glob 模式转换为正则表达式
+func globToRegexp(glob string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			// 匹配零或多层目录
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match 返回路径是否匹配模式
+func (p *pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+		rel = rel[len(p.base)+1:]
+	}
+	return p.re.MatchString(rel)
+}
+
+// fileFilter 文件过滤器
+type fileFilter struct {
+	ignoreFiles []string
+	// 从忽略文件读取的模式，按目录从浅到深的顺序
+	ignores  []*pattern
+	excludes []*pattern
+	includes []*pattern
+}
+
+// newFileFilter 创建文件过滤器
+func newFileFilter(opts FilterOptions) (*fileFilter, error) {
+	f := &fileFilter{ignoreFiles: opts.IgnoreFiles}
+	var err error
+	if f.excludes, err = parsePatterns(opts.Excludes); err != nil {
+		return nil, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	if f.includes, err = parsePatterns(opts.Includes); err != nil {
+		return nil, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	return f, nil
+}
+
+// parsePatterns 解析命令行指定的模式
+func parsePatterns(lines []string) ([]*pattern, error) {
+	var ret []*pattern
+	for _, line := range lines {
+		p, err := parsePattern("", line)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+// loadIgnoreFiles 读取目录 dir 下的忽略文件， rel 是目录相对根目录的路径
+func (f *fileFilter) loadIgnoreFiles(dir, rel string) error {
+	for _, name := range f.ignoreFiles {
+		file, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			p, err := parsePattern(rel, scanner.Text())
+			if err != nil {
+				_ = file.Close()
+				return fmt.Errorf("invalid pattern in %q: %w", filepath.Join(dir, name), err)
+			}
+			if p != nil {
+				f.ignores = append(f.ignores, p)
+			}
+		}
+		_ = file.Close()
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("read %q error: %w", filepath.Join(dir, name), err)
+		}
+	}
+	return nil
+}
+
+// excluded 返回路径是否被忽略文件或排除模式排除
+// 后面的模式优先，取反的模式可以重新包含被前面的模式排除的路径
+func (f *fileFilter) excluded(rel string, isDir bool) bool {
+	ret := false
+	for _, patterns := range [][]*pattern{f.ignores, f.excludes} {
+		for _, p := range patterns {
+			if p.match(rel, isDir) {
+				ret = !p.negate
+			}
+		}
+	}
+	return ret
+}
+
+// hasIncludes 返回是否指定了包含模式
+func (f *fileFilter) hasIncludes() bool {
+	return len(f.includes) > 0
+}
+
+// included 返回路径或其所在的目录是否匹配包含模式
+func (f *fileFilter) included(rel string, isDir bool) bool {
+	for cur, curIsDir := rel, isDir; cur != "." && cur != ""; cur, curIsDir = path.Dir(cur), true {
+		for _, p := range f.includes {
+			if !p.negate && p.match(cur, curIsDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package cp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// FileProtocolVersion 文件协议版本
+const FileProtocolVersion = 1
+
+// FileTypeOther 其它类型的文件（设备、管道、套接字等），只出现在文件服务返回的文件信息中
+const FileTypeOther FileType = "other"
+
+// fileFrameType 文件协议的帧类型，是每条消息的第一个字节
+type fileFrameType byte
+
+const (
+	// fileFrameHello 文件服务连接后发送的服务信息，内容为 JSON 格式的 FileServerInfo
+	fileFrameHello fileFrameType = 'H'
+	// fileFrameRequest 客户端的请求，内容为 JSON 格式的 FileRequest
+	fileFrameRequest fileFrameType = 'Q'
+	// fileFrameResponse 文件服务的响应，内容为 JSON 格式的 FileResponse
+	fileFrameResponse fileFrameType = 'R'
+	// fileFramePart 较大的 JSON 内容分多条消息发送时，除最后一条以外的消息
+	fileFramePart fileFrameType = 'P'
+	// fileFrameData 文件内容
+	fileFrameData fileFrameType = 'D'
+	// fileFrameEOF 文件内容结束
+	fileFrameEOF fileFrameType = 'E'
+	// fileFrameError 发送文件内容的一端出错终止发送，内容为错误信息
+	fileFrameError fileFrameType = 'X'
+)
+
+// FileOp 文件操作
+type FileOp string
+
+const (
+	// FileOpStat 获取文件信息，跟随符号链接
+	FileOpStat FileOp = "stat"
+	// FileOpList 列出目录中的文件
+	FileOpList FileOp = "list"
+	// FileOpRead 读文件，响应后发送文件内容
+	FileOpRead FileOp = "read"
+	// FileOpWrite 写文件，响应后接收文件内容，接收完再响应一次
+	FileOpWrite FileOp = "write"
+	// FileOpMkdir 创建目录（包括不存在的父目录）
+	FileOpMkdir FileOp = "mkdir"
+)
+
+// FileServerInfo 文件服务信息
+type FileServerInfo struct {
+	// 协议版本
+	Version int `json:"version"`
+	// 是否只读
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// FileRequest 文件操作请求
+type FileRequest struct {
+	// 操作
+	Op FileOp `json:"op"`
+	// 路径，使用 / 分隔，相对文件服务的根目录
+	Path string `json:"path"`
+	// 权限，用于写文件和创建目录
+	Mode fs.FileMode `json:"mode,omitempty"`
+	// 修改时间，用于写文件
+	ModTime time.Time `json:"modTime,omitempty"`
+}
+
+// FileResponse 文件操作响应
+type FileResponse struct {
+	// 错误信息，为空表示成功
+	Error string `json:"error,omitempty"`
+	// 文件是否不存在
+	NotExist bool `json:"notExist,omitempty"`
+	// 文件信息，用于获取文件信息和读文件
+	Info *RemoteFileInfo `json:"info,omitempty"`
+	// 目录中的文件，用于列出目录中的文件
+	Entries []RemoteFileInfo `json:"entries,omitempty"`
+}
+
+// RemoteFileInfo 文件服务端的文件信息
+type RemoteFileInfo struct {
+	// 文件名
+	Name string `json:"name"`
+	// 文件类型
+	Type FileType `json:"type"`
+	// 大小，仅对普通文件有效
+	Size int64 `json:"size,omitempty"`
+	// 权限
+	Mode fs.FileMode `json:"mode"`
+	// 修改时间
+	ModTime time.Time `json:"modTime"`
+	// 符号链接指向的路径，仅对符号链接有效
+	Linkname string `json:"linkname,omitempty"`
+}
+
+// sendFileFrame 发送文件协议的帧
+func sendFileFrame(ctx context.Context, conn streams.Connection, t fileFrameType, payload []byte) error {
+	msg := make([]byte, 1+len(payload))
+	msg[0] = byte(t)
+	copy(msg[1:], payload)
+	if err := conn.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send to server error: %w", err)
+	}
+	return nil
+}
+
+// sendFileJSON 发送内容为 JSON 格式的帧，超过 maxReadSize 时分多条消息发送
+func sendFileJSON(ctx context.Context, conn streams.Connection, t fileFrameType, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	for len(raw) > maxReadSize {
+		if err := sendFileFrame(ctx, conn, fileFramePart, raw[:maxReadSize]); err != nil {
+			return err
+		}
+		raw = raw[maxReadSize:]
+	}
+	return sendFileFrame(ctx, conn, t, raw)
+}
+
+// receiveFileFrame 接收文件协议的帧，合并分多条消息发送的 JSON 内容，跳过空消息
+func receiveFileFrame(ctx context.Context, conn streams.Connection) (fileFrameType, []byte, error) {
+	var parts []byte
+	for {
+		msg, err := conn.Receive(ctx)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		t := fileFrameType(msg[0])
+		if t == fileFramePart {
+			parts = append(parts, msg[1:]...)
+			continue
+		}
+		if parts != nil {
+			return t, append(parts, msg[1:]...), nil
+		}
+		return t, msg[1:], nil
+	}
+}
+
+// receiveFileJSON 接收类型为 t 的帧并解码其中 JSON 格式的内容
+func receiveFileJSON(ctx context.Context, conn streams.Connection, t fileFrameType, v interface{}) error {
+	gotT, payload, err := receiveFileFrame(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if gotT != t {
+		return fmt.Errorf("unexpected frame %q, expected %q", gotT, t)
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("unmarshal frame %q error: %w", t, err)
+	}
+	return nil
+}
+
+// remoteFileInfo 根据本地文件信息创建 RemoteFileInfo
+func remoteFileInfo(name string, info fs.FileInfo, linkname string) RemoteFileInfo {
+	ret := RemoteFileInfo{
+		Name:     name,
+		Mode:     info.Mode().Perm(),
+		ModTime:  info.ModTime(),
+		Linkname: linkname,
+	}
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		ret.Type = FileTypeDir
+	case mode.IsRegular():
+		ret.Type = FileTypeFile
+		ret.Size = info.Size()
+	case mode&fs.ModeSymlink != 0:
+		ret.Type = FileTypeSymlink
+	default:
+		ret.Type = FileTypeOther
+	}
+	return ret
+}
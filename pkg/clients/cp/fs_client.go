@@ -0,0 +1,213 @@
+package cp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// ErrFileServerDisconnected 文件服务断开
+var ErrFileServerDisconnected = errors.New("file server disconnected")
+
+// ConnectFiles 连接到流，访问对端的文件服务
+func (c *CopyFileClient) ConnectFiles(ctx context.Context, stream *streamv1.Stream) (*RemoteFiles, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	conn, err := c.c.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{
+		ConnectionName: "file-client",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to server error: %w", err)
+	}
+	if logger.V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+	f, err := newRemoteFiles(ctx, conn)
+	if err != nil {
+		_ = conn.Close(ctx)
+		return nil, err
+	}
+	return f, nil
+}
+
+// newRemoteFiles 等待文件服务信息并创建 RemoteFiles
+func newRemoteFiles(ctx context.Context, conn streams.Connection) (*RemoteFiles, error) {
+	logr.FromContextOrDiscard(ctx).Info("waiting for file server ...")
+	info := &FileServerInfo{}
+	if err := receiveFileJSON(ctx, conn, fileFrameHello, info); err != nil {
+		return nil, fmt.Errorf("receive file server info error: %w", err)
+	}
+	if info.Version != FileProtocolVersion {
+		return nil, fmt.Errorf("unsupported file protocol version %d", info.Version)
+	}
+	return &RemoteFiles{conn: conn, info: *info}, nil
+}
+
+// RemoteFiles 对端文件服务中的文件
+//
+// 同一时间只处理一个请求，可以并发调用
+type RemoteFiles struct {
+	lock sync.Mutex
+	conn streams.Connection
+	info FileServerInfo
+}
+
+// ReadOnly 返回文件服务是否只读
+func (f *RemoteFiles) ReadOnly() bool {
+	return f.info.ReadOnly
+}
+
+// Close 断开连接
+func (f *RemoteFiles) Close(ctx context.Context) error {
+	return f.conn.Close(ctx)
+}
+
+// Stat 获取文件信息，跟随符号链接，文件不存在时返回的错误满足 errors.Is(err, fs.ErrNotExist)
+func (f *RemoteFiles) Stat(ctx context.Context, p string) (*RemoteFileInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	resp, err := f.request(ctx, &FileRequest{Op: FileOpStat, Path: p})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Info == nil {
+		return nil, fmt.Errorf("stat %s: missing file info in response", p)
+	}
+	return resp.Info, nil
+}
+
+// List 列出目录中的文件，按文件名排序
+func (f *RemoteFiles) List(ctx context.Context, p string) ([]RemoteFileInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	resp, err := f.request(ctx, &FileRequest{Op: FileOpList, Path: p})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// Mkdir 创建目录（包括不存在的父目录）
+func (f *RemoteFiles) Mkdir(ctx context.Context, p string, mode fs.FileMode) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	_, err := f.request(ctx, &FileRequest{Op: FileOpMkdir, Path: p, Mode: mode})
+	return err
+}
+
+// Get 读文件并写到 w ，返回文件信息
+//
+// 写 w 出错时仍然接收完文件内容，以便继续处理后续的请求
+func (f *RemoteFiles) Get(ctx context.Context, p string, w io.Writer) (*RemoteFileInfo, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	resp, err := f.request(ctx, &FileRequest{Op: FileOpRead, Path: p})
+	if err != nil {
+		return nil, err
+	}
+	var writeErr error
+	for {
+		t, payload, err := receiveFileFrame(ctx, f.conn)
+		if err != nil {
+			return nil, fmt.Errorf("receive file content error: %w", err)
+		}
+		switch t {
+		case fileFrameData:
+			if writeErr == nil {
+				_, writeErr = w.Write(payload)
+			}
+		case fileFrameEOF:
+			if writeErr != nil {
+				return nil, writeErr
+			}
+			return resp.Info, nil
+		case fileFrameError:
+			return nil, fmt.Errorf("read %s: %s", p, string(payload))
+		default:
+			return nil, fmt.Errorf("unexpected frame %q while receiving file content", t)
+		}
+	}
+}
+
+// Put 将从 r 读到的内容写到文件，写完后设置权限和修改时间
+//
+// 文件服务先写到临时文件，成功接收所有内容后才替换已有文件
+func (f *RemoteFiles) Put(ctx context.Context, p string, r io.Reader, mode fs.FileMode, modTime time.Time) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, err := f.request(ctx, &FileRequest{Op: FileOpWrite, Path: p, Mode: mode, ModTime: modTime}); err != nil {
+		return err
+	}
+
+	var readErr error
+	buf := make([]byte, maxReadSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := sendFileFrame(ctx, f.conn, fileFrameData, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+	}
+	if readErr != nil {
+		// 通知文件服务放弃写文件
+		if err := sendFileFrame(ctx, f.conn, fileFrameError, []byte(readErr.Error())); err != nil {
+			return err
+		}
+		_, _ = f.response(ctx, FileOpWrite, p)
+		return readErr
+	}
+	if err := sendFileFrame(ctx, f.conn, fileFrameEOF, nil); err != nil {
+		return err
+	}
+	_, err := f.response(ctx, FileOpWrite, p)
+	return err
+}
+
+// request 发送请求并等待响应，响应中有错误时返回错误
+func (f *RemoteFiles) request(ctx context.Context, req *FileRequest) (*FileResponse, error) {
+	if err := sendFileJSON(ctx, f.conn, fileFrameRequest, req); err != nil {
+		return nil, err
+	}
+	return f.response(ctx, req.Op, req.Path)
+}
+
+// response 等待响应，响应中有错误时返回错误
+func (f *RemoteFiles) response(ctx context.Context, op FileOp, p string) (*FileResponse, error) {
+	resp := &FileResponse{}
+	if err := receiveFileJSON(ctx, f.conn, fileFrameResponse, resp); err != nil {
+		if errors.Is(err, streams.ErrConnectionClosed) {
+			return nil, fmt.Errorf("%s %s: %w", op, p, ErrFileServerDisconnected)
+		}
+		return nil, fmt.Errorf("%s %s: receive response error: %w", op, p, err)
+	}
+	if resp.NotExist {
+		return nil, fmt.Errorf("%s %s: %w", op, p, os.ErrNotExist)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("%s %s: %s", op, p, resp.Error)
+	}
+	return resp, nil
+}
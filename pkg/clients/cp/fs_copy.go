@@ -0,0 +1,296 @@
+package cp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/yhlooo/scaf/pkg/utils/progress"
+)
+
+// CopyOptions 与文件服务之间复制文件的选项
+type CopyOptions struct {
+	// 递归复制目录
+	Recursive bool
+	// 处理复制进度，为空时不报告进度
+	Progress progress.Handler
+}
+
+// Upload 将本地的文件或目录复制到文件服务
+//
+// 与 scp 相同，target 是已存在的目录时复制到其中，否则复制为 target ，复制多个文件或目录时 target 必须是已存在的目录。
+// 符号链接和特殊文件被跳过。
+func Upload(ctx context.Context, files *RemoteFiles, sources []string, target string, opts CopyOptions) (retErr error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	targetIsDir, err := remoteIsDir(ctx, files, target)
+	if err != nil {
+		return err
+	}
+	if len(sources) > 1 && !targetIsDir {
+		return fmt.Errorf("target %q is not a directory", target)
+	}
+
+	// 检查要复制的文件并统计总数
+	totals := &TransferTotals{}
+	for _, src := range sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && !opts.Recursive {
+			return fmt.Errorf("%q is a directory (not copied without recursive)", src)
+		}
+		t, err := countFiles(ctx, src, FilterOptions{})
+		if err != nil {
+			return err
+		}
+		totals.Files += t.Files
+		totals.Bytes += t.Bytes
+	}
+
+	tracker := progress.NewTracker(opts.Progress)
+	tracker.SetTotal(totals.Bytes, totals.Files)
+	tracker.Start(ctx)
+	defer func() {
+		tracker.Stop(retErr)
+	}()
+
+	for _, src := range sources {
+		dst := target
+		if targetIsDir {
+			_, name, err := transferName(src)
+			if err != nil {
+				return err
+			}
+			dst = path.Join(target, name)
+		}
+		if err := WalkFiles(ctx, src, FilterOptions{}, func(entry FileEntry) error {
+			p := path.Join(dst, relName(entry.Name))
+			switch entry.Type {
+			case FileTypeDir:
+				return files.Mkdir(ctx, p, entry.Mode)
+			case FileTypeFile:
+				return uploadFile(ctx, files, tracker, entry, p)
+			default:
+				logger.Info(fmt.Sprintf("WARN skip symlink %s", entry.Path))
+				tracker.StartFile(entry.Name)
+				tracker.FinishFile()
+				return nil
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadFile 将本地文件复制到文件服务的 p
+func uploadFile(ctx context.Context, files *RemoteFiles, tracker *progress.Tracker, entry FileEntry, p string) error {
+	f, err := os.Open(entry.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	tracker.StartFile(entry.Name)
+	if err := files.Put(ctx, p, tracker.Reader(f), entry.Mode, entry.ModTime); err != nil {
+		return err
+	}
+	tracker.FinishFile()
+	return nil
+}
+
+// remoteIsDir 返回文件服务中的 p 是否是已存在的目录
+func remoteIsDir(ctx context.Context, files *RemoteFiles, p string) (bool, error) {
+	info, err := files.Stat(ctx, p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			if strings.HasSuffix(p, "/") {
+				return false, fmt.Errorf("target directory %q does not exist", p)
+			}
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Type == FileTypeDir, nil
+}
+
+// downloadItem 要从文件服务复制的文件或目录
+type downloadItem struct {
+	// 文件服务中的路径
+	remote string
+	// 本地路径
+	local string
+	// 文件信息
+	info RemoteFileInfo
+}
+
+// Download 将文件服务中的文件或目录复制到本地
+//
+// 与 scp 相同，target 是已存在的目录时复制到其中，否则复制为 target ，复制多个文件或目录时 target 必须是已存在的目录。
+// 目录中的符号链接和特殊文件被跳过。
+func Download(ctx context.Context, files *RemoteFiles, sources []string, target string, opts CopyOptions) (retErr error) {
+	targetIsDir := false
+	if info, err := os.Stat(target); err == nil {
+		targetIsDir = info.IsDir()
+	} else if !os.IsNotExist(err) {
+		return err
+	} else if strings.HasSuffix(target, string(filepath.Separator)) {
+		return fmt.Errorf("target directory %q does not exist", target)
+	}
+	if len(sources) > 1 && !targetIsDir {
+		return fmt.Errorf("target %q is not a directory", target)
+	}
+
+	// 列出要复制的文件并统计总数
+	var items []downloadItem
+	totals := &TransferTotals{}
+	for _, src := range sources {
+		info, err := files.Stat(ctx, src)
+		if err != nil {
+			return err
+		}
+		if info.Type == FileTypeDir && !opts.Recursive {
+			return fmt.Errorf("%q is a directory (not copied without recursive)", src)
+		}
+		dst := target
+		if targetIsDir {
+			name := path.Base(path.Clean("/" + src))
+			if name == "/" {
+				name = "rootfs"
+			}
+			dst = filepath.Join(target, name)
+		}
+		items, err = planDownload(ctx, files, items, downloadItem{remote: src, local: dst, info: *info})
+		if err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		if item.info.Type == FileTypeFile {
+			totals.Files++
+			totals.Bytes += item.info.Size
+		}
+	}
+
+	tracker := progress.NewTracker(opts.Progress)
+	tracker.SetTotal(totals.Bytes, totals.Files)
+	tracker.Start(ctx)
+	defer func() {
+		tracker.Stop(retErr)
+	}()
+
+	var dirs []downloadItem
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		switch item.info.Type {
+		case FileTypeDir:
+			// 先以可写的权限创建，复制完后再设置权限和修改时间
+			if err := os.MkdirAll(item.local, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, item)
+		case FileTypeFile:
+			if err := downloadFile(ctx, files, tracker, item); err != nil {
+				return err
+			}
+		}
+	}
+	// 子目录在父目录之后，逆序设置以免修改时间被改变
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].local, dirs[i].info.Mode.Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].local, dirs[i].info.ModTime, dirs[i].info.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planDownload 将 item 及其中的文件（对于目录）追加到 items ，父目录总是在其中的文件之前
+func planDownload(ctx context.Context, files *RemoteFiles, items []downloadItem, item downloadItem) ([]downloadItem, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	switch item.info.Type {
+	case FileTypeFile:
+		return append(items, item), nil
+	case FileTypeDir:
+	default:
+		logger.Info(fmt.Sprintf("WARN skip %s %s", item.info.Type, item.remote))
+		return items, nil
+	}
+
+	items = append(items, item)
+	entries, err := files.List(ctx, item.remote)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		items, err = planDownload(ctx, files, items, downloadItem{
+			remote: path.Join(item.remote, entry.Name),
+			local:  filepath.Join(item.local, entry.Name),
+			info:   entry,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// downloadFile 从文件服务复制文件，先写到同目录下的临时文件，设置元数据后重命名
+func downloadFile(ctx context.Context, files *RemoteFiles, tracker *progress.Tracker, item downloadItem) error {
+	dir := filepath.Dir(item.local)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(item.local)+".scaf-*")
+	if err != nil {
+		return fmt.Errorf("create temp file for %q error: %w", item.local, err)
+	}
+	renamed := false
+	defer func() {
+		_ = f.Close()
+		if !renamed {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	tracker.StartFile(item.remote)
+	info, err := files.Get(ctx, item.remote, tracker.Writer(f))
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(info.Mode.Perm()); err != nil {
+		return fmt.Errorf("chmod %q error: %w", item.local, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write file %q error: %w", item.local, err)
+	}
+	modTime := info.ModTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	if err := os.Chtimes(f.Name(), modTime, modTime); err != nil {
+		return fmt.Errorf("set mtime of %q error: %w", item.local, err)
+	}
+	if err := os.Rename(f.Name(), item.local); err != nil {
+		return fmt.Errorf("rename %q to %q error: %w", f.Name(), item.local, err)
+	}
+	renamed = true
+	tracker.FinishFile()
+	return nil
+}
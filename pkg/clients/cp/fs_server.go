@@ -0,0 +1,274 @@
+package cp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// FileServerOptions 文件服务选项
+type FileServerOptions struct {
+	// 根目录，客户端请求的路径都相对该目录，且不能通过符号链接访问该目录之外的文件，为空时为 /
+	Root string
+	// 只读，拒绝写文件和创建目录
+	ReadOnly bool
+}
+
+// ServeFiles 连接到流，为对端提供文件服务，直到对端断开
+func (c *CopyFileClient) ServeFiles(ctx context.Context, stream *streamv1.Stream, opts FileServerOptions) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, err := c.c.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{
+		ConnectionName: "file-server",
+	})
+	if err != nil {
+		return fmt.Errorf("connect to server error: %w", err)
+	}
+	if logger.V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+
+	return serveFiles(ctx, conn, opts)
+}
+
+// serveFiles 在连接上提供文件服务，直到对端断开
+func serveFiles(ctx context.Context, conn streams.Connection, opts FileServerOptions) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	root, err := newRootDir(opts.Root)
+	if err != nil {
+		return err
+	}
+	s := &fileServer{
+		logger:   logger,
+		conn:     conn,
+		root:     root,
+		readOnly: opts.ReadOnly,
+	}
+
+	if err := sendFileJSON(ctx, conn, fileFrameHello, &FileServerInfo{
+		Version:  FileProtocolVersion,
+		ReadOnly: opts.ReadOnly,
+	}); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("serving files in %s", root.root))
+
+	for {
+		req := &FileRequest{}
+		if err := receiveFileJSON(ctx, conn, fileFrameRequest, req); err != nil {
+			if errors.Is(err, streams.ErrConnectionClosed) {
+				logger.Info("client disconnected")
+				return nil
+			}
+			return fmt.Errorf("receive request error: %w", err)
+		}
+		if err := s.handle(ctx, req); err != nil {
+			return err
+		}
+	}
+}
+
+// fileServer 文件服务
+type fileServer struct {
+	logger   logr.Logger
+	conn     streams.Connection
+	root     *rootDir
+	readOnly bool
+}
+
+// handle 处理请求，只在无法继续通信时返回错误
+func (s *fileServer) handle(ctx context.Context, req *FileRequest) error {
+	s.logger.V(1).Info(fmt.Sprintf("%s %s", req.Op, req.Path))
+
+	p, err := s.root.resolve(req.Path)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+
+	switch req.Op {
+	case FileOpStat:
+		info, err := os.Stat(p)
+		if err != nil {
+			return s.respondError(ctx, err)
+		}
+		ret := remoteFileInfo(path.Base(path.Clean("/"+req.Path)), info, "")
+		return sendFileJSON(ctx, s.conn, fileFrameResponse, &FileResponse{Info: &ret})
+	case FileOpList:
+		return s.list(ctx, p)
+	case FileOpRead:
+		return s.read(ctx, p)
+	case FileOpWrite:
+		if s.readOnly {
+			return s.respondError(ctx, fmt.Errorf("file server is read-only"))
+		}
+		return s.write(ctx, p, req)
+	case FileOpMkdir:
+		if s.readOnly {
+			return s.respondError(ctx, fmt.Errorf("file server is read-only"))
+		}
+		mode := req.Mode.Perm()
+		if mode == 0 {
+			mode = 0o755
+		}
+		if err := os.MkdirAll(p, mode); err != nil {
+			return s.respondError(ctx, err)
+		}
+		return sendFileJSON(ctx, s.conn, fileFrameResponse, &FileResponse{})
+	default:
+		return s.respondError(ctx, fmt.Errorf("unsupported operation %q", req.Op))
+	}
+}
+
+// respondError 响应错误
+func (s *fileServer) respondError(ctx context.Context, err error) error {
+	return sendFileJSON(ctx, s.conn, fileFrameResponse, &FileResponse{
+		Error:    err.Error(),
+		NotExist: errors.Is(err, os.ErrNotExist),
+	})
+}
+
+// list 列出目录中的文件
+func (s *fileServer) list(ctx context.Context, p string) error {
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+	resp := &FileResponse{Entries: make([]RemoteFileInfo, 0, len(entries))}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// 文件在读目录后被删除
+			continue
+		}
+		linkname := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			linkname, _ = os.Readlink(filepath.Join(p, entry.Name()))
+		}
+		resp.Entries = append(resp.Entries, remoteFileInfo(entry.Name(), info, linkname))
+	}
+	return sendFileJSON(ctx, s.conn, fileFrameResponse, resp)
+}
+
+// read 发送文件内容
+func (s *fileServer) read(ctx context.Context, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+	if !info.Mode().IsRegular() {
+		return s.respondError(ctx, fmt.Errorf("%q is not a regular file", filepath.Base(p)))
+	}
+	ret := remoteFileInfo(filepath.Base(p), info, "")
+	if err := sendFileJSON(ctx, s.conn, fileFrameResponse, &FileResponse{Info: &ret}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, maxReadSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := sendFileFrame(ctx, s.conn, fileFrameData, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return sendFileFrame(ctx, s.conn, fileFrameEOF, nil)
+			}
+			return sendFileFrame(ctx, s.conn, fileFrameError, []byte(err.Error()))
+		}
+	}
+}
+
+// write 接收文件内容，先写到同目录下的临时文件，接收完后重命名
+//
+// 准备好接收后先响应一次，客户端收到成功的响应后才发送文件内容
+func (s *fileServer) write(ctx context.Context, p string, req *FileRequest) error {
+	if info, err := os.Stat(p); err == nil && info.IsDir() {
+		return s.respondError(ctx, fmt.Errorf("%q is a directory", filepath.Base(p)))
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".scaf-*")
+	if err != nil {
+		return s.respondError(ctx, err)
+	}
+	renamed := false
+	defer func() {
+		_ = f.Close()
+		if !renamed {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err := sendFileJSON(ctx, s.conn, fileFrameResponse, &FileResponse{}); err != nil {
+		return err
+	}
+
+	// 写文件出错后继续接收剩余的内容，以保持与客户端同步
+	var writeErr error
+	for {
+		t, payload, err := receiveFileFrame(ctx, s.conn)
+		if err != nil {
+			return fmt.Errorf("receive file content error: %w", err)
+		}
+		switch t {
+		case fileFrameData:
+			if writeErr == nil {
+				_, writeErr = f.Write(payload)
+			}
+			continue
+		case fileFrameError:
+			return s.respondError(ctx, fmt.Errorf("aborted by client: %s", string(payload)))
+		case fileFrameEOF:
+		default:
+			return fmt.Errorf("unexpected frame %q while receiving file content", t)
+		}
+		break
+	}
+	if writeErr != nil {
+		return s.respondError(ctx, writeErr)
+	}
+
+	mode := req.Mode.Perm()
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := f.Chmod(mode); err != nil {
+		return s.respondError(ctx, err)
+	}
+	if err := f.Close(); err != nil {
+		return s.respondError(ctx, err)
+	}
+	if !req.ModTime.IsZero() {
+		if err := os.Chtimes(f.Name(), req.ModTime, req.ModTime); err != nil {
+			return s.respondError(ctx, err)
+		}
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return s.respondError(ctx, err)
+	}
+	renamed = true
+	return sendFileJSON(ctx, s.conn, fileFrameResponse, &FileResponse{})
+}
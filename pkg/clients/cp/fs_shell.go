@@ -0,0 +1,282 @@
+package cp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/utils/progress"
+)
+
+// shellHelp 交互式命令的帮助信息
+const shellHelp = `Available commands:
+  ls [PATH]                   List remote directory
+  cd PATH                     Change remote directory
+  pwd                         Print remote directory
+  lcd PATH                    Change local directory
+  lpwd                        Print local directory
+  get [-r] REMOTE [LOCAL]     Download remote file or directory (-r)
+  put [-r] LOCAL [REMOTE]     Upload local file or directory (-r)
+  mkdir PATH                  Create remote directory
+  help                        Print this help
+  exit, quit, bye             Quit
+`
+
+// ShellOptions 交互式 shell 选项
+type ShellOptions struct {
+	// 输入
+	Input io.Reader
+	// 输出
+	Output io.Writer
+	// 提示符，为空时不输出提示符
+	Prompt string
+	// 处理 get 和 put 的进度，为空时不报告进度
+	Progress progress.Handler
+}
+
+// RunShell 运行类似 sftp 的交互式 shell ，逐行从输入读取命令并执行，直到输入结束或执行 exit
+//
+// 命令出错时输出错误并继续，只在与文件服务的连接断开时返回错误
+func RunShell(ctx context.Context, files *RemoteFiles, opts ShellOptions) error {
+	s := &fileShell{
+		files: files,
+		out:   opts.Output,
+		cwd:   "/",
+		opts:  opts,
+	}
+	scanner := bufio.NewScanner(opts.Input)
+	for {
+		if opts.Prompt != "" {
+			_, _ = fmt.Fprint(s.out, opts.Prompt)
+		}
+		if !scanner.Scan() {
+			if opts.Prompt != "" {
+				_, _ = fmt.Fprintln(s.out)
+			}
+			return scanner.Err()
+		}
+		args, err := splitShellArgs(scanner.Text())
+		if err != nil {
+			_, _ = fmt.Fprintf(s.out, "error: %v\n", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "exit", "quit", "bye":
+			return nil
+		}
+		if err := s.run(ctx, args[0], args[1:]); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			_, _ = fmt.Fprintf(s.out, "error: %v\n", err)
+			if errors.Is(err, ErrFileServerDisconnected) || errors.Is(err, streams.ErrConnectionClosed) {
+				return err
+			}
+		}
+	}
+}
+
+// fileShell 交互式 shell
+type fileShell struct {
+	files *RemoteFiles
+	out   io.Writer
+	// 文件服务中的当前目录
+	cwd  string
+	opts ShellOptions
+}
+
+// run 执行命令
+func (s *fileShell) run(ctx context.Context, name string, args []string) error {
+	switch name {
+	case "help", "?":
+		_, _ = fmt.Fprint(s.out, shellHelp)
+		return nil
+	case "pwd":
+		_, _ = fmt.Fprintln(s.out, s.cwd)
+		return nil
+	case "lpwd":
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(s.out, wd)
+		return nil
+	case "ls", "dir":
+		p := s.cwd
+		if len(args) > 0 {
+			p = s.remotePath(args[0])
+		}
+		return s.ls(ctx, p)
+	case "cd":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: cd PATH")
+		}
+		p := s.remotePath(args[0])
+		info, err := s.files.Stat(ctx, p)
+		if err != nil {
+			return err
+		}
+		if info.Type != FileTypeDir {
+			return fmt.Errorf("%q is not a directory", p)
+		}
+		s.cwd = p
+		return nil
+	case "lcd":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: lcd PATH")
+		}
+		return os.Chdir(args[0])
+	case "mkdir":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: mkdir PATH")
+		}
+		return s.files.Mkdir(ctx, s.remotePath(args[0]), 0o755)
+	case "get":
+		recursive, args := cutRecursiveFlag(args)
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: get [-r] REMOTE [LOCAL]")
+		}
+		local := "."
+		if len(args) == 2 {
+			local = args[1]
+		}
+		return Download(ctx, s.files, []string{s.remotePath(args[0])}, local, CopyOptions{
+			Recursive: recursive,
+			Progress:  s.opts.Progress,
+		})
+	case "put":
+		recursive, args := cutRecursiveFlag(args)
+		if len(args) < 1 || len(args) > 2 {
+			return fmt.Errorf("usage: put [-r] LOCAL [REMOTE]")
+		}
+		remote := s.cwd
+		if len(args) == 2 {
+			remote = s.remotePath(args[1])
+		}
+		return Upload(ctx, s.files, []string{args[0]}, remote, CopyOptions{
+			Recursive: recursive,
+			Progress:  s.opts.Progress,
+		})
+	default:
+		return fmt.Errorf("unknown command %q, run help for available commands", name)
+	}
+}
+
+// ls 列出目录中的文件，对于普通文件只输出文件本身
+func (s *fileShell) ls(ctx context.Context, p string) error {
+	info, err := s.files.Stat(ctx, p)
+	if err != nil {
+		return err
+	}
+	entries := []RemoteFileInfo{*info}
+	if info.Type == FileTypeDir {
+		entries, err = s.files.List(ctx, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, entry := range entries {
+		name := entry.Name
+		switch entry.Type {
+		case FileTypeDir:
+			name += "/"
+		case FileTypeSymlink:
+			name += " -> " + entry.Linkname
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t %s\n",
+			fileModeString(entry), progress.FormatBytes(entry.Size), entry.ModTime.Format("2006-01-02 15:04"), name)
+	}
+	return w.Flush()
+}
+
+// remotePath 返回相对当前目录的路径对应的文件服务中的绝对路径
+func (s *fileShell) remotePath(p string) string {
+	if !path.IsAbs(p) {
+		p = path.Join(s.cwd, p)
+	}
+	return path.Clean(p)
+}
+
+// fileModeString 返回类似 ls -l 的权限字符串
+func fileModeString(info RemoteFileInfo) string {
+	mode := info.Mode.Perm()
+	switch info.Type {
+	case FileTypeDir:
+		mode |= os.ModeDir
+	case FileTypeSymlink:
+		mode |= os.ModeSymlink
+	case FileTypeOther:
+		mode |= os.ModeIrregular
+	}
+	return mode.String()
+}
+
+// cutRecursiveFlag 去掉参数中的 -r ，返回是否有 -r
+func cutRecursiveFlag(args []string) (bool, []string) {
+	recursive := false
+	ret := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "-r" || arg == "-R" {
+			recursive = true
+			continue
+		}
+		ret = append(ret, arg)
+	}
+	return recursive, ret
+}
+
+// splitShellArgs 按空白分割命令行，支持单引号、双引号和反斜杠转义
+func splitShellArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			cur.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
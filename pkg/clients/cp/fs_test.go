@@ -0,0 +1,146 @@
+package cp
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yhlooo/scaf/pkg/streams/streamstest"
+)
+
+// TestRemoteFiles 测试通过文件服务访问文件
+func TestRemoteFiles(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o600))
+	a.NoError(os.Symlink(outside, filepath.Join(root, "escape")))
+
+	files, done := startTestFileServer(t, FileServerOptions{Root: root})
+
+	// 写文件并读回
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	a.NoError(files.Mkdir(ctx, "/a/b", 0o750))
+	a.NoError(files.Put(ctx, "/a/b/hello.txt", strings.NewReader("hello"), 0o640, mtime))
+	buf := &bytes.Buffer{}
+	info, err := files.Get(ctx, "a/b/hello.txt", buf)
+	if a.NoError(err) {
+		a.Equal("hello", buf.String())
+		a.Equal(FileTypeFile, info.Type)
+		a.Equal(fs.FileMode(0o640), info.Mode)
+		a.True(mtime.Equal(info.ModTime))
+	}
+	entries, err := files.List(ctx, "/a/b")
+	if a.NoError(err) && a.Len(entries, 1) {
+		a.Equal("hello.txt", entries[0].Name)
+		a.Equal(int64(5), entries[0].Size)
+	}
+
+	// 不存在的文件
+	_, err = files.Stat(ctx, "/missing")
+	a.ErrorIs(err, fs.ErrNotExist)
+
+	// 不能访问根目录之外的文件
+	info, err = files.Stat(ctx, "/../../a")
+	if a.NoError(err) {
+		a.Equal(FileTypeDir, info.Type)
+	}
+	_, err = files.Get(ctx, "/escape/secret", &bytes.Buffer{})
+	a.ErrorContains(err, "outside of root")
+	a.Error(files.Put(ctx, "/escape/new", strings.NewReader("x"), 0o644, time.Time{}))
+	_, err = os.Stat(filepath.Join(outside, "new"))
+	a.True(os.IsNotExist(err))
+
+	a.NoError(files.Close(ctx))
+	a.NoError(<-done)
+}
+
+// TestResolvePath 测试将路径限制在根目录之内
+func TestResolvePath(t *testing.T) {
+	a := assert.New(t)
+
+	root := t.TempDir()
+	outside := t.TempDir()
+	a.NoError(os.Symlink(outside, filepath.Join(root, "escape")))
+	a.NoError(os.Mkdir(filepath.Join(root, "dir"), 0o755))
+
+	for p, expected := range map[string]string{
+		"":                  root,
+		"/":                 root,
+		"dir/file":          filepath.Join(root, "dir", "file"),
+		"/etc/passwd":       filepath.Join(root, "etc", "passwd"),
+		"../../etc/passwd":  filepath.Join(root, "etc", "passwd"),
+		"dir/../../new.txt": filepath.Join(root, "new.txt"),
+	} {
+		got, err := ResolvePath(root, p)
+		if a.NoError(err, p) {
+			a.Equal(expected, got, p)
+		}
+	}
+	for _, p := range []string{"escape", "escape/file", "/escape/new/file"} {
+		_, err := ResolvePath(root, p)
+		a.Error(err, p)
+	}
+}
+
+// TestUploadDownload 测试递归上传和下载目录
+func TestUploadDownload(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	src := t.TempDir()
+	a.NoError(os.MkdirAll(filepath.Join(src, "dir", "sub"), 0o755))
+	a.NoError(os.WriteFile(filepath.Join(src, "dir", "1.txt"), []byte("1"), 0o644))
+	a.NoError(os.WriteFile(filepath.Join(src, "dir", "sub", "2.txt"), bytes.Repeat([]byte("2"), 3*maxReadSize), 0o600))
+
+	root := t.TempDir()
+	files, done := startTestFileServer(t, FileServerOptions{Root: root})
+
+	// 目录需要递归复制
+	a.Error(Upload(ctx, files, []string{filepath.Join(src, "dir")}, "/", CopyOptions{}))
+	a.NoError(Upload(ctx, files, []string{filepath.Join(src, "dir")}, "/", CopyOptions{Recursive: true}))
+	content, err := os.ReadFile(filepath.Join(root, "dir", "sub", "2.txt"))
+	a.NoError(err)
+	a.Len(content, 3*maxReadSize)
+
+	// 目标不存在时复制为目标
+	dst := t.TempDir()
+	a.NoError(Download(ctx, files, []string{"/dir"}, filepath.Join(dst, "copy"), CopyOptions{Recursive: true}))
+	content, err = os.ReadFile(filepath.Join(dst, "copy", "1.txt"))
+	a.NoError(err)
+	a.Equal("1", string(content))
+	info, err := os.Stat(filepath.Join(dst, "copy", "sub", "2.txt"))
+	if a.NoError(err) {
+		a.Equal(fs.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// 复制多个文件时目标必须是目录
+	a.Error(Download(ctx, files, []string{"/dir/1.txt", "/dir/sub/2.txt"}, filepath.Join(dst, "missing"), CopyOptions{}))
+
+	a.NoError(files.Close(ctx))
+	a.NoError(<-done)
+}
+
+// startTestFileServer 通过内存中的连接启动文件服务并连接
+func startTestFileServer(t *testing.T, opts FileServerOptions) (*RemoteFiles, <-chan error) {
+	ctx := context.Background()
+	serverConn, clientConn := streamstest.NewConnectionPair()
+	done := make(chan error, 1)
+	go func() {
+		done <- serveFiles(ctx, serverConn, opts)
+	}()
+	files, err := newRemoteFiles(ctx, clientConn)
+	if err != nil {
+		t.Fatalf("connect to file server error: %v", err)
+	}
+	return files, done
+}
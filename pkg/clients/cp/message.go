@@ -0,0 +1,161 @@
+package cp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// transferFrameType 帧类型，是每条消息的第一个字节
+//
+// 双方的每条消息都以帧类型开头，文件数据不会被误认为是传输信息或结束等指令
+type transferFrameType byte
+
+const (
+	// transferFrameInfo 传输信息，内容为 JSON 格式的 ReceiverInfo 、 SenderInfo 等
+	transferFrameInfo transferFrameType = 'I'
+	// transferFrameInfoPart 较大的传输信息分多条消息发送时，除最后一条以外的消息
+	transferFrameInfoPart transferFrameType = 'P'
+	// transferFrameData 打包并压缩后的文件数据
+	transferFrameData transferFrameType = 'D'
+	// transferFrameEOF 发送端发送完数据，或接收端接收并解包完数据
+	transferFrameEOF transferFrameType = 'E'
+	// transferFrameError 一端出错终止传输，内容为错误信息
+	transferFrameError transferFrameType = 'X'
+)
+
+// 旧版本的接收端和发送端之间传输的指令，用于兼容旧版本的接收端
+const (
+	// legacyStartRecvMsg 接收端准备好接收
+	legacyStartRecvMsg = "StartReceiving"
+	// legacySendDoneMsg 发送端发送完数据
+	legacySendDoneMsg = "SendCompleted"
+	// legacyRecvDoneMsg 接收端接收完数据
+	legacyRecvDoneMsg = "ReceivingCompleted"
+)
+
+// ReceiverInfo 接收端信息，接收端准备好接收后发送，发送端收到后开始传输
+type ReceiverInfo struct {
+	// 接收端支持的压缩算法
+	Compressions []Compression `json:"compressions,omitempty"`
+	// 接收端是否支持同步模式
+	Sync bool `json:"sync,omitempty"`
+	// 接收端是否需要文件的扩展属性
+	Xattrs bool `json:"xattrs,omitempty"`
+}
+
+// SenderInfo 发送端信息，发送端收到 ReceiverInfo 后在发送数据前发送
+type SenderInfo struct {
+	// 数据使用的压缩算法
+	Compression Compression `json:"compression,omitempty"`
+	// 发送的文件或目录名
+	Name string `json:"name,omitempty"`
+	// 同步选项，为空时不使用同步模式
+	Sync *SyncOptions `json:"sync,omitempty"`
+}
+
+// TransferTotals 要发送的文件总数和总字节数，发送端在发送数据前发送
+type TransferTotals struct {
+	// 文件（包括符号链接，不包括目录）数
+	Files int `json:"files"`
+	// 文件内容的总字节数，同步模式下对只发送块差异的文件为完整文件的大小
+	Bytes int64 `json:"bytes"`
+}
+
+// add 将文件计入总数
+func (t *TransferTotals) add(entry FileEntry) {
+	switch entry.Type {
+	case FileTypeFile:
+		t.Files++
+		t.Bytes += entry.Size
+	case FileTypeSymlink:
+		t.Files++
+	}
+}
+
+// decodeTransferInfo 解码传输信息帧，不是传输信息帧时返回 false
+func decodeTransferInfo(msg []byte, info interface{}) (bool, error) {
+	if len(msg) == 0 || transferFrameType(msg[0]) != transferFrameInfo {
+		return false, nil
+	}
+	if err := json.Unmarshal(msg[1:], info); err != nil {
+		return true, fmt.Errorf("unmarshal transfer info error: %w", err)
+	}
+	return true, nil
+}
+
+// sendTransferInfo 发送传输信息，超过 maxReadSize 时分多条消息发送
+func sendTransferInfo(ctx context.Context, conn streams.Connection, info interface{}) error {
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	for {
+		t := transferFrameInfo
+		part := raw
+		if len(raw) > maxReadSize {
+			t = transferFrameInfoPart
+			part = raw[:maxReadSize]
+		}
+		if err := sendTransferFrame(ctx, conn, t, part); err != nil {
+			return fmt.Errorf("send transfer info error: %w", err)
+		}
+		raw = raw[len(part):]
+		if len(raw) == 0 {
+			return nil
+		}
+	}
+}
+
+// receiveTransferInfo 接收 sendTransferInfo 发送的传输信息
+func receiveTransferInfo(ctx context.Context, conn streams.Connection, info interface{}) error {
+	var raw []byte
+	for {
+		t, payload, err := receiveTransferFrame(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("receive transfer info error: %w", err)
+		}
+		switch t {
+		case transferFrameInfoPart:
+			raw = append(raw, payload...)
+		case transferFrameInfo:
+			raw = append(raw, payload...)
+			if err := json.Unmarshal(raw, info); err != nil {
+				return fmt.Errorf("unmarshal transfer info error: %w", err)
+			}
+			return nil
+		case transferFrameError:
+			return fmt.Errorf("peer error: %s", payload)
+		default:
+			return fmt.Errorf("unexpected frame %q while receiving transfer info", t)
+		}
+	}
+}
+
+// sendTransferFrame 发送传输文件数据阶段的帧
+func sendTransferFrame(ctx context.Context, conn streams.Connection, t transferFrameType, payload []byte) error {
+	msg := make([]byte, 1+len(payload))
+	msg[0] = byte(t)
+	copy(msg[1:], payload)
+	if err := conn.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send to server error: %w", err)
+	}
+	return nil
+}
+
+// receiveTransferFrame 接收传输文件数据阶段的帧，跳过空消息
+//
+// 对端离开时返回 streams.ErrPeerLeft
+func receiveTransferFrame(ctx context.Context, conn streams.Connection) (transferFrameType, []byte, error) {
+	for {
+		msg, err := streams.ReceiveData(ctx, conn)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(msg) > 0 {
+			return transferFrameType(msg[0]), msg[1:], nil
+		}
+	}
+}
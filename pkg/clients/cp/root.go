@@ -0,0 +1,104 @@
+package cp
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ResolvePath 返回相对根目录 root 的路径 p 对应的本地路径
+//
+// p 总是相对 root ，即使是绝对路径。拒绝通过已存在的符号链接指向 root 之外的路径
+func ResolvePath(root, p string) (string, error) {
+	d, err := newRootDir(root)
+	if err != nil {
+		return "", err
+	}
+	return d.resolve(p)
+}
+
+// newRootDir 创建 rootDir ， root 为空时为 /
+func newRootDir(root string) (*rootDir, error) {
+	if root == "" {
+		root = "/"
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("get abs path for %q err: %w", root, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve root %q error: %w", root, err)
+	}
+	return &rootDir{root: absRoot, realRoot: realRoot}, nil
+}
+
+// rootDir 限制访问范围的根目录，类似 chroot
+type rootDir struct {
+	// 根目录的绝对路径
+	root string
+	// root 解析符号链接后的路径
+	realRoot string
+}
+
+// resolve 返回相对根目录的路径 p 对应的本地路径
+//
+// p 总是相对根目录，即使是绝对路径。拒绝通过已存在的符号链接指向根目录之外的路径，包括 p 本身是这样的符号链接
+func (d *rootDir) resolve(p string) (string, error) {
+	local := d.join(p)
+	if err := d.check(p, local); err != nil {
+		return "", err
+	}
+	return local, nil
+}
+
+// resolveNoFollow 与 resolve 相同，但不检查 p 本身，用于删除、重命名符号链接等操作符号链接本身的场景
+func (d *rootDir) resolveNoFollow(p string) (string, error) {
+	local := d.join(p)
+	if local == d.root {
+		return local, nil
+	}
+	if err := d.check(p, filepath.Dir(local)); err != nil {
+		return "", err
+	}
+	return local, nil
+}
+
+// join 返回相对根目录的路径 p 拼接到根目录后的路径
+func (d *rootDir) join(p string) string {
+	return filepath.Join(d.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// check 检查 local 最深的已存在的路径解析符号链接后是否仍在根目录之内
+func (d *rootDir) check(p, local string) error {
+	if d.realRoot == string(filepath.Separator) {
+		return nil
+	}
+
+	existing := local
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if existing == d.root {
+			return nil
+		}
+		existing = filepath.Dir(existing)
+	}
+	realPath, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// 指向不存在的文件的符号链接
+			return fmt.Errorf("invalid path %q: broken symlink", p)
+		}
+		return err
+	}
+	if realPath != d.realRoot && !strings.HasPrefix(realPath, d.realRoot+string(filepath.Separator)) {
+		return fmt.Errorf("invalid path %q: outside of root through symlink", p)
+	}
+	return nil
+}
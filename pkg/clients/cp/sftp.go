@@ -0,0 +1,296 @@
+package cp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/sftp"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/sdk"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// SFTPServerOptions SFTP 服务选项
+type SFTPServerOptions struct {
+	// 根目录，客户端看到的 / 为该目录，且不能通过符号链接访问该目录之外的文件，为空时为 /
+	Root string
+	// 只读，拒绝所有修改文件的操作
+	ReadOnly bool
+}
+
+// ServeSFTP 连接到流，以 SFTP 协议为对端提供文件服务，直到对端断开
+func (c *CopyFileClient) ServeSFTP(ctx context.Context, stream *streamv1.Stream, opts SFTPServerOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, err := c.connectSFTP(ctx, stream, "sftp-server")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	return serveSFTP(ctx, conn, opts)
+}
+
+// serveSFTP 在 rwc 上提供 SFTP 服务，直到对端断开
+func serveSFTP(ctx context.Context, rwc io.ReadWriteCloser, opts SFTPServerOptions) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	root, err := newRootDir(opts.Root)
+	if err != nil {
+		return err
+	}
+	h := &sftpHandlers{root: root, readOnly: opts.ReadOnly}
+	server := sftp.NewRequestServer(rwc, sftp.Handlers{
+		FileGet:  h,
+		FilePut:  h,
+		FileCmd:  h,
+		FileList: h,
+	})
+	logger.Info(fmt.Sprintf("serving sftp in %s", root.root))
+	if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("serve sftp error: %w", err)
+	}
+	logger.Info("client disconnected")
+	return nil
+}
+
+// ConnectSFTP 连接到流，返回与对端 SFTP 服务通信的 io.ReadWriteCloser
+//
+// 流中传输的是 SFTP 协议的原始数据，通常用于将 SSH 客户端的 sftp 子系统转发到对端
+func (c *CopyFileClient) ConnectSFTP(ctx context.Context, stream *streamv1.Stream) (io.ReadWriteCloser, error) {
+	return c.connectSFTP(ctx, stream, "sftp-client")
+}
+
+// connectSFTP 以 name 为连接名连接到流，返回将流中的消息视为连续字节流的 sdk.Conn
+func (c *CopyFileClient) connectSFTP(ctx context.Context, stream *streamv1.Stream, name string) (*sdk.Conn, error) {
+	conn, err := c.c.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{
+		ConnectionName: name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to server error: %w", err)
+	}
+	if logr.FromContextOrDiscard(ctx).V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+	return sdk.NewConn(conn, stream.Name), nil
+}
+
+// sftpHandlers 处理 SFTP 请求，所有路径都限制在根目录之内
+type sftpHandlers struct {
+	root     *rootDir
+	readOnly bool
+}
+
+var (
+	_ sftp.FileReader         = (*sftpHandlers)(nil)
+	_ sftp.OpenFileWriter     = (*sftpHandlers)(nil)
+	_ sftp.FileCmder          = (*sftpHandlers)(nil)
+	_ sftp.LstatFileLister    = (*sftpHandlers)(nil)
+	_ sftp.ReadlinkFileLister = (*sftpHandlers)(nil)
+)
+
+// Fileread 打开文件用于读
+func (h *sftpHandlers) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	p, err := h.root.resolve(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Filewrite 打开文件用于写
+func (h *sftpHandlers) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return h.OpenFile(r)
+}
+
+// OpenFile 打开文件用于读写
+func (h *sftpHandlers) OpenFile(r *sftp.Request) (sftp.WriterAtReaderAt, error) {
+	if h.readOnly {
+		return nil, sftp.ErrSSHFxPermissionDenied
+	}
+	p, err := h.root.resolve(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 写通过 WriteAt 进行，不能使用 O_APPEND
+	pflags := r.Pflags()
+	flag := os.O_WRONLY
+	if pflags.Read {
+		flag = os.O_RDWR
+	}
+	if pflags.Creat {
+		flag |= os.O_CREATE
+	}
+	if pflags.Trunc {
+		flag |= os.O_TRUNC
+	}
+	if pflags.Excl {
+		flag |= os.O_EXCL
+	}
+	return os.OpenFile(p, flag, 0o644)
+}
+
+// Filecmd 处理修改文件的请求
+func (h *sftpHandlers) Filecmd(r *sftp.Request) error {
+	if h.readOnly {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+
+	switch r.Method {
+	case "Setstat":
+		p, err := h.root.resolve(r.Filepath)
+		if err != nil {
+			return err
+		}
+		return setstat(p, r)
+	case "Rename":
+		src, err := h.root.resolveNoFollow(r.Filepath)
+		if err != nil {
+			return err
+		}
+		dst, err := h.root.resolveNoFollow(r.Target)
+		if err != nil {
+			return err
+		}
+		return os.Rename(src, dst)
+	case "Rmdir", "Remove":
+		p, err := h.root.resolveNoFollow(r.Filepath)
+		if err != nil {
+			return err
+		}
+		return os.Remove(p)
+	case "Mkdir":
+		p, err := h.root.resolveNoFollow(r.Filepath)
+		if err != nil {
+			return err
+		}
+		return os.Mkdir(p, 0o755)
+	case "Link":
+		src, err := h.root.resolveNoFollow(r.Filepath)
+		if err != nil {
+			return err
+		}
+		dst, err := h.root.resolveNoFollow(r.Target)
+		if err != nil {
+			return err
+		}
+		return os.Link(src, dst)
+	case "Symlink":
+		// 符号链接的目标原样保存，访问时会检查解析后的路径是否在根目录之内
+		p, err := h.root.resolveNoFollow(r.Target)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(r.Filepath, p)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// setstat 按请求修改文件属性
+func setstat(p string, r *sftp.Request) error {
+	attrs := r.Attributes()
+	flags := r.AttrFlags()
+	if flags.Size {
+		if err := os.Truncate(p, int64(attrs.Size)); err != nil {
+			return err
+		}
+	}
+	if flags.Permissions {
+		if err := os.Chmod(p, attrs.FileMode()); err != nil {
+			return err
+		}
+	}
+	if flags.Acmodtime {
+		if err := os.Chtimes(p, attrs.AccessTime(), attrs.ModTime()); err != nil {
+			return err
+		}
+	}
+	if flags.UidGid {
+		if err := os.Chown(p, int(attrs.UID), int(attrs.GID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filelist 处理列出目录和获取文件信息的请求
+func (h *sftpHandlers) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	p, err := h.root.resolve(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case "List":
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		infos := make(sftpFileList, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				// 文件在读目录后被删除
+				continue
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	case "Stat":
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		return sftpFileList{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// Lstat 获取文件信息，不解析符号链接
+func (h *sftpHandlers) Lstat(r *sftp.Request) (sftp.ListerAt, error) {
+	p, err := h.root.resolveNoFollow(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	return sftpFileList{info}, nil
+}
+
+// Readlink 读符号链接的目标
+func (h *sftpHandlers) Readlink(p string) (string, error) {
+	local, err := h.root.resolveNoFollow(p)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(local)
+}
+
+// sftpFileList 文件信息列表
+type sftpFileList []os.FileInfo
+
+var _ sftp.ListerAt = sftpFileList(nil)
+
+// ListAt 从 offset 开始复制文件信息到 ls
+func (l sftpFileList) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,218 @@
+package cp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPBridgeOptions SFTP 桥接选项
+type SFTPBridgeOptions struct {
+	// SSH 服务的主机密钥，为空时生成临时的 ed25519 密钥
+	HostKey ssh.Signer
+	// SSH 客户端认证使用的密码，为空时不认证
+	Password string
+	// 为每个 sftp 子系统请求建立到对端 SFTP 服务的连接
+	Dial func(ctx context.Context) (io.ReadWriteCloser, error)
+}
+
+// NewSFTPBridge 创建 SFTPBridge
+func NewSFTPBridge(opts SFTPBridgeOptions) (*SFTPBridge, error) {
+	if opts.Dial == nil {
+		return nil, fmt.Errorf("dial function is required")
+	}
+	hostKey := opts.HostKey
+	if hostKey == nil {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate host key error: %w", err)
+		}
+		hostKey, err = ssh.NewSignerFromKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("create host key signer error: %w", err)
+		}
+	}
+
+	config := &ssh.ServerConfig{}
+	if opts.Password == "" {
+		config.NoClientAuth = true
+	} else {
+		password := []byte(opts.Password)
+		config.PasswordCallback = func(_ ssh.ConnMetadata, got []byte) (*ssh.Permissions, error) {
+			if subtle.ConstantTimeCompare(got, password) != 1 {
+				return nil, fmt.Errorf("password rejected")
+			}
+			return nil, nil
+		}
+	}
+	config.AddHostKey(hostKey)
+
+	return &SFTPBridge{
+		config:  config,
+		hostKey: hostKey,
+		dial:    opts.Dial,
+	}, nil
+}
+
+// SFTPBridge SFTP 桥接，作为 SSH 服务接受连接，将其中的 sftp 子系统转发到流对端的 SFTP 服务
+//
+// 使得 sftp 、 rclone 等标准工具可以访问只能通过 scaf 访问的机器上的文件
+type SFTPBridge struct {
+	config  *ssh.ServerConfig
+	hostKey ssh.Signer
+	dial    func(ctx context.Context) (io.ReadWriteCloser, error)
+}
+
+// HostKeyFingerprint 返回主机密钥的 SHA256 指纹
+func (b *SFTPBridge) HostKeyFingerprint() string {
+	return ssh.FingerprintSHA256(b.hostKey.PublicKey())
+}
+
+// Serve 接受 l 上的连接并处理，直到 ctx 结束或 l 关闭
+func (b *SFTPBridge) Serve(ctx context.Context, l net.Listener) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+	}()
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("accept connection error: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			connLogger := logger.WithValues("remote", conn.RemoteAddr().String())
+			b.handleConn(logr.NewContext(ctx, connLogger), conn)
+		}()
+	}
+}
+
+// handleConn 处理 SSH 连接
+func (b *SFTPBridge) handleConn(ctx context.Context, netConn net.Conn) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = netConn.Close()
+	}()
+
+	conn, chans, reqs, err := ssh.NewServerConn(netConn, b.config)
+	if err != nil {
+		logger.Info(fmt.Sprintf("WARN ssh handshake error: %v", err))
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	logger.Info(fmt.Sprintf("ssh connection from user %q established", conn.User()))
+	go ssh.DiscardRequests(reqs)
+
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			logger.Info(fmt.Sprintf("WARN accept channel error: %v", err))
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.handleSession(ctx, ch, chReqs)
+		}()
+	}
+	logger.Info("ssh connection closed")
+}
+
+// handleSession 处理 SSH 会话，只接受 sftp 子系统请求
+func (b *SFTPBridge) handleSession(ctx context.Context, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	logger := logr.FromContextOrDiscard(ctx)
+	defer func() {
+		_ = ch.Close()
+	}()
+
+	for req := range reqs {
+		if req.Type != "subsystem" || !isSFTPSubsystem(req.Payload) {
+			logger.V(1).Info(fmt.Sprintf("reject session request %q", req.Type))
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+			continue
+		}
+
+		remote, err := b.dial(ctx)
+		if err != nil {
+			logger.Error(err, "connect to sftp server error")
+			_ = req.Reply(false, nil)
+			return
+		}
+		_ = req.Reply(true, nil)
+		go ssh.DiscardRequests(reqs)
+
+		logger.Info("sftp session started")
+		err = forwardSFTP(ch, remote)
+		status := uint32(0)
+		if err != nil {
+			logger.Error(err, "forward sftp session error")
+			status = 1
+		}
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		logger.Info("sftp session closed")
+		return
+	}
+}
+
+// isSFTPSubsystem 返回 subsystem 请求的内容是否是 sftp 子系统
+func isSFTPSubsystem(payload []byte) bool {
+	var msg struct{ Name string }
+	if err := ssh.Unmarshal(payload, &msg); err != nil {
+		return false
+	}
+	return msg.Name == "sftp"
+}
+
+// forwardSFTP 在 SSH 通道和对端 SFTP 服务之间双向转发数据，直到任一方向结束
+//
+// 返回时已断开对端，调用方负责关闭 SSH 通道以结束另一方向的转发
+func forwardSFTP(ch ssh.Channel, remote io.ReadWriteCloser) error {
+	errCh := make(chan error, 2)
+	go func() {
+		// 客户端总是在收到所有响应后才结束，因此客户端结束后可以直接断开对端
+		_, err := io.Copy(remote, ch)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(ch, remote)
+		_ = ch.CloseWrite()
+		errCh <- err
+	}()
+	err := <-errCh
+	_ = remote.Close()
+	return err
+}
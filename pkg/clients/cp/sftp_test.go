@@ -0,0 +1,127 @@
+package cp
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/yhlooo/scaf/pkg/sdk"
+	"github.com/yhlooo/scaf/pkg/streams/streamstest"
+)
+
+// TestSFTPBridge 测试通过 SFTP 桥接访问对端的 SFTP 服务
+func TestSFTPBridge(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	root := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello"), 0o644))
+	outside := t.TempDir()
+	a.NoError(os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644))
+	a.NoError(os.Symlink(outside, filepath.Join(root, "escape")))
+
+	bridge, err := NewSFTPBridge(SFTPBridgeOptions{
+		Password: "secret",
+		Dial: func(ctx context.Context) (io.ReadWriteCloser, error) {
+			serverConn, clientConn := streamstest.NewConnectionPair()
+			go func() {
+				_ = serveSFTP(ctx, sdk.NewConn(serverConn, "test"), SFTPServerOptions{Root: root})
+			}()
+			return sdk.NewConn(clientConn, "test"), nil
+		},
+	})
+	if !a.NoError(err) {
+		return
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !a.NoError(err) {
+		return
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- bridge.Serve(ctx, l)
+	}()
+
+	// 密码错误
+	_, err = ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.Password("wrong")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	a.Error(err)
+
+	sshClient, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "user",
+		Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+		HostKeyCallback: ssh.FixedHostKey(bridge.hostKey.PublicKey()),
+	})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = sshClient.Close()
+	}()
+
+	// 不支持执行命令
+	session, err := sshClient.NewSession()
+	if a.NoError(err) {
+		a.Error(session.Run("id"))
+	}
+
+	client, err := sftp.NewClient(sshClient)
+	if !a.NoError(err) {
+		return
+	}
+	f, err := client.Open("hello.txt")
+	if a.NoError(err) {
+		content, err := io.ReadAll(f)
+		a.NoError(err)
+		a.Equal("hello", string(content))
+		_ = f.Close()
+	}
+	f, err = client.Create("new.txt")
+	if a.NoError(err) {
+		_, err = f.Write([]byte("world"))
+		a.NoError(err)
+		a.NoError(f.Close())
+	}
+	a.NoError(client.Rename("new.txt", "renamed.txt"))
+	content, err := os.ReadFile(filepath.Join(root, "renamed.txt"))
+	a.NoError(err)
+	a.Equal("world", string(content))
+
+	// 根目录之外的文件不可访问
+	wd, err := client.Getwd()
+	a.NoError(err)
+	a.Equal("/", wd)
+	f, err = client.Open("/hello.txt")
+	if a.NoError(err) {
+		_ = f.Close()
+	}
+	_, err = client.Stat(filepath.Join(outside, "secret.txt"))
+	a.Error(err)
+	_, err = client.Open("../../" + filepath.Join(outside, "secret.txt"))
+	a.Error(err)
+	_, err = client.Open("escape/secret.txt")
+	a.Error(err)
+	_, err = client.Create("escape/new.txt")
+	a.Error(err)
+	_, err = os.Stat(filepath.Join(outside, "new.txt"))
+	a.True(os.IsNotExist(err))
+	// 可以删除指向根目录之外的符号链接本身
+	a.NoError(client.Remove("escape"))
+	_, err = os.Stat(filepath.Join(outside, "secret.txt"))
+	a.NoError(err)
+	a.NoError(client.Close())
+
+	cancel()
+	a.NoError(<-done)
+}
@@ -0,0 +1,469 @@
+package cp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/go-logr/logr"
+
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+const (
+	// paxDeltaSHA256 记录差异应用后文件的 SHA256 的 PAX 记录，有该记录的文件内容是相对接收端已有文件的差异
+	paxDeltaSHA256 = "SCAF.delta.sha256"
+	// paxDeltaBlockSize 记录差异的块大小的 PAX 记录
+	paxDeltaBlockSize = "SCAF.delta.blocksize"
+)
+
+// SyncOptions 同步选项
+//
+// 同步模式下接收端先发送已有文件的清单，发送端只发送有变化的文件
+type SyncOptions struct {
+	// 删除接收端多余的文件（被过滤选项排除的文件除外）
+	Delete bool `json:"delete,omitempty"`
+	// 通过文件内容的哈希判断文件是否有变化，否则通过大小和修改时间判断
+	Checksum bool `json:"checksum,omitempty"`
+	// 对接收端已有的较大的文件只发送与已有文件的块差异
+	Delta bool `json:"delta,omitempty"`
+}
+
+// Manifest 接收端已有文件的清单
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries,omitempty"`
+}
+
+// ManifestEntry 接收端已有的文件
+type ManifestEntry struct {
+	// 相对接收的文件或目录的路径，使用 / 分隔，接收的文件或目录本身为空字符串
+	Name string `json:"name,omitempty"`
+	// 文件类型
+	Type FileType `json:"type"`
+	// 大小
+	Size int64 `json:"size,omitempty"`
+	// 修改时间（ Unix 时间戳，单位：秒）
+	ModTime int64 `json:"modTime,omitempty"`
+	// 符号链接指向的路径
+	Linkname string `json:"linkname,omitempty"`
+	// 文件内容的 SHA256 ，仅在同步选项要求时计算
+	Hash string `json:"hash,omitempty"`
+}
+
+// SyncPlan 发送端根据清单生成的同步计划
+type SyncPlan struct {
+	// 接收端需要删除的文件或目录
+	Delete []string `json:"delete,omitempty"`
+	// 需要接收端计算块签名的文件
+	Signatures []string `json:"signatures,omitempty"`
+}
+
+// Signatures 接收端已有文件的块签名
+type Signatures struct {
+	Files map[string]*FileSignature `json:"files,omitempty"`
+}
+
+// syncAction 同步模式下对一个文件的处理方式
+type syncAction int
+
+const (
+	syncActionSend syncAction = iota
+	syncActionSkip
+	syncActionDelta
+)
+
+// syncTransfer 同步模式下发送端的传输计划
+type syncTransfer struct {
+	entries    []FileEntry
+	actions    map[string]syncAction
+	signatures map[string]*FileSignature
+	// 要发送的文件总数和总字节数
+	totals TransferTotals
+}
+
+// prepareSyncSend 同步模式下发送数据前，遍历要发送的文件，根据接收端的清单生成同步计划并获取块签名
+func (c *CopyFileClient) prepareSyncSend(
+	ctx context.Context,
+	conn streams.Connection,
+	root string,
+	opts SendOptions,
+) (*syncTransfer, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	filter, err := newFileFilter(opts.Filter)
+	if err != nil {
+		return nil, err
+	}
+	t := &syncTransfer{actions: map[string]syncAction{}}
+	var skipped []string
+	if err := walkFiles(ctx, root, filter, func(entry FileEntry) error {
+		t.entries = append(t.entries, entry)
+		return nil
+	}, func(rel string) {
+		skipped = append(skipped, rel)
+	}); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := receiveTransferInfo(ctx, conn, manifest); err != nil {
+		return nil, fmt.Errorf("receive manifest error: %w", err)
+	}
+	existing := make(map[string]ManifestEntry, len(manifest.Entries))
+	for _, e := range manifest.Entries {
+		existing[e.Name] = e
+	}
+
+	plan := &SyncPlan{}
+	sent := map[string]bool{}
+	send, delta, unchanged := 0, 0, 0
+	for _, entry := range t.entries {
+		rel := relName(entry.Name)
+		sent[rel] = true
+		action, replace, err := syncActionFor(entry, existing, opts.Sync)
+		if err != nil {
+			return nil, err
+		}
+		if replace {
+			plan.Delete = append(plan.Delete, rel)
+		}
+		switch action {
+		case syncActionDelta:
+			plan.Signatures = append(plan.Signatures, rel)
+			delta++
+			send++
+		case syncActionSend:
+			if entry.Type != FileTypeDir {
+				send++
+			}
+		case syncActionSkip:
+			unchanged++
+		}
+		if action != syncActionSkip {
+			t.totals.add(entry)
+		}
+		t.actions[rel] = action
+	}
+	if opts.Sync.Delete {
+		plan.Delete = append(plan.Delete, extraneousFiles(manifest, sent, skipped, filter)...)
+	}
+	logger.Info(fmt.Sprintf(
+		"sync: %d to send (%d with delta), %d unchanged, %d to delete",
+		send, delta, unchanged, len(plan.Delete),
+	))
+
+	if err := sendTransferInfo(ctx, conn, plan); err != nil {
+		return nil, fmt.Errorf("send sync plan error: %w", err)
+	}
+	if len(plan.Signatures) > 0 {
+		sigs := &Signatures{}
+		if err := receiveTransferInfo(ctx, conn, sigs); err != nil {
+			return nil, fmt.Errorf("receive signatures error: %w", err)
+		}
+		t.signatures = sigs.Files
+	}
+	return t, nil
+}
+
+// syncActionFor 根据接收端已有的文件决定文件的处理方式，以及是否需要先删除接收端已有的文件
+func syncActionFor(entry FileEntry, existing map[string]ManifestEntry, opts *SyncOptions) (syncAction, bool, error) {
+	old, ok := existing[relName(entry.Name)]
+	if !ok {
+		return syncActionSend, false, nil
+	}
+	if old.Type != entry.Type {
+		// 类型变化，先删除
+		return syncActionSend, true, nil
+	}
+	switch entry.Type {
+	case FileTypeDir:
+		// 目录总是发送，以更新权限
+		return syncActionSend, false, nil
+	case FileTypeSymlink:
+		if old.Linkname == entry.Linkname {
+			return syncActionSkip, false, nil
+		}
+		return syncActionSend, true, nil
+	}
+
+	if old.Size == entry.Size {
+		if opts.Checksum {
+			hash, err := fileHash(entry.Path)
+			if err != nil {
+				return 0, false, err
+			}
+			if hash == old.Hash {
+				return syncActionSkip, false, nil
+			}
+		} else if old.ModTime == entry.ModTime.Unix() {
+			return syncActionSkip, false, nil
+		}
+	}
+	if opts.Delta && old.Size >= DefaultDeltaMinSize && entry.Size >= DefaultDeltaMinSize {
+		return syncActionDelta, false, nil
+	}
+	return syncActionSend, false, nil
+}
+
+// extraneousFiles 返回接收端多余的文件
+//
+// 发送端跳过的和被过滤选项排除的文件及包含它们的目录不会被删除，已删除的目录中的文件不会重复返回
+func extraneousFiles(manifest *Manifest, sent map[string]bool, skipped []string, filter *fileFilter) []string {
+	for _, e := range manifest.Entries {
+		if e.Name == "" {
+			continue
+		}
+		isDir := e.Type == FileTypeDir
+		if filter.excluded(e.Name, isDir) || (filter.hasIncludes() && !isDir && !filter.included(e.Name, false)) {
+			skipped = append(skipped, e.Name)
+		}
+	}
+
+	skippedSet := map[string]bool{}
+	// 包含被跳过的文件的目录
+	skippedParents := map[string]bool{}
+	for _, rel := range skipped {
+		skippedSet[rel] = true
+		for p := rel; p != ""; {
+			p = parentRel(p)
+			skippedParents[p] = true
+		}
+	}
+	isSkipped := func(rel string) bool {
+		for p := rel; ; p = parentRel(p) {
+			if skippedSet[p] {
+				return true
+			}
+			if p == "" {
+				return false
+			}
+		}
+	}
+
+	var ret []string
+	deleted := map[string]bool{}
+	for _, e := range manifest.Entries {
+		if sent[e.Name] || skippedParents[e.Name] || isSkipped(e.Name) {
+			continue
+		}
+		if e.Name != "" && deleted[parentRel(e.Name)] {
+			// 所在目录已删除
+			deleted[e.Name] = true
+			continue
+		}
+		deleted[e.Name] = true
+		ret = append(ret, e.Name)
+	}
+	return ret
+}
+
+// tarSyncFiles 同步模式下打包文件
+func (c *CopyFileClient) tarSyncFiles(ctx context.Context, t *syncTransfer, w *tarWriter) error {
+	for _, entry := range t.entries {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		rel := relName(entry.Name)
+		switch t.actions[rel] {
+		case syncActionSkip:
+			continue
+		case syncActionDelta:
+			if sig := t.signatures[rel]; sig != nil {
+				if err := c.writeTarDelta(ctx, w, entry, sig); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := c.writeTarEntry(ctx, w, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTarDelta 将文件相对接收端已有文件的差异写到 tar
+func (c *CopyFileClient) writeTarDelta(ctx context.Context, w *tarWriter, entry FileEntry, sig *FileSignature) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	f, err := os.Open(entry.Path)
+	if err != nil {
+		return fmt.Errorf("open file %q error: %w", entry.Path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// 差异大小确定后才能写文件头，先写到临时文件
+	tmp, err := os.CreateTemp("", "scaf-delta-*")
+	if err != nil {
+		return fmt.Errorf("create temp file error: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	h := sha256.New()
+	w.progress.StartFile(entry.Name)
+	defer w.progress.FinishFile()
+	if err := writeDelta(tmp, io.TeeReader(io.LimitReader(w.progress.Reader(f), entry.Size), h), sig); err != nil {
+		return fmt.Errorf("compute delta of %q error: %w", entry.Path, err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	logger.V(1).Info(fmt.Sprintf("delta %s (%d -> %d bytes)", entry.Name, entry.Size, size))
+	header, err := w.header(entry)
+	if err != nil {
+		return err
+	}
+	header.Size = size
+	if header.PAXRecords == nil {
+		header.PAXRecords = map[string]string{}
+	}
+	header.PAXRecords[paxDeltaSHA256] = hex.EncodeToString(h.Sum(nil))
+	header.PAXRecords[paxDeltaBlockSize] = strconv.Itoa(sig.BlockSize)
+	if err := w.WriteHeader(header); err != nil {
+		return fmt.Errorf("write header %q to tar error: %w", entry.Name, err)
+	}
+	if _, err := io.Copy(w, tmp); err != nil {
+		return fmt.Errorf("write delta of %q to tar error: %w", entry.Path, err)
+	}
+	return nil
+}
+
+// prepareSyncReceive 同步模式下接收数据前，发送已有文件的清单，根据同步计划删除多余的文件并发送块签名
+func (c *CopyFileClient) prepareSyncReceive(
+	ctx context.Context,
+	conn streams.Connection,
+	e *extractor,
+	opts *SyncOptions,
+) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	manifest, err := buildManifest(ctx, e.target, opts.Checksum)
+	if err != nil {
+		return fmt.Errorf("build manifest error: %w", err)
+	}
+	if err := sendTransferInfo(ctx, conn, manifest); err != nil {
+		return fmt.Errorf("send manifest error: %w", err)
+	}
+
+	plan := &SyncPlan{}
+	if err := receiveTransferInfo(ctx, conn, plan); err != nil {
+		return fmt.Errorf("receive sync plan error: %w", err)
+	}
+	for _, rel := range plan.Delete {
+		p, err := e.resolve(rel)
+		if err != nil {
+			return err
+		}
+		logger.V(1).Info(fmt.Sprintf("delete %s", p))
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("delete %q error: %w", p, err)
+		}
+	}
+	if len(plan.Delete) > 0 {
+		logger.Info(fmt.Sprintf("deleted %d files", len(plan.Delete)))
+	}
+
+	if len(plan.Signatures) == 0 {
+		return nil
+	}
+	sigs := &Signatures{Files: map[string]*FileSignature{}}
+	for _, rel := range plan.Signatures {
+		p, err := e.resolve(rel)
+		if err != nil {
+			return err
+		}
+		sig, err := fileSignature(p)
+		if err != nil {
+			return err
+		}
+		sigs.Files[rel] = sig
+	}
+	if err := sendTransferInfo(ctx, conn, sigs); err != nil {
+		return fmt.Errorf("send signatures error: %w", err)
+	}
+	return nil
+}
+
+// buildManifest 生成 target 下已有文件的清单， target 不存在时返回空清单
+func buildManifest(ctx context.Context, target string, checksum bool) (*Manifest, error) {
+	manifest := &Manifest{}
+	if _, err := os.Lstat(target); err != nil {
+		if os.IsNotExist(err) {
+			return manifest, nil
+		}
+		return nil, err
+	}
+	err := WalkFiles(ctx, target, FilterOptions{}, func(entry FileEntry) error {
+		e := ManifestEntry{
+			Name:     relName(entry.Name),
+			Type:     entry.Type,
+			Size:     entry.Size,
+			ModTime:  entry.ModTime.Unix(),
+			Linkname: entry.Linkname,
+		}
+		if checksum && entry.Type == FileTypeFile {
+			var err error
+			if e.Hash, err = fileHash(entry.Path); err != nil {
+				return err
+			}
+		}
+		manifest.Entries = append(manifest.Entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// fileHash 计算文件内容的 SHA256
+func fileHash(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", fmt.Errorf("open file %q error: %w", p, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("read file %q error: %w", p, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileSignature 计算文件的块签名
+func fileSignature(p string) (*FileSignature, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("open file %q error: %w", p, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := computeSignature(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("compute signature of %q error: %w", p, err)
+	}
+	return sig, nil
+}
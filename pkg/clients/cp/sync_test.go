@@ -0,0 +1,84 @@
+package cp
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDelta 测试计算和应用块差异
+func TestDelta(t *testing.T) {
+	a := assert.New(t)
+
+	rnd := rand.New(rand.NewSource(1))
+	basis := make([]byte, 3<<20+123)
+	rnd.Read(basis)
+
+	// 在开头插入、中间修改、末尾追加
+	insert := make([]byte, 1000)
+	rnd.Read(insert)
+	target := append(append([]byte{}, insert...), basis...)
+	copy(target[1<<20:], "modified")
+	target = append(target, insert...)
+
+	sig, err := computeSignature(bytes.NewReader(basis), int64(len(basis)))
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(int64(len(basis)), sig.Size)
+	a.Equal(2048, sig.BlockSize)
+
+	delta := &bytes.Buffer{}
+	if !a.NoError(writeDelta(delta, bytes.NewReader(target), sig)) {
+		return
+	}
+	a.Less(delta.Len(), 16<<10)
+
+	ret := &bytes.Buffer{}
+	if !a.NoError(applyDelta(ret, bytes.NewReader(basis), sig.BlockSize, bytes.NewReader(delta.Bytes()))) {
+		return
+	}
+	a.True(bytes.Equal(target, ret.Bytes()))
+
+	// 基础文件为空
+	sig, err = computeSignature(bytes.NewReader(nil), 0)
+	if !a.NoError(err) {
+		return
+	}
+	delta.Reset()
+	ret.Reset()
+	a.NoError(writeDelta(delta, bytes.NewReader(target), sig))
+	a.NoError(applyDelta(ret, bytes.NewReader(nil), sig.BlockSize, bytes.NewReader(delta.Bytes())))
+	a.True(bytes.Equal(target, ret.Bytes()))
+
+	// 滚动校验和与直接计算的结果一致
+	sum := weakChecksum(target[:100])
+	for i := 0; i < 1000; i++ {
+		sum = rollChecksum(sum, 100, target[i], target[i+100])
+		a.Equal(weakChecksum(target[i+1:i+101]), sum)
+	}
+}
+
+// TestExtraneousFiles 测试计算接收端多余的文件
+func TestExtraneousFiles(t *testing.T) {
+	a := assert.New(t)
+
+	manifest := &Manifest{}
+	for _, name := range []string{
+		"", "a", "a/1", "a/2", "b", "b/1", "c", "c/keep.log", "c/x", "d.log", "e",
+	} {
+		manifest.Entries = append(manifest.Entries, ManifestEntry{Name: name})
+	}
+	sent := map[string]bool{"": true, "a": true, "a/1": true, "e": true}
+	filter, err := newFileFilter(FilterOptions{Excludes: []string{"*.log"}})
+	if !a.NoError(err) {
+		return
+	}
+
+	// 发送端跳过的和被排除的文件不删除
+	a.Equal([]string{"a/2", "c/x"}, extraneousFiles(manifest, sent, []string{"b/1"}, filter))
+	filter, _ = newFileFilter(FilterOptions{})
+	a.Equal([]string{"a/2", "b", "c", "d.log"}, extraneousFiles(manifest, sent, nil, filter))
+}
@@ -0,0 +1,193 @@
+package cp
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// FileType 文件类型
+type FileType string
+
+const (
+	// FileTypeDir 目录
+	FileTypeDir FileType = "dir"
+	// FileTypeFile 普通文件
+	FileTypeFile FileType = "file"
+	// FileTypeSymlink 符号链接
+	FileTypeSymlink FileType = "link"
+)
+
+// FileEntry 要发送的文件
+type FileEntry struct {
+	// 文件类型
+	Type FileType
+	// 在传输中的名字，以发送的文件或目录名开头，使用 / 分隔
+	Name string
+	// 本地路径
+	Path string
+	// 大小，仅对普通文件有效
+	Size int64
+	// 权限
+	Mode fs.FileMode
+	// 修改时间
+	ModTime time.Time
+	// 符号链接指向的路径，仅对符号链接有效
+	Linkname string
+	// 所有者的用户 ID
+	Uid int
+	// 所有者的组 ID
+	Gid int
+}
+
+// WalkFiles 遍历 root 下要发送的文件
+//
+// 按字典序遍历，父目录总是在其中的文件之前，被过滤选项排除的文件和特殊文件（设备、管道、套接字等）被跳过。
+// 指定了包含模式时，目录只在其中有要发送的文件或目录本身匹配包含模式时才会被遍历到。
+func WalkFiles(ctx context.Context, root string, opts FilterOptions, fn func(entry FileEntry) error) error {
+	filter, err := newFileFilter(opts)
+	if err != nil {
+		return err
+	}
+	return walkFiles(ctx, root, filter, fn, nil)
+}
+
+// walkFiles 遍历 root 下要发送的文件，被跳过的文件或目录（相对 root 的路径）交给 skipped
+//
+// 遍历结束后 filter 中包含所有读取到的忽略文件中的模式
+func walkFiles(
+	ctx context.Context,
+	root string,
+	filter *fileFilter,
+	fn func(entry FileEntry) error,
+	skipped func(rel string),
+) error {
+	if skipped == nil {
+		skipped = func(string) {}
+	}
+	logger := logr.FromContextOrDiscard(ctx)
+
+	absRoot, name, err := transferName(root)
+	if err != nil {
+		return err
+	}
+
+	// 已遍历到但还未交给 fn 的目录，指定了包含模式时目录延迟到其中有要发送的文件时才交给 fn
+	pendingDirs := map[string]FileEntry{}
+	var emitDir func(rel string) error
+	emitDir = func(rel string) error {
+		entry, ok := pendingDirs[rel]
+		if !ok {
+			// 已经交给 fn
+			return nil
+		}
+		if rel != "" {
+			if err := emitDir(parentRel(rel)); err != nil {
+				return err
+			}
+		}
+		delete(pendingDirs, rel)
+		return fn(entry)
+	}
+
+	return filepath.Walk(absRoot, func(p string, info fs.FileInfo, err error) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err != nil {
+			return fmt.Errorf("walk %q error: %w", p, err)
+		}
+
+		rel := filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(p, absRoot), string(filepath.Separator)))
+		mode := info.Mode()
+		isDir := mode.IsDir()
+		if rel != "" && filter.excluded(rel, isDir) {
+			logger.V(1).Info(fmt.Sprintf("exclude %s", rel))
+			skipped(rel)
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		entry := FileEntry{
+			Name:    path.Join(name, rel),
+			Path:    p,
+			Mode:    mode.Perm(),
+			ModTime: info.ModTime(),
+		}
+		entry.Uid, entry.Gid = fileOwner(info)
+		switch {
+		case isDir:
+			entry.Type = FileTypeDir
+			if err := filter.loadIgnoreFiles(p, rel); err != nil {
+				return err
+			}
+			pendingDirs[rel] = entry
+			if rel == "" || !filter.hasIncludes() || filter.included(rel, true) {
+				return emitDir(rel)
+			}
+			return nil
+		case mode&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return fmt.Errorf("read symlink %q err: %v", p, err)
+			}
+			entry.Type = FileTypeSymlink
+			entry.Linkname = link
+		case mode.IsRegular():
+			entry.Type = FileTypeFile
+			entry.Size = info.Size()
+		default:
+			logger.Info(fmt.Sprintf("WARN skip special file %s (%s)", p, mode.Type()))
+			skipped(rel)
+			return nil
+		}
+
+		if filter.hasIncludes() && !filter.included(rel, false) {
+			skipped(rel)
+			return nil
+		}
+		if err := emitDir(parentRel(rel)); err != nil {
+			return err
+		}
+		return fn(entry)
+	})
+}
+
+// transferName 返回 root 的绝对路径和在传输中的名字
+func transferName(root string) (string, string, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", "", fmt.Errorf("get abs path for %q err: %v", root, err)
+	}
+	name := filepath.Base(absRoot)
+	if name == "/" {
+		name = "rootfs"
+	}
+	return absRoot, name, nil
+}
+
+// relName 返回传输中的名字相对发送的文件或目录的路径，发送的文件或目录本身为空字符串
+func relName(name string) string {
+	_, rel, _ := strings.Cut(name, "/")
+	return rel
+}
+
+// parentRel 返回相对路径 rel 的父目录的相对路径，根目录为空字符串
+func parentRel(rel string) string {
+	parent := path.Dir(rel)
+	if parent == "." {
+		return ""
+	}
+	return parent
+}
@@ -0,0 +1,93 @@
+package cp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWalkFiles 测试遍历并过滤要发送的文件
+func TestWalkFiles(t *testing.T) {
+	a := assert.New(t)
+
+	root := filepath.Join(t.TempDir(), "root")
+	files := map[string]string{
+		".gitignore":             "*.log\n/build/\n!keep.log\n",
+		"keep.log":               "",
+		"a.log":                  "",
+		"main.go":                "",
+		"build/out":              "",
+		"src/build/x.go":         "",
+		"src/app.log":            "",
+		"src/.scafignore":        "secret\n",
+		"src/secret":             "",
+		"docs/guide/index.md":    "",
+		"docs/guide/image.png":   "",
+		"docs/guide/tmp/temp.md": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if !a.NoError(os.MkdirAll(filepath.Dir(p), 0o755)) {
+			return
+		}
+		if !a.NoError(os.WriteFile(p, []byte(content), 0o644)) {
+			return
+		}
+	}
+
+	walk := func(opts FilterOptions) []string {
+		var ret []string
+		a.NoError(WalkFiles(context.Background(), root, opts, func(entry FileEntry) error {
+			ret = append(ret, string(entry.Type)+" "+entry.Name)
+			return nil
+		}))
+		return ret
+	}
+
+	// 忽略文件和排除模式
+	a.Equal([]string{
+		"dir root",
+		"file root/.gitignore",
+		"dir root/docs",
+		"dir root/docs/guide",
+		"file root/docs/guide/index.md",
+		"file root/keep.log",
+		"file root/main.go",
+		"dir root/src",
+		"file root/src/.scafignore",
+		"dir root/src/build",
+		"file root/src/build/x.go",
+	}, walk(FilterOptions{
+		Excludes:    []string{"*.png", "docs/**/tmp/"},
+		IgnoreFiles: []string{".gitignore", ScafIgnoreFile},
+	}))
+
+	// 包含模式，只遍历到包含匹配的文件的目录
+	a.Equal([]string{
+		"dir root",
+		"dir root/docs",
+		"dir root/docs/guide",
+		"file root/docs/guide/index.md",
+		"dir root/docs/guide/tmp",
+		"file root/docs/guide/tmp/temp.md",
+		"file root/main.go",
+		"dir root/src",
+		"dir root/src/build",
+		"file root/src/build/x.go",
+	}, walk(FilterOptions{Includes: []string{"*.go", "*.md"}}))
+
+	// 匹配包含模式的目录中的所有文件
+	a.Equal([]string{
+		"dir root",
+		"dir root/docs",
+		"dir root/docs/guide",
+		"file root/docs/guide/image.png",
+		"file root/docs/guide/index.md",
+	}, walk(FilterOptions{Includes: []string{"docs/guide/"}, Excludes: []string{"tmp"}}))
+
+	// 不合法的模式
+	a.Error(WalkFiles(context.Background(), root, FilterOptions{Excludes: []string{"[z-a]"}}, nil))
+}
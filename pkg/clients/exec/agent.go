@@ -7,10 +7,15 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/creack/pty"
 	"github.com/go-logr/logr"
 
+	"github.com/yhlooo/scaf/pkg/apierrors"
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
 	"github.com/yhlooo/scaf/pkg/clients/common"
 	"github.com/yhlooo/scaf/pkg/streams"
@@ -18,6 +23,7 @@ import (
 
 const (
 	maxReadOutputSize = 16 << 10 // 16KiB
+	closeWaitTimeout  = 5 * time.Second
 )
 
 // NewAgent 创建 Agent
@@ -27,7 +33,8 @@ func NewAgent(client common.Client) *Agent {
 
 // Agent exec 代理
 type Agent struct {
-	c common.Client
+	c      common.Client
+	policy *Policy
 }
 
 // Client 返回 Agent 使用的客户端
@@ -38,31 +45,53 @@ func (agent *Agent) Client() common.Client {
 // WithClient 返回使用指定客户端的 Agent
 func (agent *Agent) WithClient(client common.Client) *Agent {
 	return &Agent{
-		c: client,
+		c:      client,
+		policy: agent.policy,
 	}
 }
 
+// WithPolicy 返回使用指定执行策略的 Agent
+// policy 为 nil 时不限制执行的命令
+func (agent *Agent) WithPolicy(policy *Policy) *Agent {
+	return &Agent{
+		c:      agent.c,
+		policy: policy,
+	}
+}
+
+// Policy 返回 Agent 使用的执行策略
+func (agent *Agent) Policy() *Policy {
+	return agent.policy
+}
+
 // Run 与服务端建立连接并运行命令
 // 阻塞直到运行结束
+//
+// 流是会话流时，按终端的请求在会话中运行多个命令，阻塞直到会话结束
 func (agent *Agent) Run(ctx context.Context, stream *streamv1.Stream) error {
+	if IsSessionStream(stream) {
+		return agent.runSession(ctx, stream)
+	}
 	logger := logr.FromContextOrDiscard(ctx)
 
 	streamName := stream.Name
-	command, input, tty, err := GetExecOptions(stream)
+	spec, err := GetExecSpec(stream)
 	if err != nil {
-		return fmt.Errorf("get exec options error: %w", err)
+		return fmt.Errorf("get exec spec error: %w", err)
 	}
-	if len(command) < 1 {
-		return fmt.Errorf("exec command is empty")
+	if err := spec.Validate(); err != nil {
+		return err
 	}
+	input, tty := spec.Input, spec.TTY
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
-
 	// 与服务端建立连接
-	conn, err := agent.c.ConnectStream(ctx, streamName, common.ConnectStreamOptions{
+	// 连接不随命令结束而取消，命令结束后还需要发送退出码等最后的消息
+	connCtx, cancelConn := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelConn()
+	conn, err := agent.c.ConnectStream(connCtx, streamName, common.ConnectStreamOptions{
 		ConnectionName: "agent",
 	})
 	if err != nil {
@@ -72,9 +101,23 @@ func (agent *Agent) Run(ctx context.Context, stream *streamv1.Stream) error {
 		conn = streams.ConnectionWithLog{Connection: conn}
 	}
 	defer func() {
-		_ = conn.Close(ctx)
+		_ = conn.Close(connCtx)
 	}()
 
+	// 检查执行策略，违反策略时将结构化的错误发送给终端
+	var sandbox *SandboxPolicy
+	if agent.policy != nil {
+		if err := agent.policy.Apply(spec); err != nil {
+			return agent.reject(connCtx, conn, err)
+		}
+		sandbox = agent.policy.Sandbox
+	}
+
+	cmd, err := newCommand(ctx, spec, sandbox)
+	if err != nil {
+		return agent.reject(connCtx, conn, err)
+	}
+
 	var inputWriter io.Writer
 	var outputReader io.Reader
 	var errorReader io.Reader
@@ -84,7 +127,7 @@ func (agent *Agent) Run(ctx context.Context, stream *streamv1.Stream) error {
 		// 在 pty 中运行
 		ptmx, err := pty.Start(cmd)
 		if err != nil {
-			return fmt.Errorf("start command error: %w", err)
+			return agent.reject(connCtx, conn, fmt.Errorf("start command error: %w", err))
 		}
 		defer func() {
 			if err := ptmx.Close(); err != nil {
@@ -120,14 +163,33 @@ func (agent *Agent) Run(ctx context.Context, stream *streamv1.Stream) error {
 			cmd.Stdin = stdinR
 		}
 
+		// 在新进程组中运行，以便将信号发送给其所有子进程
+		// pty 中运行的命令在新会话中，本身就是进程组组长
+		setProcessGroup(cmd)
 		if err := cmd.Start(); err != nil {
-			return fmt.Errorf("start command error: %w", err)
+			return agent.reject(connCtx, conn, fmt.Errorf("start command error: %w", err))
 		}
 	}
+	signalCmd := func(name string) error {
+		return signalProcessGroup(cmd.Process, name)
+	}
+
+	// 超过最长运行时间时杀死命令
+	var timedOut atomic.Bool
+	if agent.policy != nil && agent.policy.MaxRuntime > 0 {
+		timer := time.AfterFunc(agent.policy.MaxRuntime, func() {
+			logger.Info(fmt.Sprintf("command exceeded max runtime %s, kill it", agent.policy.MaxRuntime))
+			timedOut.Store(true)
+			if err := signalCmd("SIGKILL"); err != nil {
+				_ = cmd.Process.Kill()
+			}
+		})
+		defer timer.Stop()
+	}
 
 	// 转发输入输出
 	handleConnDone := make(chan struct{})
-	go agent.handleConn(ctx, handleConnDone, conn, inputWriter, input)
+	go agent.handleConn(connCtx, handleConnDone, conn, inputWriter, input, signalCmd)
 	handleStdoutDone := make(chan struct{})
 	go agent.handleOutput(ctx, handleStdoutDone, conn, outputReader, false)
 	if errorReader != nil {
@@ -142,29 +204,124 @@ func (agent *Agent) Run(ctx context.Context, stream *streamv1.Stream) error {
 			cancel()
 		case <-ctx.Done():
 		}
-		_ = cmd.Process.Kill()
+		if err := signalCmd("SIGKILL"); err != nil {
+			_ = cmd.Process.Kill()
+		}
 	}()
 
 	// 等待命令结束
 	err = cmd.Wait()
 	var sendErr error
-	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) {
-			sendErr = conn.Send(ctx, ExitCode(exitErr.ExitCode()).Raw())
-		} else {
-			sendErr = conn.Send(ctx, ExitCode(255).Raw())
-		}
+	if timedOut.Load() {
+		status := apierrors.NewPolicyViolationError(fmt.Errorf(
+			"command exceeded max runtime %s and was killed", agent.policy.MaxRuntime,
+		))
+		sendErr = conn.Send(ctx, ErrorStatus{Status: *status}.Raw())
+		err = status
 	} else {
-		sendErr = conn.Send(ctx, ExitCode(0).Raw())
+		sendErr = conn.Send(ctx, exitMessage(err).Raw())
 	}
 	if sendErr != nil {
 		logger.Error(sendErr, "send exit code error")
+	} else {
+		waitConnDone(handleConnDone)
 	}
 
 	return err
 }
 
+// exitMessage 返回表示命令退出结果的消息， err 为 cmd.Wait 的返回值
+func exitMessage(err error) Message {
+	if err == nil {
+		return ExitCode(0)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if sig, ok := exitSignal(exitErr); ok {
+			return ExitSignal(sig)
+		}
+		return ExitCode(exitErr.ExitCode())
+	}
+	return ExitCode(255)
+}
+
+// reject 将错误以结构化的形式发送给终端，等待连接关闭后返回该错误
+func (agent *Agent) reject(ctx context.Context, conn streams.Connection, err error) error {
+	status := apierrors.NewFromError(err)
+	if sendErr := conn.Send(ctx, ErrorStatus{Status: *status}.Raw()); sendErr != nil {
+		logr.FromContextOrDiscard(ctx).Error(sendErr, "send error status error")
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, err := streams.ReceiveData(ctx, conn); err != nil {
+				return
+			}
+		}
+	}()
+	waitConnDone(done)
+	return err
+}
+
+// waitConnDone 等待终端收到最后的消息后离开（收到对端离开的控制消息或连接关闭），避免连接过早关闭导致最后的消息丢失
+// 超过 closeWaitTimeout 时直接返回
+func waitConnDone(done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-time.After(closeWaitTimeout):
+	}
+}
+
+// newCommand 基于参数创建命令
+// sandbox 不为 nil 时在沙箱中运行
+func newCommand(ctx context.Context, spec *ExecSpec, sandbox *SandboxPolicy) (*exec.Cmd, error) {
+	var cred *syscall.Credential
+	var userEnv []string
+	if spec.User != "" {
+		var err error
+		cred, userEnv, err = userCredential(spec.User)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 用执行端自身的 PATH 查找命令，请求中设置的 PATH 只对命令的子进程生效
+	if !strings.Contains(spec.Command[0], "/") {
+		path, err := exec.LookPath(spec.Command[0])
+		if err != nil {
+			return nil, err
+		}
+		resolved := *spec
+		resolved.Command = append([]string{path}, spec.Command[1:]...)
+		spec = &resolved
+	}
+
+	var cmd *exec.Cmd
+	if sandbox.isolated() {
+		var err error
+		cmd, err = newSandboxCommand(ctx, spec, sandbox, cred)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cmd = exec.CommandContext(ctx, spec.Command[0], spec.Command[1:]...)
+		if cred != nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+		}
+	}
+	cmd.Dir = spec.WorkingDir
+
+	env := append(os.Environ(), userEnv...)
+	if len(spec.Env) > 0 || spec.User != "" {
+		// 后面的值覆盖前面的同名值
+		cmd.Env = append(env, spec.Env...)
+	}
+	return cmd, nil
+}
+
 // handleConn 处理连接
 func (agent *Agent) handleConn(
 	ctx context.Context,
@@ -172,6 +329,7 @@ func (agent *Agent) handleConn(
 	conn streams.Connection,
 	stdinWriter io.Writer,
 	input bool,
+	signalCmd func(name string) error,
 ) {
 	defer close(done)
 	logger := logr.FromContextOrDiscard(ctx)
@@ -188,13 +346,17 @@ func (agent *Agent) handleConn(
 		default:
 		}
 
-		data, err := conn.Receive(ctx)
+		data, err := receiveCommandInput(ctx, conn, signalCmd)
 		if err != nil {
 			select {
 			case <-ctx.Done():
 				return
 			default:
 			}
+			if errors.Is(err, streams.ErrPeerLeft) {
+				logger.Info("terminal left")
+				return
+			}
 			logger.Error(err, "receive from server error")
 			if errors.Is(err, streams.ErrConnectionClosed) {
 				return
@@ -224,12 +386,57 @@ func (agent *Agent) handleConn(
 			if err := pty.Setsize(stdinFile, &pty.Winsize{Rows: m.Height, Cols: m.Width}); err != nil {
 				logger.Error(err, "set pty size error")
 			}
+		case Signal:
+			sendSignalToCommand(ctx, signalCmd, string(m))
 		default:
 			logger.Info(fmt.Sprintf("unsupported message type: %s, msg: %v", m.Type(), m))
 		}
 	}
 }
 
+// receiveCommandInput 接收终端发给命令的数据，跳过其它控制消息，错误消息作为错误返回
+//
+// 收到 Signal 控制消息（如通过 scaf stream signal 发送）时向命令发送信号。
+// 收到对端离开的控制消息时返回 streams.ErrPeerLeft
+func receiveCommandInput(
+	ctx context.Context,
+	conn streams.Connection,
+	signalCmd func(name string) error,
+) ([]byte, error) {
+	for {
+		msg, err := streams.ReceiveMessage(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		if msg.Control == nil {
+			return msg.Data, nil
+		}
+		switch msg.Control.Type {
+		case streams.PeerLeftControl:
+			return nil, streams.ErrPeerLeft
+		case streams.SignalControl:
+			name, err := ParseSignalName(msg.Control.Signal)
+			if err != nil {
+				logr.FromContextOrDiscard(ctx).Error(err, "parse signal error")
+				continue
+			}
+			sendSignalToCommand(ctx, signalCmd, name)
+		}
+	}
+}
+
+// sendSignalToCommand 向命令发送信号
+func sendSignalToCommand(ctx context.Context, signalCmd func(name string) error, name string) {
+	logger := logr.FromContextOrDiscard(ctx)
+	logger.Info(fmt.Sprintf("send signal %s to command", name))
+	if err := signalCmd(name); err != nil {
+		logger.Error(err, fmt.Sprintf("send signal %s to command error", name))
+	}
+}
+
 // handleOutput 处理输出
 func (agent *Agent) handleOutput(
 	ctx context.Context,
@@ -0,0 +1,403 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/go-logr/logr"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+const (
+	// channelInputBufferSize 每个通道缓冲的标准输入消息数
+	// 避免一个命令不读取标准输入时阻塞会话中的其它通道
+	channelInputBufferSize = 16
+)
+
+// runSession 与服务端建立连接，按终端的请求在会话中运行命令
+// 阻塞直到连接断开，返回前杀死仍在运行的命令
+func (agent *Agent) runSession(ctx context.Context, stream *streamv1.Stream) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	conn, err := agent.c.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{
+		ConnectionName: "agent",
+	})
+	if err != nil {
+		return fmt.Errorf("connect to server error: %w", err)
+	}
+	if logger.V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+	defer func() {
+		_ = conn.Close(context.WithoutCancel(ctx))
+	}()
+
+	session := &agentSession{
+		policy:   agent.policy,
+		conn:     &lockedConnection{Connection: conn},
+		channels: map[uint32]*agentChannel{},
+	}
+	session.serve(ctx)
+	return nil
+}
+
+// agentSession 执行端的 exec 会话
+type agentSession struct {
+	policy *Policy
+	conn   streams.Connection
+
+	lock     sync.Mutex
+	channels map[uint32]*agentChannel
+	wg       sync.WaitGroup
+}
+
+// serve 接收并处理终端的消息，直到连接断开
+func (s *agentSession) serve(ctx context.Context) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		s.killAll(ctx)
+		cancel()
+		s.wg.Wait()
+	}()
+
+	for {
+		data, err := streams.ReceiveData(ctx, s.conn)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, streams.ErrConnectionClosed) || errors.Is(err, streams.ErrPeerLeft) {
+				return
+			}
+			logger.Error(err, "receive from server error")
+			continue
+		}
+
+		msg, err := ParseMessage(data)
+		if err != nil {
+			logger.Error(err, "parse message error")
+			continue
+		}
+
+		switch m := msg.(type) {
+		case ChannelOpen:
+			s.open(ctx, m)
+		case ChannelMessage:
+			s.handleChannelMessage(ctx, m)
+		default:
+			logger.Info(fmt.Sprintf("unsupported message type: %s, msg: %v", m.Type(), m))
+		}
+	}
+}
+
+// open 打开通道并在其中运行命令
+func (s *agentSession) open(ctx context.Context, m ChannelOpen) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.channels[m.ID]; ok {
+		logger.Info(fmt.Sprintf("WARN channel %d already exists, ignore open message", m.ID))
+		return
+	}
+	ch := &agentChannel{
+		id:    m.ID,
+		input: make(chan []byte, channelInputBufferSize),
+		done:  make(chan struct{}),
+	}
+	s.channels[m.ID] = ch
+	s.wg.Add(1)
+	go s.runChannel(ctx, ch, m.Spec)
+}
+
+// runChannel 在通道中运行命令，结束后将运行结果发送给终端并关闭通道
+func (s *agentSession) runChannel(ctx context.Context, ch *agentChannel, spec ExecSpec) {
+	defer s.wg.Done()
+	logger := logr.FromContextOrDiscard(ctx).WithValues("channel", ch.id)
+	ctx = logr.NewContext(ctx, logger)
+
+	result := s.exec(ctx, ch, &spec)
+	close(ch.done)
+
+	s.lock.Lock()
+	delete(s.channels, ch.id)
+	s.lock.Unlock()
+
+	if err := s.conn.Send(ctx, ChannelMessage{ID: ch.id, Message: result}.Raw()); err != nil {
+		logger.Error(err, "send exit message error")
+	}
+}
+
+// exec 运行命令并转发输入输出，返回表示运行结果的消息
+func (s *agentSession) exec(ctx context.Context, ch *agentChannel, spec *ExecSpec) Message {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	// 检查参数和执行策略
+	if err := spec.Validate(); err != nil {
+		return ErrorStatus{Status: *apierrors.NewBadRequestError(err)}
+	}
+	var sandbox *SandboxPolicy
+	if s.policy != nil {
+		if err := s.policy.Apply(spec); err != nil {
+			return ErrorStatus{Status: *apierrors.NewFromError(err)}
+		}
+		sandbox = s.policy.Sandbox
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	cmd, err := newCommand(ctx, spec, sandbox)
+	if err != nil {
+		return ErrorStatus{Status: *apierrors.NewFromError(err)}
+	}
+
+	// 启动命令
+	var stdin io.WriteCloser
+	var stdout, stderr io.Reader
+	var ptmx *os.File
+	if spec.TTY {
+		ptmx, err = pty.Start(cmd)
+		if err != nil {
+			return ErrorStatus{Status: *apierrors.NewFromError(fmt.Errorf("start command error: %w", err))}
+		}
+		defer func() {
+			if err := ptmx.Close(); err != nil {
+				logger.Error(err, "close pty error")
+			}
+		}()
+		stdout = ptmx
+		if spec.Input {
+			stdin = ptmx
+		}
+	} else {
+		if stdout, err = cmd.StdoutPipe(); err != nil {
+			return ErrorStatus{Status: *apierrors.NewFromError(err)}
+		}
+		if stderr, err = cmd.StderrPipe(); err != nil {
+			return ErrorStatus{Status: *apierrors.NewFromError(err)}
+		}
+		if spec.Input {
+			if stdin, err = cmd.StdinPipe(); err != nil {
+				return ErrorStatus{Status: *apierrors.NewFromError(err)}
+			}
+		}
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			return ErrorStatus{Status: *apierrors.NewFromError(fmt.Errorf("start command error: %w", err))}
+		}
+	}
+	ch.started(cmd.Process, ptmx)
+
+	// 超过最长运行时间时杀死命令
+	var timedOut atomic.Bool
+	if s.policy != nil && s.policy.MaxRuntime > 0 {
+		timer := time.AfterFunc(s.policy.MaxRuntime, func() {
+			logger.Info(fmt.Sprintf("command exceeded max runtime %s, kill it", s.policy.MaxRuntime))
+			timedOut.Store(true)
+			ch.signal(ctx, "SIGKILL")
+		})
+		defer timer.Stop()
+	}
+
+	// 转发输入输出
+	// 在 TTY 中运行时关闭 pty 会导致无法读取剩余输出，因此输入结束时不关闭
+	go ch.forwardInput(ctx, stdin, !spec.TTY)
+	outputWG := sync.WaitGroup{}
+	for _, output := range []struct {
+		reader io.Reader
+		stderr bool
+	}{{stdout, false}, {stderr, true}} {
+		if output.reader == nil {
+			continue
+		}
+		outputWG.Add(1)
+		go func() {
+			defer outputWG.Done()
+			s.forwardOutput(ctx, ch.id, output.reader, output.stderr)
+		}()
+	}
+	// 需要读完输出后再等待命令结束
+	outputWG.Wait()
+
+	err = cmd.Wait()
+	if timedOut.Load() {
+		return ErrorStatus{Status: *apierrors.NewPolicyViolationError(fmt.Errorf(
+			"command exceeded max runtime %s and was killed", s.policy.MaxRuntime,
+		))}
+	}
+	return exitMessage(err)
+}
+
+// forwardOutput 将命令输出转发给终端，直到读完输出
+func (s *agentSession) forwardOutput(ctx context.Context, id uint32, reader io.Reader, stderr bool) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	tmp := make([]byte, maxReadOutputSize)
+	for {
+		n, err := reader.Read(tmp)
+		if n > 0 {
+			var msg Message = StdoutData(tmp[:n])
+			if stderr {
+				msg = StderrData(tmp[:n])
+			}
+			if err := s.conn.Send(ctx, ChannelMessage{ID: id, Message: msg}.Raw()); err != nil {
+				logger.Error(err, "send message to server error")
+			}
+		}
+		if err != nil {
+			// pty 在命令退出后读取返回 EIO ，同样表示输出结束
+			if err != io.EOF && !errors.Is(err, os.ErrClosed) && ctx.Err() == nil {
+				logger.V(1).Info(fmt.Sprintf("read output end: %v", err))
+			}
+			return
+		}
+	}
+}
+
+// handleChannelMessage 处理发给通道的消息
+func (s *agentSession) handleChannelMessage(ctx context.Context, m ChannelMessage) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	s.lock.Lock()
+	ch := s.channels[m.ID]
+	s.lock.Unlock()
+	if ch == nil {
+		logger.Info(fmt.Sprintf("WARN channel %d not found, ignore %s message", m.ID, m.Message.Type()))
+		return
+	}
+
+	switch inner := m.Message.(type) {
+	case StdinData:
+		ch.writeInput(ctx, inner)
+	case StdinEOF:
+		ch.closeInput()
+	case Resize:
+		ch.resize(ctx, inner)
+	case Signal:
+		logger.Info(fmt.Sprintf("send signal %s to command in channel %d", inner, m.ID))
+		ch.signal(ctx, string(inner))
+	default:
+		logger.Info(fmt.Sprintf("unsupported channel message type: %s, msg: %v", inner.Type(), inner))
+	}
+}
+
+// killAll 杀死会话中所有仍在运行的命令
+func (s *agentSession) killAll(ctx context.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, ch := range s.channels {
+		ch.signal(ctx, "SIGKILL")
+	}
+}
+
+// agentChannel 执行端会话中的通道，对应一个命令
+type agentChannel struct {
+	id uint32
+	// 待写入命令标准输入的数据
+	// 只在会话接收消息的协程中写入和关闭
+	input       chan []byte
+	inputClosed bool
+	// 命令结束后关闭
+	done chan struct{}
+
+	lock    sync.Mutex
+	process *os.Process
+	ptmx    *os.File
+}
+
+// started 记录已启动的命令进程
+func (ch *agentChannel) started(process *os.Process, ptmx *os.File) {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	ch.process = process
+	ch.ptmx = ptmx
+}
+
+// writeInput 将数据放入待写入标准输入的缓冲
+func (ch *agentChannel) writeInput(ctx context.Context, data []byte) {
+	if ch.inputClosed {
+		return
+	}
+	select {
+	case ch.input <- data:
+	case <-ch.done:
+	case <-ctx.Done():
+	}
+}
+
+// closeInput 标记标准输入结束
+func (ch *agentChannel) closeInput() {
+	if ch.inputClosed {
+		return
+	}
+	ch.inputClosed = true
+	close(ch.input)
+}
+
+// forwardInput 将缓冲的数据写入命令标准输入，直到输入结束或 ctx 结束
+// stdin 为 nil 时丢弃输入
+func (ch *agentChannel) forwardInput(ctx context.Context, stdin io.WriteCloser, closeOnEOF bool) {
+	logger := logr.FromContextOrDiscard(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-ch.input:
+			if !ok {
+				if stdin != nil && closeOnEOF {
+					if err := stdin.Close(); err != nil {
+						logger.Error(err, "close stdin error")
+					}
+				}
+				return
+			}
+			if stdin == nil {
+				continue
+			}
+			if _, err := stdin.Write(data); err != nil {
+				logger.Error(err, "write to stdin error")
+			}
+		}
+	}
+}
+
+// resize 调整 pty 窗口大小
+func (ch *agentChannel) resize(ctx context.Context, m Resize) {
+	logger := logr.FromContextOrDiscard(ctx)
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.ptmx == nil {
+		logger.Info(fmt.Sprintf("not support resize pty, msg: %v", m))
+		return
+	}
+	if err := pty.Setsize(ch.ptmx, &pty.Winsize{Rows: m.Height, Cols: m.Width}); err != nil {
+		logger.Error(err, "set pty size error")
+	}
+}
+
+// signal 向命令所在进程组发送信号
+func (ch *agentChannel) signal(ctx context.Context, name string) {
+	logger := logr.FromContextOrDiscard(ctx)
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.process == nil {
+		logger.Info(fmt.Sprintf("WARN command in channel %d not started, ignore signal %s", ch.id, name))
+		return
+	}
+	if err := signalProcessGroup(ch.process, name); err != nil {
+		logger.Error(err, fmt.Sprintf("send signal %s to command error", name))
+		if name == "SIGKILL" {
+			_ = ch.process.Kill()
+		}
+	}
+}
@@ -2,7 +2,10 @@ package exec
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
 )
 
 // ParseMessage 解析消息
@@ -31,6 +34,46 @@ func ParseMessage(raw []byte) (Message, error) {
 			return nil, fmt.Errorf("invalid exit code: %v (must be 5 bytes)", raw)
 		}
 		return ExitCode(int32(binary.BigEndian.Uint32(raw[1:5]))), nil
+	case SignalFlag:
+		if len(raw) < 2 {
+			return nil, fmt.Errorf("invalid signal message: %v (signal name is empty)", raw)
+		}
+		return Signal(raw[1:]), nil
+	case ExitSignalFlag:
+		if len(raw) < 2 {
+			return nil, fmt.Errorf("invalid exit signal message: %v (signal name is empty)", raw)
+		}
+		return ExitSignal(raw[1:]), nil
+	case ErrorStatusFlag:
+		status := ErrorStatus{}
+		if err := json.Unmarshal(raw[1:], &status.Status); err != nil {
+			return nil, fmt.Errorf("invalid error status message: %w", err)
+		}
+		return status, nil
+	case ChannelOpenFlag:
+		if len(raw) < 5 {
+			return nil, fmt.Errorf("invalid channel open message: %v (must be at least 5 bytes)", raw)
+		}
+		m := ChannelOpen{ID: binary.BigEndian.Uint32(raw[1:5])}
+		if err := json.Unmarshal(raw[5:], &m.Spec); err != nil {
+			return nil, fmt.Errorf("invalid channel open message: %w", err)
+		}
+		return m, nil
+	case ChannelMessageFlag:
+		if len(raw) < 6 {
+			return nil, fmt.Errorf("invalid channel message: %v (must be at least 6 bytes)", raw)
+		}
+		inner, err := ParseMessage(raw[5:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel message: %w", err)
+		}
+		switch inner.(type) {
+		case ChannelOpen, ChannelMessage:
+			return nil, fmt.Errorf("invalid channel message: nested %s message", inner.Type())
+		}
+		return ChannelMessage{ID: binary.BigEndian.Uint32(raw[1:5]), Message: inner}, nil
+	case StdinEOFFlag:
+		return StdinEOF{}, nil
 	default:
 		return nil, fmt.Errorf("unknown data flag: %d, raw: %v", raw[0], raw)
 	}
@@ -48,11 +91,17 @@ type Message interface {
 type MessageType string
 
 const (
-	StdinDataType  MessageType = "StdinData"
-	StdoutDataType MessageType = "StdoutData"
-	StderrDataType MessageType = "StderrData"
-	ResizeType     MessageType = "Resize"
-	ExitCodeType   MessageType = "ExitCode"
+	StdinDataType      MessageType = "StdinData"
+	StdoutDataType     MessageType = "StdoutData"
+	StderrDataType     MessageType = "StderrData"
+	ResizeType         MessageType = "Resize"
+	ExitCodeType       MessageType = "ExitCode"
+	SignalType         MessageType = "Signal"
+	ExitSignalType     MessageType = "ExitSignal"
+	ErrorStatusType    MessageType = "ErrorStatus"
+	ChannelOpenType    MessageType = "ChannelOpen"
+	ChannelMessageType MessageType = "ChannelMessage"
+	StdinEOFType       MessageType = "StdinEOF"
 )
 
 const (
@@ -61,6 +110,12 @@ const (
 	StderrDataFlag
 	ResizeFlag
 	ExitCodeFlag
+	SignalFlag
+	ExitSignalFlag
+	ErrorStatusFlag
+	ChannelOpenFlag
+	ChannelMessageFlag
+	StdinEOFFlag
 )
 
 // StdinData 标准输入流数据
@@ -141,3 +196,110 @@ func (e ExitCode) Raw() []byte {
 	binary.BigEndian.PutUint32(raw[1:5], uint32(e))
 	return raw
 }
+
+// Signal 向进程发送信号消息
+// 使用信号名（如 SIGINT ）而非信号值，因为不同系统上同一信号的值可能不同
+type Signal string
+
+// Type 返回消息类型
+func (s Signal) Type() MessageType {
+	return SignalType
+}
+
+// Raw 返回消息原始数据
+// 格式： 5 name([]byte)
+func (s Signal) Raw() []byte {
+	return append([]byte{SignalFlag}, s...)
+}
+
+// ExitSignal 进程因信号退出消息
+type ExitSignal string
+
+// Type 返回消息类型
+func (s ExitSignal) Type() MessageType {
+	return ExitSignalType
+}
+
+// Raw 返回消息原始数据
+// 格式： 6 name([]byte)
+func (s ExitSignal) Raw() []byte {
+	return append([]byte{ExitSignalFlag}, s...)
+}
+
+// ErrorStatus 执行端返回的结构化错误消息，如命令违反执行策略
+type ErrorStatus struct {
+	metav1.Status
+}
+
+// Type 返回消息类型
+func (s ErrorStatus) Type() MessageType {
+	return ErrorStatusType
+}
+
+// Raw 返回消息原始数据
+// 格式： 7 status(json)
+func (s ErrorStatus) Raw() []byte {
+	raw, _ := json.Marshal(s.Status)
+	return append([]byte{ErrorStatusFlag}, raw...)
+}
+
+// ChannelOpen 在会话中打开通道并启动命令的消息
+type ChannelOpen struct {
+	// 通道 ID ，由终端分配，在会话中唯一
+	ID uint32
+	// 命令参数
+	Spec ExecSpec
+}
+
+// Type 返回消息类型
+func (o ChannelOpen) Type() MessageType {
+	return ChannelOpenType
+}
+
+// Raw 返回消息原始数据
+// 格式： 8 id(uint32) spec(json)
+func (o ChannelOpen) Raw() []byte {
+	spec, _ := json.Marshal(o.Spec)
+	raw := make([]byte, 5, 5+len(spec))
+	raw[0] = ChannelOpenFlag
+	binary.BigEndian.PutUint32(raw[1:5], o.ID)
+	return append(raw, spec...)
+}
+
+// ChannelMessage 会话中属于指定通道的消息
+// 通道中可以传输除 ChannelOpen 和 ChannelMessage 外的所有消息
+type ChannelMessage struct {
+	// 通道 ID
+	ID uint32
+	// 通道内的消息
+	Message Message
+}
+
+// Type 返回消息类型
+func (m ChannelMessage) Type() MessageType {
+	return ChannelMessageType
+}
+
+// Raw 返回消息原始数据
+// 格式： 9 id(uint32) message([]byte)
+func (m ChannelMessage) Raw() []byte {
+	inner := m.Message.Raw()
+	raw := make([]byte, 5, 5+len(inner))
+	raw[0] = ChannelMessageFlag
+	binary.BigEndian.PutUint32(raw[1:5], m.ID)
+	return append(raw, inner...)
+}
+
+// StdinEOF 标准输入流结束消息，执行端收到后关闭命令的标准输入
+type StdinEOF struct{}
+
+// Type 返回消息类型
+func (e StdinEOF) Type() MessageType {
+	return StdinEOFType
+}
+
+// Raw 返回消息原始数据
+// 格式： 10
+func (e StdinEOF) Raw() []byte {
+	return []byte{StdinEOFFlag}
+}
@@ -0,0 +1,52 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseMessage 测试解析消息
+func TestParseMessage(t *testing.T) {
+	a := assert.New(t)
+
+	for _, msg := range []Message{
+		StdinData("in"),
+		StdoutData("out"),
+		StderrData("err"),
+		Resize{Height: 24, Width: 80},
+		ExitCode(-1),
+		Signal("SIGINT"),
+		ExitSignal("SIGKILL"),
+		ChannelOpen{ID: 1, Spec: ExecSpec{Command: []string{"ls", "-l"}, Input: true}},
+		ChannelMessage{ID: 2, Message: StdoutData("out")},
+		ChannelMessage{ID: 3, Message: ExitCode(1)},
+		StdinEOF{},
+	} {
+		got, err := ParseMessage(msg.Raw())
+		if a.NoError(err, msg.Type()) {
+			a.Equal(msg, got)
+		}
+	}
+
+	_, err := ParseMessage([]byte{SignalFlag})
+	a.Error(err)
+	_, err = ParseMessage(nil)
+	a.Error(err)
+	_, err = ParseMessage(ChannelMessage{ID: 1, Message: ChannelMessage{ID: 2, Message: StdinEOF{}}}.Raw())
+	a.Error(err)
+}
+
+// TestParseSignalName 测试解析信号名
+func TestParseSignalName(t *testing.T) {
+	a := assert.New(t)
+
+	for _, name := range []string{"SIGTERM", "TERM", "term", "sigterm"} {
+		got, err := ParseSignalName(name)
+		if a.NoError(err, name) {
+			a.Equal("SIGTERM", got)
+		}
+	}
+	_, err := ParseSignalName("SIGSTOP")
+	a.Error(err)
+}
@@ -0,0 +1,206 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+)
+
+// Policy 执行端策略
+// 限制通过流请求执行的命令，用于无人值守的执行端
+type Policy struct {
+	// 允许执行的命令的模式，为空时允许所有命令，语法同 path.Match
+	// 模式包含 / 时须为绝对路径，匹配命令的完整路径；否则只匹配不包含 / 的命令名，命令名通过执行端自身的 PATH 查找
+	// 相对路径基于请求指定的工作目录解析，不能用于限制执行的程序，因此不允许
+	AllowedCommands []string `json:"allowedCommands,omitempty" yaml:"allowedCommands,omitempty"`
+	// 禁止出现的参数，每项为正则表达式，匹配任一参数时拒绝执行
+	ForbiddenArgs []string `json:"forbiddenArgs,omitempty" yaml:"forbiddenArgs,omitempty"`
+	// 允许设置的环境变量名的模式，为空时允许设置 sensitiveEnv 以外的所有环境变量
+	// sensitiveEnv 中的环境变量只有在此处按名称列出时才允许设置
+	AllowedEnv []string `json:"allowedEnv,omitempty" yaml:"allowedEnv,omitempty"`
+	// 禁止设置的环境变量名的模式，优先于 AllowedEnv
+	ForbiddenEnv []string `json:"forbiddenEnv,omitempty" yaml:"forbiddenEnv,omitempty"`
+	// 允许指定的运行用户，为空时允许指定任意用户
+	AllowedUsers []string `json:"allowedUsers,omitempty" yaml:"allowedUsers,omitempty"`
+	// 命令最长运行时间，超过后命令会被杀死，为 0 时不限制
+	MaxRuntime time.Duration `json:"maxRuntime,omitempty" yaml:"maxRuntime,omitempty"`
+	// 沙箱，为 nil 时不使用沙箱
+	Sandbox *SandboxPolicy `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+}
+
+// sensitiveEnv 可以改变执行的程序或其加载的库的环境变量名的模式
+var sensitiveEnv = []string{"PATH", "LD_*", "DYLD_*"}
+
+// SandboxInitCommand 在沙箱中初始化并执行命令的子命令名
+const SandboxInitCommand = "sandbox-init"
+
+// SandboxPolicy 沙箱策略
+//
+// 命名空间和资源限制只在 Linux 上支持，且执行端需以 root 运行
+type SandboxPolicy struct {
+	// 运行命令的专用用户，设置后请求中只能不指定用户或指定该用户
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+	// 在新的 PID 命名空间中运行
+	// 命令在命名空间中是 1 号进程，不会收到其未处理的信号（ SIGKILL 除外）
+	NewPIDNamespace bool `json:"newPIDNamespace,omitempty" yaml:"newPIDNamespace,omitempty"`
+	// 在新的挂载命名空间中运行，与 NewPIDNamespace 同时开启时会重新挂载 /proc
+	NewMountNamespace bool `json:"newMountNamespace,omitempty" yaml:"newMountNamespace,omitempty"`
+	// 资源限制
+	Limits SandboxLimits `json:"limits,omitempty" yaml:"limits,omitempty"`
+}
+
+// isolated 返回是否需要通过 sandbox-init 在隔离环境中运行命令
+func (s *SandboxPolicy) isolated() bool {
+	return s != nil && (s.NewPIDNamespace || s.NewMountNamespace || !s.Limits.IsZero())
+}
+
+// SandboxLimits 沙箱资源限制，值为 0 时不限制
+type SandboxLimits struct {
+	// CPU 时间（秒）
+	CPUSeconds uint64 `json:"cpuSeconds,omitempty" yaml:"cpuSeconds,omitempty"`
+	// 虚拟内存大小（字节）
+	MemoryBytes uint64 `json:"memoryBytes,omitempty" yaml:"memoryBytes,omitempty"`
+	// 可写文件大小（字节）
+	FileSizeBytes uint64 `json:"fileSizeBytes,omitempty" yaml:"fileSizeBytes,omitempty"`
+}
+
+// IsZero 返回是否没有任何限制
+func (l SandboxLimits) IsZero() bool {
+	return l == SandboxLimits{}
+}
+
+// LoadPolicy 从 YAML 文件加载策略
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy %q error: %w", path, err)
+	}
+	policy := &Policy{}
+	if err := yaml.Unmarshal(raw, policy); err != nil {
+		return nil, fmt.Errorf("unmarshal policy %q from yaml error: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %q: %w", path, err)
+	}
+	return policy, nil
+}
+
+// Validate 校验策略
+func (p *Policy) Validate() error {
+	for _, pattern := range p.AllowedCommands {
+		if strings.Contains(pattern, "/") && !path.IsAbs(pattern) {
+			return fmt.Errorf("invalid allowed command pattern %q: must be an absolute path or a command name", pattern)
+		}
+	}
+	for _, patterns := range [][]string{p.AllowedCommands, p.AllowedEnv, p.ForbiddenEnv} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	for _, expr := range p.ForbiddenArgs {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid forbidden args expression %q: %w", expr, err)
+		}
+	}
+	if p.MaxRuntime < 0 {
+		return fmt.Errorf("maxRuntime must not be negative")
+	}
+	return nil
+}
+
+// Apply 检查 exec 参数是否符合策略，并将策略应用到参数上
+// 不符合策略时返回 Reason 为 PolicyViolation 的 *metav1.Status
+func (p *Policy) Apply(spec *ExecSpec) error {
+	if len(spec.Command) < 1 {
+		return apierrors.NewPolicyViolationError(fmt.Errorf("command is empty"))
+	}
+
+	// 命令
+	if len(p.AllowedCommands) > 0 {
+		name := spec.Command[0]
+		// 相对路径基于请求指定的工作目录解析，可以指向任意程序
+		if strings.Contains(name, "/") && !path.IsAbs(name) {
+			return apierrors.NewPolicyViolationError(fmt.Errorf(
+				"command %q is not allowed: must be an absolute path or a command name", name,
+			))
+		}
+		allowed := false
+		for _, pattern := range p.AllowedCommands {
+			// 命令名模式不匹配路径，否则允许 ls 时也会允许 /tmp/evil/ls
+			// 相对路径的模式无法限制执行的程序，不匹配任何命令
+			if strings.Contains(pattern, "/") != strings.Contains(name, "/") ||
+				(strings.Contains(pattern, "/") && !path.IsAbs(pattern)) {
+				continue
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return apierrors.NewPolicyViolationError(fmt.Errorf("command %q is not allowed", name))
+		}
+	}
+
+	// 参数
+	for _, expr := range p.ForbiddenArgs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return apierrors.NewPolicyViolationError(fmt.Errorf("invalid forbidden args expression %q: %w", expr, err))
+		}
+		for _, arg := range spec.Command[1:] {
+			if re.MatchString(arg) {
+				return apierrors.NewPolicyViolationError(fmt.Errorf("argument %q is forbidden", arg))
+			}
+		}
+	}
+
+	// 环境变量
+	for _, kv := range spec.Env {
+		key, _, _ := strings.Cut(kv, "=")
+		if matchAny(sensitiveEnv, key) && !slices.Contains(p.AllowedEnv, key) {
+			return apierrors.NewPolicyViolationError(fmt.Errorf(
+				"setting env %q is not allowed unless it is listed in allowedEnv", key,
+			))
+		}
+		if matchAny(p.ForbiddenEnv, key) || (len(p.AllowedEnv) > 0 && !matchAny(p.AllowedEnv, key)) {
+			return apierrors.NewPolicyViolationError(fmt.Errorf("setting env %q is not allowed", key))
+		}
+	}
+
+	// 用户
+	if spec.User != "" && len(p.AllowedUsers) > 0 && !matchAny(p.AllowedUsers, spec.User) {
+		return apierrors.NewPolicyViolationError(fmt.Errorf("running as user %q is not allowed", spec.User))
+	}
+	if p.Sandbox != nil && p.Sandbox.User != "" {
+		if spec.User != "" && spec.User != p.Sandbox.User {
+			return apierrors.NewPolicyViolationError(fmt.Errorf(
+				"running as user %q is not allowed, commands run as sandbox user %q",
+				spec.User, p.Sandbox.User,
+			))
+		}
+		spec.User = p.Sandbox.User
+	}
+
+	return nil
+}
+
+// matchAny 返回 name 是否匹配任一模式
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package exec
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+)
+
+// TestPolicy_Apply 测试检查执行策略
+func TestPolicy_Apply(t *testing.T) {
+	a := assert.New(t)
+
+	policy := &Policy{
+		AllowedCommands: []string{"ls", "git", "/usr/local/bin/*"},
+		ForbiddenArgs:   []string{`^--exec`},
+		AllowedEnv:      []string{"GIT_*", "LANG"},
+		ForbiddenEnv:    []string{"GIT_SSH*"},
+		Sandbox:         &SandboxPolicy{User: "runner"},
+	}
+	a.NoError(policy.Validate())
+
+	cases := []struct {
+		spec ExecSpec
+		ok   bool
+	}{
+		{spec: ExecSpec{Command: []string{"ls", "-l"}}, ok: true},
+		{spec: ExecSpec{Command: []string{"/bin/ls"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"/tmp/evil/ls"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"./ls"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"bin/tool"}, WorkingDir: "/usr/local"}, ok: false},
+		{spec: ExecSpec{Command: []string{"../local/bin/tool"}, WorkingDir: "/usr/local/bin"}, ok: false},
+		{spec: ExecSpec{Command: []string{"/usr/local/bin/tool"}}, ok: true},
+		{spec: ExecSpec{Command: []string{"/opt/tool"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"rm", "-rf", "/"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"git", "--exec-path=/tmp"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"git", "log"}, Env: []string{"GIT_DIR=.git", "LANG=C"}}, ok: true},
+		{spec: ExecSpec{Command: []string{"git", "log"}, Env: []string{"GIT_SSH_COMMAND=sh"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"git", "log"}, Env: []string{"LD_PRELOAD=x.so"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"ls"}, Env: []string{"PATH=/tmp/evil"}}, ok: false},
+		{spec: ExecSpec{Command: []string{"ls"}, User: "runner"}, ok: true},
+		{spec: ExecSpec{Command: []string{"ls"}, User: "root"}, ok: false},
+	}
+	for i, c := range cases {
+		err := policy.Apply(&c.spec)
+		if !c.ok {
+			if a.Error(err, "case %d", i) {
+				status, ok := err.(*metav1.Status)
+				a.True(ok, "case %d", i)
+				a.Equal(apierrors.ReasonPolicyViolation, status.Reason, "case %d", i)
+			}
+			continue
+		}
+		a.NoError(err, "case %d", i)
+		// 使用沙箱用户运行
+		a.Equal("runner", c.spec.User, "case %d", i)
+	}
+
+	// 未限制环境变量时也不允许设置可以改变执行的程序的环境变量，除非按名称列出
+	for _, kv := range []string{"PATH=/tmp/evil", "LD_PRELOAD=x.so", "LD_LIBRARY_PATH=/tmp/evil"} {
+		a.Error((&Policy{}).Apply(&ExecSpec{Command: []string{"ls"}, Env: []string{kv}}), kv)
+	}
+	a.NoError((&Policy{}).Apply(&ExecSpec{Command: []string{"ls"}, Env: []string{"LANG=C"}}))
+	a.Error((&Policy{AllowedEnv: []string{"*"}}).Apply(&ExecSpec{Command: []string{"ls"}, Env: []string{"PATH=/tmp"}}))
+	a.NoError((&Policy{AllowedEnv: []string{"PATH"}}).Apply(&ExecSpec{Command: []string{"ls"}, Env: []string{"PATH=/tmp"}}))
+
+	a.Error((&Policy{ForbiddenArgs: []string{"("}}).Validate())
+	a.Error((&Policy{AllowedCommands: []string{"["}}).Validate())
+
+	// 相对路径基于请求指定的工作目录解析，不能用于限制命令
+	a.Error((&Policy{AllowedCommands: []string{"bin/*"}}).Validate())
+	a.Error((&Policy{AllowedCommands: []string{"./tool"}}).Validate())
+	a.Error((&Policy{AllowedCommands: []string{"bin/*"}}).Apply(&ExecSpec{
+		Command: []string{"bin/tool"}, WorkingDir: "/tmp/evil",
+	}))
+}
+
+// TestNewCommand_PathOverride 测试请求中设置的 PATH 不影响查找要执行的命令
+func TestNewCommand_PathOverride(t *testing.T) {
+	a := assert.New(t)
+
+	evilDir := t.TempDir()
+	evil := filepath.Join(evilDir, "ls")
+	a.NoError(os.WriteFile(evil, []byte("#!/bin/sh\necho evil\n"), 0o755))
+	expected, err := exec.LookPath("ls")
+	if !a.NoError(err) {
+		return
+	}
+
+	spec := &ExecSpec{Command: []string{"ls", "-l"}, Env: []string{"PATH=" + evilDir}}
+	a.NoError((&Policy{AllowedCommands: []string{"ls"}, AllowedEnv: []string{"PATH"}}).Apply(spec))
+
+	cmd, err := newCommand(context.Background(), spec, nil)
+	if a.NoError(err) {
+		a.Equal(expected, cmd.Path)
+		a.Equal([]string{expected, "-l"}, cmd.Args)
+		a.Contains(cmd.Env, "PATH="+evilDir)
+	}
+	// 请求中的命令不变
+	a.Equal([]string{"ls", "-l"}, spec.Command)
+
+	if runtime.GOOS == "linux" {
+		// 在沙箱中由 sandbox-init 使用请求中的环境变量执行命令，需传入查找到的完整路径
+		cmd, err = newCommand(context.Background(), spec, &SandboxPolicy{NewPIDNamespace: true})
+		if a.NoError(err) {
+			a.Equal([]string{expected, "-l"}, cmd.Args[len(cmd.Args)-2:])
+			a.NotContains(cmd.Args, evil)
+		}
+	}
+}
+
+// TestLoadPolicy 测试从文件加载执行策略
+func TestLoadPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	a.NoError(os.WriteFile(path, []byte(`allowedCommands: ["ls"]
+maxRuntime: 10m
+sandbox:
+  user: nobody
+  newPIDNamespace: true
+  limits:
+    memoryBytes: 268435456
+`), 0o644))
+
+	policy, err := LoadPolicy(path)
+	if a.NoError(err) {
+		a.Equal(&Policy{
+			AllowedCommands: []string{"ls"},
+			MaxRuntime:      10 * time.Minute,
+			Sandbox: &SandboxPolicy{
+				User:            "nobody",
+				NewPIDNamespace: true,
+				Limits:          SandboxLimits{MemoryBytes: 256 << 20},
+			},
+		}, policy)
+	}
+}
@@ -0,0 +1,128 @@
+//go:build linux
+
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// sandboxInitConfig 传给 sandbox-init 的配置
+type sandboxInitConfig struct {
+	// 挂载新的 /proc
+	MountProc bool `json:"mountProc,omitempty"`
+	// 将根挂载点设置为私有，避免挂载传播到宿主
+	PrivateMounts bool `json:"privateMounts,omitempty"`
+	// 资源限制
+	Limits SandboxLimits `json:"limits,omitempty"`
+	// 运行命令的凭证，为 nil 时不切换用户
+	Credential *syscall.Credential `json:"credential,omitempty"`
+}
+
+// newSandboxCommand 创建在沙箱中运行的命令
+//
+// 命令通过当前程序的 sandbox-init 子命令启动：
+// 子进程在新的命名空间中完成挂载、设置资源限制和切换用户后，再替换为实际要执行的命令。
+// 切换用户必须在挂载之后，因此不通过 SysProcAttr.Credential 设置凭证。
+func newSandboxCommand(
+	ctx context.Context,
+	spec *ExecSpec,
+	sandbox *SandboxPolicy,
+	cred *syscall.Credential,
+) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("get executable path error: %w", err)
+	}
+	config, err := json.Marshal(sandboxInitConfig{
+		MountProc:     sandbox.NewPIDNamespace && sandbox.NewMountNamespace,
+		PrivateMounts: sandbox.NewMountNamespace,
+		Limits:        sandbox.Limits,
+		Credential:    cred,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal sandbox config error: %w", err)
+	}
+
+	args := append([]string{SandboxInitCommand, string(config), "--"}, spec.Command...)
+	cmd := exec.CommandContext(ctx, self, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if sandbox.NewPIDNamespace {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWPID
+	}
+	if sandbox.NewMountNamespace {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	return cmd, nil
+}
+
+// SandboxInit 在沙箱中初始化环境并执行命令
+// args 为 sandbox-init 子命令的参数，格式为 CONFIG -- COMMAND [ARGS...]
+// 成功时不会返回
+func SandboxInit(args []string) error {
+	if len(args) < 3 || args[1] != "--" {
+		return fmt.Errorf("usage: %s CONFIG -- COMMAND [ARGS...]", SandboxInitCommand)
+	}
+	config := sandboxInitConfig{}
+	if err := json.Unmarshal([]byte(args[0]), &config); err != nil {
+		return fmt.Errorf("unmarshal sandbox config error: %w", err)
+	}
+	command := args[2:]
+
+	// 挂载
+	if config.PrivateMounts {
+		if err := syscall.Mount("", "/", "", syscall.MS_PRIVATE|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("make mounts private error: %w", err)
+		}
+	}
+	if config.MountProc {
+		if err := syscall.Mount("proc", "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("mount /proc error: %w", err)
+		}
+	}
+
+	// 资源限制
+	for _, l := range []struct {
+		resource int
+		value    uint64
+	}{
+		{resource: syscall.RLIMIT_CPU, value: config.Limits.CPUSeconds},
+		{resource: syscall.RLIMIT_AS, value: config.Limits.MemoryBytes},
+		{resource: syscall.RLIMIT_FSIZE, value: config.Limits.FileSizeBytes},
+	} {
+		if l.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: l.value, Max: l.value}); err != nil {
+			return fmt.Errorf("set rlimit %d to %d error: %w", l.resource, l.value, err)
+		}
+	}
+
+	// 切换用户
+	if cred := config.Credential; cred != nil {
+		groups := make([]int, 0, len(cred.Groups))
+		for _, g := range cred.Groups {
+			groups = append(groups, int(g))
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			return fmt.Errorf("set groups error: %w", err)
+		}
+		if err := syscall.Setgid(int(cred.Gid)); err != nil {
+			return fmt.Errorf("set gid error: %w", err)
+		}
+		if err := syscall.Setuid(int(cred.Uid)); err != nil {
+			return fmt.Errorf("set uid error: %w", err)
+		}
+	}
+
+	// 执行命令
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, command, os.Environ())
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// newSandboxCommand 创建在沙箱中运行的命令
+// 仅在 Linux 上支持
+func newSandboxCommand(_ context.Context, _ *ExecSpec, _ *SandboxPolicy, _ *syscall.Credential) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("sandbox is only supported on linux")
+}
+
+// SandboxInit 在沙箱中初始化环境并执行命令
+// 仅在 Linux 上支持
+func SandboxInit(_ []string) error {
+	return fmt.Errorf("sandbox is only supported on linux")
+}
@@ -0,0 +1,426 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// ErrSessionClosed 会话已关闭
+var ErrSessionClosed = errors.New("session closed")
+
+// OpenSession 以终端身份连接到会话流，返回 *Session
+// stream 需是通过 NewSessionStream 创建的会话流，执行端通过 Agent.Run 加入
+func OpenSession(ctx context.Context, client common.Client, stream *streamv1.Stream) (*Session, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if !IsSessionStream(stream) {
+		return nil, fmt.Errorf("stream %q is not an exec session stream", stream.Name)
+	}
+
+	// 会话不随 ctx 结束而关闭，需调用 Close 关闭
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	conn, err := client.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{
+		ConnectionName: "terminal",
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("connect to server error: %w", err)
+	}
+	if logger.V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+
+	s := &Session{
+		ctx:    ctx,
+		cancel: cancel,
+		conn:   &lockedConnection{Connection: conn},
+		cmds:   map[uint32]*Cmd{},
+		done:   make(chan struct{}),
+	}
+	go s.receive()
+	return s, nil
+}
+
+// Session exec 会话
+//
+// 在同一个流上并发运行多个命令，每个命令使用独立的通道传输输入输出和退出码，类似 SSH 的通道
+type Session struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	conn   streams.Connection
+
+	lock   sync.Mutex
+	nextID uint32
+	cmds   map[uint32]*Cmd
+	err    error
+	done   chan struct{}
+}
+
+// Exec 返回在会话中运行指定命令的 *Cmd ，用法类似 exec.CommandContext
+// ctx 结束时向命令发送 SIGKILL 信号
+func (s *Session) Exec(ctx context.Context, name string, args ...string) *Cmd {
+	return &Cmd{
+		Args:    append([]string{name}, args...),
+		ctx:     ctx,
+		session: s,
+	}
+}
+
+// Close 关闭会话，会话中仍在运行的命令会被执行端杀死
+func (s *Session) Close(ctx context.Context) error {
+	err := s.conn.Close(ctx)
+	s.cancel()
+	return err
+}
+
+// Done 返回会话结束通知通道
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err 返回会话结束的原因
+// 应在 Done 返回的通道关闭后调用
+func (s *Session) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// receive 接收执行端的消息并分发给对应的命令，直到连接断开
+func (s *Session) receive() {
+	logger := logr.FromContextOrDiscard(s.ctx)
+
+	var err error
+	defer func() {
+		s.finish(err)
+	}()
+
+	for {
+		data, recvErr := streams.ReceiveData(s.ctx, s.conn)
+		if recvErr != nil {
+			if s.ctx.Err() != nil {
+				err = ErrSessionClosed
+				return
+			}
+			if errors.Is(recvErr, streams.ErrConnectionClosed) || errors.Is(recvErr, streams.ErrPeerLeft) {
+				err = fmt.Errorf("%w: %w", ErrSessionClosed, recvErr)
+				return
+			}
+			logger.Error(recvErr, "receive from server error")
+			continue
+		}
+
+		msg, parseErr := ParseMessage(data)
+		if parseErr != nil {
+			logger.Error(parseErr, "parse message error")
+			continue
+		}
+		m, ok := msg.(ChannelMessage)
+		if !ok {
+			logger.Info(fmt.Sprintf("unsupported message type: %s, msg: %v", msg.Type(), msg))
+			continue
+		}
+
+		s.lock.Lock()
+		cmd := s.cmds[m.ID]
+		s.lock.Unlock()
+		if cmd == nil {
+			logger.Info(fmt.Sprintf("WARN channel %d not found, ignore %s message", m.ID, m.Message.Type()))
+			continue
+		}
+
+		switch inner := m.Message.(type) {
+		case StdoutData:
+			if cmd.Stdout != nil {
+				if _, err := cmd.Stdout.Write(inner); err != nil {
+					logger.Error(err, "write to stdout error")
+				}
+			}
+		case StderrData:
+			if cmd.Stderr != nil {
+				if _, err := cmd.Stderr.Write(inner); err != nil {
+					logger.Error(err, "write to stderr error")
+				}
+			}
+		case ExitCode:
+			s.exit(cmd, int(inner), nil)
+		case ExitSignal:
+			s.exit(cmd, -1, &ExitError{Code: -1, Signal: string(inner)})
+		case ErrorStatus:
+			s.exit(cmd, -1, &inner.Status)
+		default:
+			logger.Info(fmt.Sprintf("unsupported channel message type: %s, msg: %v", inner.Type(), inner))
+		}
+	}
+}
+
+// register 为命令分配通道
+func (s *Session) register(cmd *Cmd) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.nextID++
+	cmd.id = s.nextID
+	s.cmds[cmd.id] = cmd
+	return nil
+}
+
+// exit 关闭命令的通道并记录运行结果
+func (s *Session) exit(cmd *Cmd, code int, err error) {
+	s.lock.Lock()
+	delete(s.cmds, cmd.id)
+	s.lock.Unlock()
+
+	if err == nil && code != 0 {
+		err = &ExitError{Code: code}
+	}
+	cmd.exitCode = code
+	cmd.err = err
+	close(cmd.done)
+}
+
+// finish 结束会话，仍在运行的命令以 err 结束
+func (s *Session) finish(err error) {
+	s.lock.Lock()
+	s.err = err
+	cmds := s.cmds
+	s.cmds = map[uint32]*Cmd{}
+	s.lock.Unlock()
+
+	for _, cmd := range cmds {
+		cmd.exitCode = -1
+		cmd.err = err
+		close(cmd.done)
+	}
+	s.cancel()
+	close(s.done)
+}
+
+// send 发送通道消息
+func (s *Session) send(id uint32, msg Message) error {
+	return s.conn.Send(s.ctx, ChannelMessage{ID: id, Message: msg}.Raw())
+}
+
+// Cmd 在会话中运行的命令，用法类似 *exec.Cmd
+type Cmd struct {
+	// 命令及参数
+	Args []string
+	// 额外的环境变量，格式为 KEY=VALUE
+	Env []string
+	// 工作目录，为空时使用执行端当前工作目录
+	Dir string
+	// 运行命令的用户名或 UID ，为空时使用执行端当前用户
+	User string
+	// 是否在 TTY 中运行，在 TTY 中运行时标准错误合并到标准输出
+	TTY bool
+
+	// 标准输入，为 nil 时命令不读取输入
+	Stdin io.Reader
+	// 标准输出，为 nil 时丢弃
+	Stdout io.Writer
+	// 标准错误，为 nil 时丢弃
+	Stderr io.Writer
+
+	ctx     context.Context
+	session *Session
+	id      uint32
+	// 命令结束后关闭，之后才能读取 exitCode 和 err
+	done     chan struct{}
+	exitCode int
+	err      error
+}
+
+// Start 打开通道并开始运行命令，不等待命令结束
+func (c *Cmd) Start() error {
+	if c.done != nil {
+		return fmt.Errorf("exec: already started")
+	}
+	if c.ctx != nil && c.ctx.Err() != nil {
+		return c.ctx.Err()
+	}
+	spec := ExecSpec{
+		Command:    c.Args,
+		Input:      c.Stdin != nil,
+		TTY:        c.TTY,
+		Env:        c.Env,
+		WorkingDir: c.Dir,
+		User:       c.User,
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	c.done = make(chan struct{})
+	if err := c.session.register(c); err != nil {
+		return err
+	}
+	if err := c.session.conn.Send(c.session.ctx, ChannelOpen{ID: c.id, Spec: spec}.Raw()); err != nil {
+		return fmt.Errorf("open channel error: %w", err)
+	}
+
+	if c.Stdin != nil {
+		go c.forwardInput()
+	}
+	if c.ctx != nil && c.ctx.Done() != nil {
+		go func() {
+			select {
+			case <-c.ctx.Done():
+				_ = c.Signal("SIGKILL")
+			case <-c.done:
+			}
+		}()
+	}
+	return nil
+}
+
+// Wait 等待命令结束
+// 命令以非 0 退出码或因信号退出时返回 *ExitError ，被执行端拒绝时返回 *metav1.Status
+func (c *Cmd) Wait() error {
+	if c.done == nil {
+		return fmt.Errorf("exec: not started")
+	}
+	<-c.done
+	return c.err
+}
+
+// Run 运行命令并等待其结束
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output 运行命令并返回其标准输出
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, fmt.Errorf("exec: Stdout already set")
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+	err := c.Run()
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput 运行命令并返回其标准输出和标准错误
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, fmt.Errorf("exec: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, fmt.Errorf("exec: Stderr already set")
+	}
+	// 输出只在会话接收消息的协程中写入，不需要加锁
+	var output bytes.Buffer
+	c.Stdout = &output
+	c.Stderr = &output
+	err := c.Run()
+	return output.Bytes(), err
+}
+
+// Signal 向命令发送信号，支持的信号名见 ParseSignalName
+func (c *Cmd) Signal(name string) error {
+	if c.done == nil {
+		return fmt.Errorf("exec: not started")
+	}
+	name, err := ParseSignalName(name)
+	if err != nil {
+		return err
+	}
+	return c.session.send(c.id, Signal(name))
+}
+
+// Resize 调整命令所在 TTY 的窗口大小
+func (c *Cmd) Resize(height, width uint16) error {
+	if c.done == nil {
+		return fmt.Errorf("exec: not started")
+	}
+	return c.session.send(c.id, Resize{Height: height, Width: width})
+}
+
+// ExitCode 返回命令的退出码
+// 命令未结束、因信号退出或未能运行时返回 -1
+func (c *Cmd) ExitCode() int {
+	if c.done == nil {
+		return -1
+	}
+	select {
+	case <-c.done:
+		return c.exitCode
+	default:
+		return -1
+	}
+}
+
+// forwardInput 将标准输入转发给执行端，读到 EOF 时通知执行端关闭命令的标准输入
+func (c *Cmd) forwardInput() {
+	logger := logr.FromContextOrDiscard(c.session.ctx)
+
+	tmp := make([]byte, maxReadInputSize)
+	for {
+		n, err := c.Stdin.Read(tmp)
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+		if n > 0 {
+			if err := c.session.send(c.id, StdinData(tmp[:n])); err != nil {
+				logger.Error(err, "send message to server error")
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				logger.Error(err, "read input error")
+			}
+			if err := c.session.send(c.id, StdinEOF{}); err != nil {
+				logger.Error(err, "send message to server error")
+			}
+			return
+		}
+	}
+}
+
+// ExitError 命令以非 0 退出码或因信号退出
+type ExitError struct {
+	// 退出码，因信号退出时为 -1
+	Code int
+	// 导致退出的信号名
+	Signal string
+}
+
+var _ error = (*ExitError)(nil)
+
+// Error 返回错误描述
+func (e *ExitError) Error() string {
+	if e.Signal != "" {
+		return "signal: " + e.Signal
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// lockedConnection 发送时加锁的连接，允许多个协程并发发送
+type lockedConnection struct {
+	streams.Connection
+	lock sync.Mutex
+}
+
+// Send 发送
+func (conn *lockedConnection) Send(ctx context.Context, data []byte) error {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return conn.Connection.Send(ctx, data)
+}
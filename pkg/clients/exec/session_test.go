@@ -0,0 +1,71 @@
+package exec
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yhlooo/scaf/pkg/streams/streamstest"
+)
+
+// TestSession 测试在一个会话中并发运行多个命令
+func TestSession(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	terminalConn, agentConn := streamstest.NewConnectionPair()
+	stream := NewSessionStream()
+	stream.Name = "test"
+
+	agentDone := make(chan error, 1)
+	go func() {
+		agentDone <- NewAgent(streamstest.NewClient(agentConn)).Run(ctx, stream)
+	}()
+
+	session, err := OpenSession(ctx, streamstest.NewClient(terminalConn), stream)
+	if !a.NoError(err) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		out, err := session.Exec(ctx, "echo", "hello").Output()
+		a.NoError(err)
+		a.Equal("hello\n", string(out))
+	}()
+	go func() {
+		defer wg.Done()
+		cmd := session.Exec(ctx, "sh", "-c", "echo oops >&2; exit 3")
+		out, err := cmd.CombinedOutput()
+		a.Equal("oops\n", string(out))
+		a.Equal(&ExitError{Code: 3}, err)
+		a.Equal(3, cmd.ExitCode())
+	}()
+	go func() {
+		defer wg.Done()
+		cmd := session.Exec(ctx, "cat")
+		cmd.Stdin = strings.NewReader("from stdin")
+		out, err := cmd.Output()
+		a.NoError(err)
+		a.Equal("from stdin", string(out))
+		a.Equal(0, cmd.ExitCode())
+	}()
+	wg.Wait()
+
+	// 执行端拒绝的命令
+	agentErr := session.Exec(ctx, "scaf-command-not-exists").Run()
+	a.Error(agentErr)
+
+	a.NoError(session.Close(ctx))
+	<-session.Done()
+	a.ErrorIs(session.Err(), ErrSessionClosed)
+	a.ErrorIs(session.Exec(ctx, "true").Run(), ErrSessionClosed)
+	a.NoError(<-agentDone)
+}
@@ -0,0 +1,96 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/yhlooo/scaf/pkg/clients/common"
+)
+
+// signalsByName 支持发送的信号
+var signalsByName = map[string]syscall.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+// forwardedSignals 终端收到后转发给远端进程的信号
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+// ParseSignalName 解析信号名，支持 SIGINT 、 INT 、 int 等形式
+func ParseSignalName(name string) (string, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if _, ok := signalsByName[name]; !ok {
+		return "", fmt.Errorf("unsupported signal: %q", name)
+	}
+	return name, nil
+}
+
+// signalName 返回信号名
+func signalName(sig os.Signal) string {
+	for name, s := range signalsByName {
+		if s == sig {
+			return name
+		}
+	}
+	return sig.String()
+}
+
+// setProcessGroup 设置命令在新进程组中运行，以便向其所有子进程发送信号
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalProcessGroup 向进程所在进程组发送信号
+// 进程需要是进程组的组长，即通过 setProcessGroup 设置过或在新会话中运行
+func signalProcessGroup(p *os.Process, name string) error {
+	sig, ok := signalsByName[name]
+	if !ok {
+		return fmt.Errorf("unsupported signal: %q", name)
+	}
+	return syscall.Kill(-p.Pid, sig)
+}
+
+// exitSignal 返回进程退出是否由信号导致，以及信号名
+func exitSignal(exitErr *exec.ExitError) (string, bool) {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return "", false
+	}
+	return signalName(status.Signal()), true
+}
+
+// SendSignal 通过流向正在执行的命令发送信号
+//
+// 信号由服务端作为控制消息转发给执行命令的一端，不需要加入流，流中已有终端连接时也可以发送
+func SendSignal(ctx context.Context, client common.Client, streamName, signalName string) error {
+	name, err := ParseSignalName(signalName)
+	if err != nil {
+		return err
+	}
+	if err := client.SignalStream(ctx, streamName, name); err != nil {
+		return fmt.Errorf("send signal error: %w", err)
+	}
+	return nil
+}
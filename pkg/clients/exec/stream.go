@@ -1,8 +1,11 @@
 package exec
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
 
 	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
@@ -13,25 +16,84 @@ const (
 	AnnoCommand      = "scaf/exec-command"
 	AnnoInputEnabled = "scaf/exec-input-enabled"
 	AnnoTTY          = "scaf/exec-tty"
+	AnnoEnv          = "scaf/exec-env"
+	AnnoWorkingDir   = "scaf/exec-workdir"
+	AnnoUser         = "scaf/exec-user"
+	AnnoSession      = "scaf/exec-session"
 )
 
+// ExecSpec 执行命令的参数
+type ExecSpec struct {
+	// 命令及参数
+	Command []string `json:"command"`
+	// 是否开启标准输入
+	Input bool `json:"input,omitempty"`
+	// 是否在 TTY 中运行
+	TTY bool `json:"tty,omitempty"`
+	// 额外的环境变量，格式为 KEY=VALUE
+	Env []string `json:"env,omitempty"`
+	// 工作目录，为空时使用执行端当前工作目录
+	WorkingDir string `json:"workingDir,omitempty"`
+	// 运行命令的用户名或 UID ，为空时使用执行端当前用户
+	User string `json:"user,omitempty"`
+}
+
+// Validate 校验参数
+func (spec *ExecSpec) Validate() error {
+	if len(spec.Command) < 1 {
+		return fmt.Errorf("exec command is empty")
+	}
+	for _, kv := range spec.Env {
+		if err := validateEnv(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // NewExecStream 创建 exec 流
-func NewExecStream(command []string, input, tty bool) *streamv1.Stream {
-	commandVal, _ := json.Marshal(command)
+func NewExecStream(spec ExecSpec) *streamv1.Stream {
+	commandVal, _ := json.Marshal(spec.Command)
 	inputVal := "false"
-	if input {
+	if spec.Input {
 		inputVal = "true"
 	}
 	ttyVal := "false"
-	if tty {
+	if spec.TTY {
 		ttyVal = "true"
 	}
+	annotations := map[string]string{
+		AnnoCommand:      string(commandVal),
+		AnnoInputEnabled: inputVal,
+		AnnoTTY:          ttyVal,
+	}
+	if len(spec.Env) > 0 {
+		envVal, _ := json.Marshal(spec.Env)
+		annotations[AnnoEnv] = string(envVal)
+	}
+	if spec.WorkingDir != "" {
+		annotations[AnnoWorkingDir] = spec.WorkingDir
+	}
+	if spec.User != "" {
+		annotations[AnnoUser] = spec.User
+	}
+	return &streamv1.Stream{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: annotations,
+		},
+		Spec: streamv1.StreamSpec{
+			StopPolicy: streamv1.OnFirstConnectionLeft,
+		},
+	}
+}
+
+// NewSessionStream 创建 exec 会话流
+// 会话中执行的命令由终端通过 Session 在会话建立后请求
+func NewSessionStream() *streamv1.Stream {
 	return &streamv1.Stream{
 		ObjectMeta: metav1.ObjectMeta{
 			Annotations: map[string]string{
-				AnnoCommand:      string(commandVal),
-				AnnoInputEnabled: inputVal,
-				AnnoTTY:          ttyVal,
+				AnnoSession: "true",
 			},
 		},
 		Spec: streamv1.StreamSpec{
@@ -40,13 +102,76 @@ func NewExecStream(command []string, input, tty bool) *streamv1.Stream {
 	}
 }
 
-// GetExecOptions 通过流获取 exec 选项
-func GetExecOptions(stream *streamv1.Stream) (command []string, input, tty bool, err error) {
+// IsSessionStream 返回流是否是 exec 会话流
+func IsSessionStream(stream *streamv1.Stream) bool {
+	return stream != nil && stream.Annotations[AnnoSession] == "true"
+}
+
+// GetExecSpec 通过流获取 exec 参数
+func GetExecSpec(stream *streamv1.Stream) (*ExecSpec, error) {
 	if stream == nil {
-		return nil, false, false, fmt.Errorf("stream is nil")
+		return nil, fmt.Errorf("stream is nil")
+	}
+	if IsSessionStream(stream) {
+		return nil, fmt.Errorf("stream %q is an exec session stream, commands are requested through session", stream.Name)
+	}
+	spec := &ExecSpec{
+		Input:      stream.Annotations[AnnoInputEnabled] == "true",
+		TTY:        stream.Annotations[AnnoTTY] == "true",
+		WorkingDir: stream.Annotations[AnnoWorkingDir],
+		User:       stream.Annotations[AnnoUser],
+	}
+	if err := json.Unmarshal([]byte(stream.Annotations[AnnoCommand]), &spec.Command); err != nil {
+		return nil, fmt.Errorf("unmarshal annotation %q error: %w", AnnoCommand, err)
+	}
+	if envVal, ok := stream.Annotations[AnnoEnv]; ok {
+		if err := json.Unmarshal([]byte(envVal), &spec.Env); err != nil {
+			return nil, fmt.Errorf("unmarshal annotation %q error: %w", AnnoEnv, err)
+		}
+	}
+	return spec, nil
+}
+
+// LoadEnvFile 从文件加载环境变量
+// 文件每行一个 KEY=VALUE ，忽略空行和以 # 开头的行
+func LoadEnvFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open env file %q error: %w", path, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var env []string
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		if err := validateEnv(line); err != nil {
+			return nil, fmt.Errorf("invalid env file %q line %d: %w", path, lineNum, err)
+		}
+		env = append(env, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read env file %q error: %w", path, err)
+	}
+	return env, nil
+}
+
+// validateEnv 校验 KEY=VALUE 格式的环境变量
+func validateEnv(kv string) error {
+	key, _, ok := strings.Cut(kv, "=")
+	if !ok {
+		return fmt.Errorf("invalid env %q: must be in KEY=VALUE format", kv)
+	}
+	if key == "" || strings.ContainsAny(key, " \t\x00") {
+		return fmt.Errorf("invalid env %q: invalid key %q", kv, key)
 	}
-	err = json.Unmarshal([]byte(stream.Annotations[AnnoCommand]), &command)
-	input = stream.Annotations[AnnoInputEnabled] == "true"
-	tty = stream.Annotations[AnnoTTY] == "true"
-	return
+	return nil
 }
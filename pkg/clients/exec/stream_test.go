@@ -0,0 +1,53 @@
+package exec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExecSpec 测试 exec 参数编码到流和从流解析
+func TestExecSpec(t *testing.T) {
+	a := assert.New(t)
+
+	spec := ExecSpec{
+		Command:    []string{"sh", "-c", "echo $FOO"},
+		Input:      true,
+		TTY:        true,
+		Env:        []string{"FOO=bar", "EMPTY="},
+		WorkingDir: "/tmp",
+		User:       "nobody",
+	}
+	got, err := GetExecSpec(NewExecStream(spec))
+	if a.NoError(err) {
+		a.Equal(spec, *got)
+	}
+
+	// 只有基本参数
+	spec = ExecSpec{Command: []string{"ls"}}
+	got, err = GetExecSpec(NewExecStream(spec))
+	if a.NoError(err) {
+		a.Equal(spec, *got)
+	}
+
+	a.Error((&ExecSpec{}).Validate())
+	a.Error((&ExecSpec{Command: []string{"ls"}, Env: []string{"FOO"}}).Validate())
+}
+
+// TestLoadEnvFile 测试从文件加载环境变量
+func TestLoadEnvFile(t *testing.T) {
+	a := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "env")
+	a.NoError(os.WriteFile(path, []byte("# comment\n\nFOO=bar\nexport BAZ=a=b\n"), 0o644))
+	env, err := LoadEnvFile(path)
+	if a.NoError(err) {
+		a.Equal([]string{"FOO=bar", "BAZ=a=b"}, env)
+	}
+
+	a.NoError(os.WriteFile(path, []byte("FOO\n"), 0o644))
+	_, err = LoadEnvFile(path)
+	a.Error(err)
+}
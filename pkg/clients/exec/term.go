@@ -8,11 +8,13 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/go-logr/logr"
 	"golang.org/x/term"
 
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
 	"github.com/yhlooo/scaf/pkg/clients/common"
 	"github.com/yhlooo/scaf/pkg/streams"
@@ -46,15 +48,21 @@ func (t *Terminal) WithClient(client common.Client) *Terminal {
 
 // Run 与服务端建立连接并转发输入输出
 // 阻塞直到运行结束
+//
+// 收到的 SIGINT 、 SIGTERM 等信号会转发给远端命令，由远端命令决定是否退出。
+// 因此远端命令开始运行后， ctx 被取消（通常是由于收到信号）时不会立即返回，而是继续等待远端命令退出。
 func (t *Terminal) Run(ctx context.Context, stream *streamv1.Stream, stdin io.Reader, stdout, stderr io.Writer) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
-	_, input, tty, err := GetExecOptions(stream)
+	spec, err := GetExecSpec(stream)
 	if err != nil {
-		return fmt.Errorf("get stream options error: %w", err)
+		return fmt.Errorf("get exec spec error: %w", err)
 	}
+	input, tty := spec.Input, spec.TTY
 
-	ctx, cancel := context.WithCancel(ctx)
+	parentCtx := ctx
+	parentDone := parentCtx.Done()
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parentCtx))
 	defer cancel()
 
 	// 与服务端建立连接
@@ -109,13 +117,24 @@ func (t *Terminal) Run(ctx context.Context, stream *streamv1.Stream, stdin io.Re
 		signal.Stop(resizeCh)
 		close(resizeCh)
 	}()
+	// 转发信号到远端进程
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, forwardedSignals...)
+	defer func() {
+		signal.Stop(signalCh)
+		close(signalCh)
+	}()
 	for {
 		select {
-		case <-ctx.Done():
-			return ctx.Err()
+		case <-parentDone:
+			if !session.Started() {
+				return parentCtx.Err()
+			}
+			// 已经开始，等待远端命令处理转发的信号后退出
+			parentDone = nil
 		case <-session.HandleConnDone():
 			cancel()
-			return nil
+			return session.Err()
 		case <-session.HandleInputDone():
 			cancel()
 			return nil
@@ -131,6 +150,17 @@ func (t *Terminal) Run(ctx context.Context, stream *streamv1.Stream, stdin io.Re
 			if err := conn.Send(ctx, Resize{Height: uint16(h), Width: uint16(w)}.Raw()); err != nil {
 				logger.Error(err, "send resize message error")
 			}
+		case sig := <-signalCh:
+			if !session.Started() {
+				// 还没开始，直接退出
+				cancel()
+				return nil
+			}
+			name := signalName(sig)
+			logger.V(1).Info(fmt.Sprintf("forward signal %s", name))
+			if err := conn.Send(ctx, Signal(name).Raw()); err != nil {
+				logger.Error(err, "send signal message error")
+			}
 		}
 	}
 }
@@ -158,7 +188,9 @@ type TerminalSession struct {
 	handleInputDone chan struct{}
 	conn            streams.Connection
 
-	started bool
+	started atomic.Bool
+	// 执行端返回的错误
+	err *metav1.Status
 
 	stdin  io.Reader
 	stdout io.Writer
@@ -179,7 +211,7 @@ func (s *TerminalSession) HandleConn(ctx context.Context) {
 		default:
 		}
 
-		data, err := s.conn.Receive(ctx)
+		data, err := streams.ReceiveData(ctx, s.conn)
 		if err != nil {
 			select {
 			case <-ctx.Done():
@@ -187,7 +219,7 @@ func (s *TerminalSession) HandleConn(ctx context.Context) {
 			default:
 			}
 			logger.Error(err, "receive from server error")
-			if errors.Is(err, streams.ErrConnectionClosed) {
+			if errors.Is(err, streams.ErrConnectionClosed) || errors.Is(err, streams.ErrPeerLeft) {
 				return
 			}
 			continue
@@ -200,7 +232,7 @@ func (s *TerminalSession) HandleConn(ctx context.Context) {
 			continue
 		}
 
-		s.started = true
+		s.started.Store(true)
 
 		// 分类处理
 		switch m := msg.(type) {
@@ -220,6 +252,15 @@ func (s *TerminalSession) HandleConn(ctx context.Context) {
 				logger.Error(err, "write to stderr error")
 			}
 			return
+		case ExitSignal:
+			if _, err := s.stderr.Write([]byte(fmt.Sprintf("command killed by signal: %s\n", m))); err != nil {
+				logger.Error(err, "write to stderr error")
+			}
+			return
+		case ErrorStatus:
+			// 由 Run 返回给调用方
+			s.err = &m.Status
+			return
 		default:
 			logger.Info(fmt.Sprintf("unsupported message type: %s, msg: %v", m.Type(), m))
 		}
@@ -256,7 +297,7 @@ func (s *TerminalSession) HandleInput(ctx context.Context) {
 		}
 
 		// 没开始或禁用输入，可以通过 Ctrl-C 或 Ctrl-D 退出
-		if (!s.started || !s.input) &&
+		if (!s.started.Load() || !s.input) &&
 			(bytes.Contains(tmp[:n], []byte{'\x03'}) || bytes.Contains(tmp[:n], []byte{'\x04'})) {
 			return
 		}
@@ -270,6 +311,20 @@ func (s *TerminalSession) HandleInput(ctx context.Context) {
 	}
 }
 
+// Started 返回是否已开始收到远端消息
+func (s *TerminalSession) Started() bool {
+	return s.started.Load()
+}
+
+// Err 返回执行端返回的错误
+// 应在 HandleConnDone 返回的通道关闭后调用
+func (s *TerminalSession) Err() error {
+	if s.err == nil {
+		return nil
+	}
+	return s.err
+}
+
 // HandleConnDone 返回处理连接完成通知通道
 func (s *TerminalSession) HandleConnDone() <-chan struct{} {
 	return s.handleConnDone
@@ -0,0 +1,62 @@
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// lookupUser 查找用户，支持用户名或 UID
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+	if _, parseErr := strconv.ParseUint(name, 10, 32); parseErr == nil {
+		if u, idErr := user.LookupId(name); idErr == nil {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("lookup user %q error: %w", name, err)
+}
+
+// userCredential 返回以指定用户运行命令所需的凭证，以及该用户的默认环境变量
+// 指定的用户就是当前用户时返回 nil 凭证
+func userCredential(name string) (*syscall.Credential, []string, error) {
+	u, err := lookupUser(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid uid %q of user %q: %w", u.Uid, name, err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid gid %q of user %q: %w", u.Gid, name, err)
+	}
+	env := []string{"HOME=" + u.HomeDir, "USER=" + u.Username, "LOGNAME=" + u.Username}
+
+	if uint64(os.Geteuid()) == uid {
+		return nil, env, nil
+	}
+	if os.Geteuid() != 0 {
+		return nil, nil, fmt.Errorf("running command as user %q requires the executor to run as root", name)
+	}
+
+	var groups []uint32
+	if groupIDs, err := u.GroupIds(); err == nil {
+		for _, g := range groupIDs {
+			if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+				groups = append(groups, uint32(id))
+			}
+		}
+	}
+	return &syscall.Credential{
+		Uid:    uint32(uid),
+		Gid:    uint32(gid),
+		Groups: groups,
+	}, env, nil
+}
@@ -0,0 +1,118 @@
+package pipe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// ProtocolVersion 管道协议版本
+const ProtocolVersion = 1
+
+// maxFrameSize 数据帧的最大数据长度
+const maxFrameSize = 16 << 10
+
+// FrameType 帧类型，是每条消息的第一个字节
+type FrameType byte
+
+const (
+	// FrameHello 接收端连接后发送的接收端信息，内容为 JSON 格式的 Hello
+	FrameHello FrameType = 'H'
+	// FrameHeader 发送端收到 FrameHello 后发送的发送端信息，内容为 JSON 格式的 Header
+	FrameHeader FrameType = 'S'
+	// FrameData 发送端发送的数据
+	FrameData FrameType = 'D'
+	// FrameEOF 发送端发送完所有数据
+	FrameEOF FrameType = 'E'
+	// FrameError 任意一端出错终止传输，内容为错误信息
+	FrameError FrameType = 'X'
+	// FrameAck 接收端写完所有数据
+	FrameAck FrameType = 'A'
+)
+
+// Hello 接收端信息
+type Hello struct {
+	// 协议版本
+	Version int `json:"version"`
+	// 接收端支持的压缩算法
+	Compressions []clientscp.Compression `json:"compressions,omitempty"`
+}
+
+// Header 发送端信息
+type Header struct {
+	// 协议版本
+	Version int `json:"version"`
+	// 数据使用的压缩算法
+	Compression clientscp.Compression `json:"compression,omitempty"`
+	// 数据（压缩前）的总字节数，未知时为 0
+	Size int64 `json:"size,omitempty"`
+}
+
+// sendFrame 发送帧
+func sendFrame(ctx context.Context, conn streams.Connection, t FrameType, payload []byte) error {
+	msg := make([]byte, 1+len(payload))
+	msg[0] = byte(t)
+	copy(msg[1:], payload)
+	if err := conn.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send to server error: %w", err)
+	}
+	return nil
+}
+
+// sendJSONFrame 发送内容为 JSON 格式的帧
+func sendJSONFrame(ctx context.Context, conn streams.Connection, t FrameType, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return sendFrame(ctx, conn, t, raw)
+}
+
+// receiveFrame 接收帧，跳过空消息
+func receiveFrame(ctx context.Context, conn streams.Connection) (FrameType, []byte, error) {
+	for {
+		msg, err := conn.Receive(ctx)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		return FrameType(msg[0]), msg[1:], nil
+	}
+}
+
+// PeerError 对端通过 FrameError 报告的错误
+type PeerError struct {
+	// 对端
+	Peer string
+	// 错误信息
+	Message string
+}
+
+// Error 返回错误信息
+func (err *PeerError) Error() string {
+	return fmt.Sprintf("%s error: %s", err.Peer, err.Message)
+}
+
+// frameWriter 将写入的数据以数据帧发送的 io.Writer
+type frameWriter struct {
+	ctx  context.Context
+	conn streams.Connection
+}
+
+// Write 写
+func (w *frameWriter) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		part := p[n:min(len(p), n+maxFrameSize)]
+		if err := sendFrame(w.ctx, w.conn, FrameData, part); err != nil {
+			return n, err
+		}
+		n += len(part)
+	}
+	return n, nil
+}
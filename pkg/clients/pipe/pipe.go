@@ -0,0 +1,378 @@
+package pipe
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/utils/progress"
+)
+
+// closeTimeout 发送确认或错误后等待对端断开的最长时间
+const closeTimeout = 5 * time.Second
+
+// New 创建 PipeClient
+func New(client common.Client) *PipeClient {
+	return &PipeClient{c: client}
+}
+
+// PipeClient 管道客户端，将一端的输入通过流传输到另一端的输出
+type PipeClient struct {
+	c common.Client
+}
+
+// Client 返回使用的客户端
+func (c *PipeClient) Client() common.Client {
+	return c.c
+}
+
+// WithClient 返回使用指定客户端的管道客户端
+func (c *PipeClient) WithClient(client common.Client) *PipeClient {
+	return &PipeClient{
+		c: client,
+	}
+}
+
+// SendOptions 发送选项
+type SendOptions struct {
+	// 压缩算法，接收端不支持时不压缩
+	Compression clientscp.Compression
+	// 数据的总字节数，未知时为 0 ，仅用于报告进度
+	Size int64
+	// 处理发送进度，为空时不报告进度
+	Progress progress.Handler
+}
+
+// Send 将从 r 读到的数据发送到流，直到读到 io.EOF 并且接收端确认写完所有数据
+//
+// 读 r 出错时通知接收端传输失败
+func (c *PipeClient) Send(ctx context.Context, stream *streamv1.Stream, r io.Reader, opts SendOptions) (retErr error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if err := opts.Compression.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 与服务端建立连接
+	conn, err := c.connect(ctx, stream, "sender")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+
+	// 等待接收端
+	logger.Info("waiting for receiver ...")
+	hello := &Hello{}
+	for hello.Version == 0 {
+		t, payload, err := receiveFrame(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("receive message error: %w", err)
+		}
+		switch t {
+		case FrameHello:
+			if err := json.Unmarshal(payload, hello); err != nil {
+				return fmt.Errorf("unmarshal receiver hello error: %w", err)
+			}
+			if hello.Version == 0 {
+				return fmt.Errorf("invalid receiver hello: version is missing")
+			}
+		case FrameError:
+			return &PeerError{Peer: "receiver", Message: string(payload)}
+		}
+	}
+
+	// 协商压缩算法
+	compression := clientscp.NegotiateCompression(opts.Compression, hello.Compressions)
+	if opts.Compression != "" && opts.Compression != clientscp.CompressionAuto && compression != opts.Compression {
+		logger.Info(fmt.Sprintf("WARN receiver does not support compression %q, send without compression", opts.Compression))
+	}
+	if err := sendJSONFrame(ctx, conn, FrameHeader, &Header{
+		Version:     ProtocolVersion,
+		Compression: compression,
+		Size:        opts.Size,
+	}); err != nil {
+		return err
+	}
+	logger.Info(fmt.Sprintf("start send (compression: %s)", compression))
+
+	tracker := progress.NewTracker(opts.Progress)
+	tracker.SetTotal(opts.Size, 0)
+	tracker.Start(ctx)
+	defer func() {
+		tracker.Stop(retErr)
+	}()
+
+	// 接收接收端的确认或错误
+	peerDone := make(chan error, 1)
+	go func() {
+		for {
+			t, payload, err := receiveFrame(ctx, conn)
+			if err != nil {
+				if errors.Is(err, streams.ErrConnectionClosed) {
+					peerDone <- fmt.Errorf("receiver disconnected before acknowledging all data")
+				} else {
+					peerDone <- fmt.Errorf("receive message error: %w", err)
+				}
+				return
+			}
+			switch t {
+			case FrameAck:
+				peerDone <- nil
+				return
+			case FrameError:
+				peerDone <- &PeerError{Peer: "receiver", Message: string(payload)}
+				return
+			}
+		}
+	}()
+
+	// 发送数据，读输入可能一直阻塞，因此在单独的协程中进行
+	sendDone := make(chan error, 1)
+	go func() {
+		sendDone <- sendData(ctx, conn, tracker.Reader(r), compression)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-sendDone:
+		if err != nil {
+			// 通知接收端传输失败，并等待接收端收到后断开
+			_ = sendFrame(ctx, conn, FrameError, []byte(err.Error()))
+			select {
+			case <-ctx.Done():
+			case <-peerDone:
+			case <-time.After(closeTimeout):
+			}
+			return err
+		}
+	case err := <-peerDone:
+		if err == nil {
+			err = fmt.Errorf("receiver acknowledged before all data was sent")
+		}
+		return err
+	}
+
+	// 发送结束帧并等待接收端确认
+	if err := sendFrame(ctx, conn, FrameEOF, nil); err != nil {
+		return err
+	}
+	logger.Info("send completed, waiting for receiver ...")
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-peerDone:
+		if err != nil {
+			return err
+		}
+	}
+	logger.Info("receive completed")
+	return nil
+}
+
+// sendData 压缩从 r 读到的数据并以数据帧发送
+func sendData(ctx context.Context, conn streams.Connection, r io.Reader, compression clientscp.Compression) error {
+	w, err := clientscp.NewCompressWriter(&frameWriter{ctx: ctx, conn: conn}, compression)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, maxFrameSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("read input error: %w", err)
+		}
+	}
+	return w.Close()
+}
+
+// ReceiveOptions 接收选项
+type ReceiveOptions struct {
+	// 处理接收进度，为空时不报告进度
+	Progress progress.Handler
+}
+
+// Receive 从流接收数据并写到 w ，直到发送端发送结束帧
+//
+// 发送端报告错误或在发送结束帧之前断开时返回错误，写 w 出错时通知发送端传输失败
+func (c *PipeClient) Receive(ctx context.Context, stream *streamv1.Stream, w io.Writer, opts ReceiveOptions) (retErr error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 与服务端建立连接
+	conn, err := c.connect(ctx, stream, "receiver")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+
+	// 发送接收端信息
+	if err := sendJSONFrame(ctx, conn, FrameHello, &Hello{
+		Version:      ProtocolVersion,
+		Compressions: clientscp.SupportedCompressions,
+	}); err != nil {
+		return err
+	}
+
+	// 等待发送端信息
+	logger.Info("waiting for sender ...")
+	header := &Header{}
+	for header.Version == 0 {
+		t, payload, err := receiveFrame(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("receive message error: %w", err)
+		}
+		switch t {
+		case FrameHeader:
+			if err := json.Unmarshal(payload, header); err != nil {
+				return fmt.Errorf("unmarshal sender header error: %w", err)
+			}
+			if header.Version == 0 {
+				return fmt.Errorf("invalid sender header: version is missing")
+			}
+		case FrameError:
+			return &PeerError{Peer: "sender", Message: string(payload)}
+		default:
+			return fmt.Errorf("unexpected frame %q before sender header", t)
+		}
+	}
+	logger.Info(fmt.Sprintf("start receive (compression: %s)", header.Compression))
+
+	tracker := progress.NewTracker(opts.Progress)
+	tracker.SetTotal(header.Size, 0)
+	tracker.Start(ctx)
+	defer func() {
+		tracker.Stop(retErr)
+	}()
+
+	// 解压并写输出
+	pipeR, pipeW := io.Pipe()
+	writeDone := make(chan error, 1)
+	go func() {
+		err := writeOutput(tracker.Writer(w), pipeR, header.Compression)
+		_ = pipeR.CloseWithError(err)
+		writeDone <- err
+	}()
+	// abort 通知发送端传输失败，并等待发送端收到后断开
+	abort := func(err error) error {
+		_ = sendFrame(ctx, conn, FrameError, []byte(err.Error()))
+		waitClosed(ctx, conn)
+		return err
+	}
+
+	for {
+		t, payload, err := receiveFrame(ctx, conn)
+		if err != nil {
+			if errors.Is(err, streams.ErrConnectionClosed) {
+				err = fmt.Errorf("sender disconnected before sending all data")
+			} else {
+				err = fmt.Errorf("receive message error: %w", err)
+			}
+			_ = pipeW.CloseWithError(err)
+			return err
+		}
+
+		switch t {
+		case FrameData:
+			if _, err := pipeW.Write(payload); err != nil {
+				if werr := <-writeDone; werr != nil {
+					err = werr
+				}
+				return abort(err)
+			}
+		case FrameEOF:
+			_ = pipeW.Close()
+			if err := <-writeDone; err != nil {
+				return abort(err)
+			}
+			if err := sendFrame(ctx, conn, FrameAck, nil); err != nil {
+				return err
+			}
+			waitClosed(ctx, conn)
+			return nil
+		case FrameError:
+			err := &PeerError{Peer: "sender", Message: string(payload)}
+			_ = pipeW.CloseWithError(err)
+			<-writeDone
+			return err
+		}
+	}
+}
+
+// waitClosed 等待对端断开，最多等待 closeTimeout
+//
+// 发送确认或错误后立即断开可能导致流停止时对端还未收到
+func waitClosed(ctx context.Context, conn streams.Connection) {
+	ctx, cancel := context.WithTimeout(ctx, closeTimeout)
+	defer cancel()
+	for {
+		if _, _, err := receiveFrame(ctx, conn); err != nil {
+			return
+		}
+	}
+}
+
+// writeOutput 从 r 读数据，解压后写到 w
+func writeOutput(w io.Writer, r io.Reader, compression clientscp.Compression) error {
+	dr, err := clientscp.NewDecompressReader(r, compression)
+	if err != nil {
+		return fmt.Errorf("decompress data error: %w", err)
+	}
+	defer func() {
+		_ = dr.Close()
+	}()
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := dr.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return fmt.Errorf("write output error: %w", err)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("decompress data error: %w", err)
+		}
+	}
+}
+
+// connect 与服务端建立名为 name 的连接
+func (c *PipeClient) connect(ctx context.Context, stream *streamv1.Stream, name string) (streams.Connection, error) {
+	conn, err := c.c.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{
+		ConnectionName: name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to server error: %w", err)
+	}
+	if logr.FromContextOrDiscard(ctx).V(1).Enabled() {
+		conn = streams.ConnectionWithLog{Connection: conn}
+	}
+	return conn, nil
+}
@@ -0,0 +1,86 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	"github.com/yhlooo/scaf/pkg/streams/streamstest"
+)
+
+// TestPipe 测试通过流传输数据
+func TestPipe(t *testing.T) {
+	a := assert.New(t)
+
+	rnd := rand.New(rand.NewSource(1))
+	data := make([]byte, 1<<20+123)
+	rnd.Read(data[:len(data)/2])
+
+	for _, compression := range []clientscp.Compression{clientscp.CompressionNone, clientscp.CompressionZstd} {
+		out := &bytes.Buffer{}
+		sendErr, recvErr := runPipe(bytes.NewReader(data), out, compression)
+		a.NoError(sendErr, compression)
+		a.NoError(recvErr, compression)
+		a.True(bytes.Equal(data, out.Bytes()), compression)
+	}
+
+	// 读输入出错时接收端返回错误
+	sendErr, recvErr := runPipe(io.MultiReader(bytes.NewReader(data), errReader{}), io.Discard, clientscp.CompressionGzip)
+	a.ErrorContains(sendErr, "read input error: broken input")
+	peerErr := &PeerError{}
+	if a.ErrorAs(recvErr, &peerErr) {
+		a.Equal("sender", peerErr.Peer)
+		a.Contains(peerErr.Message, "broken input")
+	}
+
+	// 写输出出错时发送端返回错误
+	sendErr, recvErr = runPipe(bytes.NewReader(data), errWriter{}, clientscp.CompressionNone)
+	a.ErrorContains(recvErr, "write output error: broken output")
+	if a.ErrorAs(sendErr, &peerErr) {
+		a.Equal("receiver", peerErr.Peer)
+	}
+}
+
+// runPipe 通过内存中的连接将 r 的数据传输到 w
+func runPipe(r io.Reader, w io.Writer, compression clientscp.Compression) (sendErr, recvErr error) {
+	ctx := context.Background()
+	senderConn, receiverConn := streamstest.NewConnectionPair()
+	stream := &streamv1.Stream{}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		sendErr = New(streamstest.NewClient(senderConn)).Send(ctx, stream, r, SendOptions{Compression: compression})
+	}()
+	go func() {
+		defer wg.Done()
+		recvErr = New(streamstest.NewClient(receiverConn)).Receive(ctx, stream, w, ReceiveOptions{})
+	}()
+	wg.Wait()
+	return sendErr, recvErr
+}
+
+// errReader 总是返回错误的 io.Reader
+type errReader struct{}
+
+// Read 读
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("broken input")
+}
+
+// errWriter 总是返回错误的 io.Writer
+type errWriter struct{}
+
+// Write 写
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken output")
+}
@@ -0,0 +1,94 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	"github.com/yhlooo/scaf/pkg/server/generic"
+)
+
+var _ generic.AgentRegistry = (*Manager)(nil)
+
+// RegisterAgent 记录注册到当前副本的代理
+func (mgr *Manager) RegisterAgent(ctx context.Context, agent *agentv1.Agent) error {
+	return mgr.store.PutAgent(ctx, &AgentRecord{
+		Object:  *agent,
+		Replica: mgr.AdvertiseAddress(),
+	})
+}
+
+// UnregisterAgent 删除注册到当前副本的代理的记录，记录已被同名代理的新注册替代时不删除
+func (mgr *Manager) UnregisterAgent(ctx context.Context, agent *agentv1.Agent) error {
+	record, err := mgr.store.GetAgent(ctx, agent.Name)
+	if err != nil {
+		if errors.Is(err, ErrAgentNotFound) {
+			return nil
+		}
+		return err
+	}
+	if record.Object.UID != agent.UID {
+		return nil
+	}
+	if err := mgr.store.DeleteAgent(ctx, agent.Name); err != nil && !errors.Is(err, ErrAgentNotFound) {
+		return err
+	}
+	return nil
+}
+
+// GetAgent 获取注册到其它副本的代理，不存在时返回 NotFound 错误
+func (mgr *Manager) GetAgent(ctx context.Context, name string) (*agentv1.Agent, error) {
+	record, err := mgr.remoteAgentRecord(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &record.Object, nil
+}
+
+// ListAgents 列出注册到其它副本的代理
+func (mgr *Manager) ListAgents(ctx context.Context) ([]agentv1.Agent, error) {
+	records, err := mgr.store.ListAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replica := mgr.AdvertiseAddress()
+	var ret []agentv1.Agent
+	for _, record := range records {
+		if record.Replica != replica {
+			ret = append(ret, record.Object)
+		}
+	}
+	return ret, nil
+}
+
+// CreateDispatch 向注册到其它副本的代理派发请求，由代理所在副本鉴权
+func (mgr *Manager) CreateDispatch(ctx context.Context, dispatch *agentv1.Dispatch) (*agentv1.Dispatch, error) {
+	record, err := mgr.remoteAgentRecord(ctx, dispatch.Spec.Agent)
+	if err != nil {
+		return nil, err
+	}
+	client, err := mgr.replicaClient(ctx, record.Replica)
+	if err != nil {
+		return nil, apierrors.NewInternalServerError(err)
+	}
+	// 直接返回代理所在副本的错误，保留其状态码
+	return client.CreateDispatch(ctx, dispatch)
+}
+
+// remoteAgentRecord 获取注册到其它副本的代理的记录
+// 记录属于当前副本时，代理已经从当前副本注销，返回 NotFound 错误
+func (mgr *Manager) remoteAgentRecord(ctx context.Context, name string) (*AgentRecord, error) {
+	record, err := mgr.store.GetAgent(ctx, name)
+	if err != nil {
+		if errors.Is(err, ErrAgentNotFound) {
+			return nil, apierrors.NewNotFoundError(fmt.Errorf("agent %q not found", name))
+		}
+		return nil, apierrors.NewInternalServerError(fmt.Errorf("get agent record error: %w", err))
+	}
+	if record.Replica == mgr.AdvertiseAddress() {
+		return nil, apierrors.NewNotFoundError(fmt.Errorf("agent %q not found", name))
+	}
+	return record, nil
+}
@@ -0,0 +1,324 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/server/generic"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// Options 集群选项
+type Options struct {
+	// 共享的流元信息存储，为 nil 时不启用集群模式
+	Store Store
+	// 当前副本供其它副本访问的地址，如 grpc://10.0.0.1:9443
+	AdvertiseAddress string
+}
+
+// NewManager 创建 *Manager
+func NewManager(local streams.Manager, opts Options) *Manager {
+	return &Manager{
+		local:         local,
+		store:         opts.Store,
+		advertiseAddr: opts.AdvertiseAddress,
+		clients:       map[string]common.Client{},
+		relays:        map[metav1.UID]*streams.StreamInstance{},
+	}
+}
+
+// Manager 是 streams.Manager 的集群实现
+//
+// 流实际存在于创建它的副本的本地管理器中，元信息记录在共享存储中。
+// 连接加入其它副本上的流时，会通过 gRPC 连接到流所在副本，并在两个连接之间转发数据。
+// 本地的流停止时删除其记录，其它副本上的流的实例在流的记录不存在后停止。
+type Manager struct {
+	local streams.Manager
+	store Store
+
+	addrLock      sync.RWMutex
+	advertiseAddr string
+
+	clientsLock sync.Mutex
+	clients     map[string]common.Client
+
+	relaysLock sync.Mutex
+	// 其它副本上的流的实例，每个流只创建一个
+	relays map[metav1.UID]*streams.StreamInstance
+}
+
+var _ streams.Manager = (*Manager)(nil)
+
+// AdvertiseAddress 返回当前副本供其它副本访问的地址
+func (mgr *Manager) AdvertiseAddress() string {
+	mgr.addrLock.RLock()
+	defer mgr.addrLock.RUnlock()
+	return mgr.advertiseAddr
+}
+
+// SetAdvertiseAddress 设置当前副本供其它副本访问的地址
+func (mgr *Manager) SetAdvertiseAddress(addr string) {
+	mgr.addrLock.Lock()
+	defer mgr.addrLock.Unlock()
+	mgr.advertiseAddr = addr
+}
+
+// CreateStream 在本地创建流并记录到共享存储
+func (mgr *Manager) CreateStream(ctx context.Context, stream *streams.StreamInstance) (*streams.StreamInstance, error) {
+	strm := &recordedStream{Stream: stream.Stream, mgr: mgr}
+	stream = stream.Clone()
+	stream.Stream = strm
+	ins, err := mgr.local.CreateStream(ctx, stream)
+	if err != nil {
+		return nil, err
+	}
+	strm.setUID(ins.Object.UID)
+
+	record := &StreamRecord{
+		Object:  ins.Clone().Object,
+		Replica: mgr.AdvertiseAddress(),
+	}
+	record.Object.Status.Token = ""
+	if err := mgr.store.PutStream(ctx, record); err != nil {
+		if err := mgr.local.DeleteStream(ctx, ins.Object.UID); err != nil {
+			logr.FromContextOrDiscard(ctx).Error(err, fmt.Sprintf("delete stream %q error", ins.Object.UID))
+		}
+		return nil, fmt.Errorf("save stream record error: %w", err)
+	}
+
+	return ins, nil
+}
+
+// ListStreams 列出集群中所有流
+func (mgr *Manager) ListStreams(ctx context.Context) ([]*streams.StreamInstance, error) {
+	records, err := mgr.store.ListStreams(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list stream records error: %w", err)
+	}
+	ret := make([]*streams.StreamInstance, 0, len(records))
+	exists := make(map[metav1.UID]bool, len(records))
+	for _, record := range records {
+		ret = append(ret, mgr.instance(ctx, record))
+		exists[record.Object.UID] = true
+	}
+
+	// 停止记录已不存在的流的实例
+	mgr.relaysLock.Lock()
+	var stale []*streams.StreamInstance
+	for uid, ins := range mgr.relays {
+		if !exists[uid] {
+			stale = append(stale, ins)
+			delete(mgr.relays, uid)
+		}
+	}
+	mgr.relaysLock.Unlock()
+	for _, ins := range stale {
+		_ = ins.Stream.Stop(ctx)
+	}
+
+	return ret, nil
+}
+
+// GetStream 获取流
+// 流在其它副本上时，返回的流实例会将加入的连接中继到流所在副本
+func (mgr *Manager) GetStream(ctx context.Context, uid metav1.UID) (*streams.StreamInstance, error) {
+	ins, err := mgr.local.GetStream(ctx, uid)
+	if err == nil {
+		return ins, nil
+	}
+	if !errors.Is(err, streams.ErrStreamNotFound) {
+		return nil, err
+	}
+
+	record, err := mgr.store.GetStream(ctx, uid)
+	if err != nil {
+		if errors.Is(err, streams.ErrStreamNotFound) {
+			mgr.stopRelay(ctx, uid)
+		}
+		return nil, err
+	}
+	if record.Replica == mgr.AdvertiseAddress() {
+		// 记录属于当前副本，但本地已经没有了
+		return nil, fmt.Errorf("%w: stream %q not found", streams.ErrStreamNotFound, uid)
+	}
+	return mgr.instance(ctx, record), nil
+}
+
+// DeleteStream 删除流
+func (mgr *Manager) DeleteStream(ctx context.Context, uid metav1.UID) error {
+	err := mgr.local.DeleteStream(ctx, uid)
+	if err == nil {
+		if err := mgr.store.DeleteStream(ctx, uid); err != nil && !errors.Is(err, streams.ErrStreamNotFound) {
+			return fmt.Errorf("delete stream record error: %w", err)
+		}
+		return nil
+	}
+	if !errors.Is(err, streams.ErrStreamNotFound) {
+		return err
+	}
+
+	record, err := mgr.store.GetStream(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if record.Replica == mgr.AdvertiseAddress() {
+		// 记录属于当前副本，但本地已经没有了，清理记录
+		return mgr.store.DeleteStream(ctx, uid)
+	}
+
+	// 到流所在副本删除
+	client, err := mgr.replicaClient(ctx, record.Replica)
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteStream(ctx, record.Object.Name); err != nil {
+		return fmt.Errorf("delete stream on replica %q error: %w", record.Replica, err)
+	}
+	mgr.stopRelay(ctx, uid)
+	return nil
+}
+
+// instance 返回流记录对应的流实例
+// 流在其它副本上时，复用之前为该流创建的中继流实例，不存在时创建并启动
+func (mgr *Manager) instance(ctx context.Context, record *StreamRecord) *streams.StreamInstance {
+	if record.Replica == mgr.AdvertiseAddress() {
+		if ins, err := mgr.local.GetStream(ctx, record.Object.UID); err == nil {
+			return ins
+		}
+	}
+
+	mgr.relaysLock.Lock()
+	defer mgr.relaysLock.Unlock()
+
+	ins, ok := mgr.relays[record.Object.UID]
+	if !ok {
+		replica := record.Replica
+		name := record.Object.Name
+		strm := streams.NewRelayStream(func(ctx context.Context, conn streams.Connection) (streams.Connection, error) {
+			client, err := mgr.replicaClient(ctx, replica)
+			if err != nil {
+				return nil, err
+			}
+			return client.ConnectStream(ctx, name, common.ConnectStreamOptions{ConnectionName: conn.Name()})
+		})
+		_ = strm.Start(ctx)
+		ins = &streams.StreamInstance{Stream: &remoteStream{RelayStream: strm, mgr: mgr, replica: replica, name: name}}
+		mgr.relays[record.Object.UID] = ins
+	}
+	ins.Object = record.Object
+	return ins.Clone()
+}
+
+// stopRelay 停止并移除其它副本上的流的实例
+func (mgr *Manager) stopRelay(ctx context.Context, uid metav1.UID) {
+	mgr.relaysLock.Lock()
+	ins, ok := mgr.relays[uid]
+	delete(mgr.relays, uid)
+	mgr.relaysLock.Unlock()
+
+	if ok {
+		_ = ins.Stream.Stop(ctx)
+	}
+}
+
+// replicaClient 返回访问指定副本的客户端
+// 使用发起请求的用户的 Token ，由流所在副本再次鉴权
+func (mgr *Manager) replicaClient(ctx context.Context, replica string) (common.Client, error) {
+	mgr.clientsLock.Lock()
+	client, ok := mgr.clients[replica]
+	if !ok {
+		var err error
+		client, err = common.NewClient(common.ClientOptions{Server: replica})
+		if err != nil {
+			mgr.clientsLock.Unlock()
+			return nil, fmt.Errorf("create client for replica %q error: %w", replica, err)
+		}
+		mgr.clients[replica] = client
+	}
+	mgr.clientsLock.Unlock()
+
+	token, _ := generic.TokenFromContext(ctx)
+	return client.WithToken(token), nil
+}
+
+// remoteStream 其它副本上的流的中继流，信号发送到流所在副本
+type remoteStream struct {
+	*streams.RelayStream
+	mgr     *Manager
+	replica string
+	name    string
+}
+
+var _ streams.Signaler = (*remoteStream)(nil)
+
+// Signal 到流所在副本向流中执行命令的一端发送信号
+func (s *remoteStream) Signal(ctx context.Context, signal string) error {
+	client, err := s.mgr.replicaClient(ctx, s.replica)
+	if err != nil {
+		return err
+	}
+	return client.SignalStream(ctx, s.name, signal)
+}
+
+// recordedStream 记录在共享存储中的本地的流，停止时删除其记录
+//
+// 流可能因停止策略停止，而不经过 DeleteStream
+type recordedStream struct {
+	streams.Stream
+	mgr *Manager
+
+	// 流的 uid ，由本地的管理器创建流时分配
+	uidLock sync.Mutex
+	uid     metav1.UID
+}
+
+var _ streams.ControlNotifier = (*recordedStream)(nil)
+var _ streams.Signaler = (*recordedStream)(nil)
+
+// Stop 停止传输并删除流的记录
+func (s *recordedStream) Stop(ctx context.Context) error {
+	if err := s.Stream.Stop(ctx); err != nil {
+		return err
+	}
+	s.uidLock.Lock()
+	uid := s.uid
+	s.uidLock.Unlock()
+	if uid == "" {
+		return nil
+	}
+	// 流可能因停止策略在创建流的请求结束后停止
+	ctx = context.WithoutCancel(ctx)
+	if err := s.mgr.store.DeleteStream(ctx, uid); err != nil && !errors.Is(err, streams.ErrStreamNotFound) {
+		logr.FromContextOrDiscard(ctx).Error(err, fmt.Sprintf("delete stream record %q error", uid))
+	}
+	return nil
+}
+
+// setUID 设置流的 uid
+func (s *recordedStream) setUID(uid metav1.UID) {
+	s.uidLock.Lock()
+	defer s.uidLock.Unlock()
+	s.uid = uid
+}
+
+// NotifyConnections 向流中的连接发送控制消息
+func (s *recordedStream) NotifyConnections(ctx context.Context, control streams.Control) {
+	if notifier, ok := s.Stream.(streams.ControlNotifier); ok {
+		notifier.NotifyConnections(ctx, control)
+	}
+}
+
+// Signal 向流中执行命令的一端发送信号
+func (s *recordedStream) Signal(ctx context.Context, signal string) error {
+	signaler, ok := s.Stream.(streams.Signaler)
+	if !ok {
+		return fmt.Errorf("stream does not support signals")
+	}
+	return signaler.Signal(ctx, signal)
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/streams/streamstest"
+)
+
+// TestManager_Instances 测试复用其它副本上的流的实例，流的记录不存在后停止实例
+func TestManager_Instances(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	store := NewInMemoryStore()
+	mgr := NewManager(streams.NewInMemoryManager(), Options{Store: store, AdvertiseAddress: "grpc://replica-1"})
+
+	// 其它副本上的流
+	for _, uid := range []metav1.UID{"s1", "s2"} {
+		record := &StreamRecord{Replica: "grpc://replica-2"}
+		record.Object.Name = string(uid)
+		record.Object.UID = uid
+		a.NoError(store.PutStream(ctx, record))
+	}
+	ins1, err := mgr.GetStream(ctx, "s1")
+	if !a.NoError(err) {
+		return
+	}
+	ins2, err := mgr.GetStream(ctx, "s1")
+	if !a.NoError(err) {
+		return
+	}
+	a.Same(ins1.Stream, ins2.Stream)
+	list, err := mgr.ListStreams(ctx)
+	if a.NoError(err) && a.Len(list, 2) {
+		a.Same(ins1.Stream, list[0].Stream)
+	}
+
+	// 记录不存在后获取或列出时停止实例
+	ins3, err := mgr.GetStream(ctx, "s2")
+	if !a.NoError(err) {
+		return
+	}
+	a.NoError(store.DeleteStream(ctx, "s1"))
+	a.NoError(store.DeleteStream(ctx, "s2"))
+	_, err = mgr.GetStream(ctx, "s1")
+	a.ErrorIs(err, streams.ErrStreamNotFound)
+	a.ErrorIs(ins1.Stream.Join(ctx, newTestConnection()), streams.ErrStreamAlreadyStopped)
+	list, err = mgr.ListStreams(ctx)
+	a.NoError(err)
+	a.Empty(list)
+	a.ErrorIs(ins3.Stream.Join(ctx, newTestConnection()), streams.ErrStreamAlreadyStopped)
+	a.Empty(mgr.relays)
+
+	// 本地的流不经过 DeleteStream 停止（如因停止策略停止）时删除记录
+	ins, err := mgr.CreateStream(ctx, &streams.StreamInstance{
+		Object: streamv1.Stream{Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnDelete}},
+		Stream: streams.NewBufferedStream(streams.BufferedStreamOptions{}),
+	})
+	if !a.NoError(err) {
+		return
+	}
+	_, err = store.GetStream(ctx, ins.Object.UID)
+	a.NoError(err)
+	a.NoError(ins.Stream.Stop(ctx))
+	_, err = store.GetStream(ctx, ins.Object.UID)
+	a.ErrorIs(err, streams.ErrStreamNotFound)
+}
+
+// newTestConnection 返回测试用的连接
+func newTestConnection() streams.Connection {
+	conn, _ := streamstest.NewConnectionPair()
+	return conn
+}
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+)
+
+// ErrAgentNotFound 代理记录不存在
+var ErrAgentNotFound = errors.New("AgentNotFound")
+
+// Store 集群中各副本共享的流和代理元信息存储
+type Store interface {
+	// PutStream 保存流记录
+	PutStream(ctx context.Context, record *StreamRecord) error
+	// GetStream 获取流记录
+	GetStream(ctx context.Context, uid metav1.UID) (*StreamRecord, error)
+	// ListStreams 列出流记录
+	ListStreams(ctx context.Context) ([]*StreamRecord, error)
+	// DeleteStream 删除流记录
+	DeleteStream(ctx context.Context, uid metav1.UID) error
+
+	// PutAgent 保存代理记录
+	PutAgent(ctx context.Context, record *AgentRecord) error
+	// GetAgent 获取代理记录
+	GetAgent(ctx context.Context, name string) (*AgentRecord, error)
+	// ListAgents 列出代理记录
+	ListAgents(ctx context.Context) ([]*AgentRecord, error)
+	// DeleteAgent 删除代理记录
+	DeleteAgent(ctx context.Context, name string) error
+}
+
+// StreamRecord 流记录
+type StreamRecord struct {
+	// 流对象
+	Object streamv1.Stream `json:"object"`
+	// 流所在副本的地址
+	Replica string `json:"replica"`
+}
+
+// AgentRecord 代理记录
+type AgentRecord struct {
+	// 代理对象
+	Object agentv1.Agent `json:"object"`
+	// 代理注册到的副本的地址
+	Replica string `json:"replica"`
+}
+
+// NewStoreFromURL 基于 URL 创建存储
+//
+// 支持：
+//   - memory:// 进程内存储，仅用于同一进程中运行多个服务
+//   - file:///PATH 基于目录的存储，可用于共享存储卷上的多个服务
+func NewStoreFromURL(rawURL string) (Store, error) {
+	urlObj, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store url %q: %w", rawURL, err)
+	}
+	switch urlObj.Scheme {
+	case "memory":
+		return NewInMemoryStore(), nil
+	case "file":
+		return NewFileStore(urlObj.Path)
+	default:
+		return nil, fmt.Errorf("invalid store url %q: unsupported scheme %q", rawURL, urlObj.Scheme)
+	}
+}
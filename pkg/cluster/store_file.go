@@ -0,0 +1,243 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+const (
+	fileStoreRecordSuffix = ".json"
+	// fileStoreAgentsDir 代理记录所在子目录
+	fileStoreAgentsDir = "agents"
+)
+
+// NewFileStore 创建 FileStore
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("store directory must not be empty")
+	}
+	if err := os.MkdirAll(filepath.Join(dir, fileStoreAgentsDir), 0o700); err != nil {
+		return nil, fmt.Errorf("make store directory %q error: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// FileStore 是 Store 的基于目录的实现
+// 每个流记录保存为目录中的一个 JSON 文件，每个代理记录保存为 agents 子目录中的一个 JSON 文件，
+// 目录放在共享存储卷上时可在多个服务间共享
+type FileStore struct {
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+// PutStream 保存流记录
+func (s *FileStore) PutStream(_ context.Context, record *StreamRecord) error {
+	path, err := s.recordPath(record.Object.UID)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal stream record to json error: %w", err)
+	}
+	if err := s.writeFile(path, raw); err != nil {
+		return fmt.Errorf("write stream record error: %w", err)
+	}
+	return nil
+}
+
+// GetStream 获取流记录
+func (s *FileStore) GetStream(_ context.Context, uid metav1.UID) (*StreamRecord, error) {
+	path, err := s.recordPath(uid)
+	if err != nil {
+		return nil, err
+	}
+	return readRecordFile(uid, path)
+}
+
+// ListStreams 列出流记录
+func (s *FileStore) ListStreams(_ context.Context) ([]*StreamRecord, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read store directory %q error: %w", s.dir, err)
+	}
+	var ret []*StreamRecord
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, fileStoreRecordSuffix) {
+			continue
+		}
+		uid := metav1.UID(strings.TrimSuffix(name, fileStoreRecordSuffix))
+		record, err := readRecordFile(uid, filepath.Join(s.dir, name))
+		if err != nil {
+			// 可能被其它副本删除了
+			continue
+		}
+		ret = append(ret, record)
+	}
+	// 按 uid 排序，保持结果稳定
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Object.UID < ret[j].Object.UID
+	})
+	return ret, nil
+}
+
+// DeleteStream 删除流记录
+func (s *FileStore) DeleteStream(_ context.Context, uid metav1.UID) error {
+	path, err := s.recordPath(uid)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: stream %q not found", streams.ErrStreamNotFound, uid)
+		}
+		return fmt.Errorf("remove stream record %q error: %w", path, err)
+	}
+	return nil
+}
+
+// PutAgent 保存代理记录
+func (s *FileStore) PutAgent(_ context.Context, record *AgentRecord) error {
+	path, err := s.agentRecordPath(record.Object.Name)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal agent record to json error: %w", err)
+	}
+	if err := s.writeFile(path, raw); err != nil {
+		return fmt.Errorf("write agent record error: %w", err)
+	}
+	return nil
+}
+
+// GetAgent 获取代理记录
+func (s *FileStore) GetAgent(_ context.Context, name string) (*AgentRecord, error) {
+	path, err := s.agentRecordPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return readAgentRecordFile(name, path)
+}
+
+// ListAgents 列出代理记录
+func (s *FileStore) ListAgents(_ context.Context) ([]*AgentRecord, error) {
+	dir := filepath.Join(s.dir, fileStoreAgentsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read store directory %q error: %w", dir, err)
+	}
+	var ret []*AgentRecord
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, fileStoreRecordSuffix) {
+			continue
+		}
+		record, err := readAgentRecordFile(strings.TrimSuffix(name, fileStoreRecordSuffix), filepath.Join(dir, name))
+		if err != nil {
+			// 可能被其它副本删除了
+			continue
+		}
+		ret = append(ret, record)
+	}
+	// 按名字排序，保持结果稳定
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Object.Name < ret[j].Object.Name
+	})
+	return ret, nil
+}
+
+// DeleteAgent 删除代理记录
+func (s *FileStore) DeleteAgent(_ context.Context, name string) error {
+	path, err := s.agentRecordPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: agent %q not found", ErrAgentNotFound, name)
+		}
+		return fmt.Errorf("remove agent record %q error: %w", path, err)
+	}
+	return nil
+}
+
+// writeFile 写记录文件
+// 先写临时文件再重命名，避免其它副本读到写了一半的记录
+func (s *FileStore) writeFile(path string, raw []byte) error {
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file error: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// recordPath 返回流记录文件路径
+func (s *FileStore) recordPath(uid metav1.UID) (string, error) {
+	name := string(uid)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%w: invalid stream uid %q", streams.ErrStreamNotFound, uid)
+	}
+	return filepath.Join(s.dir, name+fileStoreRecordSuffix), nil
+}
+
+// agentRecordPath 返回代理记录文件路径
+func (s *FileStore) agentRecordPath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%w: invalid agent name %q", ErrAgentNotFound, name)
+	}
+	return filepath.Join(s.dir, fileStoreAgentsDir, name+fileStoreRecordSuffix), nil
+}
+
+// readRecordFile 读流记录文件
+func readRecordFile(uid metav1.UID, path string) (*StreamRecord, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: stream %q not found", streams.ErrStreamNotFound, uid)
+		}
+		return nil, fmt.Errorf("read stream record %q error: %w", path, err)
+	}
+	record := &StreamRecord{}
+	if err := json.Unmarshal(raw, record); err != nil {
+		return nil, fmt.Errorf("unmarshal stream record %q from json error: %w", path, err)
+	}
+	return record, nil
+}
+
+// readAgentRecordFile 读代理记录文件
+func readAgentRecordFile(name string, path string) (*AgentRecord, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: agent %q not found", ErrAgentNotFound, name)
+		}
+		return nil, fmt.Errorf("read agent record %q error: %w", path, err)
+	}
+	record := &AgentRecord{}
+	if err := json.Unmarshal(raw, record); err != nil {
+		return nil, fmt.Errorf("unmarshal agent record %q from json error: %w", path, err)
+	}
+	return record, nil
+}
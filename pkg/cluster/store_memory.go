@@ -0,0 +1,153 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// NewInMemoryStore 创建 InMemoryStore
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		records: map[metav1.UID]StreamRecord{},
+		agents:  map[string]AgentRecord{},
+	}
+}
+
+// InMemoryStore 是 Store 的基于内存的实现
+// 只能在同一进程中的多个服务间共享
+type InMemoryStore struct {
+	lock    sync.RWMutex
+	records map[metav1.UID]StreamRecord
+	agents  map[string]AgentRecord
+}
+
+var _ Store = (*InMemoryStore)(nil)
+
+// PutStream 保存流记录
+func (s *InMemoryStore) PutStream(_ context.Context, record *StreamRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.records[record.Object.UID] = *cloneRecord(record)
+	return nil
+}
+
+// GetStream 获取流记录
+func (s *InMemoryStore) GetStream(_ context.Context, uid metav1.UID) (*StreamRecord, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	record, ok := s.records[uid]
+	if !ok {
+		return nil, fmt.Errorf("%w: stream %q not found", streams.ErrStreamNotFound, uid)
+	}
+	return cloneRecord(&record), nil
+}
+
+// ListStreams 列出流记录
+func (s *InMemoryStore) ListStreams(_ context.Context) ([]*StreamRecord, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	ret := make([]*StreamRecord, 0, len(s.records))
+	for _, record := range s.records {
+		ret = append(ret, cloneRecord(&record))
+	}
+	// 按 uid 排序，保持结果稳定
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Object.UID < ret[j].Object.UID
+	})
+	return ret, nil
+}
+
+// DeleteStream 删除流记录
+func (s *InMemoryStore) DeleteStream(_ context.Context, uid metav1.UID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.records[uid]; !ok {
+		return fmt.Errorf("%w: stream %q not found", streams.ErrStreamNotFound, uid)
+	}
+	delete(s.records, uid)
+	return nil
+}
+
+// PutAgent 保存代理记录
+func (s *InMemoryStore) PutAgent(_ context.Context, record *AgentRecord) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.agents[record.Object.Name] = *cloneAgentRecord(record)
+	return nil
+}
+
+// GetAgent 获取代理记录
+func (s *InMemoryStore) GetAgent(_ context.Context, name string) (*AgentRecord, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	record, ok := s.agents[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: agent %q not found", ErrAgentNotFound, name)
+	}
+	return cloneAgentRecord(&record), nil
+}
+
+// ListAgents 列出代理记录
+func (s *InMemoryStore) ListAgents(_ context.Context) ([]*AgentRecord, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	ret := make([]*AgentRecord, 0, len(s.agents))
+	for _, record := range s.agents {
+		ret = append(ret, cloneAgentRecord(&record))
+	}
+	// 按名字排序，保持结果稳定
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Object.Name < ret[j].Object.Name
+	})
+	return ret, nil
+}
+
+// DeleteAgent 删除代理记录
+func (s *InMemoryStore) DeleteAgent(_ context.Context, name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.agents[name]; !ok {
+		return fmt.Errorf("%w: agent %q not found", ErrAgentNotFound, name)
+	}
+	delete(s.agents, name)
+	return nil
+}
+
+// cloneRecord 返回流记录的一个拷贝
+func cloneRecord(record *StreamRecord) *StreamRecord {
+	ins := (&streams.StreamInstance{Object: record.Object}).Clone()
+	return &StreamRecord{
+		Object:  ins.Object,
+		Replica: record.Replica,
+	}
+}
+
+// cloneAgentRecord 返回代理记录的一个拷贝
+func cloneAgentRecord(record *AgentRecord) *AgentRecord {
+	ret := &AgentRecord{
+		Object:  record.Object,
+		Replica: record.Replica,
+	}
+	ret.Object.Annotations = cloneMap(record.Object.Annotations)
+	ret.Object.Spec.Labels = cloneMap(record.Object.Spec.Labels)
+	ret.Object.Owners = append([]string(nil), record.Object.Owners...)
+	ret.Object.Spec.Users = append([]string(nil), record.Object.Spec.Users...)
+	return ret
+}
+
+// cloneMap 返回 map 的一个拷贝
+func cloneMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		ret[k] = v
+	}
+	return ret
+}
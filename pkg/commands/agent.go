@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	clientsagent "github.com/yhlooo/scaf/pkg/clients/agent"
+	clientsexec "github.com/yhlooo/scaf/pkg/clients/exec"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+)
+
+// NewAgentCommandWithOptions 基于选项创建 agent 子命令
+func NewAgentCommandWithOptions(opts *options.AgentOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Run an agent that registers to the server and serves dispatched exec and file transfer requests",
+		Example: `# Run an agent
+scaf agent -s SERVER --name build-box-3 -l arch=amd64 [--allow-user USER] [--policy POLICY_FILE]
+
+# Allow reading files in /srv/share and writing files there
+scaf agent -s SERVER --allow-file-transfer --file-root /srv/share --allow-file-write
+
+# Execute a command on the agent
+scaf exec-remote -s SERVER --agent build-box-3 -it -- bash`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			// 创建客户端
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+
+			daemonOpts := clientsagent.Options{
+				Name:              opts.Name,
+				Labels:            opts.Labels,
+				Users:             opts.AllowedUsers,
+				AllowFileTransfer: opts.AllowFileTransfer,
+				FileRoot:          opts.FileRoot,
+				AllowFileWrite:    opts.AllowFileWrite,
+			}
+			if daemonOpts.Name == "" {
+				daemonOpts.Name, err = os.Hostname()
+				if err != nil {
+					return fmt.Errorf("get hostname error: %w", err)
+				}
+			}
+			if opts.Policy != "" {
+				daemonOpts.Policy, err = clientsexec.LoadPolicy(opts.Policy)
+				if err != nil {
+					return err
+				}
+			}
+
+			return clientsagent.NewDaemon(client, daemonOpts).Run(ctx)
+		},
+	}
+
+	// 绑定选项到命令行
+	opts.AddPFlags(cmd.Flags())
+
+	cmd.AddCommand(
+		NewAgentListCommandWithOptions(&opts.List),
+	)
+
+	return cmd
+}
+
+// NewAgentListCommandWithOptions 基于选项创建 agent list 子命令
+func NewAgentListCommandWithOptions(opts *options.AgentListOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List agents that requests can be dispatched to",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// 创建客户端
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+
+			agentList, err := client.ListAgents(ctx)
+			if err != nil {
+				return err
+			}
+
+			for _, agent := range agentList.Items {
+				if !agent.MatchLabels(opts.Selector) {
+					continue
+				}
+				labels := make([]string, 0, len(agent.Spec.Labels))
+				for k, v := range agent.Spec.Labels {
+					labels = append(labels, k+"="+v)
+				}
+				sort.Strings(labels)
+				fmt.Printf("%s\t%s\n", agent.Name, strings.Join(labels, ","))
+			}
+
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
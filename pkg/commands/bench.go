@@ -1,14 +1,22 @@
 package commands
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
 	clientsbench "github.com/yhlooo/scaf/pkg/clients/bench"
+	clientscommon "github.com/yhlooo/scaf/pkg/clients/common"
 	"github.com/yhlooo/scaf/pkg/commands/options"
 	"github.com/yhlooo/scaf/pkg/utils/units"
 )
@@ -22,6 +30,9 @@ func NewBenchCommandWithOptions(opts *options.BenchOptions) *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			logger := logr.FromContextOrDiscard(ctx)
+			if err := opts.Validate(); err != nil {
+				return err
+			}
 
 			// 创建客户端
 			client, err := opts.NewClient(ctx)
@@ -29,6 +40,25 @@ func NewBenchCommandWithOptions(opts *options.BenchOptions) *cobra.Command {
 				return fmt.Errorf("create client error: %w", err)
 			}
 
+			switch {
+			case opts.Load:
+				// 负载测试
+				report, err := clientsbench.NewClient(client).RunLoad(ctx, opts.LoadOptions())
+				if report != nil {
+					if err := outputBenchLoadReport(opts.OutputFormat, report); err != nil {
+						return err
+					}
+				}
+				return err
+			case opts.Loopback:
+				// 在本进程中运行测试服务端和测试客户端
+				report, err := runBenchLoopback(ctx, opts, client)
+				if err != nil {
+					return err
+				}
+				return outputBenchReport(opts, report)
+			}
+
 			if opts.Stream == "" {
 				// 创建流并运行测试服务端
 				stream, err := client.CreateStream(ctx, &streamv1.Stream{})
@@ -61,52 +91,12 @@ func NewBenchCommandWithOptions(opts *options.BenchOptions) *cobra.Command {
 
 			// 运行测试客户端
 			benchClient := clientsbench.NewClient(client)
-			report, err := benchClient.Run(ctx, stream)
+			report, err := benchClient.Run(ctx, stream, opts.RunOptions())
 			if err != nil {
 				return err
 			}
 
-			// 展示结果
-			fmt.Println()
-			fmt.Println("Result:")
-			fmt.Println()
-			fmt.Println("Ping:")
-			fmt.Printf(
-				"  Time:   %s\t(lost: %.2f%%)\n",
-				report.Ping.RoundTripTime, report.Ping.LossRate*100,
-			)
-			fmt.Println("Read Only:")
-			fmt.Printf(
-				"  Read:   %sB/s\t(read: %sB, packages: %s, lost: %.2f%%)\n",
-				units.NewIECValue(int64(report.ReadOnly.Throughput)).RoundString(2),
-				units.NewIECValue(int64(report.ReadOnly.Size)).RoundString(2),
-				units.NewSIValue(int64(report.ReadOnly.Packages)).RoundString(2),
-				report.ReadOnly.LossRate*100,
-			)
-			fmt.Println("Write Only:")
-			fmt.Printf(
-				"  Write:  %sB/s\t(write: %sB, packages: %s, lost: %.2f%%)\n",
-				units.NewIECValue(int64(report.WriteOnly.Throughput)).RoundString(2),
-				units.NewIECValue(int64(report.WriteOnly.Size)).RoundString(2),
-				units.NewSIValue(int64(report.WriteOnly.Packages)).RoundString(2),
-				report.WriteOnly.LossRate*100,
-			)
-			fmt.Println("Read and Write:")
-			fmt.Printf(
-				"  Read:   %sB/s\t(read: %sB, packages: %s, lost: %.2f%%)\n",
-				units.NewIECValue(int64(report.ReadWrite.Read.Throughput)).RoundString(2),
-				units.NewIECValue(int64(report.ReadWrite.Read.Size)).RoundString(2),
-				units.NewSIValue(int64(report.ReadWrite.Read.Packages)).RoundString(2),
-				report.ReadWrite.Read.LossRate*100,
-			)
-			fmt.Printf(
-				"  Write:  %sB/s\t(write: %sB, packages: %s, lost: %.2f%%)\n",
-				units.NewIECValue(int64(report.ReadWrite.Write.Throughput)).RoundString(2),
-				units.NewIECValue(int64(report.ReadWrite.Write.Size)).RoundString(2),
-				units.NewSIValue(int64(report.ReadWrite.Write.Packages)).RoundString(2),
-				report.ReadWrite.Write.LossRate*100,
-			)
-			return nil
+			return outputBenchReport(opts, report)
 		},
 	}
 
@@ -114,3 +104,262 @@ func NewBenchCommandWithOptions(opts *options.BenchOptions) *cobra.Command {
 
 	return cmd
 }
+
+// printBenchReport 以表格形式输出测试报告
+func printBenchReport(out io.Writer, report *clientsbench.Report, compareFile string, threshold float64) {
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintf(
+		out, "Streams: %d, Duration: %s, Package Size: %sB\n",
+		report.Options.Parallel, report.Options.Duration,
+		units.NewIECValue(int64(report.Options.PackageSize)).RoundString(2),
+	)
+	_, _ = fmt.Fprintf(
+		out, "Connect: min %s, mean %s, max %s\n",
+		formatLatency(report.Connect.Min), formatLatency(report.Connect.Mean), formatLatency(report.Connect.Max),
+	)
+	_, _ = fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TEST\tDIRECTION\tTHROUGHPUT\tTRANSFERRED\tPACKAGES\tLOST\tP50\tP90\tP99\tMAX\tJITTER")
+	_, _ = fmt.Fprintf(
+		w, "Ping\tround-trip\t-\t-\t%d\t%.2f%%\t%s\n",
+		report.Ping.Latency.Count, report.Ping.LossRate*100, formatLatencyStats(report.Ping.Latency),
+	)
+	printRow := func(test, direction string, ret clientsbench.TransmissionResult) {
+		_, _ = fmt.Fprintf(
+			w, "%s\t%s\t%sB/s\t%sB\t%s\t%.2f%%\t%s\n",
+			test, direction,
+			units.NewIECValue(int64(ret.Throughput)).RoundString(2),
+			units.NewIECValue(int64(ret.Size)).RoundString(2),
+			units.NewSIValue(int64(ret.Packages)).RoundString(2),
+			ret.LossRate*100,
+			formatLatencyStats(ret.Latency),
+		)
+	}
+	printRow("Read Only", "read", report.ReadOnly)
+	printRow("Write Only", "write", report.WriteOnly)
+	printRow("Read and Write", "read", report.ReadWrite.Read)
+	printRow("Read and Write", "write", report.ReadWrite.Write)
+	_ = w.Flush()
+
+	if compareFile == "" {
+		return
+	}
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintf(out, "Compared with %s (threshold: %.0f%%):\n", compareFile, threshold*100)
+	_, _ = fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "METRIC\tPREVIOUS\tCURRENT\tCHANGE\t")
+	for _, c := range report.Comparison {
+		mark := ""
+		if c.Regression {
+			mark = "REGRESSION"
+		}
+		_, _ = fmt.Fprintf(
+			w, "%s\t%s\t%s\t%+.2f%%\t%s\n",
+			c.Metric, formatMetric(c.Previous, c.Unit), formatMetric(c.Current, c.Unit), c.Change*100, mark,
+		)
+	}
+	_ = w.Flush()
+}
+
+// formatLatencyStats 格式化时延统计为表格的 P50 、 P90 、 P99 、 MAX 、 JITTER 列
+func formatLatencyStats(stats clientsbench.LatencyStats) string {
+	if stats.Count == 0 {
+		return "-\t-\t-\t-\t-"
+	}
+	return strings.Join([]string{
+		formatLatency(stats.P50),
+		formatLatency(stats.P90),
+		formatLatency(stats.P99),
+		formatLatency(stats.Max),
+		formatLatency(stats.Jitter),
+	}, "\t")
+}
+
+// formatLatency 格式化时延，保留 3 位左右有效数字
+func formatLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= 10*time.Millisecond:
+		return d.Round(100 * time.Microsecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// formatMetric 格式化指标值
+func formatMetric(v float64, unit clientsbench.MetricUnit) string {
+	switch unit {
+	case clientsbench.MetricUnitBytesPerSecond:
+		return units.NewIECValue(int64(v)).RoundString(2) + "B/s"
+	case clientsbench.MetricUnitMilliseconds:
+		return fmt.Sprintf("%.3fms", v)
+	case clientsbench.MetricUnitPercent:
+		return fmt.Sprintf("%.2f%%", v)
+	default:
+		return fmt.Sprintf("%g", v)
+	}
+}
+
+// runBenchLoopback 在本进程中同时运行测试服务端和测试客户端
+func runBenchLoopback(
+	ctx context.Context,
+	opts *options.BenchOptions,
+	client clientscommon.Client,
+) (*clientsbench.Report, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	// 创建流
+	stream, err := client.CreateStream(ctx, &streamv1.Stream{})
+	if err != nil {
+		return nil, fmt.Errorf("create stream error: %w", err)
+	}
+	defer func() {
+		if err := client.DeleteStream(context.WithoutCancel(ctx), stream.Name); err != nil {
+			logger.Error(err, "delete stream error")
+		}
+	}()
+	streamClient := client
+	if stream.Status.Token != "" {
+		streamClient = client.WithToken(stream.Status.Token)
+	}
+
+	// 测试服务端可以通过另一种协议连接服务端
+	peerClient := streamClient
+	if opts.PeerServer != "" && opts.PeerServer != opts.Server {
+		peerOpts := opts.ClientOptions
+		peerOpts.Server = opts.PeerServer
+		peerOpts.NoLogin = true
+		peerClient, err = peerOpts.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("create client for peer server error: %w", err)
+		}
+		peerClient = peerClient.WithToken(stream.Status.Token)
+	}
+
+	// 运行测试服务端，测试服务端异常退出时停止测试
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	serverCtx, stopServer := context.WithCancel(ctx)
+	serverDone := make(chan error, 1)
+	go func() {
+		err := clientsbench.NewServer(peerClient).Serve(serverCtx, stream)
+		if err != nil {
+			cancelRun()
+		}
+		serverDone <- err
+	}()
+
+	// 运行测试客户端
+	report, err := clientsbench.NewClient(streamClient).Run(runCtx, stream, opts.RunOptions())
+	stopServer()
+	if serverErr := <-serverDone; serverErr != nil {
+		return nil, fmt.Errorf("benchmark server error: %w", serverErr)
+	}
+	return report, err
+}
+
+// outputBenchReport 与之前的报告比较并输出测试报告，有指标退化时返回错误
+func outputBenchReport(opts *options.BenchOptions, report *clientsbench.Report) error {
+	// 与之前的报告比较
+	regressions := 0
+	if opts.Compare != "" {
+		previous, err := clientsbench.ReadReport(opts.Compare)
+		if err != nil {
+			return fmt.Errorf("read report to compare error: %w", err)
+		}
+		report.Comparison = clientsbench.Compare(previous, report, opts.RegressionThreshold)
+		for _, c := range report.Comparison {
+			if c.Regression {
+				regressions++
+			}
+		}
+	}
+
+	// 展示结果
+	switch opts.OutputFormat {
+	case "yaml":
+		raw, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(raw))
+	case "json":
+		raw, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(raw))
+	default:
+		printBenchReport(os.Stdout, report, opts.Compare, opts.RegressionThreshold)
+	}
+
+	if regressions > 0 {
+		return fmt.Errorf("%d metric(s) regressed compared with %s", regressions, opts.Compare)
+	}
+	return nil
+}
+
+// outputBenchLoadReport 输出负载测试报告
+func outputBenchLoadReport(format string, report *clientsbench.LoadReport) error {
+	switch format {
+	case "yaml":
+		raw, err := yaml.Marshal(report)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(raw))
+	case "json":
+		raw, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(raw))
+	default:
+		fmt.Println()
+		fmt.Printf(
+			"Streams: %d succeeded, %d failed in %s (%.1f streams/s, concurrency: %d)\n",
+			report.Succeeded, report.Failed, report.Duration.Round(time.Millisecond),
+			report.StreamsPerSecond, report.Options.Concurrency,
+		)
+		if report.FirstError != "" {
+			fmt.Printf("First Error: %s\n", report.FirstError)
+		}
+		if report.Memory != nil {
+			fmt.Printf(
+				"Server Memory: %sB before, %sB after, %sB per stream\n",
+				units.NewIECValue(int64(report.Memory.Before)).RoundString(2),
+				units.NewIECValue(int64(report.Memory.After)).RoundString(2),
+				units.NewIECValue(int64(report.Memory.PerStream)).RoundString(2),
+			)
+		}
+		fmt.Println()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "OPERATION\tCOUNT\tMIN\tMEAN\tP50\tP90\tP99\tMAX")
+		for _, row := range []struct {
+			name  string
+			stats clientsbench.LatencyStats
+		}{
+			{name: "Create", stats: report.Create},
+			{name: "Connect", stats: report.Connect},
+			{name: "Round Trip", stats: report.RoundTrip},
+		} {
+			if row.stats.Count == 0 {
+				continue
+			}
+			_, _ = fmt.Fprintf(
+				w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				row.name, row.stats.Count,
+				formatLatency(row.stats.Min), formatLatency(row.stats.Mean), formatLatency(row.stats.P50),
+				formatLatency(row.stats.P90), formatLatency(row.stats.P99), formatLatency(row.stats.Max),
+			)
+		}
+		_ = w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	clientscommon "github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+	"github.com/yhlooo/scaf/pkg/server"
+)
+
+// TestRunBenchLoopback 测试测试客户端通过 gRPC 、测试服务端通过 WebSocket 连接服务端时在一个进程中运行基准测试
+func TestRunBenchLoopback(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	s := server.NewServer(server.Options{ListenAddr: "127.0.0.1:0"})
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start server error: %v", err)
+	}
+	defer func() {
+		_ = s.Stop(context.Background())
+	}()
+	token, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+
+	opts := options.NewDefaultBenchOptions()
+	opts.Server = "grpc://" + s.Address().String()
+	opts.PeerServer = "http://" + s.Address().String()
+	opts.Token = token
+	opts.Loopback = true
+	opts.Duration = time.Second
+	opts.PackageSize = 1 << 10
+	opts.PingCount = 5
+	if !a.NoError(opts.Validate()) {
+		return
+	}
+	client, err := clientscommon.NewClient(clientscommon.ClientOptions{Server: opts.Server, Token: token})
+	if !a.NoError(err) {
+		return
+	}
+
+	report, err := runBenchLoopback(ctx, &opts, client)
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal(opts.RunOptions(), report.Options)
+	a.Positive(report.Connect.Count)
+	a.Positive(report.Ping.RoundTripTime)
+	a.Equal(uint64(5), report.Ping.Latency.Count)
+	a.Positive(report.ReadOnly.Throughput)
+	a.Positive(report.WriteOnly.Throughput)
+	a.Positive(report.ReadWrite.Read.Throughput)
+	a.Positive(report.ReadWrite.Write.Throughput)
+
+	// 测试结束后删除流
+	list, err := client.ListStreams(ctx)
+	if a.NoError(err) {
+		a.Empty(list.Items)
+	}
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	clientscommon "github.com/yhlooo/scaf/pkg/clients/common"
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+)
+
+// NewCopyCommandWithOptions 基于选项创建 cp 子命令
+func NewCopyCommandWithOptions(opts *options.CopyOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cp [-r] SOURCE... TARGET",
+		Short: "Copy files from or to the file server on the other side of a stream",
+		Long: `Copy files from or to the file server on the other side of a stream, like scp.
+
+Remote paths are in the form REMOTE:PATH, where REMOTE is the name of a stream served by scaf file-server,
+or @AGENT to dispatch a file server to a registered agent (started with --allow-file-transfer).
+Either all sources or the target must be remote.`,
+		Example: `# Upload a file to the file server serving stream STREAM
+scaf cp -s SERVER --token TOKEN local.txt STREAM:/tmp/
+
+# Download a directory from the file server
+scaf cp -s SERVER --token TOKEN -r STREAM:/var/log/app ./logs
+
+# Download a file from a registered agent
+scaf cp -s SERVER @AGENT:/logs/app.log .`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			logger := logr.FromContextOrDiscard(ctx)
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			remote, sources, target, upload, err := parseCopyArgs(args)
+			if err != nil {
+				return err
+			}
+
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+			files, cleanup, err := connectRemoteFiles(ctx, client, remote)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if upload {
+				err = clientscp.Upload(ctx, files, sources, target, opts.CopyOptions())
+			} else {
+				err = clientscp.Download(ctx, files, sources, target, opts.CopyOptions())
+			}
+			if err != nil {
+				return err
+			}
+			logger.Info("done")
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
+
+// parseCopyArgs 解析 cp 的参数，返回远端、源路径、目标路径和是否是上传
+func parseCopyArgs(args []string) (remote string, sources []string, target string, upload bool, err error) {
+	srcArgs, targetArg := args[:len(args)-1], args[len(args)-1]
+
+	targetRemote, targetPath, targetIsRemote := parseRemotePath(targetArg)
+	if targetIsRemote {
+		for _, arg := range srcArgs {
+			if _, _, ok := parseRemotePath(arg); ok {
+				return "", nil, "", false, fmt.Errorf("copying between remotes is not supported")
+			}
+		}
+		return targetRemote, srcArgs, targetPath, true, nil
+	}
+
+	for _, arg := range srcArgs {
+		r, p, ok := parseRemotePath(arg)
+		if !ok {
+			return "", nil, "", false, fmt.Errorf("either all sources or the target must be remote (REMOTE:PATH)")
+		}
+		if remote != "" && r != remote {
+			return "", nil, "", false, fmt.Errorf("all remote sources must be on the same remote")
+		}
+		remote = r
+		sources = append(sources, p)
+	}
+	return remote, sources, targetArg, false, nil
+}
+
+// parseRemotePath 解析 REMOTE:PATH 形式的远端路径
+//
+// 与 scp 相同，冒号之前的部分为空或包含 / 时为本地路径， PATH 为空时为 /
+func parseRemotePath(arg string) (remote, p string, ok bool) {
+	remote, p, ok = strings.Cut(arg, ":")
+	if !ok || remote == "" || strings.Contains(remote, "/") {
+		return "", "", false
+	}
+	if p == "" {
+		p = "/"
+	}
+	return remote, p, true
+}
+
+// connectRemoteFiles 连接到远端的文件服务
+//
+// remote 为 @AGENT 时创建流并派发文件服务请求给代理，否则 remote 为已有文件服务的流名。
+// 返回的 cleanup 用于断开连接并删除创建的流。
+func connectRemoteFiles(
+	ctx context.Context,
+	client clientscommon.Client,
+	remote string,
+) (*clientscp.RemoteFiles, func(), error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	agent, viaAgent := strings.CutPrefix(remote, "@")
+	if !viaAgent {
+		stream, err := client.GetStream(ctx, remote)
+		if err != nil {
+			return nil, nil, fmt.Errorf("get stream %q error: %w", remote, err)
+		}
+		files, err := clientscp.New(client).ConnectFiles(ctx, stream)
+		if err != nil {
+			return nil, nil, err
+		}
+		return files, func() {
+			_ = files.Close(ctx)
+		}, nil
+	}
+
+	// 创建流并派发给代理
+	stream, err := client.CreateStream(ctx, &streamv1.Stream{
+		Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnFirstConnectionLeft},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("create stream error: %w", err)
+	}
+	deleteStream := func() {
+		if err := client.DeleteStream(ctx, stream.Name); err != nil {
+			logger.Error(err, "delete stream error")
+		}
+	}
+	if err := dispatchToAgent(ctx, client, options.AgentFileOptions{
+		Agent:      agent,
+		RemotePath: "/",
+	}, agentv1.DispatchFileServer, stream); err != nil {
+		deleteStream()
+		return nil, nil, err
+	}
+	cpClient := clientscp.New(client)
+	if stream.Status.Token != "" {
+		cpClient = cpClient.WithClient(client.WithToken(stream.Status.Token))
+	}
+	files, err := cpClient.ConnectFiles(ctx, stream)
+	if err != nil {
+		deleteStream()
+		return nil, nil, err
+	}
+	return files, func() {
+		_ = files.Close(ctx)
+		deleteStream()
+	}, nil
+}
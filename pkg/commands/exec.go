@@ -18,10 +18,13 @@ func NewExecCommandWithOptions(opts *options.ExecOptions) *cobra.Command {
 		Use:   "exec [-- COMMAND [ARGS...]]",
 		Short: "Execute command and forward input and output through stream",
 		Example: `# Create a new stream
-scaf exec [-i] [-t] -s SERVER -- COMMAND [ARGS...]
+scaf exec [-i] [-t] [-e KEY=VALUE] [-w WORKDIR] [-u USER] -s SERVER -- COMMAND [ARGS...]
 
 # Join an existing stream
-scaf exec -s SERVER --stream STREAM --token TOKEN`,
+scaf exec -s SERVER --stream STREAM --token TOKEN
+
+# Join an existing stream, only executing commands allowed by a policy file
+scaf exec -s SERVER --stream STREAM --token TOKEN --policy POLICY_FILE`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			logger := logr.FromContextOrDiscard(ctx)
@@ -32,6 +35,14 @@ scaf exec -s SERVER --stream STREAM --token TOKEN`,
 				return fmt.Errorf("create client error: %w", err)
 			}
 			agent := clientsexec.NewAgent(client)
+			var policy *clientsexec.Policy
+			if opts.Policy != "" {
+				policy, err = clientsexec.LoadPolicy(opts.Policy)
+				if err != nil {
+					return err
+				}
+				agent = agent.WithPolicy(policy)
+			}
 
 			var stream *streamv1.Stream
 			if opts.Stream != "" {
@@ -40,27 +51,55 @@ scaf exec -s SERVER --stream STREAM --token TOKEN`,
 				if err != nil {
 					return fmt.Errorf("get stream %q error: %w", opts.Stream, err)
 				}
+				if clientsexec.IsSessionStream(stream) {
+					// 会话中的命令由终端在会话建立后请求，逐个检查执行策略
+					fmt.Println("Session: commands are requested by the terminal")
+					if !opts.Yes && !confirm() {
+						fmt.Println("abort")
+						return nil
+					}
+					return agent.Run(ctx, stream)
+				}
 				// 解析执行信息
-				command, input, tty, err := clientsexec.GetExecOptions(stream)
+				spec, err := clientsexec.GetExecSpec(stream)
 				if err != nil {
-					return fmt.Errorf("get exec options error: %w", err)
+					return fmt.Errorf("get exec spec error: %w", err)
 				}
-				fmt.Printf("Command: %q\n", command)
-				fmt.Printf("Input:   %t\n", input)
-				fmt.Printf("TTY:     %t\n", tty)
-				// 二次确认
-				if !opts.Yes {
-					fmt.Print("Continue? (Y/n): ")
-					confirm := ""
-					_, _ = fmt.Scanln(&confirm)
-					if confirm != "Y" {
-						fmt.Println("abort")
-						return nil
+				// 违反执行策略时不需要确认，由 agent 拒绝执行并通知终端
+				if policy != nil {
+					if err := policy.Apply(spec); err != nil {
+						fmt.Printf("Rejected: %s\n", err)
+						return agent.Run(ctx, stream)
 					}
 				}
+				fmt.Printf("Command: %q\n", spec.Command)
+				fmt.Printf("Input:   %t\n", spec.Input)
+				fmt.Printf("TTY:     %t\n", spec.TTY)
+				if spec.WorkingDir != "" {
+					fmt.Printf("Workdir: %s\n", spec.WorkingDir)
+				}
+				if spec.User != "" {
+					fmt.Printf("User:    %s\n", spec.User)
+				}
+				for i, kv := range spec.Env {
+					if i == 0 {
+						fmt.Printf("Env:     %q\n", kv)
+					} else {
+						fmt.Printf("         %q\n", kv)
+					}
+				}
+				// 二次确认
+				if !opts.Yes && !confirm() {
+					fmt.Println("abort")
+					return nil
+				}
 			} else {
 				// 创建流
-				stream = clientsexec.NewExecStream(args, opts.Input, opts.TTY)
+				spec, err := opts.ExecSpec(args)
+				if err != nil {
+					return err
+				}
+				stream = clientsexec.NewExecStream(*spec)
 				newStream, err := client.CreateStream(ctx, stream)
 				if err != nil {
 					return fmt.Errorf("create stream error: %w", err)
@@ -93,3 +132,11 @@ scaf exec -s SERVER --stream STREAM --token TOKEN`,
 
 	return cmd
 }
+
+// confirm 请求用户确认，输入 Y 时返回 true
+func confirm() bool {
+	fmt.Print("Continue? (Y/n): ")
+	input := ""
+	_, _ = fmt.Scanln(&input)
+	return input == "Y"
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
 	clientsexec "github.com/yhlooo/scaf/pkg/clients/exec"
 	"github.com/yhlooo/scaf/pkg/commands/options"
 )
@@ -17,7 +18,12 @@ func NewExecRemoteCommandWithOptions(opts *options.ExecRemoteOptions) *cobra.Com
 	cmd := &cobra.Command{
 		Use:   "exec-remote -- COMMAND [ARGS...]",
 		Short: "Create remote exec stream and attach to the stream",
-		Args:  cobra.MinimumNArgs(1),
+		Example: `# Create a stream and wait for someone to execute the command with scaf exec
+scaf exec-remote -s SERVER [-i] [-t] -- COMMAND [ARGS...]
+
+# Execute the command on a registered agent
+scaf exec-remote -s SERVER --agent AGENT [-i] [-t] -- COMMAND [ARGS...]`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			logger := logr.FromContextOrDiscard(ctx)
@@ -30,7 +36,11 @@ func NewExecRemoteCommandWithOptions(opts *options.ExecRemoteOptions) *cobra.Com
 			term := clientsexec.NewTerminal(client)
 
 			// 创建流
-			stream, err := client.CreateStream(ctx, clientsexec.NewExecStream(args, opts.Input, opts.TTY))
+			spec, err := opts.ExecSpec(args)
+			if err != nil {
+				return err
+			}
+			stream, err := client.CreateStream(ctx, clientsexec.NewExecStream(*spec))
 			if err != nil {
 				return fmt.Errorf("create stream error: %w", err)
 			}
@@ -40,6 +50,23 @@ func NewExecRemoteCommandWithOptions(opts *options.ExecRemoteOptions) *cobra.Com
 				}
 			}()
 			fmt.Printf("Stream: %s\n", stream.Name)
+			if opts.Agent != "" {
+				// 派发给代理执行
+				if _, err := client.CreateDispatch(ctx, &agentv1.Dispatch{
+					Spec: agentv1.DispatchSpec{
+						Agent:  opts.Agent,
+						Type:   agentv1.DispatchExec,
+						Stream: stream.Name,
+					},
+				}); err != nil {
+					return fmt.Errorf("dispatch to agent %q error: %w", opts.Agent, err)
+				}
+				fmt.Printf("Dispatched to agent: %s\n", opts.Agent)
+				if stream.Status.Token != "" {
+					term = term.WithClient(client.WithToken(stream.Status.Token))
+				}
+				return term.Run(ctx, stream, os.Stdin, os.Stdout, os.Stderr)
+			}
 			execCmd := []string{"scaf", "exec", "-s", opts.Server, "--stream", stream.Name}
 			if stream.Status.Token != "" {
 				fmt.Printf("Token: %s\n", stream.Status.Token)
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+)
+
+// NewFileServerCommandWithOptions 基于选项创建 file-server 子命令
+func NewFileServerCommandWithOptions(opts *options.FileServerOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "file-server [ROOT]",
+		Short: "Serve files in a directory to scaf cp and scaf sftp on the other side of a stream",
+		Long: `Serve files in a directory (default /) to scaf cp and scaf sftp on the other side of a stream.
+
+Paths requested by the other side are relative to ROOT, and files outside ROOT can not be accessed through symlinks.
+The file server exits after the other side disconnects.`,
+		Example: `# Create a stream and serve files in /var/log read-only
+scaf file-server -s SERVER --read-only /var/log
+
+# Serve files to an existing stream
+scaf file-server -s SERVER --stream STREAM --token TOKEN`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			logger := logr.FromContextOrDiscard(ctx)
+
+			root := "/"
+			if len(args) > 0 {
+				root = args[0]
+			}
+
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+			cpClient := clientscp.New(client)
+
+			var stream *streamv1.Stream
+			if opts.Stream != "" {
+				stream, err = client.GetStream(ctx, opts.Stream)
+				if err != nil {
+					return fmt.Errorf("get stream %q error: %w", opts.Stream, err)
+				}
+			} else {
+				// 创建流
+				stream, err = client.CreateStream(ctx, &streamv1.Stream{
+					Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnFirstConnectionLeft},
+				})
+				if err != nil {
+					return fmt.Errorf("create stream error: %w", err)
+				}
+				defer func() {
+					if err := client.DeleteStream(ctx, stream.Name); err != nil {
+						logger.Error(err, "delete stream error")
+					}
+				}()
+				fmt.Printf("Stream: %s\n", stream.Name)
+				connectArgs := []string{"-s", opts.Server}
+				if stream.Status.Token != "" {
+					fmt.Printf("Token: %s\n", stream.Status.Token)
+					connectArgs = append(connectArgs, "--token", stream.Status.Token)
+					cpClient = cpClient.WithClient(client.WithToken(stream.Status.Token))
+				}
+				fmt.Printf("Copy files command: scaf cp %s LOCAL_PATH %s:REMOTE_PATH\n",
+					strings.Join(connectArgs, " "), stream.Name)
+				fmt.Printf("Interactive shell command: scaf sftp %s %s\n", strings.Join(connectArgs, " "), stream.Name)
+			}
+
+			if err := cpClient.ServeFiles(ctx, stream, opts.FileServerOptions(root)); err != nil {
+				return err
+			}
+			logger.Info("done")
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
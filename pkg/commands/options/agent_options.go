@@ -0,0 +1,101 @@
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// NewDefaultAgentOptions 创建默认 AgentOptions
+func NewDefaultAgentOptions() AgentOptions {
+	return AgentOptions{
+		ClientOptions:     NewDefaultClientOptions(),
+		Name:              "",
+		Labels:            nil,
+		AllowedUsers:      nil,
+		Policy:            "",
+		AllowFileTransfer: false,
+		FileRoot:          "",
+		AllowFileWrite:    false,
+		List: AgentListOptions{
+			ClientOptions: NewDefaultClientOptions(),
+			Selector:      nil,
+		},
+	}
+}
+
+// AgentOptions agent 子命令选项
+type AgentOptions struct {
+	ClientOptions `yaml:",inline"`
+	// 代理名，为空时使用主机名
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// 代理标签
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// 除自身外允许向代理派发请求的用户
+	AllowedUsers []string `json:"allowedUsers,omitempty" yaml:"allowedUsers,omitempty"`
+	// 执行策略文件路径
+	Policy string `json:"policy,omitempty" yaml:"policy,omitempty"`
+	// 是否允许通过代理传输文件
+	AllowFileTransfer bool `json:"allowFileTransfer,omitempty" yaml:"allowFileTransfer,omitempty"`
+	// 传输文件的根目录
+	FileRoot string `json:"fileRoot,omitempty" yaml:"fileRoot,omitempty"`
+	// 是否允许通过代理写文件
+	AllowFileWrite bool `json:"allowFileWrite,omitempty" yaml:"allowFileWrite,omitempty"`
+
+	// agent list 子命令选项
+	List AgentListOptions `json:"list,omitempty" yaml:"list,omitempty"`
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *AgentOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ClientOptions.AddPFlags(fs)
+	fs.StringVar(&opts.Name, "name", opts.Name, "Agent name (default is the hostname)")
+	fs.StringToStringVarP(&opts.Labels, "label", "l", opts.Labels, "Agent labels (KEY=VALUE)")
+	fs.StringArrayVar(
+		&opts.AllowedUsers, "allow-user", opts.AllowedUsers,
+		"Username allowed to dispatch requests to the agent besides the agent owner",
+	)
+	fs.StringVar(
+		&opts.Policy, "policy", opts.Policy,
+		"Path to a YAML policy file restricting the commands that can be executed",
+	)
+	fs.BoolVar(
+		&opts.AllowFileTransfer, "allow-file-transfer", opts.AllowFileTransfer,
+		"Allow sending, receiving and serving files (scaf cp, scaf sftp and scaf sftp-bridge) through the agent",
+	)
+	fs.StringVar(
+		&opts.FileRoot, "file-root", opts.FileRoot,
+		"Directory file transfers are confined to, remote paths are relative to it (required with --allow-file-transfer)",
+	)
+	fs.BoolVar(
+		&opts.AllowFileWrite, "allow-file-write", opts.AllowFileWrite,
+		"Allow writing files in --file-root, otherwise files can only be read",
+	)
+}
+
+// Validate 校验选项
+func (opts *AgentOptions) Validate() error {
+	if opts.AllowFileTransfer && opts.FileRoot == "" {
+		return fmt.Errorf("--file-root is required with --allow-file-transfer")
+	}
+	if opts.AllowFileWrite && !opts.AllowFileTransfer {
+		return fmt.Errorf("--allow-file-write requires --allow-file-transfer")
+	}
+	return nil
+}
+
+// AgentListOptions agent list 子命令选项
+type AgentListOptions struct {
+	ClientOptions `yaml:",inline"`
+	// 标签选择器，只列出具有所有这些标签的代理
+	Selector map[string]string `json:"selector,omitempty" yaml:"selector,omitempty"`
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *AgentListOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ClientOptions.AddPFlags(fs)
+	fs.StringToStringVarP(
+		&opts.Selector, "selector", "l", opts.Selector,
+		"Only list agents with all these labels (KEY=VALUE)",
+	)
+}
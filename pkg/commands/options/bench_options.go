@@ -1,13 +1,154 @@
 package options
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	clientsbench "github.com/yhlooo/scaf/pkg/clients/bench"
+)
+
 // NewDefaultBenchOptions 创建默认 BenchOptions
 func NewDefaultBenchOptions() BenchOptions {
+	runOpts := clientsbench.NewDefaultRunOptions()
+	loadOpts := clientsbench.NewDefaultLoadOptions()
 	return BenchOptions{
-		ConnectOptions: NewDefaultConnectOptions(),
+		ConnectOptions:      NewDefaultConnectOptions(),
+		Duration:            runOpts.Duration,
+		PackageSize:         runOpts.PackageSize,
+		Parallel:            runOpts.Parallel,
+		PingCount:           runOpts.PingCount,
+		OutputFormat:        "table",
+		RegressionThreshold: clientsbench.DefaultRegressionThreshold,
+		LoadStreams:         loadOpts.Streams,
+		LoadConcurrency:     loadOpts.Concurrency,
 	}
 }
 
 // BenchOptions bench 子命令选项
 type BenchOptions struct {
 	ConnectOptions `yaml:",inline"`
+	// 每项读写测试的持续时间
+	Duration time.Duration `json:"duration,omitempty" yaml:"duration,omitempty"`
+	// 读写测试的数据包大小
+	PackageSize uint64 `json:"packageSize,omitempty" yaml:"packageSize,omitempty"`
+	// 并行测试的流数量
+	Parallel int `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	// 测试往返时延的 Ping 次数
+	PingCount int `json:"pingCount,omitempty" yaml:"pingCount,omitempty"`
+	// 输出格式
+	// table 、 yaml 或 json
+	OutputFormat string `json:"outputFormat,omitempty" yaml:"outputFormat,omitempty"`
+	// 用于比较的之前的测试报告文件
+	Compare string `json:"compare,omitempty" yaml:"compare,omitempty"`
+	// 判定为性能退化的相对变化阈值
+	RegressionThreshold float64 `json:"regressionThreshold,omitempty" yaml:"regressionThreshold,omitempty"`
+
+	// 在一个进程中同时运行测试服务端和测试客户端
+	Loopback bool `json:"loopback,omitempty" yaml:"loopback,omitempty"`
+	// loopback 模式下测试服务端使用的服务端地址，为空时与测试客户端相同
+	PeerServer string `json:"peerServer,omitempty" yaml:"peerServer,omitempty"`
+
+	// 运行负载测试
+	Load bool `json:"load,omitempty" yaml:"load,omitempty"`
+	// 负载测试创建的流数量
+	LoadStreams int `json:"loadStreams,omitempty" yaml:"loadStreams,omitempty"`
+	// 负载测试的并发数
+	LoadConcurrency int `json:"loadConcurrency,omitempty" yaml:"loadConcurrency,omitempty"`
+	// 负载测试时连接每个流的两端
+	LoadConnect bool `json:"loadConnect,omitempty" yaml:"loadConnect,omitempty"`
+	// 服务端 pprof 服务地址，用于负载测试时统计服务端内存占用
+	ServerPprofURL string `json:"serverPprofURL,omitempty" yaml:"serverPprofURL,omitempty"`
+}
+
+// Validate 校验选项
+func (opts *BenchOptions) Validate() error {
+	switch opts.OutputFormat {
+	case "table", "yaml", "json":
+	default:
+		return fmt.Errorf(
+			"invalid output format: %s (must be one of 'table', 'yaml' or 'json')",
+			opts.OutputFormat,
+		)
+	}
+	if opts.RegressionThreshold < 0 {
+		return fmt.Errorf("regression threshold must not be negative")
+	}
+	if opts.Loopback && opts.Load {
+		return fmt.Errorf("--loopback and --load can not be used together")
+	}
+	if (opts.Loopback || opts.Load) && opts.Stream != "" {
+		return fmt.Errorf("--stream can not be used with --loopback or --load")
+	}
+	if opts.Load {
+		if opts.Compare != "" {
+			return fmt.Errorf("--compare can not be used with --load")
+		}
+		return opts.LoadOptions().Validate()
+	}
+	return opts.RunOptions().Validate()
+}
+
+// RunOptions 返回运行基准测试的选项
+func (opts *BenchOptions) RunOptions() clientsbench.RunOptions {
+	return clientsbench.RunOptions{
+		Duration:    opts.Duration,
+		PackageSize: opts.PackageSize,
+		Parallel:    opts.Parallel,
+		PingCount:   opts.PingCount,
+	}
+}
+
+// LoadOptions 返回负载测试选项
+func (opts *BenchOptions) LoadOptions() clientsbench.LoadOptions {
+	return clientsbench.LoadOptions{
+		Streams:        opts.LoadStreams,
+		Concurrency:    opts.LoadConcurrency,
+		Connect:        opts.LoadConnect,
+		ServerPprofURL: opts.ServerPprofURL,
+	}
+}
+
+// AddPFlags 将选项绑定到命令行
+func (opts *BenchOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ConnectOptions.AddPFlags(fs)
+	fs.DurationVar(&opts.Duration, "duration", opts.Duration, "Duration of each read/write test")
+	fs.Uint64Var(&opts.PackageSize, "package-size", opts.PackageSize, "Size in bytes of packages in read/write tests")
+	fs.IntVarP(&opts.Parallel, "parallel", "P", opts.Parallel, "Number of streams to test in parallel")
+	fs.IntVar(&opts.PingCount, "ping-count", opts.PingCount, "Number of pings to measure the round-trip time")
+	fs.StringVarP(
+		&opts.OutputFormat, "output", "o", opts.OutputFormat,
+		"Output format. One of 'table', 'yaml' or 'json'.",
+	)
+	fs.StringVar(
+		&opts.Compare, "compare", opts.Compare,
+		"Compare with a previous report file (json or yaml) and fail if any metric regressed",
+	)
+	fs.Float64Var(
+		&opts.RegressionThreshold, "regression-threshold", opts.RegressionThreshold,
+		"Relative change of a metric to be considered as a regression when comparing reports",
+	)
+	fs.BoolVar(
+		&opts.Loopback, "loopback", opts.Loopback,
+		"Run both the benchmark server side and client side in this process",
+	)
+	fs.StringVar(
+		&opts.PeerServer, "peer-server", opts.PeerServer,
+		"Server address used by the benchmark server side in loopback mode (default is the same as --server)",
+	)
+	fs.BoolVar(&opts.Load, "load", opts.Load, "Run load test that creates many streams concurrently")
+	fs.IntVar(&opts.LoadStreams, "load-streams", opts.LoadStreams, "Number of streams to create in load test")
+	fs.IntVar(
+		&opts.LoadConcurrency, "load-concurrency", opts.LoadConcurrency,
+		"Number of streams created concurrently in load test",
+	)
+	fs.BoolVar(
+		&opts.LoadConnect, "load-connect", opts.LoadConnect,
+		"Connect both ends of each stream and send a message through it in load test",
+	)
+	fs.StringVar(
+		&opts.ServerPprofURL, "server-pprof-url", opts.ServerPprofURL,
+		"URL of the server pprof endpoint (started with --pprof-addr) to measure server memory in load test",
+	)
 }
@@ -3,10 +3,17 @@ package options
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
 
 	clientscommon "github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/utils/progress"
 )
 
 // NewDefaultClientOptions 创建默认 ClientOptions
@@ -16,6 +23,10 @@ func NewDefaultClientOptions() ClientOptions {
 		Token:     "",
 		NoLogin:   false,
 		RenewUser: false,
+		Insecure:  false,
+
+		KeepaliveInterval: streams.DefaultKeepaliveInterval,
+		KeepaliveTimeout:  streams.DefaultKeepaliveTimeout,
 	}
 }
 
@@ -31,6 +42,14 @@ type ClientOptions struct {
 	RenewUser bool `json:"renewUser,omitempty" yaml:"renewUser,omitempty"`
 	// 是否对传输数据进行压缩
 	Compress bool `json:"compress,omitempty" yaml:"compress,omitempty"`
+	// 用于校验服务端证书的 CA 证书文件
+	CAFile string `json:"caFile,omitempty" yaml:"caFile,omitempty"`
+	// 不校验服务端证书
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+	// 流连接发送心跳的间隔，为 0 时不发送心跳
+	KeepaliveInterval time.Duration `json:"keepaliveInterval,omitempty" yaml:"keepaliveInterval,omitempty"`
+	// 等待心跳响应的超时时间
+	KeepaliveTimeout time.Duration `json:"keepaliveTimeout,omitempty" yaml:"keepaliveTimeout,omitempty"`
 }
 
 // AddPFlags 绑定选项到命令行
@@ -40,14 +59,42 @@ func (opts *ClientOptions) AddPFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&opts.NoLogin, "no-login", opts.NoLogin, "Do not login and access anonymously")
 	fs.BoolVar(&opts.RenewUser, "renew-user", opts.RenewUser, "Renew user")
 	fs.BoolVar(&opts.Compress, "compress", opts.Compress, "Compress the transport stream")
+	fs.StringVar(
+		&opts.CAFile, "ca-file", opts.CAFile,
+		"CA certificate file used to verify the server certificate (quic only, system CA certificates are used if empty)",
+	)
+	fs.BoolVar(
+		&opts.Insecure, "insecure", opts.Insecure,
+		"Skip verifying the server certificate (quic only), the connection is vulnerable to man-in-the-middle attacks",
+	)
+	fs.DurationVar(
+		&opts.KeepaliveInterval, "keepalive-interval", opts.KeepaliveInterval,
+		"Interval of heartbeats sent to the server on stream connections (0 to disable, at least 10s for grpc)",
+	)
+	fs.DurationVar(
+		&opts.KeepaliveTimeout, "keepalive-timeout", opts.KeepaliveTimeout,
+		"Close the connection if no data is received within interval + timeout",
+	)
 }
 
 // NewClient 基于选项创建客户端
 func (opts *ClientOptions) NewClient(ctx context.Context) (clientscommon.Client, error) {
+	if opts.Insecure {
+		logr.FromContextOrDiscard(ctx).Info(
+			"WARN server certificate verification is disabled by --insecure, " +
+				"the connection is vulnerable to man-in-the-middle attacks",
+		)
+	}
 	client, err := clientscommon.NewClient(clientscommon.ClientOptions{
 		Server:   opts.Server,
 		Token:    opts.Token,
 		Compress: opts.Compress,
+		CAFile:   opts.CAFile,
+		Insecure: opts.Insecure,
+		Keepalive: &streams.KeepaliveOptions{
+			Interval: opts.KeepaliveInterval,
+			Timeout:  opts.KeepaliveTimeout,
+		},
 	})
 	if err != nil {
 		return nil, err
@@ -81,3 +128,58 @@ func (opts *ConnectOptions) AddPFlags(fs *pflag.FlagSet) {
 	opts.ClientOptions.AddPFlags(fs)
 	fs.StringVar(&opts.Stream, "stream", opts.Stream, "Stream name connect to")
 }
+
+// NewDefaultProgressOptions 创建默认 ProgressOptions
+func NewDefaultProgressOptions() ProgressOptions {
+	return ProgressOptions{
+		Progress: "auto",
+	}
+}
+
+// ProgressOptions 传输进度选项
+type ProgressOptions struct {
+	// 进度输出格式
+	// auto （标准错误是终端时输出进度条）、 bar 、 json 或 none
+	Progress string `json:"progress,omitempty" yaml:"progress,omitempty"`
+}
+
+// Validate 校验选项
+func (opts *ProgressOptions) Validate() error {
+	switch opts.Progress {
+	case "auto", "bar", "json", "none":
+		return nil
+	default:
+		return fmt.Errorf(
+			"invalid progress format: %s (must be one of 'auto', 'bar', 'json' or 'none')",
+			opts.Progress,
+		)
+	}
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *ProgressOptions) AddPFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
+		&opts.Progress, "progress", opts.Progress,
+		"Transfer progress format. One of 'auto' (bar if stderr is a terminal), "+
+			"'bar' (to stderr), 'json' (periodic events, one per line) or 'none'.",
+	)
+}
+
+// ProgressHandler 返回处理传输进度的 progress.Handler ，不输出进度时返回 nil
+//
+// 进度条输出到标准错误， JSON 格式的事件输出到 jsonOut
+func (opts *ProgressOptions) ProgressHandler(jsonOut io.Writer) progress.Handler {
+	switch opts.Progress {
+	case "auto":
+		if !term.IsTerminal(int(os.Stderr.Fd())) {
+			return nil
+		}
+		return progress.NewBarHandler(os.Stderr)
+	case "bar":
+		return progress.NewBarHandler(os.Stderr)
+	case "json":
+		return progress.NewJSONHandler(jsonOut)
+	default:
+		return nil
+	}
+}
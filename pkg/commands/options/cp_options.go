@@ -0,0 +1,102 @@
+package options
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+)
+
+// NewDefaultCopyOptions 创建默认 CopyOptions
+func NewDefaultCopyOptions() CopyOptions {
+	return CopyOptions{
+		ClientOptions:   NewDefaultClientOptions(),
+		ProgressOptions: NewDefaultProgressOptions(),
+		Recursive:       false,
+	}
+}
+
+// CopyOptions cp 子命令选项
+type CopyOptions struct {
+	ClientOptions   `yaml:",inline"`
+	ProgressOptions `yaml:",inline"`
+
+	// 递归复制目录
+	Recursive bool `json:"recursive,omitempty" yaml:"recursive,omitempty"`
+}
+
+// Validate 校验选项
+func (opts *CopyOptions) Validate() error {
+	return opts.ProgressOptions.Validate()
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *CopyOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ClientOptions.AddPFlags(fs)
+	opts.ProgressOptions.AddPFlags(fs)
+	fs.BoolVarP(&opts.Recursive, "recursive", "r", opts.Recursive, "Copy directories recursively")
+}
+
+// CopyOptions 返回复制选项
+func (opts *CopyOptions) CopyOptions() clientscp.CopyOptions {
+	return clientscp.CopyOptions{
+		Recursive: opts.Recursive,
+		Progress:  opts.ProgressHandler(os.Stdout),
+	}
+}
+
+// NewDefaultSftpOptions 创建默认 SftpOptions
+func NewDefaultSftpOptions() SftpOptions {
+	return SftpOptions{
+		ClientOptions:   NewDefaultClientOptions(),
+		ProgressOptions: NewDefaultProgressOptions(),
+	}
+}
+
+// SftpOptions sftp 子命令选项
+type SftpOptions struct {
+	ClientOptions   `yaml:",inline"`
+	ProgressOptions `yaml:",inline"`
+}
+
+// Validate 校验选项
+func (opts *SftpOptions) Validate() error {
+	return opts.ProgressOptions.Validate()
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *SftpOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ClientOptions.AddPFlags(fs)
+	opts.ProgressOptions.AddPFlags(fs)
+}
+
+// NewDefaultFileServerOptions 创建默认 FileServerOptions
+func NewDefaultFileServerOptions() FileServerOptions {
+	return FileServerOptions{
+		ConnectOptions: NewDefaultConnectOptions(),
+		ReadOnly:       false,
+	}
+}
+
+// FileServerOptions file-server 子命令选项
+type FileServerOptions struct {
+	ConnectOptions `yaml:",inline"`
+
+	// 只读，拒绝写文件和创建目录
+	ReadOnly bool `json:"readOnly,omitempty" yaml:"readOnly,omitempty"`
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *FileServerOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ConnectOptions.AddPFlags(fs)
+	fs.BoolVar(&opts.ReadOnly, "read-only", opts.ReadOnly, "Reject writing files and creating directories")
+}
+
+// FileServerOptions 返回文件服务选项
+func (opts *FileServerOptions) FileServerOptions(root string) clientscp.FileServerOptions {
+	return clientscp.FileServerOptions{
+		Root:     root,
+		ReadOnly: opts.ReadOnly,
+	}
+}
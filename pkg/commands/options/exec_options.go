@@ -1,24 +1,27 @@
 package options
 
-import "github.com/spf13/pflag"
+import (
+	"github.com/spf13/pflag"
+
+	clientsexec "github.com/yhlooo/scaf/pkg/clients/exec"
+)
 
 // NewDefaultExecOptions 创建默认 ExecOptions
 func NewDefaultExecOptions() ExecOptions {
 	return ExecOptions{
-		ConnectOptions: NewDefaultConnectOptions(),
-		Input:          false,
-		TTY:            false,
-		Yes:            false,
+		ConnectOptions:  NewDefaultConnectOptions(),
+		ExecSpecOptions: NewDefaultExecSpecOptions(),
+		Policy:          "",
+		Yes:             false,
 	}
 }
 
 // ExecOptions exec 子命令选项
 type ExecOptions struct {
-	ConnectOptions `yaml:",inline"`
-	// 是否需要开启标准输入流
-	Input bool `json:"input,omitempty" yaml:"input,omitempty"`
-	// 标准输入是 TTY
-	TTY bool `json:"tty,omitempty" yaml:"tty,omitempty"`
+	ConnectOptions  `yaml:",inline"`
+	ExecSpecOptions `yaml:",inline"`
+	// 执行策略文件路径
+	Policy string `json:"policy,omitempty" yaml:"policy,omitempty"`
 	// 是否同意所有二次确认
 	Yes bool `json:"yes,omitempty" yaml:"yes,omitempty"`
 }
@@ -26,7 +29,81 @@ type ExecOptions struct {
 // AddPFlags 绑定选项到命令行
 func (opts *ExecOptions) AddPFlags(fs *pflag.FlagSet) {
 	opts.ConnectOptions.AddPFlags(fs)
+	opts.ExecSpecOptions.AddPFlags(fs)
+	fs.StringVar(
+		&opts.Policy, "policy", opts.Policy,
+		"Path to a YAML policy file restricting the commands that can be executed",
+	)
+	fs.BoolVarP(&opts.Yes, "yes", "y", opts.Yes, "Skip confirmations and always yes")
+}
+
+// NewDefaultExecSpecOptions 创建默认 ExecSpecOptions
+func NewDefaultExecSpecOptions() ExecSpecOptions {
+	return ExecSpecOptions{
+		Input:      false,
+		TTY:        false,
+		Env:        nil,
+		EnvFiles:   nil,
+		WorkingDir: "",
+		User:       "",
+	}
+}
+
+// ExecSpecOptions 执行命令参数选项
+type ExecSpecOptions struct {
+	// 是否需要开启标准输入流
+	Input bool `json:"input,omitempty" yaml:"input,omitempty"`
+	// 标准输入是 TTY
+	TTY bool `json:"tty,omitempty" yaml:"tty,omitempty"`
+	// 额外的环境变量，格式为 KEY=VALUE
+	Env []string `json:"env,omitempty" yaml:"env,omitempty"`
+	// 从文件加载的环境变量
+	EnvFiles []string `json:"envFiles,omitempty" yaml:"envFiles,omitempty"`
+	// 工作目录
+	WorkingDir string `json:"workingDir,omitempty" yaml:"workingDir,omitempty"`
+	// 运行命令的用户
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *ExecSpecOptions) AddPFlags(fs *pflag.FlagSet) {
 	fs.BoolVarP(&opts.Input, "input", "i", opts.Input, "Enable stdin")
 	fs.BoolVarP(&opts.TTY, "tty", "t", opts.TTY, "Stdin is a TTY")
-	fs.BoolVarP(&opts.Yes, "yes", "y", opts.Yes, "Skip confirmations and always yes")
+	fs.StringArrayVarP(&opts.Env, "env", "e", opts.Env, "Set environment variables for the command (KEY=VALUE)")
+	fs.StringArrayVar(
+		&opts.EnvFiles, "env-file", opts.EnvFiles,
+		"Read environment variables for the command from a file (one KEY=VALUE per line)",
+	)
+	fs.StringVarP(&opts.WorkingDir, "workdir", "w", opts.WorkingDir, "Working directory of the command")
+	fs.StringVarP(
+		&opts.User, "user", "u", opts.User,
+		"Username or UID to run the command as (the executor must run as root to switch user)",
+	)
+}
+
+// ExecSpec 基于选项创建执行参数
+// 环境变量文件中的变量在前， --env 指定的变量在后，同名时后者生效
+func (opts *ExecSpecOptions) ExecSpec(command []string) (*clientsexec.ExecSpec, error) {
+	var env []string
+	for _, path := range opts.EnvFiles {
+		fileEnv, err := clientsexec.LoadEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, fileEnv...)
+	}
+	env = append(env, opts.Env...)
+
+	spec := &clientsexec.ExecSpec{
+		Command:    command,
+		Input:      opts.Input,
+		TTY:        opts.TTY,
+		Env:        env,
+		WorkingDir: opts.WorkingDir,
+		User:       opts.User,
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
 }
@@ -0,0 +1,90 @@
+package options
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	clientspipe "github.com/yhlooo/scaf/pkg/clients/pipe"
+)
+
+// NewDefaultPipeOptions 创建默认 PipeOptions
+func NewDefaultPipeOptions() PipeOptions {
+	return PipeOptions{
+		Send: PipeSendOptions{
+			ConnectOptions:  NewDefaultConnectOptions(),
+			ProgressOptions: NewDefaultProgressOptions(),
+		},
+		Receive: PipeReceiveOptions{
+			ConnectOptions:  NewDefaultConnectOptions(),
+			ProgressOptions: NewDefaultProgressOptions(),
+		},
+	}
+}
+
+// PipeOptions pipe 子命令选项
+type PipeOptions struct {
+	// pipe send 子命令选项
+	Send PipeSendOptions `json:"send,omitempty" yaml:"send,omitempty"`
+	// pipe recv 子命令选项
+	Receive PipeReceiveOptions `json:"receive,omitempty" yaml:"receive,omitempty"`
+}
+
+// PipeSendOptions pipe send 子命令选项
+type PipeSendOptions struct {
+	ConnectOptions  `yaml:",inline"`
+	ProgressOptions `yaml:",inline"`
+}
+
+// Validate 校验选项
+func (opts *PipeSendOptions) Validate() error {
+	return opts.ProgressOptions.Validate()
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *PipeSendOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ConnectOptions.AddPFlags(fs)
+	opts.ProgressOptions.AddPFlags(fs)
+}
+
+// SendOptions 返回发送选项， size 为输入的总字节数，未知时为 0
+//
+// 指定 --compress 时压缩管道中的数据，而不是压缩传输流，与 pipe recv 一致， JSON 格式的进度事件输出到标准错误
+func (opts *PipeSendOptions) SendOptions(size int64) clientspipe.SendOptions {
+	ret := clientspipe.SendOptions{
+		Compression: clientscp.CompressionNone,
+		Size:        size,
+		Progress:    opts.ProgressHandler(os.Stderr),
+	}
+	if opts.Compress {
+		ret.Compression = clientscp.CompressionZstd
+	}
+	return ret
+}
+
+// PipeReceiveOptions pipe recv 子命令选项
+type PipeReceiveOptions struct {
+	ConnectOptions  `yaml:",inline"`
+	ProgressOptions `yaml:",inline"`
+}
+
+// Validate 校验选项
+func (opts *PipeReceiveOptions) Validate() error {
+	return opts.ProgressOptions.Validate()
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *PipeReceiveOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ConnectOptions.AddPFlags(fs)
+	opts.ProgressOptions.AddPFlags(fs)
+}
+
+// ReceiveOptions 返回接收选项
+//
+// 标准输出用于输出数据，因此 JSON 格式的进度事件输出到标准错误
+func (opts *PipeReceiveOptions) ReceiveOptions() clientspipe.ReceiveOptions {
+	return clientspipe.ReceiveOptions{
+		Progress: opts.ProgressHandler(os.Stderr),
+	}
+}
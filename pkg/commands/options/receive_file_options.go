@@ -1,20 +1,78 @@
 package options
 
-import "github.com/spf13/pflag"
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+)
 
 // NewDefaultReceiveFileOptions 创建默认 ReceiveFileOptions
 func NewDefaultReceiveFileOptions() ReceiveFileOptions {
 	return ReceiveFileOptions{
 		ConnectOptions: NewDefaultConnectOptions(),
+		AgentFileOptions: AgentFileOptions{
+			Agent:      "",
+			RemotePath: "",
+		},
+		ProgressOptions: NewDefaultProgressOptions(),
+		Overwrite:       string(clientscp.OverwriteAlways),
 	}
 }
 
 // ReceiveFileOptions receive-file 子命令选项
 type ReceiveFileOptions struct {
-	ConnectOptions `yaml:",inline"`
+	ConnectOptions   `yaml:",inline"`
+	AgentFileOptions `yaml:",inline"`
+	ProgressOptions  `yaml:",inline"`
+
+	// 已有文件的覆盖策略
+	// never 、 newer 或 always
+	Overwrite string `json:"overwrite,omitempty" yaml:"overwrite,omitempty"`
+	// 允许符号链接指向接收的目录之外
+	AllowExternalSymlinks bool `json:"allowExternalSymlinks,omitempty" yaml:"allowExternalSymlinks,omitempty"`
+	// 保留文件所有者
+	PreserveOwner bool `json:"preserveOwner,omitempty" yaml:"preserveOwner,omitempty"`
+	// 保留文件扩展属性
+	PreserveXattrs bool `json:"preserveXattrs,omitempty" yaml:"preserveXattrs,omitempty"`
+}
+
+// Validate 校验选项
+func (opts *ReceiveFileOptions) Validate() error {
+	if err := opts.ProgressOptions.Validate(); err != nil {
+		return err
+	}
+	return clientscp.OverwritePolicy(opts.Overwrite).Validate()
 }
 
 // AddPFlags 绑定选项到参数
 func (opts *ReceiveFileOptions) AddPFlags(fs *pflag.FlagSet) {
 	opts.ConnectOptions.AddPFlags(fs)
+	opts.AgentFileOptions.AddPFlags(fs)
+	opts.ProgressOptions.AddPFlags(fs)
+	fs.StringVar(
+		&opts.Overwrite, "overwrite", opts.Overwrite,
+		"Policy for existing files, one of 'never', 'newer' (only if the received file is newer) or 'always'",
+	)
+	fs.BoolVar(
+		&opts.AllowExternalSymlinks, "allow-external-symlinks", opts.AllowExternalSymlinks,
+		"Allow received symlinks pointing outside of the received directory",
+	)
+	fs.BoolVar(
+		&opts.PreserveOwner, "preserve-owner", opts.PreserveOwner,
+		"Preserve owner (uid and gid) of received files, usually requires root privilege",
+	)
+	fs.BoolVar(&opts.PreserveXattrs, "preserve-xattrs", opts.PreserveXattrs, "Preserve extended attributes of received files")
+}
+
+// ReceiveOptions 返回接收选项
+func (opts *ReceiveFileOptions) ReceiveOptions() clientscp.ReceiveOptions {
+	return clientscp.ReceiveOptions{
+		Overwrite:             clientscp.OverwritePolicy(opts.Overwrite),
+		AllowExternalSymlinks: opts.AllowExternalSymlinks,
+		PreserveOwner:         opts.PreserveOwner,
+		PreserveXattrs:        opts.PreserveXattrs,
+		Progress:              opts.ProgressHandler(os.Stdout),
+	}
 }
@@ -11,12 +11,20 @@ func NewDefaultOptions() Options {
 		Exec:       NewDefaultExecOptions(),
 		ExecRemote: NewDefaultExecRemoteOptions(),
 
+		Agent: NewDefaultAgentOptions(),
+
 		SendFile:    NewDefaultSendFileOptions(),
 		ReceiveFile: NewDefaultReceiveFileOptions(),
+		Pipe:        NewDefaultPipeOptions(),
+		Copy:        NewDefaultCopyOptions(),
+		Sftp:        NewDefaultSftpOptions(),
+		FileServer:  NewDefaultFileServerOptions(),
+		SFTPBridge:  NewDefaultSFTPBridgeOptions(),
 
 		Bench: NewDefaultBenchOptions(),
 
 		Stream: NewDefaultStreamOptions(),
+		Token:  NewDefaultTokenOptions(),
 
 		Version: NewDefaultVersionOptions(),
 	}
@@ -37,16 +45,31 @@ type Options struct {
 	// exec-remote 子命令选项
 	ExecRemote ExecRemoteOptions `json:"execRemote,omitempty" yaml:"execRemote,omitempty"`
 
+	// agent 子命令选项
+	Agent AgentOptions `json:"agent,omitempty" yaml:"agent,omitempty"`
+
 	// send-file 子命令选项
 	SendFile SendFileOptions `json:"sendFile,omitempty" yaml:"sendFile,omitempty"`
 	// receive-file 子命令选项
 	ReceiveFile ReceiveFileOptions `json:"receiveFile,omitempty" yaml:"receiveFile,omitempty"`
+	// pipe 子命令选项
+	Pipe PipeOptions `json:"pipe,omitempty" yaml:"pipe,omitempty"`
+	// cp 子命令选项
+	Copy CopyOptions `json:"cp,omitempty" yaml:"cp,omitempty"`
+	// sftp 子命令选项
+	Sftp SftpOptions `json:"sftp,omitempty" yaml:"sftp,omitempty"`
+	// file-server 子命令选项
+	FileServer FileServerOptions `json:"fileServer,omitempty" yaml:"fileServer,omitempty"`
+	// sftp-bridge 子命令选项
+	SFTPBridge SFTPBridgeOptions `json:"sftpBridge,omitempty" yaml:"sftpBridge,omitempty"`
 
 	// bench 子命令选项
 	Bench BenchOptions `json:"bench,omitempty" yaml:"bench,omitempty"`
 
 	// stream 子命令选项
 	Stream StreamOptions `json:"stream,omitempty" yaml:"stream,omitempty"`
+	// token 子命令选项
+	Token TokenOptions `json:"token,omitempty" yaml:"token,omitempty"`
 
 	// version 子命令选项
 	Version VersionOptions `json:"version,omitempty" yaml:"version,omitempty"`
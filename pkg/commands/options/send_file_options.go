@@ -1,22 +1,138 @@
 package options
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/spf13/pflag"
+
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
 )
 
 // NewDefaultSendFileOptions 创建默认 SendFileOptions
 func NewDefaultSendFileOptions() SendFileOptions {
 	return SendFileOptions{
 		ClientOptions: NewDefaultClientOptions(),
+		AgentFileOptions: AgentFileOptions{
+			Agent:      "",
+			RemotePath: ".",
+		},
+		ProgressOptions: NewDefaultProgressOptions(),
+		Compression:     string(clientscp.CompressionNone),
 	}
 }
 
 // SendFileOptions send-file 子命令选项
 type SendFileOptions struct {
-	ClientOptions `yaml:",inline"`
+	ClientOptions    `yaml:",inline"`
+	AgentFileOptions `yaml:",inline"`
+	ProgressOptions  `yaml:",inline"`
+
+	// 压缩算法
+	// none 、 gzip 、 zstd 或 auto
+	Compression string `json:"compression,omitempty" yaml:"compression,omitempty"`
+	// 排除匹配这些模式的文件
+	Excludes []string `json:"excludes,omitempty" yaml:"excludes,omitempty"`
+	// 只发送匹配这些模式的文件
+	Includes []string `json:"includes,omitempty" yaml:"includes,omitempty"`
+	// 排除 .gitignore 中的文件
+	GitIgnore bool `json:"gitignore,omitempty" yaml:"gitignore,omitempty"`
+	// 只列出要发送的文件，不发送
+	DryRun bool `json:"dryRun,omitempty" yaml:"dryRun,omitempty"`
+
+	// 同步模式，只发送接收端没有或有变化的文件
+	Sync bool `json:"sync,omitempty" yaml:"sync,omitempty"`
+	// 同步模式下删除接收端多余的文件
+	Delete bool `json:"delete,omitempty" yaml:"delete,omitempty"`
+	// 同步模式下通过文件内容的哈希判断文件是否有变化
+	Checksum bool `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	// 同步模式下对较大的文件只发送块差异
+	Delta bool `json:"delta,omitempty" yaml:"delta,omitempty"`
+}
+
+// Validate 校验选项
+func (opts *SendFileOptions) Validate() error {
+	if err := opts.ProgressOptions.Validate(); err != nil {
+		return err
+	}
+	if err := clientscp.Compression(opts.Compression).Validate(); err != nil {
+		return err
+	}
+	if !opts.Sync && (opts.Delete || opts.Checksum || opts.Delta) {
+		return fmt.Errorf("--delete, --checksum and --delta can only be used with --sync")
+	}
+	return nil
 }
 
 // AddPFlags 绑定选项到参数
 func (opts *SendFileOptions) AddPFlags(fs *pflag.FlagSet) {
 	opts.ClientOptions.AddPFlags(fs)
+	opts.AgentFileOptions.AddPFlags(fs)
+	opts.ProgressOptions.AddPFlags(fs)
+	fs.StringVar(
+		&opts.Compression, "compression", opts.Compression,
+		"Compression algorithm of transferred files, one of 'none', 'gzip', 'zstd' or 'auto' (the best one the receiver supports)",
+	)
+	fs.StringArrayVar(
+		&opts.Excludes, "exclude", opts.Excludes,
+		"Exclude files matching the pattern (.gitignore syntax), can be specified multiple times",
+	)
+	fs.StringArrayVar(
+		&opts.Includes, "include", opts.Includes,
+		"Only send files matching the pattern (.gitignore syntax), can be specified multiple times",
+	)
+	fs.BoolVar(&opts.GitIgnore, "gitignore", opts.GitIgnore, "Exclude files ignored by .gitignore files")
+	fs.BoolVar(&opts.DryRun, "dry-run", opts.DryRun, "Only list files that would be sent, without sending")
+	fs.BoolVar(
+		&opts.Sync, "sync", opts.Sync,
+		"Sync mode, only send files that are missing or changed (by size and modification time) on the receiver side",
+	)
+	fs.BoolVar(&opts.Delete, "delete", opts.Delete, "Delete extraneous files on the receiver side in sync mode")
+	fs.BoolVar(
+		&opts.Checksum, "checksum", opts.Checksum,
+		"Compare file contents by checksum instead of size and modification time in sync mode",
+	)
+	fs.BoolVar(
+		&opts.Delta, "delta", opts.Delta,
+		"Only send changed blocks of large files that exist on the receiver side in sync mode",
+	)
+}
+
+// SendOptions 返回发送选项
+func (opts *SendFileOptions) SendOptions() clientscp.SendOptions {
+	ignoreFiles := []string{clientscp.ScafIgnoreFile}
+	if opts.GitIgnore {
+		ignoreFiles = append([]string{".gitignore"}, ignoreFiles...)
+	}
+	ret := clientscp.SendOptions{
+		Compression: clientscp.Compression(opts.Compression),
+		Filter: clientscp.FilterOptions{
+			Excludes:    opts.Excludes,
+			Includes:    opts.Includes,
+			IgnoreFiles: ignoreFiles,
+		},
+		Progress: opts.ProgressHandler(os.Stdout),
+	}
+	if opts.Sync {
+		ret.Sync = &clientscp.SyncOptions{
+			Delete:   opts.Delete,
+			Checksum: opts.Checksum,
+			Delta:    opts.Delta,
+		}
+	}
+	return ret
+}
+
+// AgentFileOptions 通过代理传输文件选项
+type AgentFileOptions struct {
+	// 传输文件的代理，为空时等待手动在远端传输文件
+	Agent string `json:"agent,omitempty" yaml:"agent,omitempty"`
+	// 代理端的文件路径
+	RemotePath string `json:"remotePath,omitempty" yaml:"remotePath,omitempty"`
+}
+
+// AddPFlags 绑定选项到参数
+func (opts *AgentFileOptions) AddPFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&opts.Agent, "agent", opts.Agent, "Transfer the file with the agent with this name")
+	fs.StringVar(&opts.RemotePath, "remote-path", opts.RemotePath, "Path of the file on the agent side")
 }
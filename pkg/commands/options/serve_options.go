@@ -1,26 +1,157 @@
 package options
 
-import "github.com/spf13/pflag"
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/yhlooo/scaf/pkg/streams"
+)
 
 // NewDefaultServeOptions 创建默认 serve 子命令选项
 func NewDefaultServeOptions() ServeOptions {
 	return ServeOptions{
 		ListenAddr: ":9443",
+		UnixSocket: "",
 		JWTIssuer:  "scaf-server",
 		JWTKey:     nil,
+
+		QUICListenAddr: "",
+		TLSCertFile:    "",
+		TLSKeyFile:     "",
+
+		GRPCReflection: false,
+
+		ClusterStore:            "",
+		ClusterAdvertiseAddress: "",
+
+		FederationConfig: "",
+
+		KeepaliveInterval: streams.DefaultKeepaliveInterval,
+		KeepaliveTimeout:  streams.DefaultKeepaliveTimeout,
+
+		StreamWindow:     streams.DefaultStreamWindow,
+		MaxInFlightBytes: streams.DefaultMaxInFlightBytes,
 	}
 }
 
 // ServeOptions serve 子命令选项
 type ServeOptions struct {
 	ListenAddr string `json:"listenAddr,omitempty" yaml:"listenAddr,omitempty"`
+	// 额外监听的 Unix Socket 路径
+	UnixSocket string `json:"unixSocket,omitempty" yaml:"unixSocket,omitempty"`
 	JWTIssuer  string `json:"jwtIssuer,omitempty" yaml:"jwtIssuer,omitempty"`
 	JWTKey     []byte `json:"jwtKey,omitempty" yaml:"jwtKey,omitempty"`
+
+	// 额外监听的 QUIC （ UDP ）地址
+	QUICListenAddr string `json:"quicListenAddr,omitempty" yaml:"quicListenAddr,omitempty"`
+	// QUIC 使用的 TLS 证书文件
+	TLSCertFile string `json:"tlsCertFile,omitempty" yaml:"tlsCertFile,omitempty"`
+	// QUIC 使用的 TLS 私钥文件
+	TLSKeyFile string `json:"tlsKeyFile,omitempty" yaml:"tlsKeyFile,omitempty"`
+
+	// 是否启用 gRPC 服务反射
+	GRPCReflection bool `json:"grpcReflection,omitempty" yaml:"grpcReflection,omitempty"`
+
+	// 集群共享存储 URL ，为空时不启用集群模式
+	ClusterStore string `json:"clusterStore,omitempty" yaml:"clusterStore,omitempty"`
+	// 当前副本供其它副本访问的地址
+	ClusterAdvertiseAddress string `json:"clusterAdvertiseAddress,omitempty" yaml:"clusterAdvertiseAddress,omitempty"`
+
+	// 联邦配置文件路径
+	FederationConfig string `json:"federationConfig,omitempty" yaml:"federationConfig,omitempty"`
+
+	// 流连接发送心跳的间隔，为 0 时不发送心跳
+	KeepaliveInterval time.Duration `json:"keepaliveInterval,omitempty" yaml:"keepaliveInterval,omitempty"`
+	// 等待心跳响应的超时时间
+	KeepaliveTimeout time.Duration `json:"keepaliveTimeout,omitempty" yaml:"keepaliveTimeout,omitempty"`
+
+	// 每个流每个方向已接收但还未发出的最大字节数
+	StreamWindow int64 `json:"streamWindow,omitempty" yaml:"streamWindow,omitempty"`
+	// 所有流已接收但还未发出的最大总字节数，为 0 时不限制
+	MaxInFlightBytes int64 `json:"maxInFlightBytes,omitempty" yaml:"maxInFlightBytes,omitempty"`
+}
+
+// Keepalive 返回连接保活选项
+func (opts *ServeOptions) Keepalive() *streams.KeepaliveOptions {
+	return &streams.KeepaliveOptions{
+		Interval: opts.KeepaliveInterval,
+		Timeout:  opts.KeepaliveTimeout,
+	}
+}
+
+// FlowControl 返回流控选项
+func (opts *ServeOptions) FlowControl() *streams.FlowControlOptions {
+	return &streams.FlowControlOptions{
+		StreamWindow:     opts.StreamWindow,
+		MaxInFlightBytes: opts.MaxInFlightBytes,
+	}
+}
+
+// Validate 校验选项
+func (opts *ServeOptions) Validate() error {
+	if opts.ClusterStore != "" && len(opts.JWTKey) == 0 {
+		// 各副本需要使用相同的密钥签发和认证 Token
+		return fmt.Errorf("--jwt-key must be specified when --cluster-store is set")
+	}
+	if (opts.TLSCertFile == "") != (opts.TLSKeyFile == "") {
+		return fmt.Errorf("--tls-cert-file and --tls-key-file must be specified together")
+	}
+	if err := opts.Keepalive().Validate(); err != nil {
+		return err
+	}
+	if err := opts.FlowControl().Validate(); err != nil {
+		return err
+	}
+	return nil
 }
 
 // AddPFlags 绑定选项到参数
 func (opts *ServeOptions) AddPFlags(fs *pflag.FlagSet) {
 	fs.StringVarP(&opts.ListenAddr, "listen", "l", opts.ListenAddr, "Listen address")
+	fs.StringVar(
+		&opts.UnixSocket, "unix-socket", opts.UnixSocket,
+		"Also listen on the Unix domain socket at this path (e.g. /var/run/scaf.sock)",
+	)
+	fs.StringVar(
+		&opts.QUICListenAddr, "quic-listen", opts.QUICListenAddr,
+		"Also listen on this UDP address with QUIC (e.g. :9443)",
+	)
+	fs.StringVar(
+		&opts.TLSCertFile, "tls-cert-file", opts.TLSCertFile,
+		"TLS certificate file for QUIC (use a self-signed certificate if empty)",
+	)
+	fs.StringVar(&opts.TLSKeyFile, "tls-key-file", opts.TLSKeyFile, "TLS private key file for QUIC")
 	fs.StringVar(&opts.JWTIssuer, "jwt-issuer", opts.JWTIssuer, "JWT issuer name")
 	fs.BytesBase64Var(&opts.JWTKey, "jwt-key", opts.JWTKey, "JWT signing key")
+	fs.BoolVar(&opts.GRPCReflection, "grpc-reflection", opts.GRPCReflection, "Enable gRPC server reflection")
+	fs.StringVar(
+		&opts.ClusterStore, "cluster-store", opts.ClusterStore,
+		"Shared stream metadata store URL for running multiple replicas (e.g. file:///var/lib/scaf/streams)",
+	)
+	fs.StringVar(
+		&opts.ClusterAdvertiseAddress, "cluster-advertise-addr", opts.ClusterAdvertiseAddress,
+		"Address other replicas use to reach this replica (e.g. grpc://10.0.0.1:9443)",
+	)
+	fs.StringVar(
+		&opts.FederationConfig, "federation-config", opts.FederationConfig,
+		"Path to federation config file listing trusted peer servers",
+	)
+	fs.DurationVar(
+		&opts.KeepaliveInterval, "keepalive-interval", opts.KeepaliveInterval,
+		"Interval of heartbeats sent to clients on stream connections (0 to disable)",
+	)
+	fs.DurationVar(
+		&opts.KeepaliveTimeout, "keepalive-timeout", opts.KeepaliveTimeout,
+		"Close the connection if no data is received within interval + timeout",
+	)
+	fs.Int64Var(
+		&opts.StreamWindow, "stream-window", opts.StreamWindow,
+		"Max bytes buffered per stream direction before pausing reads from the sender",
+	)
+	fs.Int64Var(
+		&opts.MaxInFlightBytes, "max-inflight-bytes", opts.MaxInFlightBytes,
+		"Max bytes buffered across all streams (0 for unlimited)",
+	)
 }
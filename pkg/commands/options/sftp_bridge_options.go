@@ -0,0 +1,82 @@
+package options
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/ssh"
+)
+
+// NewDefaultSFTPBridgeOptions 创建默认 SFTPBridgeOptions
+func NewDefaultSFTPBridgeOptions() SFTPBridgeOptions {
+	return SFTPBridgeOptions{
+		ClientOptions: NewDefaultClientOptions(),
+		AgentFileOptions: AgentFileOptions{
+			Agent:      "",
+			RemotePath: ".",
+		},
+		Listen:      "127.0.0.1:2022",
+		Password:    "",
+		HostKeyFile: "",
+	}
+}
+
+// SFTPBridgeOptions sftp-bridge 子命令选项
+type SFTPBridgeOptions struct {
+	ClientOptions    `yaml:",inline"`
+	AgentFileOptions `yaml:",inline"`
+
+	// SSH 服务监听地址
+	Listen string `json:"listen,omitempty" yaml:"listen,omitempty"`
+	// SSH 客户端认证使用的密码，为空时随机生成
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// SSH 服务的主机密钥文件，为空时每次启动生成临时密钥
+	HostKeyFile string `json:"hostKeyFile,omitempty" yaml:"hostKeyFile,omitempty"`
+}
+
+// Validate 校验选项
+func (opts *SFTPBridgeOptions) Validate() error {
+	if opts.Agent == "" {
+		return fmt.Errorf("--agent is required")
+	}
+	if opts.Listen == "" {
+		return fmt.Errorf("--listen is required")
+	}
+	return nil
+}
+
+// AddPFlags 绑定选项到命令行
+func (opts *SFTPBridgeOptions) AddPFlags(fs *pflag.FlagSet) {
+	opts.ClientOptions.AddPFlags(fs)
+	fs.StringVar(&opts.Agent, "agent", opts.Agent, "Serve files on the agent with this name")
+	fs.StringVar(
+		&opts.RemotePath, "remote-path", opts.RemotePath,
+		"Root directory of the SFTP session on the agent side, files outside it can not be accessed",
+	)
+	fs.StringVar(&opts.Listen, "listen", opts.Listen, "Address the SSH server listens on")
+	fs.StringVar(
+		&opts.Password, "password", opts.Password,
+		"Password for SSH clients (any user name), a random one is generated and printed if not specified",
+	)
+	fs.StringVar(
+		&opts.HostKeyFile, "host-key", opts.HostKeyFile,
+		"Private key file used as the SSH host key, a temporary key is generated if not specified",
+	)
+}
+
+// HostKey 返回主机密钥，未指定主机密钥文件时返回 nil
+func (opts *SFTPBridgeOptions) HostKey() (ssh.Signer, error) {
+	if opts.HostKeyFile == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(opts.HostKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read host key file %q error: %w", opts.HostKeyFile, err)
+	}
+	key, err := ssh.ParsePrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse host key file %q error: %w", opts.HostKeyFile, err)
+	}
+	return key, nil
+}
@@ -12,6 +12,9 @@ func NewDefaultStreamOptions() StreamOptions {
 		Delete: StreamDeleteOptions{
 			ClientOptions: NewDefaultClientOptions(),
 		},
+		Signal: StreamSignalOptions{
+			ClientOptions: NewDefaultClientOptions(),
+		},
 	}
 }
 
@@ -23,6 +26,8 @@ type StreamOptions struct {
 	List StreamListOptions `json:"list,omitempty" yaml:"list,omitempty"`
 	// stream delete 子命令选项
 	Delete StreamDeleteOptions `json:"delete,omitempty" yaml:"delete,omitempty"`
+	// stream signal 子命令选项
+	Signal StreamSignalOptions `json:"signal,omitempty" yaml:"signal,omitempty"`
 }
 
 // StreamGetOptions stream get 子命令选项
@@ -39,3 +44,8 @@ type StreamListOptions struct {
 type StreamDeleteOptions struct {
 	ClientOptions `yaml:",inline"`
 }
+
+// StreamSignalOptions stream signal 子命令选项
+type StreamSignalOptions struct {
+	ClientOptions `yaml:",inline"`
+}
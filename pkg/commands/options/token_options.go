@@ -0,0 +1,21 @@
+package options
+
+// NewDefaultTokenOptions 创建默认 TokenOptions
+func NewDefaultTokenOptions() TokenOptions {
+	return TokenOptions{
+		CreateService: TokenCreateServiceOptions{
+			ClientOptions: NewDefaultClientOptions(),
+		},
+	}
+}
+
+// TokenOptions token 子命令选项
+type TokenOptions struct {
+	// token create-service 子命令选项
+	CreateService TokenCreateServiceOptions `json:"createService,omitempty" yaml:"createService,omitempty"`
+}
+
+// TokenCreateServiceOptions token create-service 子命令选项
+type TokenCreateServiceOptions struct {
+	ClientOptions `yaml:",inline"`
+}
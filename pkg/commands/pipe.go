@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	clientscommon "github.com/yhlooo/scaf/pkg/clients/common"
+	clientspipe "github.com/yhlooo/scaf/pkg/clients/pipe"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+)
+
+// NewPipeCommandWithOptions 基于选项创建 pipe 子命令
+func NewPipeCommandWithOptions(opts *options.PipeOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pipe",
+		Short: "Pipe stdin on one side to stdout on the other side through a stream",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		NewPipeSendCommandWithOptions(&opts.Send),
+		NewPipeReceiveCommandWithOptions(&opts.Receive),
+	)
+	return cmd
+}
+
+// NewPipeSendCommandWithOptions 基于选项创建 pipe send 子命令
+func NewPipeSendCommandWithOptions(opts *options.PipeSendOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "send",
+		Short: "Send stdin to the stream",
+		Example: `# Create a stream and send a tarball to whoever runs scaf pipe recv
+tar c . | scaf pipe send -s SERVER
+
+# Send a database dump to an existing stream created by scaf pipe recv, compressing the data
+pg_dump DB | scaf pipe send -s SERVER --stream STREAM --token TOKEN --compress`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			logger := logr.FromContextOrDiscard(ctx)
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			// 数据已经压缩，不需要再压缩传输流
+			clientOpts := opts.ClientOptions
+			clientOpts.Compress = false
+			client, err := clientOpts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+			client, stream, cleanup, err := preparePipeStream(ctx, client, opts.ConnectOptions, "recv")
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			// 标准输入是普通文件时可以确定总大小
+			var size int64
+			if info, err := os.Stdin.Stat(); err == nil && info.Mode().IsRegular() {
+				size = info.Size()
+			}
+
+			if err := clientspipe.New(client).Send(ctx, stream, os.Stdin, opts.SendOptions(size)); err != nil {
+				return err
+			}
+			logger.Info("done")
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
+
+// NewPipeReceiveCommandWithOptions 基于选项创建 pipe recv 子命令
+func NewPipeReceiveCommandWithOptions(opts *options.PipeReceiveOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "recv",
+		Aliases: []string{"receive"},
+		Short:   "Receive data from the stream to stdout",
+		Example: `# Receive a tarball from an existing stream created by scaf pipe send
+scaf pipe recv -s SERVER --stream STREAM --token TOKEN | tar x
+
+# Create a stream and wait for someone to send data with scaf pipe send
+scaf pipe recv -s SERVER > dump.sql`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			logger := logr.FromContextOrDiscard(ctx)
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			// 标准输出被关闭时返回错误并通知发送端，而不是直接退出
+			signal.Ignore(syscall.SIGPIPE)
+
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+			client, stream, cleanup, err := preparePipeStream(ctx, client, opts.ConnectOptions, "send")
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := clientspipe.New(client).Receive(ctx, stream, os.Stdout, opts.ReceiveOptions()); err != nil {
+				return err
+			}
+			logger.Info("done")
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
+
+// preparePipeStream 获取 --stream 指定的流，未指定时创建流，并输出另一端连接流的命令
+//
+// 标准输入输出用于传输数据，因此信息输出到标准错误，返回的 cleanup 用于删除创建的流
+func preparePipeStream(
+	ctx context.Context,
+	client clientscommon.Client,
+	opts options.ConnectOptions,
+	peerSubcommand string,
+) (clientscommon.Client, *streamv1.Stream, func(), error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if opts.Stream != "" {
+		stream, err := client.GetStream(ctx, opts.Stream)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("get stream %q error: %w", opts.Stream, err)
+		}
+		return client, stream, func() {}, nil
+	}
+
+	stream, err := client.CreateStream(ctx, &streamv1.Stream{
+		Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnFirstConnectionLeft},
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("create stream error: %w", err)
+	}
+	cleanup := func() {
+		if err := client.DeleteStream(ctx, stream.Name); err != nil {
+			logger.Error(err, "delete stream error")
+		}
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Stream: %s\n", stream.Name)
+	peerCmd := []string{"scaf", "pipe", peerSubcommand, "-s", opts.Server, "--stream", stream.Name}
+	if stream.Status.Token != "" {
+		_, _ = fmt.Fprintf(os.Stderr, "Token: %s\n", stream.Status.Token)
+		peerCmd = append(peerCmd, "--token", stream.Status.Token)
+		client = client.WithToken(stream.Status.Token)
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "Command on the other side: %s\n", strings.Join(peerCmd, " "))
+	return client, stream, cleanup, nil
+}
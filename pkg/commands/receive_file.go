@@ -6,6 +6,8 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
 	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
 	"github.com/yhlooo/scaf/pkg/commands/options"
 )
@@ -15,11 +17,23 @@ func NewReceiveFileCommandWithOptions(opts *options.ReceiveFileOptions) *cobra.C
 	cmd := &cobra.Command{
 		Use:   "receive-file [PATH]",
 		Short: "Receive file from stream",
-		Args:  cobra.MaximumNArgs(1),
+		Example: `# Receive the file from an existing stream
+scaf receive-file -s SERVER --stream STREAM --token TOKEN [PATH]
+
+# Receive the file from a registered agent
+scaf receive-file -s SERVER --agent AGENT --remote-path REMOTE_PATH [PATH]
+
+# Receive the file without overwriting existing files that are newer
+scaf receive-file -s SERVER --stream STREAM --token TOKEN --overwrite newer [PATH]`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			logger := logr.FromContextOrDiscard(ctx)
 
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
 			// 创建客户端
 			client, err := opts.NewClient(ctx)
 			if err != nil {
@@ -27,10 +41,36 @@ func NewReceiveFileCommandWithOptions(opts *options.ReceiveFileOptions) *cobra.C
 			}
 			cpClient := clientscp.New(client)
 
-			// 获取流
-			stream, err := client.GetStream(ctx, opts.Stream)
-			if err != nil {
-				return fmt.Errorf("get stream %q error: %w", opts.Stream, err)
+			var stream *streamv1.Stream
+			if opts.Agent != "" {
+				// 创建流并派发给代理发送
+				if opts.RemotePath == "" {
+					return fmt.Errorf("--remote-path is required when receiving file from agent")
+				}
+				stream, err = client.CreateStream(ctx, &streamv1.Stream{
+					Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnFirstConnectionLeft},
+				})
+				if err != nil {
+					return fmt.Errorf("create stream error: %w", err)
+				}
+				defer func() {
+					if err := client.DeleteStream(ctx, stream.Name); err != nil {
+						logger.Error(err, "delete stream error")
+					}
+				}()
+				fmt.Printf("Stream: %s\n", stream.Name)
+				if err := dispatchToAgent(ctx, client, opts.AgentFileOptions, agentv1.DispatchSendFile, stream); err != nil {
+					return err
+				}
+				if stream.Status.Token != "" {
+					cpClient = cpClient.WithClient(client.WithToken(stream.Status.Token))
+				}
+			} else {
+				// 获取流
+				stream, err = client.GetStream(ctx, opts.Stream)
+				if err != nil {
+					return fmt.Errorf("get stream %q error: %w", opts.Stream, err)
+				}
 			}
 
 			path := "."
@@ -38,7 +78,7 @@ func NewReceiveFileCommandWithOptions(opts *options.ReceiveFileOptions) *cobra.C
 				path = args[0]
 			}
 
-			target, err := cpClient.Receive(ctx, stream, path)
+			target, err := cpClient.Receive(ctx, stream, path, opts.ReceiveOptions())
 			if err != nil {
 				return err
 			}
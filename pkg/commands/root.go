@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"encoding/json"
+	"expvar"
 	"fmt"
 	"net"
 	"net/http"
@@ -82,13 +83,21 @@ func NewScafCommandWithOptions(opts *options.Options) *cobra.Command {
 		NewAttachCommandWithOptions(&opts.Attach),
 		NewExecCommandWithOptions(&opts.Exec),
 		NewExecRemoteCommandWithOptions(&opts.ExecRemote),
+		NewSandboxInitCommand(),
+		NewAgentCommandWithOptions(&opts.Agent),
 
 		NewSendFileCommandWithOptions(&opts.SendFile),
 		NewReceiveFileCommandWithOptions(&opts.ReceiveFile),
+		NewPipeCommandWithOptions(&opts.Pipe),
+		NewCopyCommandWithOptions(&opts.Copy),
+		NewSftpCommandWithOptions(&opts.Sftp),
+		NewFileServerCommandWithOptions(&opts.FileServer),
+		NewSFTPBridgeCommandWithOptions(&opts.SFTPBridge),
 
 		NewBenchCommandWithOptions(&opts.Bench),
 
 		NewStreamCommandWithOptions(&opts.Stream),
+		NewTokenCommandWithOptions(&opts.Token),
 
 		NewVersionCommandWithOptions(&opts.Version),
 	)
@@ -125,6 +134,8 @@ func startServePprof(ctx context.Context, addr string) error {
 	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
 	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
 	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	// 运行时内存统计等，用于 bench --load 统计服务端内存占用
+	mux.Handle("/debug/vars", expvar.Handler())
 
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -0,0 +1,21 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	clientsexec "github.com/yhlooo/scaf/pkg/clients/exec"
+)
+
+// NewSandboxInitCommand 创建 sandbox-init 子命令
+// 供 exec 在沙箱中启动命令时内部使用
+func NewSandboxInitCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:                clientsexec.SandboxInitCommand + " CONFIG -- COMMAND [ARGS...]",
+		Short:              "Initialize the sandbox and execute command (internal use only)",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return clientsexec.SandboxInit(args)
+		},
+	}
+}
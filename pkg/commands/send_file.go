@@ -1,15 +1,19 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	clientscommon "github.com/yhlooo/scaf/pkg/clients/common"
 	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
 	"github.com/yhlooo/scaf/pkg/commands/options"
+	"github.com/yhlooo/scaf/pkg/utils/units"
 )
 
 // NewSendFileCommandWithOptions 基于选项创建 send-file 子命令
@@ -17,11 +21,36 @@ func NewSendFileCommandWithOptions(opts *options.SendFileOptions) *cobra.Command
 	cmd := &cobra.Command{
 		Use:   "send-file PATH",
 		Short: "Send file to stream",
-		Args:  cobra.ExactArgs(1),
+		Example: `# Create a stream and wait for someone to receive the file with scaf receive-file
+scaf send-file -s SERVER PATH
+
+# Send the file to a registered agent
+scaf send-file -s SERVER --agent AGENT --remote-path REMOTE_PATH PATH
+
+# Send a directory compressed, without files ignored by .gitignore and *.log files
+scaf send-file -s SERVER --compression auto --gitignore --exclude '*.log' PATH
+
+# List files that would be sent
+scaf send-file --dry-run --gitignore PATH
+
+# Only send changed files and delete extraneous files on the receiver side
+scaf send-file -s SERVER --sync --delete --delta PATH
+
+# Output progress as JSON events (one per line) to stdout
+scaf send-file -s SERVER --progress json PATH`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx := cmd.Context()
 			logger := logr.FromContextOrDiscard(ctx)
 
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			sendOpts := opts.SendOptions()
+			if opts.DryRun {
+				return listFilesToSend(ctx, args[0], sendOpts.Filter)
+			}
+
 			// 创建客户端
 			client, err := opts.NewClient(ctx)
 			if err != nil {
@@ -42,6 +71,20 @@ func NewSendFileCommandWithOptions(opts *options.SendFileOptions) *cobra.Command
 				}
 			}()
 			fmt.Printf("Stream: %s\n", stream.Name)
+			if opts.Agent != "" {
+				// 派发给代理接收
+				if err := dispatchToAgent(ctx, client, opts.AgentFileOptions, agentv1.DispatchReceiveFile, stream); err != nil {
+					return err
+				}
+				if stream.Status.Token != "" {
+					cpClient = cpClient.WithClient(client.WithToken(stream.Status.Token))
+				}
+				if err := cpClient.Send(ctx, stream, args[0], sendOpts); err != nil {
+					return err
+				}
+				logger.Info("done")
+				return nil
+			}
 			recvCmd := []string{"scaf", "receive-file", "-s", opts.Server, "--stream", stream.Name}
 			if stream.Status.Token != "" {
 				fmt.Printf("Token: %s\n", stream.Status.Token)
@@ -51,7 +94,7 @@ func NewSendFileCommandWithOptions(opts *options.SendFileOptions) *cobra.Command
 			}
 			fmt.Printf("Receive file command: %s\n", strings.Join(recvCmd, " "))
 
-			if err := cpClient.Send(ctx, stream, args[0]); err != nil {
+			if err := cpClient.Send(ctx, stream, args[0], sendOpts); err != nil {
 				return err
 			}
 			logger.Info("done")
@@ -64,3 +107,54 @@ func NewSendFileCommandWithOptions(opts *options.SendFileOptions) *cobra.Command
 
 	return cmd
 }
+
+// dispatchToAgent 派发通过代理传输文件的请求
+func dispatchToAgent(
+	ctx context.Context,
+	client clientscommon.Client,
+	opts options.AgentFileOptions,
+	dispatchType agentv1.DispatchType,
+	stream *streamv1.Stream,
+) error {
+	if _, err := client.CreateDispatch(ctx, &agentv1.Dispatch{
+		Spec: agentv1.DispatchSpec{
+			Agent:  opts.Agent,
+			Type:   dispatchType,
+			Stream: stream.Name,
+			Path:   opts.RemotePath,
+		},
+	}); err != nil {
+		return fmt.Errorf("dispatch to agent %q error: %w", opts.Agent, err)
+	}
+	fmt.Printf("Dispatched to agent: %s\n", opts.Agent)
+	return nil
+}
+
+// listFilesToSend 列出要发送的文件及总大小
+func listFilesToSend(ctx context.Context, path string, filter clientscp.FilterOptions) error {
+	files, dirs, links := 0, 0, 0
+	var size int64
+	err := clientscp.WalkFiles(ctx, path, filter, func(entry clientscp.FileEntry) error {
+		switch entry.Type {
+		case clientscp.FileTypeDir:
+			dirs++
+			fmt.Printf("%-4s %s/\n", entry.Type, entry.Name)
+		case clientscp.FileTypeSymlink:
+			links++
+			fmt.Printf("%-4s %s -> %s\n", entry.Type, entry.Name, entry.Linkname)
+		case clientscp.FileTypeFile:
+			files++
+			size += entry.Size
+			fmt.Printf("%-4s %s (%sB)\n", entry.Type, entry.Name, units.NewIECValue(entry.Size).RoundString(2))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf(
+		"Total: %d files, %d directories, %d symlinks, %sB\n",
+		files, dirs, links, units.NewIECValue(size).RoundString(2),
+	)
+	return nil
+}
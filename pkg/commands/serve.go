@@ -1,14 +1,18 @@
 package commands
 
 import (
+	"crypto/tls"
 	"fmt"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 
 	"github.com/yhlooo/scaf/pkg/auth"
+	"github.com/yhlooo/scaf/pkg/cluster"
 	"github.com/yhlooo/scaf/pkg/commands/options"
+	"github.com/yhlooo/scaf/pkg/federation"
 	"github.com/yhlooo/scaf/pkg/server"
+	"github.com/yhlooo/scaf/pkg/server/generic"
 )
 
 // NewServeCommandWithOptions 创建基于选项的 serve 子命令
@@ -20,17 +24,61 @@ func NewServeCommandWithOptions(opts *options.ServeOptions) *cobra.Command {
 			ctx := cmd.Context()
 			logger := logr.FromContextOrDiscard(ctx)
 
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			clusterOpts := cluster.Options{AdvertiseAddress: opts.ClusterAdvertiseAddress}
+			if opts.ClusterStore != "" {
+				store, err := cluster.NewStoreFromURL(opts.ClusterStore)
+				if err != nil {
+					return fmt.Errorf("create cluster store error: %w", err)
+				}
+				clusterOpts.Store = store
+			}
+
+			var fed generic.Federation
+			if opts.FederationConfig != "" {
+				cfg, err := federation.LoadConfig(opts.FederationConfig)
+				if err != nil {
+					return err
+				}
+				fed, err = federation.New(*cfg)
+				if err != nil {
+					return fmt.Errorf("create federation error: %w", err)
+				}
+			}
+
+			var tlsConf *tls.Config
+			if opts.TLSCertFile != "" {
+				cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+				if err != nil {
+					return fmt.Errorf("load tls certificate error: %w", err)
+				}
+				tlsConf = &tls.Config{Certificates: []tls.Certificate{cert}}
+			}
+
 			s := server.NewServer(server.Options{
-				ListenAddr: opts.ListenAddr,
+				ListenAddr:     opts.ListenAddr,
+				UnixSocket:     opts.UnixSocket,
+				QUICListenAddr: opts.QUICListenAddr,
+				TLSConfig:      tlsConf,
 				TokenAuthenticator: auth.TokenAuthenticatorOptions{
 					Issuer:  opts.JWTIssuer,
 					SignKey: opts.JWTKey,
 				},
+				EnableGRPCReflection: opts.GRPCReflection,
+				Keepalive:            opts.Keepalive(),
+				FlowControl:          opts.FlowControl(),
+				Cluster:              clusterOpts,
+				Federation:           fed,
 			})
 			if err := s.Start(ctx); err != nil {
 				return fmt.Errorf("start server error: %w", err)
 			}
 			logger.Info(fmt.Sprintf("scaf serve on %q", s.Address().String()))
+			if addr := s.QUICAddress(); addr != nil {
+				logger.Info(fmt.Sprintf("scaf serve quic on %q", addr.String()))
+			}
 			if len(opts.JWTKey) == 0 {
 				// key 是随机生成的，需要生成个管理员 token ，否则没有地方能获取该 token
 				token, _ := s.AdminToken()
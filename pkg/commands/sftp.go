@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+)
+
+// NewSftpCommandWithOptions 基于选项创建 sftp 子命令
+func NewSftpCommandWithOptions(opts *options.SftpOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sftp REMOTE",
+		Short: "Browse and transfer files on the other side of a stream interactively",
+		Long: `Browse and transfer files on the other side of a stream interactively, like sftp.
+
+REMOTE is the name of a stream served by scaf file-server, or @AGENT to dispatch a file server to a registered agent
+(started with --allow-file-transfer). Run help in the shell for available commands.`,
+		Example: `# Open a shell on the file server serving stream STREAM
+scaf sftp -s SERVER --token TOKEN STREAM
+
+# Open a shell on a registered agent
+scaf sftp -s SERVER @AGENT`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+			files, cleanup, err := connectRemoteFiles(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			shellOpts := clientscp.ShellOptions{
+				Input:    os.Stdin,
+				Output:   os.Stdout,
+				Progress: opts.ProgressHandler(os.Stdout),
+			}
+			if term.IsTerminal(int(os.Stdin.Fd())) {
+				shellOpts.Prompt = "sftp> "
+			}
+			return clientscp.RunShell(ctx, files, shellOpts)
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
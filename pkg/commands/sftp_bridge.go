@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	clientscommon "github.com/yhlooo/scaf/pkg/clients/common"
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+)
+
+// NewSFTPBridgeCommandWithOptions 基于选项创建 sftp-bridge 子命令
+func NewSFTPBridgeCommandWithOptions(opts *options.SFTPBridgeOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sftp-bridge",
+		Short: "Expose files on an agent to standard SFTP clients through a local SSH server",
+		Long: `Expose files on an agent to standard SFTP clients (sftp, rclone, IDEs, ...) through a local SSH server.
+
+Each SFTP session creates a stream and dispatches an SFTP server to the agent (started with --allow-file-transfer),
+so the agent must allow the current user. Only the sftp subsystem is supported, shells and commands are rejected.`,
+		Example: `# Listen on 127.0.0.1:2022 and serve files on AGENT
+scaf sftp-bridge -s SERVER --agent AGENT
+
+# Then connect with any SFTP client using the printed password
+sftp -P 2022 user@127.0.0.1`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			logger := logr.FromContextOrDiscard(ctx)
+
+			if err := opts.Validate(); err != nil {
+				return err
+			}
+			hostKey, err := opts.HostKey()
+			if err != nil {
+				return err
+			}
+			password := opts.Password
+			if password == "" {
+				password, err = randomPassword()
+				if err != nil {
+					return err
+				}
+			}
+
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+			bridge, err := clientscp.NewSFTPBridge(clientscp.SFTPBridgeOptions{
+				HostKey:  hostKey,
+				Password: password,
+				Dial: func(ctx context.Context) (io.ReadWriteCloser, error) {
+					return dialAgentSFTP(ctx, client, opts.AgentFileOptions)
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			l, err := net.Listen("tcp", opts.Listen)
+			if err != nil {
+				return fmt.Errorf("listen on %q error: %w", opts.Listen, err)
+			}
+			fmt.Printf("Listening on: %s\n", l.Addr())
+			fmt.Printf("Host key fingerprint: %s\n", bridge.HostKeyFingerprint())
+			if opts.Password == "" {
+				fmt.Printf("Password: %s\n", password)
+			}
+			if host, port, err := net.SplitHostPort(l.Addr().String()); err == nil {
+				fmt.Printf("SFTP command: sftp -P %s %s@%s\n", port, opts.Agent, host)
+			}
+
+			if err := bridge.Serve(ctx, l); err != nil {
+				return err
+			}
+			logger.Info("done")
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
+
+// dialAgentSFTP 创建流并派发 SFTP 服务请求给代理，返回与代理端 SFTP 服务通信的连接
+//
+// 关闭返回的连接时删除创建的流
+func dialAgentSFTP(
+	ctx context.Context,
+	client clientscommon.Client,
+	opts options.AgentFileOptions,
+) (io.ReadWriteCloser, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	stream, err := client.CreateStream(ctx, &streamv1.Stream{
+		Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnFirstConnectionLeft},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create stream error: %w", err)
+	}
+	// 会话结束时 ctx 可能已经结束，仍然需要删除流
+	cleanupCtx := context.WithoutCancel(ctx)
+	deleteStream := func() {
+		if err := client.DeleteStream(cleanupCtx, stream.Name); err != nil {
+			logger.Error(err, "delete stream error")
+		}
+	}
+
+	if _, err := client.CreateDispatch(ctx, &agentv1.Dispatch{
+		Spec: agentv1.DispatchSpec{
+			Agent:  opts.Agent,
+			Type:   agentv1.DispatchSFTP,
+			Stream: stream.Name,
+			Path:   opts.RemotePath,
+		},
+	}); err != nil {
+		deleteStream()
+		return nil, fmt.Errorf("dispatch to agent %q error: %w", opts.Agent, err)
+	}
+	logger.Info(fmt.Sprintf("dispatched to agent %s (stream: %s)", opts.Agent, stream.Name))
+
+	cpClient := clientscp.New(client)
+	if stream.Status.Token != "" {
+		cpClient = cpClient.WithClient(client.WithToken(stream.Status.Token))
+	}
+	conn, err := cpClient.ConnectSFTP(ctx, stream)
+	if err != nil {
+		deleteStream()
+		return nil, err
+	}
+	return &cleanupReadWriteCloser{ReadWriteCloser: conn, cleanup: deleteStream}, nil
+}
+
+// cleanupReadWriteCloser 关闭后执行清理的 io.ReadWriteCloser
+type cleanupReadWriteCloser struct {
+	io.ReadWriteCloser
+	cleanup func()
+}
+
+// Close 关闭并清理
+func (rwc *cleanupReadWriteCloser) Close() error {
+	err := rwc.ReadWriteCloser.Close()
+	rwc.cleanup()
+	return err
+}
+
+// randomPassword 生成随机密码
+func randomPassword() (string, error) {
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generate password error: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/spf13/cobra"
 
+	clientsexec "github.com/yhlooo/scaf/pkg/clients/exec"
 	"github.com/yhlooo/scaf/pkg/commands/options"
 )
 
@@ -23,6 +24,7 @@ func NewStreamCommandWithOptions(opts *options.StreamOptions) *cobra.Command {
 		NewStreamGetCommandWithOptions(&opts.Get),
 		NewStreamListCommandWithOptions(&opts.List),
 		NewStreamDeleteCommandWithOptions(&opts.Delete),
+		NewStreamSignalCommandWithOptions(&opts.Signal),
 	)
 	return cmd
 }
@@ -127,3 +129,38 @@ func NewStreamDeleteCommandWithOptions(opts *options.StreamDeleteOptions) *cobra
 
 	return cmd
 }
+
+// NewStreamSignalCommandWithOptions 创建基于选项的 stream signal 子命令
+func NewStreamSignalCommandWithOptions(opts *options.StreamSignalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "signal STREAM_NAME SIGNAL",
+		Short: "Send a signal to the command executing through an exec stream",
+		Long: "Send a signal to the command executing through an exec stream. " +
+			"SIGNAL is one of INT, TERM, HUP, QUIT, USR1, USR2 and KILL (with or without the SIG prefix). " +
+			"The signal is delivered by the server, so a terminal may be attached to the stream.",
+		Example: "scaf stream signal -s SERVER --token TOKEN STREAM_NAME TERM",
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			logger := logr.FromContextOrDiscard(ctx)
+
+			// 创建客户端
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+
+			if err := clientsexec.SendSignal(ctx, client, args[0], args[1]); err != nil {
+				return err
+			}
+
+			logger.Info(fmt.Sprintf("signal %s sent to stream %q", args[1], args[0]))
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	authnv1 "github.com/yhlooo/scaf/pkg/apis/authn/v1"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	"github.com/yhlooo/scaf/pkg/auth"
+	"github.com/yhlooo/scaf/pkg/commands/options"
+)
+
+// NewTokenCommandWithOptions 创建基于选项的 token 子命令
+func NewTokenCommandWithOptions(opts *options.TokenOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage tokens",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		NewTokenCreateServiceCommandWithOptions(&opts.CreateService),
+	)
+	return cmd
+}
+
+// NewTokenCreateServiceCommandWithOptions 创建基于选项的 token create-service 子命令
+func NewTokenCreateServiceCommandWithOptions(opts *options.TokenCreateServiceOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create-service SERVICE_NAME",
+		Short: "Issue a service token (e.g. for a federated peer server), requires admin token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			// 创建客户端
+			client, err := opts.NewClient(ctx)
+			if err != nil {
+				return fmt.Errorf("create client error: %w", err)
+			}
+
+			ret, err := client.CreateToken(ctx, &authnv1.TokenRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: auth.ServiceUsername(args[0])},
+			})
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(ret.Status.Token)
+			return nil
+		},
+	}
+
+	// 绑定选项到命令行参数
+	opts.AddPFlags(cmd.Flags())
+
+	return cmd
+}
@@ -0,0 +1,61 @@
+package federation
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 联邦配置
+type Config struct {
+	// 信任的对端服务
+	Peers []PeerConfig `json:"peers,omitempty" yaml:"peers,omitempty"`
+}
+
+// PeerConfig 对端服务配置
+type PeerConfig struct {
+	// 对端名，用于联邦流名 NAME@PEER
+	Name string `json:"name" yaml:"name"`
+	// 对端服务地址，如 grpc://scaf.region-b.example.com:9443
+	Server string `json:"server" yaml:"server"`
+	// 对端服务为当前服务签发的服务 Token
+	Token string `json:"token" yaml:"token"`
+}
+
+// Validate 校验配置
+func (cfg *Config) Validate() error {
+	names := map[string]struct{}{}
+	for i, peer := range cfg.Peers {
+		if peer.Name == "" {
+			return fmt.Errorf("peers[%d].name must not be empty", i)
+		}
+		if _, ok := names[peer.Name]; ok {
+			return fmt.Errorf("duplicate peer name %q", peer.Name)
+		}
+		names[peer.Name] = struct{}{}
+		if peer.Server == "" {
+			return fmt.Errorf("peers[%d].server must not be empty", i)
+		}
+		if peer.Token == "" {
+			return fmt.Errorf("peers[%d].token must not be empty", i)
+		}
+	}
+	return nil
+}
+
+// LoadConfig 从 YAML 文件加载配置
+func LoadConfig(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read federation config %q error: %w", path, err)
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(raw, cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal federation config %q from yaml error: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid federation config %q: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,164 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/server/generic"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// NameSeparator 联邦流名中流名与对端名的分隔符
+const NameSeparator = "@"
+
+// New 创建 *Federation
+func New(cfg Config) (*Federation, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	f := &Federation{
+		peers:  map[string]common.Client{},
+		relays: map[string]*streams.RelayStream{},
+	}
+	for _, peer := range cfg.Peers {
+		client, err := common.NewClient(common.ClientOptions{
+			Server: peer.Server,
+			Token:  peer.Token,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create client for peer %q error: %w", peer.Name, err)
+		}
+		f.peers[peer.Name] = client
+	}
+	return f, nil
+}
+
+// Federation 跨服务联邦
+//
+// 访问联邦流 NAME@PEER 时，使用对端为当前服务签发的服务 Token 连接对端服务，
+// 并转发请求方的 Token ，由对端服务以请求方的身份鉴权。
+// 流名中可以包含多个对端，如 NAME@PEER1@PEER2 ，会先连接 PEER2 ，再由 PEER2 连接 PEER1 。
+// 每个联邦流只创建一个中继流，对端的流不存在或被删除时停止。
+type Federation struct {
+	peers map[string]common.Client
+
+	lock sync.Mutex
+	// 联邦流名到中继流的映射
+	relays map[string]*streams.RelayStream
+}
+
+var _ generic.Federation = (*Federation)(nil)
+
+// IsFederated 返回流名是否是联邦流名
+func (f *Federation) IsFederated(name string) bool {
+	return strings.Contains(name, NameSeparator)
+}
+
+// GetStreamInstance 获取联邦流实例
+//
+// 每次获取都以请求方的身份从对端获取流，对端的流不存在时停止该联邦流的中继流
+func (f *Federation) GetStreamInstance(ctx context.Context, name string) (*streams.StreamInstance, error) {
+	client, remoteName, err := f.peerClient(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := client.GetStream(ctx, remoteName)
+	if err != nil {
+		if apierrors.NewFromError(err).Reason == apierrors.ReasonNotFound {
+			f.stopRelay(ctx, name)
+		}
+		return nil, err
+	}
+	// 使用联邦流名，请求方才能通过当前服务继续访问该流
+	stream.Name = name
+	stream.Status.Token = ""
+
+	return &streams.StreamInstance{
+		Object: *stream,
+		Stream: f.relay(ctx, name),
+	}, nil
+}
+
+// DeleteStream 删除联邦流
+func (f *Federation) DeleteStream(ctx context.Context, name string) error {
+	client, remoteName, err := f.peerClient(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteStream(ctx, remoteName); err != nil {
+		return err
+	}
+	f.stopRelay(ctx, name)
+	return nil
+}
+
+// SignalStream 向联邦流中执行命令的一端发送信号
+func (f *Federation) SignalStream(ctx context.Context, name string, signal string) error {
+	client, remoteName, err := f.peerClient(ctx, name)
+	if err != nil {
+		return err
+	}
+	return client.SignalStream(ctx, remoteName, signal)
+}
+
+// relay 返回联邦流的中继流，不存在时创建并启动
+//
+// 中继流为每个加入的连接以发起加入的请求方的身份连接对端的流
+func (f *Federation) relay(ctx context.Context, name string) *streams.RelayStream {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if strm, ok := f.relays[name]; ok {
+		return strm
+	}
+	strm := streams.NewRelayStream(func(ctx context.Context, conn streams.Connection) (streams.Connection, error) {
+		client, remoteName, err := f.peerClient(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return client.ConnectStream(ctx, remoteName, common.ConnectStreamOptions{ConnectionName: conn.Name()})
+	})
+	_ = strm.Start(ctx)
+	f.relays[name] = strm
+	return strm
+}
+
+// stopRelay 停止并移除联邦流的中继流
+func (f *Federation) stopRelay(ctx context.Context, name string) {
+	f.lock.Lock()
+	strm, ok := f.relays[name]
+	delete(f.relays, name)
+	f.lock.Unlock()
+
+	if ok {
+		_ = strm.Stop(ctx)
+	}
+}
+
+// peerClient 返回访问联邦流所在对端的客户端，以及流在对端的名字
+func (f *Federation) peerClient(ctx context.Context, name string) (common.Client, string, error) {
+	i := strings.LastIndex(name, NameSeparator)
+	if i < 0 {
+		return nil, "", apierrors.NewBadRequestError(fmt.Errorf("%q is not a federated stream name", name))
+	}
+	remoteName, peerName := name[:i], name[i+1:]
+	client, ok := f.peers[peerName]
+	if !ok {
+		return nil, "", apierrors.NewNotFoundError(fmt.Errorf("unknown peer %q", peerName))
+	}
+
+	// 转发请求方的 Token ，请求方本身也是对端服务时，继续转发其转发的 Token
+	token, ok := generic.ForwardedTokenFromContext(ctx)
+	if !ok || token == "" {
+		token, _ = generic.TokenFromContext(ctx)
+	}
+	if token == "" {
+		return nil, "", apierrors.NewUnauthorizedError(fmt.Errorf("token is required to access federated stream"))
+	}
+	return client.WithForwardedToken(token), remoteName, nil
+}
@@ -0,0 +1,94 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/server/generic"
+	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/streams/streamstest"
+)
+
+// TestFederation_GetStreamInstance 测试每个联邦流复用同一个中继流，对端的流被删除或不存在时停止
+func TestFederation_GetStreamInstance(t *testing.T) {
+	a := assert.New(t)
+	ctx := generic.NewContextWithToken(context.Background(), "token")
+
+	peer := &fakePeerClient{streams: map[string]bool{"s1": true, "s2": true}}
+	f := &Federation{
+		peers:  map[string]common.Client{"peer": peer},
+		relays: map[string]*streams.RelayStream{},
+	}
+
+	ins1, err := f.GetStreamInstance(ctx, "s1@peer")
+	if !a.NoError(err) {
+		return
+	}
+	a.Equal("s1@peer", ins1.Object.Name)
+	ins2, err := f.GetStreamInstance(ctx, "s1@peer")
+	if !a.NoError(err) {
+		return
+	}
+	a.Same(ins1.Stream, ins2.Stream)
+
+	// 删除后中继流停止，再次创建的流使用新的中继流
+	a.NoError(f.DeleteStream(ctx, "s1@peer"))
+	a.ErrorIs(ins1.Stream.Join(ctx, nopConnection()), streams.ErrStreamAlreadyStopped)
+	_, err = f.GetStreamInstance(ctx, "s1@peer")
+	a.Error(err)
+	peer.streams["s1"] = true
+	ins3, err := f.GetStreamInstance(ctx, "s1@peer")
+	if a.NoError(err) {
+		a.NotSame(ins1.Stream, ins3.Stream)
+	}
+
+	// 对端的流不存在时中继流停止
+	ins4, err := f.GetStreamInstance(ctx, "s2@peer")
+	if !a.NoError(err) {
+		return
+	}
+	delete(peer.streams, "s2")
+	_, err = f.GetStreamInstance(ctx, "s2@peer")
+	a.Error(err)
+	a.ErrorIs(ins4.Stream.Join(ctx, nopConnection()), streams.ErrStreamAlreadyStopped)
+	a.Len(f.relays, 1)
+}
+
+// nopConnection 返回测试用的连接
+func nopConnection() streams.Connection {
+	conn, _ := streamstest.NewConnectionPair()
+	return conn
+}
+
+// fakePeerClient 测试用的对端客户端，只支持获取和删除流
+type fakePeerClient struct {
+	common.Client
+	streams map[string]bool
+}
+
+// WithForwardedToken 返回代替指定 Token 的用户发起请求的客户端
+func (c *fakePeerClient) WithForwardedToken(string) common.Client {
+	return c
+}
+
+// GetStream 获取流
+func (c *fakePeerClient) GetStream(_ context.Context, name string) (*streamv1.Stream, error) {
+	if !c.streams[name] {
+		return nil, apierrors.NewNotFoundError(fmt.Errorf("stream %q not found", name))
+	}
+	stream := &streamv1.Stream{}
+	stream.Name = name
+	return stream, nil
+}
+
+// DeleteStream 删除流
+func (c *fakePeerClient) DeleteStream(_ context.Context, name string) error {
+	delete(c.streams, name)
+	return nil
+}
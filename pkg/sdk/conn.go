@@ -0,0 +1,468 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+const (
+	// maxFrameSize 每条消息中数据的最大字节数，较大的写入分多条消息发送
+	maxFrameSize = 32 << 10
+	// closeTimeout 关闭连接时等待未发送完的数据的最长时间
+	closeTimeout = 5 * time.Second
+	// connIDSize 连接 ID 的字节数
+	connIDSize = len(uuid.UUID{})
+
+	// frameHello 握手帧，内容为发送端 ID ，连接建立后首先发送
+	frameHello byte = 'H'
+	// frameHelloAck 握手回复帧，内容为发送端 ID 和被回复的连接 ID
+	frameHelloAck byte = 'A'
+	// frameData 数据帧
+	frameData byte = 'D'
+	// frameFin 结束帧，发送端不再发送数据（半关闭）
+	frameFin byte = 'F'
+)
+
+// ErrWriteClosed 在已经关闭写的连接上写
+var ErrWriteClosed = errors.New("write on closed write side")
+
+// Addr 流中连接的地址
+type Addr struct {
+	// 流名
+	Stream string
+	// 连接名
+	Name string
+}
+
+var _ net.Addr = Addr{}
+
+// Network 返回网络名
+func (addr Addr) Network() string {
+	return "scaf"
+}
+
+// String 返回地址的字符串形式
+func (addr Addr) String() string {
+	return addr.Stream + "/" + addr.Name
+}
+
+// NewConn 基于流中的连接创建 Conn
+//
+// 两端都需要使用 Conn ，每条消息的第一个字节是帧类型，用于区分握手、数据和半关闭。
+// 同一个流可以先后被多对 Conn 使用，流中可能残留之前的连接发送的消息，
+// 因此两端先交换带有随机 ID 的握手帧，收到对端对本端握手的回复后才开始收发数据。
+func NewConn(conn streams.Connection, stream string) *Conn {
+	return newConn(conn, stream, nil)
+}
+
+// newConn 创建 Conn ，关闭时调用 release 释放连接相关的资源
+func newConn(conn streams.Connection, stream string, release func()) *Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	id := uuid.New()
+	c := &Conn{
+		conn:          conn,
+		ctx:           ctx,
+		cancel:        cancel,
+		release:       release,
+		id:            id[:],
+		local:         Addr{Stream: stream, Name: conn.Name()},
+		remote:        Addr{Stream: stream, Name: "peer"},
+		readCh:        make(chan readResult),
+		helloCh:       make(chan struct{}),
+		receiveDone:   make(chan struct{}),
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+		writeSem:      make(chan struct{}, 1),
+		closed:        make(chan struct{}),
+	}
+	go c.receiveLoop()
+	// 握手帧可能在对端加入前被服务端缓存，不需要等待发送结果
+	go func() {
+		_ = c.sendControl(append([]byte{frameHello}, c.id...))
+	}()
+	return c
+}
+
+// Conn 基于流中的连接的 net.Conn ，将消息视为连续的字节流
+//
+// 支持读写超时和半关闭（ CloseWrite ），对端关闭或半关闭后读返回 io.EOF 。
+// 与对端完成握手前写会阻塞。可以与 net/http 、 crypto/tls 等标准库一起使用。
+type Conn struct {
+	conn    streams.Connection
+	ctx     context.Context
+	cancel  context.CancelFunc
+	release func()
+	id      []byte
+	local   Addr
+	remote  Addr
+
+	// 接收到的消息
+	readCh chan readResult
+	// 与对端完成握手后关闭
+	helloCh chan struct{}
+	// 接收出错（通常是连接已关闭）后关闭，关闭后 receiveErr 为接收的错误
+	receiveDone chan struct{}
+	receiveErr  error
+	// 读锁，保护 readBuf 和 readErr
+	readLock sync.Mutex
+	readBuf  []byte
+	readErr  error
+
+	readDeadline  *deadline
+	writeDeadline *deadline
+	// 写信号量，同一时间只有一次写在发送
+	writeSem chan struct{}
+	// 写状态锁，保护 writeClosed
+	writeLock   sync.Mutex
+	writeClosed bool
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ net.Conn = (*Conn)(nil)
+
+// readResult 接收结果
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// receiveLoop 持续从连接接收消息，直到连接关闭
+//
+// 流中的消息依次来自先后加入流的对端，每个对端最先发送握手帧或握手回复帧。
+// 收到新的对端的握手时回复，收到对端对本端握手的回复时完成握手，之后的数据都来自该对端。
+func (c *Conn) receiveLoop() {
+	var peer []byte
+	established := false
+	for {
+		data, err := c.conn.Receive(c.ctx)
+		if err == nil && len(data) > 0 && (data[0] == frameHello || data[0] == frameHelloAck) {
+			if len(data) < 1+connIDSize {
+				continue
+			}
+			from := data[1 : 1+connIDSize]
+			switch {
+			case established && bytes.Equal(from, peer):
+				// 对端重复的握手
+				continue
+			case established:
+				// 有新的对端加入，之前的对端已经离开
+				err = fmt.Errorf("%w: peer replaced", streams.ErrConnectionClosed)
+			default:
+				if !bytes.Equal(from, peer) {
+					peer = bytes.Clone(from)
+					reply := append(append([]byte{frameHelloAck}, c.id...), peer...)
+					if c.sendControl(reply) != nil {
+						continue
+					}
+				}
+				if data[0] == frameHelloAck && bytes.Equal(data[1+connIDSize:], c.id) {
+					established = true
+					close(c.helloCh)
+				}
+				continue
+			}
+		}
+		if err == nil && !established {
+			// 握手完成之前的消息来自之前使用该流的连接
+			continue
+		}
+		if err != nil {
+			c.receiveErr = err
+			close(c.receiveDone)
+		}
+		select {
+		case c.readCh <- readResult{data: data, err: err}:
+		case <-c.closed:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read 读
+func (c *Conn) Read(p []byte) (int, error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	for len(c.readBuf) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+		select {
+		case <-c.closed:
+			return 0, net.ErrClosed
+		default:
+		}
+		select {
+		case <-c.closed:
+			return 0, net.ErrClosed
+		case <-c.readDeadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		case ret := <-c.readCh:
+			switch {
+			case ret.err != nil:
+				if errors.Is(ret.err, streams.ErrConnectionClosed) {
+					c.readErr = io.EOF
+				} else {
+					c.readErr = ret.err
+				}
+			case len(ret.data) == 0:
+				// 忽略空消息
+			case ret.data[0] == frameData:
+				c.readBuf = ret.data[1:]
+			case ret.data[0] == frameFin:
+				c.readErr = io.EOF
+			default:
+				c.readErr = fmt.Errorf("unexpected frame type %q", ret.data[0])
+			}
+		}
+	}
+
+	n := copy(p, c.readBuf)
+	c.readBuf = c.readBuf[n:]
+	return n, nil
+}
+
+// Write 写，较大的数据分多条消息发送
+//
+// 超时后已经开始发送的数据仍会在后台发送完，之后的写在其发送完后才开始
+func (c *Conn) Write(p []byte) (int, error) {
+	c.writeLock.Lock()
+	writeClosed := c.writeClosed
+	c.writeLock.Unlock()
+	if writeClosed {
+		return 0, ErrWriteClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// 发送的消息可能被缓存，不能复用调用方的切片
+	frames := make([][]byte, 0, (len(p)+maxFrameSize-1)/maxFrameSize)
+	for start := 0; start < len(p); start += maxFrameSize {
+		end := min(len(p), start+maxFrameSize)
+		frame := make([]byte, 1+end-start)
+		frame[0] = frameData
+		copy(frame[1:], p[start:end])
+		frames = append(frames, frame)
+	}
+	err := c.send(frames...)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CloseWrite 关闭写，对端读完已发送的数据后读返回 io.EOF ，仍然可以继续读
+func (c *Conn) CloseWrite() error {
+	c.writeLock.Lock()
+	if c.writeClosed {
+		c.writeLock.Unlock()
+		return nil
+	}
+	c.writeClosed = true
+	c.writeLock.Unlock()
+	return c.send([]byte{frameFin})
+}
+
+// send 等待握手完成后依次发送 frames ，直到发送完、超时或连接关闭
+func (c *Conn) send(frames ...[]byte) error {
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	case <-c.writeDeadline.wait():
+		return os.ErrDeadlineExceeded
+	case <-c.receiveDone:
+		return fmt.Errorf("%w: %w", net.ErrClosed, c.receiveErr)
+	case <-c.helloCh:
+	}
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	case <-c.writeDeadline.wait():
+		return os.ErrDeadlineExceeded
+	case c.writeSem <- struct{}{}:
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			<-c.writeSem
+		}()
+		for _, frame := range frames {
+			if err := c.conn.Send(c.ctx, frame); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	case <-c.writeDeadline.wait():
+		return os.ErrDeadlineExceeded
+	case err := <-done:
+		if errors.Is(err, streams.ErrConnectionClosed) {
+			return fmt.Errorf("%w: %w", net.ErrClosed, err)
+		}
+		return err
+	}
+}
+
+// sendControl 发送握手相关的帧，不等待握手完成
+func (c *Conn) sendControl(frame []byte) error {
+	select {
+	case <-c.closed:
+		return net.ErrClosed
+	case c.writeSem <- struct{}{}:
+	}
+	defer func() {
+		<-c.writeSem
+	}()
+	return c.conn.Send(c.ctx, frame)
+}
+
+// Close 关闭连接，先尽量通知对端不再发送数据
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		if isClosed(c.helloCh) {
+			// 等待正在发送的数据发送完，最多等待 closeTimeout
+			_ = c.SetWriteDeadline(time.Now().Add(closeTimeout))
+			_ = c.CloseWrite()
+		}
+
+		close(c.closed)
+		c.cancel()
+		err = c.conn.Close(context.Background())
+		if c.release != nil {
+			c.release()
+		}
+	})
+	return err
+}
+
+// waitForPeer 等待收到对端的握手帧
+func (c *Conn) waitForPeer(ctx context.Context) error {
+	select {
+	case <-c.helloCh:
+		return nil
+	case <-c.receiveDone:
+		return fmt.Errorf("connection closed before peer joined: %w", c.receiveErr)
+	case <-c.closed:
+		return net.ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LocalAddr 返回本端地址
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr 返回对端地址
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline 设置读写超时时间
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// SetReadDeadline 设置读超时时间
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+// SetWriteDeadline 设置写超时时间
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// newDeadline 创建 deadline
+func newDeadline() *deadline {
+	return &deadline{cancel: make(chan struct{})}
+}
+
+// deadline 可以随时修改的超时时间，超时后 wait 返回的通道被关闭
+type deadline struct {
+	lock   sync.Mutex
+	timer  *time.Timer
+	cancel chan struct{}
+}
+
+// set 设置超时时间，为零值时不超时
+func (d *deadline) set(t time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		// 计时器已经触发，等待其关闭通道
+		<-d.cancel
+	}
+	d.timer = nil
+
+	// 已经超时的通道需要重新创建
+	closed := isClosed(d.cancel)
+	if t.IsZero() {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+		return
+	}
+
+	dur := time.Until(t)
+	if dur <= 0 {
+		if !closed {
+			close(d.cancel)
+		}
+		return
+	}
+	if closed {
+		d.cancel = make(chan struct{})
+	}
+	cancel := d.cancel
+	d.timer = time.AfterFunc(dur, func() {
+		close(cancel)
+	})
+}
+
+// wait 返回超时时关闭的通道
+func (d *deadline) wait() <-chan struct{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.cancel
+}
+
+// isClosed 返回通道是否已关闭
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
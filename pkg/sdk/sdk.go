@@ -0,0 +1,215 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+)
+
+const (
+	// dialerConnectionName 拨号端连接名
+	dialerConnectionName = "sdk-dialer"
+	// listenerConnectionName 监听端连接名
+	listenerConnectionName = "sdk-listener"
+
+	// retryInterval 连接失败后重试的间隔
+	retryInterval = 200 * time.Millisecond
+	// maxDialAttempts 拨号的最大尝试次数
+	maxDialAttempts = 10
+)
+
+// Dial 连接到 serverURL 服务端上名为 streamName 的流，与流另一端 Listen 或 NewConn 创建的 Conn 完成握手后返回
+//
+// token 为流的 Token 或有权限访问该流的用户 Token 。
+// 之前的连接可能还未完全离开流，加入流失败时会重试，直到 ctx 结束或达到最大重试次数。
+// ctx 仅用于建立连接，连接建立后取消 ctx 不影响返回的 Conn
+func Dial(ctx context.Context, serverURL, streamName, token string) (*Conn, error) {
+	client, err := common.NewClient(common.ClientOptions{
+		Server: serverURL,
+		Token:  token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create client error: %w", err)
+	}
+
+	for i := 1; ; i++ {
+		c, err := dial(ctx, client, streamName)
+		if err == nil {
+			return c, nil
+		}
+		if ctx.Err() != nil || i >= maxDialAttempts {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// dial 使用 client 连接到名为 streamName 的流并等待与对端完成握手
+func dial(ctx context.Context, client common.Client, streamName string) (*Conn, error) {
+	// 连接的生命周期与 ctx 无关，仅在建立连接期间响应 ctx 取消
+	connCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	conn, err := client.ConnectStream(connCtx, streamName, common.ConnectStreamOptions{
+		ConnectionName: dialerConnectionName,
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("connect to stream %q error: %w", streamName, err)
+	}
+
+	c := newConn(conn, streamName, cancel)
+	if err := c.waitForPeer(ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Listen 在 serverURL 服务端上创建流并返回监听该流的 Listener
+//
+// token 为有权限创建流的用户 Token 。通过 Listener.Stream 获取流名和 Token 后，对端使用 Dial 连接。
+// ctx 仅用于创建流，关闭 Listener 时删除创建的流
+func Listen(ctx context.Context, serverURL, token string) (*Listener, error) {
+	client, err := common.NewClient(common.ClientOptions{
+		Server: serverURL,
+		Token:  token,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create client error: %w", err)
+	}
+
+	// 流在连接离开后保留，以便之后的连接复用
+	stream, err := client.CreateStream(ctx, &streamv1.Stream{
+		Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnDelete},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create stream error: %w", err)
+	}
+	streamClient := client
+	if stream.Status.Token != "" {
+		streamClient = client.WithToken(stream.Status.Token)
+	}
+
+	lCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	return &Listener{
+		client:       client,
+		streamClient: streamClient,
+		stream:       stream,
+		ctx:          lCtx,
+		cancel:       cancel,
+		slot:         make(chan struct{}, 1),
+	}, nil
+}
+
+// Listener 监听流的 net.Listener
+//
+// 一个流同一时间只能有一对连接，因此 Listener 同一时间只有一个已接受的 Conn ，
+// 该 Conn 关闭后才会接受下一个连接。
+type Listener struct {
+	client       common.Client
+	streamClient common.Client
+	stream       *streamv1.Stream
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// 已接受的连接占用的槽位，连接关闭时释放
+	slot chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var _ net.Listener = (*Listener)(nil)
+
+// Stream 返回监听的流，对端通过其中的流名和 Token 连接
+func (l *Listener) Stream() *streamv1.Stream {
+	stream := *l.stream
+	return &stream
+}
+
+// Accept 等待并返回下一个连接
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case <-l.ctx.Done():
+		return nil, net.ErrClosed
+	case l.slot <- struct{}{}:
+	}
+	releaseSlot := func() {
+		<-l.slot
+	}
+
+	for {
+		c, err := l.accept(releaseSlot)
+		if err == nil {
+			return c, nil
+		}
+		if l.ctx.Err() != nil {
+			releaseSlot()
+			return nil, net.ErrClosed
+		}
+
+		// 之前的连接可能还未完全离开流，流仍然存在时稍后重试
+		if _, getErr := l.client.GetStream(l.ctx, l.stream.Name); getErr != nil {
+			releaseSlot()
+			if l.ctx.Err() != nil {
+				return nil, net.ErrClosed
+			}
+			return nil, err
+		}
+		select {
+		case <-l.ctx.Done():
+			releaseSlot()
+			return nil, net.ErrClosed
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// accept 连接到流并等待对端连接，关闭返回的连接时调用 release
+func (l *Listener) accept(release func()) (*Conn, error) {
+	conn, err := l.streamClient.ConnectStream(l.ctx, l.stream.Name, common.ConnectStreamOptions{
+		ConnectionName: listenerConnectionName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to stream %q error: %w", l.stream.Name, err)
+	}
+
+	// 关闭连接可能发生在其它 goroutine 中
+	var accepted atomic.Bool
+	c := newConn(conn, l.stream.Name, func() {
+		if accepted.Load() {
+			release()
+		}
+	})
+	if err := c.waitForPeer(l.ctx); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	accepted.Store(true)
+	return c, nil
+}
+
+// Close 停止监听并删除流，已接受的连接随之断开
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() {
+		l.cancel()
+		if err := l.client.DeleteStream(context.Background(), l.stream.Name); err != nil {
+			l.closeErr = fmt.Errorf("delete stream %q error: %w", l.stream.Name, err)
+		}
+	})
+	return l.closeErr
+}
+
+// Addr 返回监听地址
+func (l *Listener) Addr() net.Addr {
+	return Addr{Stream: l.stream.Name, Name: listenerConnectionName}
+}
@@ -0,0 +1,142 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yhlooo/scaf/pkg/server"
+)
+
+// startTestServer 启动测试用的服务，返回服务地址和管理员 Token
+func startTestServer(t *testing.T, ctx context.Context) (string, string) {
+	s := server.NewServer(server.Options{ListenAddr: "127.0.0.1:0"})
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start server error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.Stop(context.Background())
+	})
+	adminToken, err := s.AdminToken()
+	if err != nil {
+		t.Fatalf("get admin token error: %v", err)
+	}
+	return s.Address().String(), adminToken
+}
+
+// TestListenAndDial 测试通过流运行 HTTP 服务
+func TestListenAndDial(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr, adminToken := startTestServer(t, ctx)
+	l, err := Listen(ctx, "grpc://"+addr, adminToken)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	stream := l.Stream()
+
+	httpServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = fmt.Fprintf(w, "hello %s", req.URL.Path)
+	})}
+	done := make(chan error, 1)
+	go func() {
+		done <- httpServer.Serve(l)
+	}()
+
+	// 依次通过不同的传输方式连接到同一个流
+	for _, scheme := range []string{"grpc", "http", "tcp"} {
+		httpClient := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return Dial(ctx, scheme+"://"+addr, stream.Name, stream.Status.Token)
+			},
+			DisableKeepAlives: true,
+		}}
+		resp, err := httpClient.Get("http://scaf/" + scheme)
+		if !a.NoError(err, scheme) {
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		a.NoError(err, scheme)
+		a.Equal("hello /"+scheme, string(body), scheme)
+		_ = resp.Body.Close()
+	}
+
+	a.NoError(l.Close())
+	a.ErrorIs(<-done, net.ErrClosed)
+}
+
+// TestConnDeadlineAndCloseWrite 测试 Conn 超时和半关闭
+func TestConnDeadlineAndCloseWrite(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr, adminToken := startTestServer(t, ctx)
+	l, err := Listen(ctx, "grpc://"+addr, adminToken)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	stream := l.Stream()
+
+	// 服务端读到 EOF 后回复并关闭
+	served := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			served <- err
+			return
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		data, err := io.ReadAll(conn)
+		if err != nil {
+			served <- err
+			return
+		}
+		_, err = conn.Write(append([]byte("echo "), data...))
+		served <- err
+	}()
+
+	conn, err := Dial(ctx, "tcp://"+addr, stream.Name, stream.Status.Token)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	a.Equal(stream.Name, conn.LocalAddr().(Addr).Stream)
+
+	// 读超时
+	a.NoError(conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)))
+	_, err = conn.Read(make([]byte, 1))
+	a.ErrorIs(err, os.ErrDeadlineExceeded)
+	a.NoError(conn.SetReadDeadline(time.Time{}))
+
+	// 半关闭后仍然可以读
+	_, err = conn.Write([]byte("ping"))
+	a.NoError(err)
+	a.NoError(conn.CloseWrite())
+	_, err = conn.Write([]byte("ping"))
+	a.True(errors.Is(err, ErrWriteClosed))
+	data, err := io.ReadAll(conn)
+	a.NoError(err)
+	a.Equal("echo ping", string(data))
+	a.NoError(<-served)
+}
@@ -0,0 +1,433 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	"github.com/yhlooo/scaf/pkg/auth"
+	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/utils/randutil"
+)
+
+// agentNameRegexp 代理名格式
+var agentNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-_.a-zA-Z0-9]{0,61}[a-zA-Z0-9])?$`)
+
+// AgentsServerOptions agents 服务选项
+type AgentsServerOptions struct {
+	TokenAuthenticator *auth.TokenAuthenticator
+	// 用于获取派发请求中的流
+	StreamsServer *StreamsServer
+	// 集群中各副本共享的代理注册表，为 nil 时只能访问注册到当前副本的代理
+	Registry AgentRegistry
+}
+
+// AgentRegistry 集群中各副本共享的代理注册表
+//
+// 代理只连接到一个副本，注册表记录代理注册到的副本，派发给其它副本上的代理的请求转发到该副本
+type AgentRegistry interface {
+	// RegisterAgent 记录注册到当前副本的代理
+	RegisterAgent(ctx context.Context, agent *agentv1.Agent) error
+	// UnregisterAgent 删除注册到当前副本的代理的记录，记录已被同名代理的新注册替代时不删除
+	UnregisterAgent(ctx context.Context, agent *agentv1.Agent) error
+	// GetAgent 获取注册到其它副本的代理，不存在时返回 NotFound 错误
+	GetAgent(ctx context.Context, name string) (*agentv1.Agent, error)
+	// ListAgents 列出注册到其它副本的代理
+	ListAgents(ctx context.Context) ([]agentv1.Agent, error)
+	// CreateDispatch 向注册到其它副本的代理派发请求，由代理所在副本鉴权
+	CreateDispatch(ctx context.Context, dispatch *agentv1.Dispatch) (*agentv1.Dispatch, error)
+}
+
+// NewAgentsServer 创建 *AgentsServer
+func NewAgentsServer(opts AgentsServerOptions) *AgentsServer {
+	return &AgentsServer{
+		authenticator: opts.TokenAuthenticator,
+		streamsServer: opts.StreamsServer,
+		registry:      opts.Registry,
+		agents:        map[string]*AgentInstance{},
+	}
+}
+
+// AgentsServer 通用 agents 服务
+//
+// 代理通过一个长连接注册，连接断开时自动注销，派发给代理的请求通过该连接发送给代理
+type AgentsServer struct {
+	authenticator *auth.TokenAuthenticator
+	streamsServer *StreamsServer
+	registry      AgentRegistry
+
+	lock         sync.RWMutex
+	agents       map[string]*AgentInstance
+	shuttingDown bool
+}
+
+// AgentInstance 代理实例
+type AgentInstance struct {
+	Object agentv1.Agent
+
+	conn      streams.Connection
+	sendLock  sync.Mutex
+	done      chan struct{}
+	closeErr  error
+	closeOnce sync.Once
+}
+
+// close 关闭代理实例，结束其 ServeAgent ，err 不为 nil 时 ServeAgent 返回该错误
+func (ins *AgentInstance) close(err error) {
+	ins.closeOnce.Do(func() {
+		ins.closeErr = err
+		close(ins.done)
+	})
+}
+
+// send 向代理发送派发请求
+func (ins *AgentInstance) send(ctx context.Context, dispatch *agentv1.Dispatch) error {
+	raw, err := json.Marshal(dispatch)
+	if err != nil {
+		return fmt.Errorf("marshal dispatch to json error: %w", err)
+	}
+	ins.sendLock.Lock()
+	defer ins.sendLock.Unlock()
+	return ins.conn.Send(ctx, raw)
+}
+
+// PrepareAgent 检查代理能否注册，返回待注册的代理实例
+// 需要在建立注册代理的连接前调用，以便在连接建立前返回错误
+func (s *AgentsServer) PrepareAgent(ctx context.Context, agent *agentv1.Agent) (*AgentInstance, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	username, err := GetUsernameFromContext(ctx, s.authenticator)
+	if err != nil {
+		logger.Error(err, "get username error")
+		return nil, apierrors.NewUnauthorizedError(err)
+	}
+	if auth.IsAnonymous(username) || auth.IsStreams(username) {
+		err := fmt.Errorf("user %q is not allowed to register agent", username)
+		logger.Info(err.Error())
+		return nil, apierrors.NewForbiddenError(err)
+	}
+	if !agentNameRegexp.MatchString(agent.Name) {
+		return nil, apierrors.NewBadRequestError(fmt.Errorf(
+			"invalid agent name %q: must consist of alphanumeric characters, '-', '_' or '.', "+
+				"start and end with an alphanumeric character, and be at most 63 characters", agent.Name,
+		))
+	}
+
+	ins := &AgentInstance{Object: *agent, done: make(chan struct{})}
+	ins.Object.UID = ""
+	ins.Object.Owners = []string{username}
+	s.lock.RLock()
+	err = s.checkConflict(ins)
+	s.lock.RUnlock()
+	if err == nil {
+		err = s.checkRegistryConflict(ctx, ins)
+	}
+	if err != nil {
+		logger.Info(err.Error())
+		return nil, err
+	}
+	return ins, nil
+}
+
+// ServeAgent 注册代理并通过连接向其派发请求，阻塞直到连接断开
+// 同一所有者注册同名代理时，新的代理会替代旧的代理，旧代理的 ServeAgent 返回冲突错误
+// 返回错误时由调用方关闭连接
+func (s *AgentsServer) ServeAgent(ctx context.Context, ins *AgentInstance, conn streams.Connection) error {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("agent", ins.Object.Name)
+
+	ins.conn = conn
+	ins.Object.UID = metav1.UID(uuid.New().String())
+
+	if err := s.checkRegistryConflict(ctx, ins); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	if s.shuttingDown {
+		s.lock.Unlock()
+		return apierrors.NewServiceUnavailableError(fmt.Errorf("server is shutting down"))
+	}
+	if err := s.checkConflict(ins); err != nil {
+		s.lock.Unlock()
+		return err
+	}
+	old := s.agents[ins.Object.Name]
+	s.agents[ins.Object.Name] = ins
+	s.lock.Unlock()
+
+	if old != nil {
+		logger.Info("agent replaced by a new connection")
+		// 通知旧代理不要再重连，以免两个同名代理交替替代对方
+		old.close(apierrors.NewConflictError(fmt.Errorf(
+			"agent %q replaced by a new registration", ins.Object.Name,
+		)))
+	}
+	if s.registry != nil {
+		if err := s.registry.RegisterAgent(ctx, &ins.Object); err != nil {
+			logger.Error(err, "save agent record error")
+			s.lock.Lock()
+			if s.agents[ins.Object.Name] == ins {
+				delete(s.agents, ins.Object.Name)
+			}
+			s.lock.Unlock()
+			ins.close(nil)
+			return apierrors.NewServiceUnavailableError(fmt.Errorf("save agent record error: %w", err))
+		}
+	}
+	logger.Info(fmt.Sprintf("agent registered, labels: %v", ins.Object.Spec.Labels))
+
+	// 代理不会主动发送消息，接收只用于感知连接断开
+	recvDone := make(chan struct{})
+	go func() {
+		defer close(recvDone)
+		for {
+			if _, err := conn.Receive(ctx); err != nil {
+				return
+			}
+		}
+	}()
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-recvDone:
+	case <-ins.done:
+		err = ins.closeErr
+	}
+	ins.close(nil)
+
+	s.lock.Lock()
+	if s.agents[ins.Object.Name] == ins {
+		delete(s.agents, ins.Object.Name)
+	}
+	s.lock.Unlock()
+	if s.registry != nil {
+		if err := s.registry.UnregisterAgent(context.WithoutCancel(ctx), &ins.Object); err != nil {
+			logger.Error(err, "delete agent record error")
+		}
+	}
+	logger.Info("agent unregistered")
+
+	if err != nil {
+		return err
+	}
+	_ = conn.Close(ctx)
+	return nil
+}
+
+// Shutdown 断开所有代理，并拒绝新的代理注册
+// 代理会收到服务不可用错误，稍后重连到其它副本或重启后的服务
+func (s *AgentsServer) Shutdown() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.shuttingDown = true
+	for _, ins := range s.agents {
+		ins.close(apierrors.NewServiceUnavailableError(fmt.Errorf("server is shutting down")))
+	}
+}
+
+// checkConflict 检查注册到当前副本的同名代理是否属于其它用户
+// 需要持有锁
+func (s *AgentsServer) checkConflict(ins *AgentInstance) error {
+	existing, ok := s.agents[ins.Object.Name]
+	if !ok {
+		return nil
+	}
+	return checkOwnerConflict(ins, &existing.Object)
+}
+
+// checkRegistryConflict 检查注册到其它副本的同名代理是否属于其它用户
+func (s *AgentsServer) checkRegistryConflict(ctx context.Context, ins *AgentInstance) error {
+	if s.registry == nil {
+		return nil
+	}
+	existing, err := s.registry.GetAgent(ctx, ins.Object.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return apierrors.NewServiceUnavailableError(fmt.Errorf("get agent record error: %w", err))
+	}
+	return checkOwnerConflict(ins, existing)
+}
+
+// checkOwnerConflict 检查同名代理 existing 是否属于其它用户
+func checkOwnerConflict(ins *AgentInstance, existing *agentv1.Agent) error {
+	username := ins.Object.Owners[0]
+	if auth.IsOwner(username, &existing.ObjectMeta) || auth.IsAdmin(username) {
+		return nil
+	}
+	return apierrors.NewConflictError(fmt.Errorf("agent %q already registered by another user", ins.Object.Name))
+}
+
+// ListAgents 列出当前用户可以派发请求的代理
+func (s *AgentsServer) ListAgents(ctx context.Context) (*agentv1.AgentList, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	username, err := GetUsernameFromContext(ctx, s.authenticator)
+	if err != nil {
+		logger.Error(err, "get username error")
+		return nil, apierrors.NewUnauthorizedError(err)
+	}
+	if auth.IsAnonymous(username) || auth.IsStreams(username) {
+		err := fmt.Errorf("user %q is not allowed to list agents", username)
+		logger.Info(err.Error())
+		return nil, apierrors.NewForbiddenError(err)
+	}
+
+	ret := &agentv1.AgentList{}
+	local := map[string]bool{}
+	s.lock.RLock()
+	for _, ins := range s.agents {
+		local[ins.Object.Name] = true
+		if canDispatch(username, &ins.Object) {
+			ret.Items = append(ret.Items, ins.Object)
+		}
+	}
+	s.lock.RUnlock()
+	if s.registry != nil {
+		remote, err := s.registry.ListAgents(ctx)
+		if err != nil {
+			logger.Error(err, "list agent records error")
+			return nil, apierrors.NewInternalServerError(fmt.Errorf("list agent records error: %w", err))
+		}
+		for _, agent := range remote {
+			if !local[agent.Name] && canDispatch(username, &agent) {
+				ret.Items = append(ret.Items, agent)
+			}
+		}
+	}
+	sort.Slice(ret.Items, func(i, j int) bool {
+		return ret.Items[i].Name < ret.Items[j].Name
+	})
+
+	return ret, nil
+}
+
+// GetAgent 获取代理
+func (s *AgentsServer) GetAgent(ctx context.Context, name string) (*agentv1.Agent, error) {
+	agent, _, err := s.getAgent(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return agent, nil
+}
+
+// getAgent 获取当前用户可以派发请求的代理
+// 代理注册到当前副本时同时返回其实例，注册到其它副本时返回的实例为 nil
+func (s *AgentsServer) getAgent(ctx context.Context, name string) (*agentv1.Agent, *AgentInstance, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	username, err := GetUsernameFromContext(ctx, s.authenticator)
+	if err != nil {
+		logger.Error(err, "get username error")
+		return nil, nil, apierrors.NewUnauthorizedError(err)
+	}
+	if auth.IsAnonymous(username) || auth.IsStreams(username) {
+		err := fmt.Errorf("user %q is not allowed to get agent %q", username, name)
+		logger.Info(err.Error())
+		return nil, nil, apierrors.NewForbiddenError(err)
+	}
+
+	var agent *agentv1.Agent
+	s.lock.RLock()
+	ins, ok := s.agents[name]
+	if ok {
+		obj := ins.Object
+		agent = &obj
+	}
+	s.lock.RUnlock()
+	if !ok {
+		if s.registry == nil {
+			return nil, nil, apierrors.NewNotFoundError(fmt.Errorf("agent %q not found", name))
+		}
+		if agent, err = s.registry.GetAgent(ctx, name); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !canDispatch(username, agent) {
+		err := fmt.Errorf("user %q is not allowed to get agent %q", username, name)
+		logger.Info(err.Error())
+		return nil, nil, apierrors.NewForbiddenError(err)
+	}
+	return agent, ins, nil
+}
+
+// CreateDispatch 向代理派发请求
+// 请求方需要能访问请求中的流，代理会收到加入该流的 token
+func (s *AgentsServer) CreateDispatch(ctx context.Context, dispatch *agentv1.Dispatch) (*agentv1.Dispatch, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("agent", dispatch.Spec.Agent)
+	ctx = logr.NewContext(ctx, logger)
+
+	switch dispatch.Spec.Type {
+	case agentv1.DispatchExec:
+	case agentv1.DispatchSendFile, agentv1.DispatchReceiveFile, agentv1.DispatchFileServer, agentv1.DispatchSFTP:
+		if dispatch.Spec.Path == "" {
+			return nil, apierrors.NewBadRequestError(fmt.Errorf("path is required for %s dispatch", dispatch.Spec.Type))
+		}
+	default:
+		return nil, apierrors.NewBadRequestError(fmt.Errorf("unsupported dispatch type: %q", dispatch.Spec.Type))
+	}
+	if dispatch.Spec.Stream == "" {
+		return nil, apierrors.NewBadRequestError(fmt.Errorf("stream is required"))
+	}
+	if s.streamsServer.federation != nil && s.streamsServer.federation.IsFederated(dispatch.Spec.Stream) {
+		return nil, apierrors.NewBadRequestError(fmt.Errorf(
+			"dispatching federated stream %q is not supported", dispatch.Spec.Stream,
+		))
+	}
+
+	_, ins, err := s.getAgent(ctx, dispatch.Spec.Agent)
+	if err != nil {
+		return nil, err
+	}
+	if ins == nil {
+		// 代理注册到其它副本，转发到该副本
+		logger.V(1).Info("forward dispatch to the replica of agent")
+		return s.registry.CreateDispatch(ctx, dispatch)
+	}
+	// 检查请求方能否访问流
+	streamIns, err := s.streamsServer.GetStreamInstance(ctx, dispatch.Spec.Stream)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &agentv1.Dispatch{Spec: dispatch.Spec}
+	ret.Name = randutil.LowerAlphaNumeric(16)
+	ret.Annotations = dispatch.Annotations
+	msg := *ret
+	msg.Status.Token, err = s.authenticator.IssueToken(auth.StreamUsername(streamIns.Object.Name), 0)
+	if err != nil {
+		logger.Error(err, "issue stream token error")
+		return nil, apierrors.NewInternalServerError(fmt.Errorf("issue stream token error: %w", err))
+	}
+	if err := ins.send(ctx, &msg); err != nil {
+		logger.Error(err, "send dispatch to agent error")
+		return nil, apierrors.NewServiceUnavailableError(fmt.Errorf(
+			"send dispatch to agent %q error: %w", dispatch.Spec.Agent, err,
+		))
+	}
+	logger.Info(fmt.Sprintf("dispatched %s %q for stream %q", ret.Spec.Type, ret.Name, ret.Spec.Stream))
+
+	return ret, nil
+}
+
+// canDispatch 返回用户是否可以向代理派发请求
+func canDispatch(username string, agent *agentv1.Agent) bool {
+	if auth.IsAdmin(username) || auth.IsOwner(username, &agent.ObjectMeta) {
+		return true
+	}
+	for _, u := range agent.Spec.Users {
+		if u == username {
+			return true
+		}
+	}
+	return false
+}
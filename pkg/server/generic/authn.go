@@ -31,10 +31,28 @@ type AuthenticationServer struct {
 }
 
 // CreateToken 创建 Token
-func (s *AuthenticationServer) CreateToken(ctx context.Context, _ *authnv1.TokenRequest) (*authnv1.TokenRequest, error) {
+//
+// 默认为随机的新用户签发 Token ，管理员可通过指定请求名为服务用户名为服务签发 Token
+func (s *AuthenticationServer) CreateToken(ctx context.Context, req *authnv1.TokenRequest) (*authnv1.TokenRequest, error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
 	username := auth.RandNormalUsername()
+	if req != nil && req.Name != "" {
+		if !auth.IsService(req.Name) {
+			return nil, apierrors.NewBadRequestError(fmt.Errorf("invalid service username %q", req.Name))
+		}
+		requester, err := GetUsernameFromContext(ctx, s.authenticator)
+		if err != nil {
+			logger.Error(err, "get username error")
+			return nil, apierrors.NewUnauthorizedError(err)
+		}
+		if !auth.IsAdmin(requester) {
+			err := fmt.Errorf("user %q is not allowed to issue token for %q", requester, req.Name)
+			logger.Info(err.Error())
+			return nil, apierrors.NewForbiddenError(err)
+		}
+		username = req.Name
+	}
 	token, err := s.authenticator.IssueToken(username, 0)
 	if err != nil {
 		logger.Error(err, "issue token error")
@@ -2,6 +2,7 @@ package generic
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/yhlooo/scaf/pkg/auth"
 )
@@ -9,6 +10,9 @@ import (
 // tokenContextKey 上下文中存储 Token 的键
 type tokenContextKey struct{}
 
+// forwardedTokenContextKey 上下文中存储转发的 Token 的键
+type forwardedTokenContextKey struct{}
+
 // NewContextWithToken 创建包含 Token 的上下文
 func NewContextWithToken(parent context.Context, token string) context.Context {
 	return context.WithValue(parent, tokenContextKey{}, token)
@@ -20,11 +24,36 @@ func TokenFromContext(ctx context.Context) (string, bool) {
 	return token, ok
 }
 
+// NewContextWithForwardedToken 创建包含转发的 Token 的上下文
+func NewContextWithForwardedToken(parent context.Context, token string) context.Context {
+	return context.WithValue(parent, forwardedTokenContextKey{}, token)
+}
+
+// ForwardedTokenFromContext 从上下文获取转发的 Token
+func ForwardedTokenFromContext(ctx context.Context) (string, bool) {
+	token, ok := ctx.Value(forwardedTokenContextKey{}).(string)
+	return token, ok
+}
+
 // GetUsernameFromContext 从上下文获取用户名
+//
+// 请求方是服务且带有转发的 Token 时，返回转发的 Token 对应的用户名
 func GetUsernameFromContext(ctx context.Context, authenticator *auth.TokenAuthenticator) (string, error) {
 	token, ok := TokenFromContext(ctx)
 	if !ok || token == "" {
 		return auth.AnonymousUsername, nil
 	}
-	return authenticator.AuthenticateToken(token)
+	username, err := authenticator.AuthenticateToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	forwardedToken, ok := ForwardedTokenFromContext(ctx)
+	if !ok || forwardedToken == "" {
+		return username, nil
+	}
+	if !auth.IsService(username) {
+		return "", fmt.Errorf("user %q is not allowed to forward token", username)
+	}
+	return authenticator.AuthenticateToken(forwardedToken)
 }
@@ -18,6 +18,24 @@ import (
 type StreamsServerOptions struct {
 	TokenAuthenticator *auth.TokenAuthenticator
 	StreamManager      streams.Manager
+	// 访问其它服务上的流的联邦，为 nil 时不支持
+	Federation Federation
+	// 创建流使用的选项
+	StreamOptions streams.BufferedStreamOptions
+}
+
+// Federation 访问其它服务上的流的联邦
+//
+// 联邦流名形如 NAME@PEER ，其鉴权由流所在服务完成
+type Federation interface {
+	// IsFederated 返回流名是否是联邦流名
+	IsFederated(name string) bool
+	// GetStreamInstance 获取联邦流实例
+	GetStreamInstance(ctx context.Context, name string) (*streams.StreamInstance, error)
+	// DeleteStream 删除联邦流
+	DeleteStream(ctx context.Context, name string) error
+	// SignalStream 向联邦流中执行命令的一端发送信号
+	SignalStream(ctx context.Context, name string, signal string) error
 }
 
 // NewStreamsServer 创建 *StreamsServer
@@ -25,6 +43,8 @@ func NewStreamsServer(opts StreamsServerOptions) *StreamsServer {
 	return &StreamsServer{
 		streamMgr:     opts.StreamManager,
 		authenticator: opts.TokenAuthenticator,
+		federation:    opts.Federation,
+		streamOpts:    opts.StreamOptions,
 	}
 }
 
@@ -32,6 +52,8 @@ func NewStreamsServer(opts StreamsServerOptions) *StreamsServer {
 type StreamsServer struct {
 	streamMgr     streams.Manager
 	authenticator *auth.TokenAuthenticator
+	federation    Federation
+	streamOpts    streams.BufferedStreamOptions
 }
 
 // CreateStream 创建流
@@ -48,7 +70,7 @@ func (s *StreamsServer) CreateStream(ctx context.Context, stream *streamv1.Strea
 	}
 
 	// 创建流
-	strm := streams.NewBufferedStream()
+	strm := streams.NewBufferedStream(s.streamOpts)
 	ins, err := s.streamMgr.CreateStream(ctx, &streams.StreamInstance{
 		Object: *stream,
 		Stream: strm,
@@ -82,6 +104,15 @@ func (s *StreamsServer) GetStream(ctx context.Context, name string) (*streamv1.S
 func (s *StreamsServer) GetStreamInstance(ctx context.Context, name string) (*streams.StreamInstance, error) {
 	logger := logr.FromContextOrDiscard(ctx)
 
+	if s.federation != nil && s.federation.IsFederated(name) {
+		ins, err := s.federation.GetStreamInstance(ctx, name)
+		if err != nil {
+			logger.Error(err, "get federated stream error")
+			return nil, apierrors.NewFromError(err)
+		}
+		return ins, nil
+	}
+
 	username, err := GetUsernameFromContext(ctx, s.authenticator)
 	if err != nil {
 		logger.Error(err, "get username error")
@@ -149,6 +180,14 @@ func (s *StreamsServer) ListStreams(ctx context.Context) (*streamv1.StreamList,
 func (s *StreamsServer) DeleteStream(ctx context.Context, name string) error {
 	logger := logr.FromContextOrDiscard(ctx)
 
+	if s.federation != nil && s.federation.IsFederated(name) {
+		if err := s.federation.DeleteStream(ctx, name); err != nil {
+			logger.Error(err, "delete federated stream error")
+			return apierrors.NewFromError(err)
+		}
+		return nil
+	}
+
 	username, err := GetUsernameFromContext(ctx, s.authenticator)
 	if err != nil {
 		logger.Error(err, "get username error")
@@ -189,3 +228,44 @@ func (s *StreamsServer) DeleteStream(ctx context.Context, name string) error {
 
 	return nil
 }
+
+// SignalStream 向流中执行命令的一端发送信号
+//
+// 信号作为控制消息发给流中的连接，不需要加入流，因此流的两端都已有连接时也可以发送
+func (s *StreamsServer) SignalStream(ctx context.Context, name string, signal string) error {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if signal == "" {
+		return apierrors.NewBadRequestError(fmt.Errorf("signal must not be empty"))
+	}
+
+	if s.federation != nil && s.federation.IsFederated(name) {
+		if err := s.federation.SignalStream(ctx, name, signal); err != nil {
+			logger.Error(err, "signal federated stream error")
+			return apierrors.NewFromError(err)
+		}
+		return nil
+	}
+
+	ins, err := s.GetStreamInstance(ctx, name)
+	if err != nil {
+		return err
+	}
+	signaler, ok := ins.Stream.(streams.Signaler)
+	if !ok {
+		return apierrors.NewBadRequestError(fmt.Errorf("stream %q does not support signals", name))
+	}
+	if err := signaler.Signal(ctx, signal); err != nil {
+		logger.Error(err, "signal stream error")
+		switch {
+		case errors.Is(err, streams.ErrStreamIsEmpty):
+			return apierrors.NewConflictError(fmt.Errorf("no connection in stream %q: %w", name, err))
+		case errors.Is(err, streams.ErrStreamAlreadyStopped):
+			return apierrors.NewNotFoundError(err)
+		default:
+			return apierrors.NewFromError(err)
+		}
+	}
+
+	return nil
+}
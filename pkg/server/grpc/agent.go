@@ -0,0 +1,92 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	agentv1grpc "github.com/yhlooo/scaf/pkg/apis/agent/v1/grpc"
+	"github.com/yhlooo/scaf/pkg/server/generic"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// NewAgentsServer 创建 gRPC 代理服务
+func NewAgentsServer(genericServer *generic.AgentsServer) *AgentsServer {
+	return &AgentsServer{
+		genericServer: genericServer,
+	}
+}
+
+// AgentsServer 代理服务
+type AgentsServer struct {
+	agentv1grpc.UnimplementedAgentsServer
+	genericServer *generic.AgentsServer
+}
+
+var _ agentv1grpc.AgentsServer = (*AgentsServer)(nil)
+
+// ListAgents 列出代理
+func (s *AgentsServer) ListAgents(
+	ctx context.Context,
+	_ *agentv1grpc.ListAgentsRequest,
+) (*agentv1grpc.AgentList, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("request", "ListAgents")
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	ret, err := s.genericServer.ListAgents(ctx)
+	return agentv1.NewGRPCAgentList(ret), err
+}
+
+// GetAgent 获取代理
+func (s *AgentsServer) GetAgent(ctx context.Context, req *agentv1grpc.GetAgentRequest) (*agentv1grpc.Agent, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("agent", req.GetName())
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	ret, err := s.genericServer.GetAgent(ctx, req.GetName())
+	return agentv1.NewGRPCAgent(ret), err
+}
+
+// ConnectAgent 注册代理并保持连接
+func (s *AgentsServer) ConnectAgent(server agentv1grpc.Agents_ConnectAgentServer) error {
+	ctx := server.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+	agent := &agentv1.Agent{}
+	if values := md.Get(MetadataKeyAgentSpec); len(values) > 0 && values[0] != "" {
+		if err := json.Unmarshal([]byte(values[0]), &agent.Spec); err != nil {
+			return apierrors.NewBadRequestError(fmt.Errorf("invalid agent spec: %w", err))
+		}
+	}
+	if values := md.Get(MetadataKeyAgentName); len(values) > 0 {
+		agent.Name = values[0]
+	}
+
+	logger := logr.FromContextOrDiscard(ctx).WithValues("agent", agent.Name)
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	ins, err := s.genericServer.PrepareAgent(ctx, agent)
+	if err != nil {
+		return err
+	}
+	return s.genericServer.ServeAgent(ctx, ins, streams.NewGRPCStreamServerConnection(agent.Name, server))
+}
+
+// CreateDispatch 向代理派发请求
+func (s *AgentsServer) CreateDispatch(
+	ctx context.Context,
+	dispatch *agentv1grpc.Dispatch,
+) (*agentv1grpc.Dispatch, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	ret, err := s.genericServer.CreateDispatch(ctx, agentv1.NewDispatchFromGRPC(dispatch))
+	return agentv1.NewGRPCDispatch(ret), err
+}
@@ -19,6 +19,15 @@ const (
 	MetadataKeyConnectionName = "scaf-connection-name"
 	// MetadataKeyToken 表示 Token 的 metadata 键
 	MetadataKeyToken = "scaf-token"
+	// MetadataKeyForwardedToken 表示转发的 Token 的 metadata 键
+	MetadataKeyForwardedToken = "scaf-forwarded-token"
+	// MetadataKeyProtocolVersion 表示连接协议版本的 metadata 键
+	// 客户端在请求 metadata 中设置其支持的最高版本，服务端在响应头 metadata 中确认协商的版本
+	MetadataKeyProtocolVersion = "scaf-protocol-version"
+	// MetadataKeyAgentName 表示代理名的 metadata 键
+	MetadataKeyAgentName = "scaf-agent-name"
+	// MetadataKeyAgentSpec 表示 JSON 格式代理定义的 metadata 键
+	MetadataKeyAgentSpec = "scaf-agent-spec"
 )
 
 // WithLoggerInterceptor 往上下文注入 logr.Logger 的拦截器
@@ -82,5 +91,8 @@ func handleContextToken(ctx context.Context) context.Context {
 	if token != "" {
 		ctx = generic.NewContextWithToken(ctx, token)
 	}
+	if values := md.Get(MetadataKeyForwardedToken); len(values) > 0 && values[0] != "" {
+		ctx = generic.NewContextWithForwardedToken(ctx, values[0])
+	}
 	return ctx
 }
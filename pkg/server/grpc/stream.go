@@ -3,6 +3,7 @@ package grpc
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/go-logr/logr"
 	_ "google.golang.org/grpc/encoding/gzip" // 注册 gzip 压缩器
@@ -83,6 +84,22 @@ func (s *StreamsServer) DeleteStream(
 	return &metav1grpc.Status{Code: 200, Reason: "Ok"}, nil
 }
 
+// SignalStream 向流中执行命令的一端发送信号
+func (s *StreamsServer) SignalStream(
+	ctx context.Context,
+	req *streamv1grpc.SignalStreamRequest,
+) (*metav1grpc.Status, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("stream", req.GetName(), "signal", req.GetSignal())
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	err := s.genericServer.SignalStream(ctx, req.GetName(), req.GetSignal())
+	if err != nil {
+		return nil, err
+	}
+	return &metav1grpc.Status{Code: 200, Reason: "Ok"}, nil
+}
+
 // ConnectStream 连接流
 func (s *StreamsServer) ConnectStream(server streamv1grpc.Streams_ConnectStreamServer) error {
 	ctx := server.Context()
@@ -106,6 +123,18 @@ func (s *StreamsServer) ConnectStream(server streamv1grpc.Streams_ConnectStreamS
 		connName = values[0]
 	}
 	conn := streams.NewGRPCStreamServerConnection(connName, server)
+	protocolVersion := 0
+	if values := md.Get(MetadataKeyProtocolVersion); len(values) > 0 {
+		protocolVersion = streams.NegotiateProtocolVersion(values[0])
+	}
+	if protocolVersion > 0 {
+		// 加入流前确认协商的版本，客户端据此得知之后是否会收到控制消息
+		err := server.SendHeader(metadata.Pairs(MetadataKeyProtocolVersion, strconv.Itoa(protocolVersion)))
+		if err != nil {
+			return err
+		}
+		conn.SetProtocolVersion(protocolVersion)
+	}
 	if err := ins.Stream.Join(ctx, conn); err != nil {
 		logger.Error(err, "join stream error")
 		return apierrors.NewInternalServerError(fmt.Errorf("join stream error: %w", err))
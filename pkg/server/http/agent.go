@@ -0,0 +1,105 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-logr/logr"
+
+	"github.com/yhlooo/scaf/pkg/apierrors"
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// AgentSpecHeader JSON 格式代理定义头
+const AgentSpecHeader = "X-Scaf-Agent-Spec"
+
+// HandleListAgents 处理列出代理
+func (h *httpHandlers) HandleListAgents(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := logr.FromContextOrDiscard(ctx).WithValues("method", "ListAgents")
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	ret, err := h.genericAgentsServer.ListAgents(ctx)
+	if err != nil {
+		responseStatus(ctx, w, apierrors.NewFromError(err))
+		return
+	}
+	responseJSON(ctx, w, http.StatusOK, ret)
+}
+
+// HandleGetOrConnectAgent 处理获取代理或注册代理
+func (h *httpHandlers) HandleGetOrConnectAgent(w http.ResponseWriter, req *http.Request) {
+	agentName := req.PathValue("name")
+	ctx := req.Context()
+	logger := logr.FromContextOrDiscard(ctx).WithValues("method", "GetOrConnectAgent", "agent", agentName)
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	if !isUpgrade(req) {
+		ret, err := h.genericAgentsServer.GetAgent(ctx, agentName)
+		if err != nil {
+			responseStatus(ctx, w, apierrors.NewFromError(err))
+			return
+		}
+		responseJSON(ctx, w, http.StatusOK, ret)
+		return
+	}
+
+	// 升级连接注册代理
+	agent := &agentv1.Agent{}
+	agent.Name = agentName
+	if spec := req.Header.Get(AgentSpecHeader); spec != "" {
+		if err := json.Unmarshal([]byte(spec), &agent.Spec); err != nil {
+			responseStatus(ctx, w, apierrors.NewBadRequestError(fmt.Errorf("invalid agent spec: %w", err)))
+			return
+		}
+	}
+	ins, err := h.genericAgentsServer.PrepareAgent(ctx, agent)
+	if err != nil {
+		responseStatus(ctx, w, apierrors.NewFromError(err))
+		return
+	}
+	conn, reject := h.upgradeConnection(
+		ctx, w, req, agentName,
+		streams.NegotiateProtocolVersion(req.Header.Get(ProtocolVersionHeader)),
+	)
+	if conn == nil {
+		return
+	}
+	if err := h.genericAgentsServer.ServeAgent(ctx, ins, conn); err != nil {
+		logger.Error(err, "serve agent error")
+		reject(apierrors.NewFromError(err))
+	}
+}
+
+// HandleCreateDispatch 处理向代理派发请求
+func (h *httpHandlers) HandleCreateDispatch(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := logr.FromContextOrDiscard(ctx).WithValues("method", "CreateDispatch")
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	reqBody, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		logger.Error(err, "read request error")
+		responseStatus(ctx, w, apierrors.NewInternalServerError(fmt.Errorf("read request error: %w", err)))
+		return
+	}
+	dispatch := &agentv1.Dispatch{}
+	if err := json.Unmarshal(reqBody, dispatch); err != nil {
+		logger.Error(err, "unmarshal request error")
+		responseStatus(ctx, w, apierrors.NewBadRequestError(fmt.Errorf("parse request error: %w", err)))
+		return
+	}
+
+	ret, err := h.genericAgentsServer.CreateDispatch(ctx, dispatch)
+	if err != nil {
+		responseStatus(ctx, w, apierrors.NewFromError(err))
+		return
+	}
+	responseJSON(ctx, w, http.StatusCreated, ret)
+}
@@ -18,6 +18,9 @@ func GetTokenHandler(handler http.Handler) http.HandlerFunc {
 			token = token[7:]
 			req = req.WithContext(generic.NewContextWithToken(req.Context(), token))
 		}
+		if forwardedToken := req.Header.Get(ForwardedTokenHeader); forwardedToken != "" {
+			req = req.WithContext(generic.NewContextWithForwardedToken(req.Context(), forwardedToken))
+		}
 		handler.ServeHTTP(w, req)
 	}
 }
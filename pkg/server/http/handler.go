@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/go-logr/logr"
 	"github.com/gorilla/websocket"
+	"github.com/soheilhy/cmux"
 
 	"github.com/yhlooo/scaf/pkg/apierrors"
 	authnv1 "github.com/yhlooo/scaf/pkg/apis/authn/v1"
@@ -17,37 +20,65 @@ import (
 	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
 	"github.com/yhlooo/scaf/pkg/server/generic"
 	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/version"
 )
 
 const (
 	// ConnectionNameHeader 连接名头
 	ConnectionNameHeader = "X-Scaf-Connection-Name"
+	// ForwardedTokenHeader 转发的 Token 头
+	ForwardedTokenHeader = "X-Scaf-Forwarded-Token"
+	// ProtocolVersionHeader 连接协议版本头
+	// 客户端在升级请求中设置其支持的最高版本，服务端在升级响应中确认协商的版本
+	ProtocolVersionHeader = "X-Scaf-Protocol-Version"
+	// RawUpgradeProtocol 升级为原始字节流连接时使用的协议名
+	RawUpgradeProtocol = "scaf-raw"
 )
 
+// wsWriteBufferPool WebSocket 连接共用的写缓冲区池，空闲的连接不占用写缓冲区
+var wsWriteBufferPool = &sync.Pool{}
+
 // Options 选项
 type Options struct {
 	Logger logr.Logger
+	// 返回服务是否已就绪，为 nil 时总是认为已就绪
+	Ready func() bool
+	// WebSocket 和原始字节流连接的保活选项
+	Keepalive streams.KeepaliveOptions
 }
 
 // NewHTTPHandler 创建 HTTP 请求处理器
 func NewHTTPHandler(
 	genericAuthnServer *generic.AuthenticationServer,
 	genericStreamsServer *generic.StreamsServer,
+	genericAgentsServer *generic.AgentsServer,
 	opts Options) http.Handler {
 	handlers := &httpHandlers{
 		genericAuthnServer:   genericAuthnServer,
 		genericStreamsServer: genericStreamsServer,
+		genericAgentsServer:  genericAgentsServer,
+		ready:                opts.Ready,
+		keepalive:            opts.Keepalive,
 	}
 
 	mux := http.NewServeMux()
 
+	mux.HandleFunc("GET /healthz", handlers.HandleHealthz)
+	mux.HandleFunc("GET /readyz", handlers.HandleReadyz)
+	mux.HandleFunc("GET /version", handlers.HandleVersion)
+
 	mux.HandleFunc("POST /v1/tokens", handlers.HandleCreateToken)
 	mux.HandleFunc("POST /v1/selfsubjectreviews", handlers.HandleCreateSelfSubjectReview)
-	
+
 	mux.HandleFunc("POST /v1/streams", handlers.HandleCreateStream)
 	mux.HandleFunc("GET /v1/streams", handlers.HandleListStreams)
 	mux.HandleFunc("GET /v1/streams/{name}", handlers.HandleGetOrConnectStream)
 	mux.HandleFunc("DELETE /v1/streams/{name}", handlers.HandleDeleteStream)
+	mux.HandleFunc("POST /v1/streams/{name}/signals", handlers.HandleSignalStream)
+
+	mux.HandleFunc("GET /v1/agents", handlers.HandleListAgents)
+	mux.HandleFunc("GET /v1/agents/{name}", handlers.HandleGetOrConnectAgent)
+	mux.HandleFunc("POST /v1/dispatches", handlers.HandleCreateDispatch)
 
 	return GetTokenHandler(WithLoggerHandler(mux, opts.Logger))
 }
@@ -56,6 +87,29 @@ func NewHTTPHandler(
 type httpHandlers struct {
 	genericAuthnServer   *generic.AuthenticationServer
 	genericStreamsServer *generic.StreamsServer
+	genericAgentsServer  *generic.AgentsServer
+	ready                func() bool
+	keepalive            streams.KeepaliveOptions
+}
+
+// HandleHealthz 处理存活检查
+func (h *httpHandlers) HandleHealthz(w http.ResponseWriter, req *http.Request) {
+	responseStatus(req.Context(), w, newOKStatus())
+}
+
+// HandleReadyz 处理就绪检查
+func (h *httpHandlers) HandleReadyz(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if h.ready != nil && !h.ready() {
+		responseStatus(ctx, w, apierrors.NewServiceUnavailableError(fmt.Errorf("server is not ready")))
+		return
+	}
+	responseStatus(ctx, w, newOKStatus())
+}
+
+// HandleVersion 处理获取版本信息
+func (h *httpHandlers) HandleVersion(w http.ResponseWriter, req *http.Request) {
+	responseJSON(req.Context(), w, http.StatusOK, version.GetVersion())
 }
 
 // HandleCreateSelfSubjectReview 处理检查自身身份
@@ -172,39 +226,18 @@ func (h *httpHandlers) HandleGetOrConnectStream(w http.ResponseWriter, req *http
 	}
 
 	// 升级连接加入流
-	if strings.ToLower(req.Header.Get("Connection")) == "upgrade" {
-		connName := req.Header.Get(ConnectionNameHeader)
-		switch {
-		case websocket.IsWebSocketUpgrade(req):
-			upgrader := &websocket.Upgrader{
-				CheckOrigin: func(r *http.Request) bool {
-					return true
-				},
-			}
-			conn, err := upgrader.Upgrade(w, req, nil)
-			if err != nil {
-				logger.Error(err, "websocket upgrade error")
-				responseStatus(ctx, w, apierrors.NewInternalServerError(
-					fmt.Errorf("websocket upgrade error: %w", err),
-				))
-				return
-			}
-			if err := ins.Stream.Join(ctx, streams.NewWebSocketConnection(connName, conn)); err != nil {
-				logger.Error(err, "join stream error")
-				errMsg, _ := json.Marshal(apierrors.NewInternalServerError(fmt.Errorf("join stream error: %w", err)))
-				if err := conn.WriteMessage(websocket.TextMessage, errMsg); err != nil {
-					logger.Error(err, "send message error")
-				}
-				if err := conn.Close(); err != nil {
-					logger.Error(err, "close websocket connection error")
-				}
-				return
-			}
-		default:
-			// TODO: 支持其它协议
-			responseStatus(ctx, w, apierrors.NewBadRequestError(
-				fmt.Errorf("unsupported protocol: %s", req.Header.Get("Upgrade")),
-			))
+	if isUpgrade(req) {
+		conn, reject := h.upgradeConnection(
+			ctx, w, req,
+			req.Header.Get(ConnectionNameHeader),
+			streams.NegotiateProtocolVersion(req.Header.Get(ProtocolVersionHeader)),
+		)
+		if conn == nil {
+			return
+		}
+		if err := ins.Stream.Join(ctx, conn); err != nil {
+			logger.Error(err, "join stream error")
+			reject(apierrors.NewInternalServerError(fmt.Errorf("join stream error: %w", err)))
 		}
 		return
 	}
@@ -228,6 +261,151 @@ func (h *httpHandlers) HandleDeleteStream(w http.ResponseWriter, req *http.Reque
 	responseStatus(ctx, w, newOKStatus())
 }
 
+// HandleSignalStream 处理向流中执行命令的一端发送信号
+func (h *httpHandlers) HandleSignalStream(w http.ResponseWriter, req *http.Request) {
+	streamName := req.PathValue("name")
+	ctx := req.Context()
+	logger := logr.FromContextOrDiscard(ctx).WithValues("method", "SignalStream", "stream", streamName)
+	ctx = logr.NewContext(ctx, logger)
+	logger.Info("request received")
+
+	reqBody, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		logger.Error(err, "read request error")
+		responseStatus(ctx, w, apierrors.NewInternalServerError(fmt.Errorf("read request error: %w", err)))
+		return
+	}
+	signal := &streamv1.StreamSignal{}
+	if err := json.Unmarshal(reqBody, signal); err != nil {
+		logger.Error(err, "unmarshal request error")
+		responseStatus(ctx, w, apierrors.NewBadRequestError(fmt.Errorf("parse request error: %w", err)))
+		return
+	}
+
+	if err := h.genericStreamsServer.SignalStream(ctx, streamName, signal.Signal); err != nil {
+		responseStatus(ctx, w, apierrors.NewFromError(err))
+		return
+	}
+	responseStatus(ctx, w, newOKStatus())
+}
+
+// isUpgrade 返回是否是升级连接请求
+func isUpgrade(req *http.Request) bool {
+	return strings.ToLower(req.Header.Get("Connection")) == "upgrade"
+}
+
+// upgradeConnection 将请求升级为 WebSocket 或原始字节流连接
+// 升级失败时已向客户端发送响应并返回 nil ，
+// 升级后如需拒绝连接应调用返回的 reject ，协议支持时会将状态发送给对端。
+// protocolVersion 为协商的连接协议版本，大于 0 时在升级响应中确认并设置到连接
+func (h *httpHandlers) upgradeConnection(
+	ctx context.Context,
+	w http.ResponseWriter,
+	req *http.Request,
+	connName string,
+	protocolVersion int,
+) (conn streams.Connection, reject func(status *metav1.Status)) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	respHeader := http.Header{}
+	if protocolVersion > 0 {
+		respHeader.Set(ProtocolVersionHeader, strconv.Itoa(protocolVersion))
+	}
+
+	switch {
+	case websocket.IsWebSocketUpgrade(req):
+		upgrader := &websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+			WriteBufferPool: wsWriteBufferPool,
+		}
+		wsConn, err := upgrader.Upgrade(w, req, respHeader)
+		if err != nil {
+			logger.Error(err, "websocket upgrade error")
+			responseStatus(ctx, w, apierrors.NewInternalServerError(
+				fmt.Errorf("websocket upgrade error: %w", err),
+			))
+			return nil, nil
+		}
+		streamConn := streams.NewWebSocketConnection(connName, wsConn, h.keepalive)
+		streamConn.SetProtocolVersion(protocolVersion)
+		return streamConn, func(status *metav1.Status) {
+			if protocolVersion > 0 {
+				if err := streamConn.SendError(ctx, status); err != nil {
+					logger.Error(err, "send error message error")
+				}
+			} else {
+				// 旧客户端以文本消息接收状态
+				errMsg, _ := json.Marshal(status)
+				if err := wsConn.WriteMessage(websocket.TextMessage, errMsg); err != nil {
+					logger.Error(err, "send message error")
+				}
+			}
+			if err := wsConn.Close(); err != nil {
+				logger.Error(err, "close websocket connection error")
+			}
+		}
+	case strings.EqualFold(req.Header.Get("Upgrade"), RawUpgradeProtocol):
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			responseStatus(ctx, w, apierrors.NewInternalServerError(
+				fmt.Errorf("connection does not support hijacking"),
+			))
+			return nil, nil
+		}
+		rawConn, rw, err := hijacker.Hijack()
+		if err != nil {
+			logger.Error(err, "hijack connection error")
+			responseStatus(ctx, w, apierrors.NewInternalServerError(
+				fmt.Errorf("hijack connection error: %w", err),
+			))
+			return nil, nil
+		}
+		_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Connection: Upgrade\r\n" +
+			"Upgrade: " + RawUpgradeProtocol + "\r\n")
+		if err == nil {
+			err = respHeader.Write(rw)
+		}
+		if err == nil {
+			_, err = rw.WriteString("\r\n")
+		}
+		if err == nil {
+			err = rw.Flush()
+		}
+		if err != nil {
+			logger.Error(err, "send upgrade response error")
+			_ = rawConn.Close()
+			return nil, nil
+		}
+		// 使用 rw.Reader 读取，避免丢失已被缓冲的数据
+		// cmux 识别协议时缓冲的数据在解析 HTTP 请求时已被读完，直接使用底层连接，使流转发时可以使用 splice
+		netConn := rawConn
+		if muxConn, ok := rawConn.(*cmux.MuxConn); ok {
+			netConn = muxConn.Conn
+		}
+		streamConn := streams.NewRawConnection(connName, netConn, rw.Reader, h.keepalive)
+		streamConn.SetProtocolVersion(protocolVersion)
+		return streamConn, func(status *metav1.Status) {
+			// 旧客户端在协议切换后无法再接收状态，只能直接关闭连接
+			if protocolVersion > 0 {
+				if err := streamConn.SendError(ctx, status); err != nil {
+					logger.Error(err, "send error message error")
+				}
+			}
+			if err := rawConn.Close(); err != nil {
+				logger.Error(err, "close raw connection error")
+			}
+		}
+	default:
+		responseStatus(ctx, w, apierrors.NewBadRequestError(
+			fmt.Errorf("unsupported protocol: %s", req.Header.Get("Upgrade")),
+		))
+		return nil, nil
+	}
+}
+
 // newOKStatus 创建普通正常状态
 func newOKStatus() *metav1.Status {
 	return &metav1.Status{
@@ -2,35 +2,64 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/soheilhy/cmux"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthgrpc "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 
+	agentv1grpc "github.com/yhlooo/scaf/pkg/apis/agent/v1/grpc"
 	authnv1grpc "github.com/yhlooo/scaf/pkg/apis/authn/v1/grpc"
 	streamv1grpc "github.com/yhlooo/scaf/pkg/apis/stream/v1/grpc"
 	"github.com/yhlooo/scaf/pkg/auth"
+	"github.com/yhlooo/scaf/pkg/cluster"
 	"github.com/yhlooo/scaf/pkg/server/generic"
 	servergrpc "github.com/yhlooo/scaf/pkg/server/grpc"
 	serverhttp "github.com/yhlooo/scaf/pkg/server/http"
 	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/utils/quicutil"
 )
 
 const (
 	loggerName        = "server"
 	defaultListenAddr = ":9443"
+	// 允许客户端发送 gRPC keepalive Ping 的最短间隔， gRPC 客户端的最短间隔为 10s
+	grpcKeepaliveMinTime = 5 * time.Second
 )
 
 // Options 是 Server 运行选项
 type Options struct {
 	// 监听地址
 	ListenAddr string
+	// 额外监听的 Unix Socket 路径，为空时不监听
+	UnixSocket string
+	// 额外监听的 QUIC （ UDP ）地址，为空时不监听
+	QUICListenAddr string
+	// QUIC 使用的 TLS 配置，为 nil 时使用随机生成的自签名证书
+	TLSConfig *tls.Config
 	// Token 认证器选项
 	TokenAuthenticator auth.TokenAuthenticatorOptions
+	// 是否启用 gRPC 服务反射
+	EnableGRPCReflection bool
+	// 集群选项
+	Cluster cluster.Options
+	// 访问其它服务上的流的联邦，为 nil 时不支持
+	Federation generic.Federation
+	// 连接保活选项，为 nil 时使用默认选项
+	Keepalive *streams.KeepaliveOptions
+	// 流转发的流控选项，为 nil 时使用默认选项
+	FlowControl *streams.FlowControlOptions
 }
 
 // Complete 将选项补充完整
@@ -38,26 +67,58 @@ func (opts *Options) Complete() {
 	if opts.ListenAddr == "" {
 		opts.ListenAddr = defaultListenAddr
 	}
+	if opts.Keepalive == nil {
+		keepalive := streams.NewDefaultKeepaliveOptions()
+		opts.Keepalive = &keepalive
+	}
+	if opts.FlowControl == nil {
+		flowControl := streams.NewDefaultFlowControlOptions()
+		opts.FlowControl = &flowControl
+	}
 }
 
 // NewServer 创建 *Server
 func NewServer(opts Options) *Server {
 	opts.Complete()
 	authenticator := auth.NewTokenAuthenticator(opts.TokenAuthenticator)
-	streamMgr := streams.NewInMemoryManager()
+	flowScheduler := streams.NewFlowScheduler(opts.FlowControl.MaxInFlightBytes)
+	localStreamMgr := streams.NewInMemoryManager()
+	var streamMgr streams.Manager = localStreamMgr
+	var clusterMgr *cluster.Manager
+	if opts.Cluster.Store != nil {
+		clusterMgr = cluster.NewManager(streamMgr, opts.Cluster)
+		streamMgr = clusterMgr
+	}
 	genericAuthnServer := generic.NewAuthenticationServer(generic.AuthenticationServerOptions{
 		TokenAuthenticator: authenticator,
 	})
 	genericStreamsServer := generic.NewStreamsServer(generic.StreamsServerOptions{
 		TokenAuthenticator: authenticator,
 		StreamManager:      streamMgr,
+		Federation:         opts.Federation,
+		StreamOptions: streams.BufferedStreamOptions{
+			Window:    opts.FlowControl.StreamWindow,
+			Scheduler: flowScheduler,
+		},
 	})
+	agentsOpts := generic.AgentsServerOptions{
+		TokenAuthenticator: authenticator,
+		StreamsServer:      genericStreamsServer,
+	}
+	if clusterMgr != nil {
+		agentsOpts.Registry = clusterMgr
+	}
+	genericAgentsServer := generic.NewAgentsServer(agentsOpts)
 	return &Server{
 		opts:                 opts,
 		authenticator:        authenticator,
 		streamMgr:            streamMgr,
+		localStreamMgr:       localStreamMgr,
+		clusterMgr:           clusterMgr,
+		flowScheduler:        flowScheduler,
 		genericAuthnServer:   genericAuthnServer,
 		genericStreamsServer: genericStreamsServer,
+		genericAgentsServer:  genericAgentsServer,
 	}
 }
 
@@ -73,6 +134,13 @@ type Server struct {
 	listener net.Listener
 	cmux     cmux.CMux
 
+	unixListener     net.Listener
+	unixCMux         cmux.CMux
+	unixHTTPListener net.Listener
+	unixGRPCListener net.Listener
+
+	quicListener net.Listener
+
 	httpListener net.Listener
 	httpHandler  http.Handler
 
@@ -80,11 +148,19 @@ type Server struct {
 	grpcServer        *grpc.Server
 	grpcAuthnServer   *servergrpc.AuthenticationServer
 	grpcStreamsServer *servergrpc.StreamsServer
+	grpcAgentsServer  *servergrpc.AgentsServer
+	grpcHealthServer  *health.Server
+
+	ready atomic.Bool
 
 	authenticator        *auth.TokenAuthenticator
 	streamMgr            streams.Manager
+	localStreamMgr       streams.Manager
+	clusterMgr           *cluster.Manager
+	flowScheduler        *streams.FlowScheduler
 	genericStreamsServer *generic.StreamsServer
 	genericAuthnServer   *generic.AuthenticationServer
+	genericAgentsServer  *generic.AgentsServer
 }
 
 // Start 启动服务
@@ -106,22 +182,52 @@ func (s *Server) Start(ctx context.Context) error {
 		if err != nil {
 			return
 		}
-		s.cmux = cmux.New(s.listener)
-		// 根据协议分流
-		s.grpcListener = s.cmux.MatchWithWriters(
-			cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"),
-		)
-		s.httpListener = s.cmux.Match(cmux.Any())
+		if s.clusterMgr != nil && s.clusterMgr.AdvertiseAddress() == "" {
+			// 未指定时使用实际监听地址
+			s.clusterMgr.SetAdvertiseAddress("grpc://" + s.listener.Addr().String())
+		}
+		s.cmux, s.grpcListener, s.httpListener = newCMux(s.listener)
+
+		// 监听 Unix Socket
+		if s.opts.UnixSocket != "" {
+			s.unixListener, err = listenUnix(s.opts.UnixSocket)
+			if err != nil {
+				_ = s.listener.Close()
+				return
+			}
+			s.unixCMux, s.unixGRPCListener, s.unixHTTPListener = newCMux(s.unixListener)
+		}
+
+		// 监听 QUIC
+		if s.opts.QUICListenAddr != "" {
+			s.quicListener, err = s.listenQUIC(logger)
+			if err != nil {
+				_ = s.listener.Close()
+				if s.unixListener != nil {
+					_ = s.unixListener.Close()
+				}
+				return
+			}
+		}
 
 		s.httpHandler = serverhttp.NewHTTPHandler(
 			s.genericAuthnServer,
 			s.genericStreamsServer,
+			s.genericAgentsServer,
 			serverhttp.Options{
-				Logger: logger.WithName("http"),
+				Logger:    logger.WithName("http"),
+				Ready:     s.Ready,
+				Keepalive: *s.opts.Keepalive,
 			},
 		)
 
 		s.grpcServer = grpc.NewServer(
+			// 允许客户端发送 keepalive Ping ，否则客户端会因 too_many_pings 被断开
+			grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+				MinTime:             grpcKeepaliveMinTime,
+				PermitWithoutStream: true,
+			}),
+			grpc.KeepaliveParams(s.grpcKeepaliveParams()),
 			grpc.ChainUnaryInterceptor(
 				servergrpc.GetTokenInterceptor,
 				servergrpc.WithLoggerInterceptor(logger.WithName("grpc")),
@@ -135,6 +241,13 @@ func (s *Server) Start(ctx context.Context) error {
 		authnv1grpc.RegisterAuthenticationServer(s.grpcServer, s.grpcAuthnServer)
 		s.grpcStreamsServer = servergrpc.NewStreamsServer(s.genericStreamsServer)
 		streamv1grpc.RegisterStreamsServer(s.grpcServer, s.grpcStreamsServer)
+		s.grpcAgentsServer = servergrpc.NewAgentsServer(s.genericAgentsServer)
+		agentv1grpc.RegisterAgentsServer(s.grpcServer, s.grpcAgentsServer)
+		s.grpcHealthServer = health.NewServer()
+		healthgrpc.RegisterHealthServer(s.grpcServer, s.grpcHealthServer)
+		if s.opts.EnableGRPCReflection {
+			reflection.Register(s.grpcServer)
+		}
 
 		go s.run(ctx)
 	})
@@ -189,6 +302,45 @@ func (s *Server) Address() net.Addr {
 	return s.httpListener.Addr()
 }
 
+// QUICAddress 返回 QUIC 监听地址，未监听时返回 nil
+func (s *Server) QUICAddress() net.Addr {
+	s.startLock.RLock()
+	defer s.startLock.RUnlock()
+
+	if s.quicListener == nil {
+		return nil
+	}
+	return s.quicListener.Addr()
+}
+
+// UnixAddress 返回 Unix Socket 监听地址，未监听时返回 nil
+func (s *Server) UnixAddress() net.Addr {
+	s.startLock.RLock()
+	defer s.startLock.RUnlock()
+
+	if s.unixListener == nil {
+		return nil
+	}
+	return s.unixListener.Addr()
+}
+
+// grpcKeepaliveParams 返回 gRPC 服务端的 keepalive 参数
+// 禁用保活时使用 gRPC 的默认参数，即 2h 没有活动时才发送 Ping
+func (s *Server) grpcKeepaliveParams() keepalive.ServerParameters {
+	if !s.opts.Keepalive.Enabled() {
+		return keepalive.ServerParameters{}
+	}
+	return keepalive.ServerParameters{
+		Time:    s.opts.Keepalive.Interval,
+		Timeout: s.opts.Keepalive.Timeout,
+	}
+}
+
+// Ready 返回服务是否已就绪
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
 // AdminToken 获取管理员用户 Token
 func (s *Server) AdminToken() (string, error) {
 	return s.authenticator.IssueToken(auth.AdminUsername, 0)
@@ -199,59 +351,143 @@ func (s *Server) run(ctx context.Context) {
 	logger := logr.FromContextOrDiscard(ctx)
 
 	defer func() {
+		s.setReady(false)
+		// 通知本副本上流中的连接服务端即将停止
+		drainingCtx := context.WithoutCancel(ctx)
+		if err := streams.NotifyStreams(
+			drainingCtx, s.localStreamMgr, streams.Control{Type: streams.ServerDrainingControl},
+		); err != nil {
+			logger.Error(err, "notify streams draining error")
+		}
+		// 代理连接不会自行结束，需要先断开，否则 gRPC 服务无法停止
+		s.genericAgentsServer.Shutdown()
 		s.grpcServer.GracefulStop()
 		if err := s.listener.Close(); err != nil {
 			logger.Error(err, "close tcp listener error")
 		}
+		if s.unixListener != nil {
+			if err := s.unixListener.Close(); err != nil {
+				logger.Error(err, "close unix listener error")
+			}
+		}
+		if s.quicListener != nil {
+			if err := s.quicListener.Close(); err != nil {
+				logger.Error(err, "close quic listener error")
+			}
+		}
 		close(s.done)
 	}()
 
-	cmuxDone := make(chan struct{})
+	cmuxDone := serve(ctx, "cmux", s.cmux.Serve)
+	httpDone := serve(ctx, "http", func() error {
+		return http.Serve(s.httpListener, s.httpHandler)
+	})
+	grpcDone := serve(ctx, "grpc", func() error {
+		return s.grpcServer.Serve(s.grpcListener)
+	})
+
+	var unixCMuxDone, unixHTTPDone, unixGRPCDone <-chan struct{}
+	if s.unixListener != nil {
+		unixCMuxDone = serve(ctx, "unix cmux", s.unixCMux.Serve)
+		unixHTTPDone = serve(ctx, "unix http", func() error {
+			return http.Serve(s.unixHTTPListener, s.httpHandler)
+		})
+		unixGRPCDone = serve(ctx, "unix grpc", func() error {
+			return s.grpcServer.Serve(s.unixGRPCListener)
+		})
+	}
+
+	var quicHTTPDone <-chan struct{}
+	if s.quicListener != nil {
+		// QUIC 上的每个流作为一个 HTTP 连接，流连接通过 scaf-raw 协议升级
+		quicHTTPDone = serve(ctx, "quic http", func() error {
+			return http.Serve(s.quicListener, s.httpHandler)
+		})
+	}
+
+	s.setReady(true)
+
+	select {
+	case <-ctx.Done():
+	case <-cmuxDone:
+	case <-httpDone:
+	case <-grpcDone:
+	case <-unixCMuxDone:
+	case <-unixHTTPDone:
+	case <-unixGRPCDone:
+	case <-quicHTTPDone:
+	}
+}
+
+// serve 在新协程中运行 serveFn ，返回其结束时会被关闭的 channel
+func serve(ctx context.Context, name string, serveFn func() error) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
-		defer close(cmuxDone)
-		if err := s.cmux.Serve(); err != nil {
+		defer close(done)
+		if err := serveFn(); err != nil {
 			select {
 			case <-ctx.Done():
 				// ctx 结束了错误就没所谓了
 				return
 			default:
 			}
-			logger.Error(err, "cmux serve error")
+			logr.FromContextOrDiscard(ctx).Error(err, name+" serve error")
 		}
 	}()
+	return done
+}
 
-	httpDone := make(chan struct{})
-	go func() {
-		defer close(httpDone)
-		if err := http.Serve(s.httpListener, s.httpHandler); err != nil {
-			select {
-			case <-ctx.Done():
-				// ctx 结束了错误就没所谓了
-				return
-			default:
-			}
-			logger.Error(err, "http serve error")
+// newCMux 基于 listener 创建 cmux.CMux ，并根据协议分流为 gRPC 和 HTTP 两个监听器
+func newCMux(listener net.Listener) (m cmux.CMux, grpcListener, httpListener net.Listener) {
+	m = cmux.New(listener)
+	grpcListener = m.MatchWithWriters(
+		cmux.HTTP2MatchHeaderFieldSendSettings("content-type", "application/grpc"),
+	)
+	httpListener = m.Match(cmux.Any())
+	return m, grpcListener, httpListener
+}
+
+// listenQUIC 监听 QUIC
+func (s *Server) listenQUIC(logger logr.Logger) (net.Listener, error) {
+	tlsConf := s.opts.TLSConfig
+	if tlsConf == nil {
+		logger.Info("WARNING: no tls certificate specified, using a self-signed certificate for quic")
+		var err error
+		tlsConf, err = quicutil.NewSelfSignedTLSConfig()
+		if err != nil {
+			return nil, err
 		}
-	}()
+	}
+	return quicutil.Listen(s.opts.QUICListenAddr, tlsConf)
+}
 
-	grpcDone := make(chan struct{})
-	go func() {
-		defer close(grpcDone)
-		if err := s.grpcServer.Serve(s.grpcListener); err != nil {
-			select {
-			case <-ctx.Done():
-				// ctx 结束了错误就没所谓了
-				return
-			default:
-			}
-			logger.Error(err, "grpc serve error")
+// listenUnix 监听 Unix Socket
+// 路径上已存在 Socket 文件时（比如上次运行未正常退出残留的）先将其删除
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%q already exists and is not a socket", path)
 		}
-	}()
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket %q error: %w", path, err)
+		}
+	}
+	return net.Listen("unix", path)
+}
 
-	select {
-	case <-ctx.Done():
-	case <-cmuxDone:
-	case <-httpDone:
-	case <-grpcDone:
+// setReady 设置服务是否已就绪
+func (s *Server) setReady(ready bool) {
+	s.ready.Store(ready)
+	status := healthgrpc.HealthCheckResponse_NOT_SERVING
+	if ready {
+		status = healthgrpc.HealthCheckResponse_SERVING
+	}
+	for _, name := range []string{
+		"",
+		authnv1grpc.Authentication_ServiceDesc.ServiceName,
+		streamv1grpc.Streams_ServiceDesc.ServiceName,
+		agentv1grpc.Agents_ServiceDesc.ServiceName,
+	} {
+		s.grpcHealthServer.SetServingStatus(name, status)
 	}
 }
@@ -0,0 +1,1030 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	agentv1 "github.com/yhlooo/scaf/pkg/apis/agent/v1"
+	authnv1 "github.com/yhlooo/scaf/pkg/apis/authn/v1"
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	streamv1 "github.com/yhlooo/scaf/pkg/apis/stream/v1"
+	"github.com/yhlooo/scaf/pkg/auth"
+	clientsbench "github.com/yhlooo/scaf/pkg/clients/bench"
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	clientscp "github.com/yhlooo/scaf/pkg/clients/cp"
+	clientsexec "github.com/yhlooo/scaf/pkg/clients/exec"
+	"github.com/yhlooo/scaf/pkg/cluster"
+	"github.com/yhlooo/scaf/pkg/federation"
+	"github.com/yhlooo/scaf/pkg/streams"
+	"github.com/yhlooo/scaf/pkg/version"
+)
+
+// startTestServer 启动测试用的服务
+func startTestServer(t *testing.T, ctx context.Context, opts Options) *Server {
+	opts.ListenAddr = "127.0.0.1:0"
+	s := NewServer(opts)
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start server error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.Stop(context.Background())
+	})
+	return s
+}
+
+// newTestClient 创建访问测试服务的客户端
+func newTestClient(t *testing.T, scheme string, s *Server, token string) common.Client {
+	client, err := common.NewClient(common.ClientOptions{
+		Server: scheme + "://" + s.Address().String(),
+		Token:  token,
+	})
+	if err != nil {
+		t.Fatalf("create client error: %v", err)
+	}
+	return client
+}
+
+// receiveWithTimeout 带超时地从连接接收数据
+func receiveWithTimeout(ctx context.Context, conn streams.Connection, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		data []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		data, err := conn.Receive(ctx)
+		ch <- result{data: data, err: err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case ret := <-ch:
+		return ret.data, ret.err
+	}
+}
+
+// TestServer_Cluster 测试多个副本共享流
+func TestServer_Cluster(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := cluster.NewInMemoryStore()
+	authOpts := auth.TokenAuthenticatorOptions{Issuer: "scaf-test", SignKey: []byte("test-key")}
+	s1 := startTestServer(t, ctx, Options{
+		TokenAuthenticator: authOpts,
+		Cluster:            cluster.Options{Store: store},
+	})
+	s2 := startTestServer(t, ctx, Options{
+		TokenAuthenticator: authOpts,
+		Cluster:            cluster.Options{Store: store},
+	})
+	adminToken, err := s1.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+
+	// 在副本 1 创建流
+	stream, err := newTestClient(t, "grpc", s1, adminToken).CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+
+	// 在副本 2 可以获取和列出流
+	client2 := newTestClient(t, "grpc", s2, adminToken)
+	got, err := client2.GetStream(ctx, stream.Name)
+	if a.NoError(err) {
+		a.Equal(stream.UID, got.UID)
+	}
+	list, err := client2.ListStreams(ctx)
+	if a.NoError(err) && a.Len(list.Items, 1) {
+		a.Equal(stream.UID, list.Items[0].UID)
+	}
+
+	// 两端分别连接到不同副本，以及不同协议
+	connA, err := newTestClient(t, "grpc", s1, stream.Status.Token).
+		ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "a"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connA.Close(ctx)
+	}()
+	connB, err := newTestClient(t, "http", s2, stream.Status.Token).
+		ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "b"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connB.Close(ctx)
+	}()
+
+	a.NoError(connA.Send(ctx, []byte("hello from a")))
+	data, err := receiveWithTimeout(ctx, connB, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello from a", string(data))
+	}
+	a.NoError(connB.Send(ctx, []byte("hello from b")))
+	data, err = receiveWithTimeout(ctx, connA, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello from b", string(data))
+	}
+
+	// 在副本 2 删除流
+	a.NoError(client2.DeleteStream(ctx, stream.Name))
+	_, err = store.GetStream(ctx, stream.UID)
+	a.ErrorIs(err, streams.ErrStreamNotFound)
+
+	// 因停止策略停止的流的记录也被删除
+	stream, err = newTestClient(t, "grpc", s1, adminToken).CreateStream(ctx, &streamv1.Stream{
+		Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnFirstConnectionLeft},
+	})
+	if !a.NoError(err) {
+		return
+	}
+	connC, err := newTestClient(t, "http", s2, stream.Status.Token).
+		ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "c"})
+	if !a.NoError(err) {
+		return
+	}
+	a.NoError(connC.Send(ctx, []byte("hello")))
+	a.NoError(connC.Close(ctx))
+	a.Eventually(func() bool {
+		_, err := store.GetStream(ctx, stream.UID)
+		return errors.Is(err, streams.ErrStreamNotFound)
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = client2.GetStream(ctx, stream.Name)
+	a.Error(err)
+}
+
+// TestServer_Federation 测试通过联邦访问其它服务上的流
+func TestServer_Federation(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 区域 B 的服务
+	sB := startTestServer(t, ctx, Options{})
+	adminTokenB, err := sB.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+	adminClientB := newTestClient(t, "grpc", sB, adminTokenB)
+
+	// 只有管理员可以签发服务 Token
+	_, err = newTestClient(t, "grpc", sB, "").CreateToken(ctx, &authnv1.TokenRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: auth.ServiceUsername("region-a")},
+	})
+	a.Error(err)
+	serviceToken, err := adminClientB.CreateToken(ctx, &authnv1.TokenRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: auth.ServiceUsername("region-a")},
+	})
+	if !a.NoError(err) {
+		return
+	}
+
+	// 区域 A 的服务，信任区域 B
+	fed, err := federation.New(federation.Config{Peers: []federation.PeerConfig{{
+		Name:   "region-b",
+		Server: "grpc://" + sB.Address().String(),
+		Token:  serviceToken.Status.Token,
+	}}})
+	if !a.NoError(err) {
+		return
+	}
+	sA := startTestServer(t, ctx, Options{Federation: fed})
+
+	// 在区域 B 创建流
+	stream, err := adminClientB.CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+	federatedName := stream.Name + "@region-b"
+
+	// 没有区域 B 签发的 Token 不能访问
+	_, err = newTestClient(t, "http", sA, "").GetStream(ctx, federatedName)
+	a.Error(err)
+	// 未知对端
+	_, err = newTestClient(t, "http", sA, stream.Status.Token).GetStream(ctx, stream.Name+"@region-c")
+	a.Error(err)
+
+	clientA := newTestClient(t, "http", sA, stream.Status.Token)
+	got, err := clientA.GetStream(ctx, federatedName)
+	if a.NoError(err) {
+		a.Equal(federatedName, got.Name)
+		a.Equal(stream.UID, got.UID)
+	}
+
+	// 一端直接连接区域 B ，另一端通过区域 A 连接
+	connB, err := newTestClient(t, "grpc", sB, stream.Status.Token).
+		ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "b"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connB.Close(ctx)
+	}()
+	connA, err := clientA.ConnectStream(ctx, federatedName, common.ConnectStreamOptions{ConnectionName: "a"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connA.Close(ctx)
+	}()
+
+	a.NoError(connA.Send(ctx, []byte("hello from a")))
+	data, err := receiveWithTimeout(ctx, connB, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello from a", string(data))
+	}
+	a.NoError(connB.Send(ctx, []byte("hello from b")))
+	data, err = receiveWithTimeout(ctx, connA, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello from b", string(data))
+	}
+
+	// 再次获取时复用同一个中继流，已加入的连接不受影响
+	_, err = clientA.GetStream(ctx, federatedName)
+	a.NoError(err)
+	a.NoError(connA.Send(ctx, []byte("hello again")))
+	data, err = receiveWithTimeout(ctx, connB, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello again", string(data))
+	}
+
+	// 通过区域 A 删除流后中继流停止，连接被断开
+	a.NoError(clientA.DeleteStream(ctx, federatedName))
+	_, err = receiveWithTimeout(ctx, connA, 5*time.Second)
+	a.Error(err)
+	a.NotErrorIs(err, context.DeadlineExceeded)
+	_, err = clientA.GetStream(ctx, federatedName)
+	a.Error(err)
+}
+
+// TestServer_RawTransports 测试通过原始 TCP 和 Unix Socket 连接流
+func TestServer_RawTransports(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sockPath := filepath.Join(t.TempDir(), "scaf.sock")
+	s := startTestServer(t, ctx, Options{UnixSocket: sockPath})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+	stream, err := newTestClient(t, "tcp", s, adminToken).CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+
+	connA, err := newTestClient(t, "tcp", s, stream.Status.Token).
+		ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "a"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connA.Close(ctx)
+	}()
+	unixClient, err := common.NewClient(common.ClientOptions{Server: "unix://" + sockPath, Token: stream.Status.Token})
+	if !a.NoError(err) {
+		return
+	}
+	connB, err := unixClient.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "b"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connB.Close(ctx)
+	}()
+
+	a.NoError(connA.Send(ctx, []byte("hello from a")))
+	data, err := receiveWithTimeout(ctx, connB, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello from a", string(data))
+	}
+	// 空消息
+	a.NoError(connB.Send(ctx, []byte{}))
+	data, err = receiveWithTimeout(ctx, connA, 5*time.Second)
+	if a.NoError(err) {
+		a.Empty(data)
+	}
+
+	// 不存在的流
+	_, err = unixClient.ConnectStream(ctx, "not-exists", common.ConnectStreamOptions{ConnectionName: "c"})
+	a.Error(err)
+}
+
+// TestServer_QUIC 测试通过 QUIC 连接流
+func TestServer_QUIC(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := startTestServer(t, ctx, Options{QUICListenAddr: "127.0.0.1:0"})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+	// 默认校验服务端证书，自签名证书校验失败
+	quicClient, err := common.NewClient(common.ClientOptions{
+		Server: "quic://" + s.QUICAddress().String(),
+		Token:  adminToken,
+	})
+	if !a.NoError(err) {
+		return
+	}
+	_, err = quicClient.CreateStream(ctx, &streamv1.Stream{})
+	a.ErrorContains(err, "certificate")
+
+	quicClient, err = common.NewClient(common.ClientOptions{
+		Server:   "quic://" + s.QUICAddress().String(),
+		Token:    adminToken,
+		Insecure: true,
+	})
+	if !a.NoError(err) {
+		return
+	}
+	stream, err := quicClient.CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+
+	// 一端使用 QUIC ，另一端使用 gRPC
+	connA, err := quicClient.WithToken(stream.Status.Token).
+		ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "a"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connA.Close(ctx)
+	}()
+	connB, err := newTestClient(t, "grpc", s, stream.Status.Token).
+		ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "b"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connB.Close(ctx)
+	}()
+
+	a.NoError(connA.Send(ctx, []byte("hello from a")))
+	data, err := receiveWithTimeout(ctx, connB, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello from a", string(data))
+	}
+	a.NoError(connB.Send(ctx, []byte("hello from b")))
+	data, err = receiveWithTimeout(ctx, connA, 5*time.Second)
+	if a.NoError(err) {
+		a.Equal("hello from b", string(data))
+	}
+}
+
+// TestServer_Agents 测试注册代理和向代理派发请求
+func TestServer_Agents(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := startTestServer(t, ctx, Options{})
+	newUser := func(scheme string) common.Client {
+		ret, err := newTestClient(t, scheme, s, "").
+			CreateToken(ctx, &authnv1.TokenRequest{})
+		if err != nil {
+			t.Fatalf("create token error: %v", err)
+		}
+		return newTestClient(t, scheme, s, ret.Status.Token)
+	}
+	owner := newUser("grpc")
+	other := newUser("http")
+
+	// 注册代理
+	agent := &agentv1.Agent{Spec: agentv1.AgentSpec{Labels: map[string]string{"os": "linux"}}}
+	agent.Name = "box"
+	agentConn, err := owner.ConnectAgent(ctx, agent)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = agentConn.Close(ctx)
+	}()
+	a.Eventually(func() bool {
+		_, err := owner.GetAgent(ctx, "box")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	list, err := owner.ListAgents(ctx)
+	if a.NoError(err) && a.Len(list.Items, 1) {
+		a.Equal(map[string]string{"os": "linux"}, list.Items[0].Spec.Labels)
+	}
+
+	// 其它用户不可见，也不能注册同名代理
+	list, err = other.ListAgents(ctx)
+	if a.NoError(err) {
+		a.Empty(list.Items)
+	}
+	_, err = other.ConnectAgent(ctx, agent)
+	if status, ok := err.(*metav1.Status); a.True(ok, "unexpected error: %v", err) {
+		a.Equal(http.StatusConflict, status.Code)
+	}
+
+	// 派发请求
+	stream, err := owner.CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+	_, err = other.CreateDispatch(ctx, &agentv1.Dispatch{Spec: agentv1.DispatchSpec{
+		Agent: "box", Type: agentv1.DispatchExec, Stream: stream.Name,
+	}})
+	a.Error(err)
+	dispatch, err := owner.CreateDispatch(ctx, &agentv1.Dispatch{Spec: agentv1.DispatchSpec{
+		Agent: "box", Type: agentv1.DispatchExec, Stream: stream.Name,
+	}})
+	if !a.NoError(err) {
+		return
+	}
+	a.Empty(dispatch.Status.Token)
+	raw, err := receiveWithTimeout(ctx, agentConn, 5*time.Second)
+	if !a.NoError(err) {
+		return
+	}
+	received := &agentv1.Dispatch{}
+	if a.NoError(json.Unmarshal(raw, received)) {
+		a.Equal(dispatch.Name, received.Name)
+		a.Equal(dispatch.Spec, received.Spec)
+		// 代理可以使用收到的 token 访问流
+		_, err := newTestClient(t, "grpc", s, received.Status.Token).GetStream(ctx, stream.Name)
+		a.NoError(err)
+	}
+
+	// 连接断开后注销
+	a.NoError(agentConn.Close(ctx))
+	a.Eventually(func() bool {
+		_, err := owner.GetAgent(ctx, "box")
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+// TestServer_ClusterAgents 测试多个副本共享代理，在任一副本都可以获取、列出代理和向代理派发请求
+func TestServer_ClusterAgents(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	store := cluster.NewInMemoryStore()
+	authOpts := auth.TokenAuthenticatorOptions{Issuer: "scaf-test", SignKey: []byte("test-key")}
+	s1 := startTestServer(t, ctx, Options{
+		TokenAuthenticator: authOpts,
+		Cluster:            cluster.Options{Store: store},
+	})
+	s2 := startTestServer(t, ctx, Options{
+		TokenAuthenticator: authOpts,
+		Cluster:            cluster.Options{Store: store},
+	})
+	newToken := func() string {
+		ret, err := newTestClient(t, "grpc", s1, "").CreateToken(ctx, &authnv1.TokenRequest{})
+		if err != nil {
+			t.Fatalf("create token error: %v", err)
+		}
+		return ret.Status.Token
+	}
+	ownerToken, otherToken := newToken(), newToken()
+	owner1 := newTestClient(t, "grpc", s1, ownerToken)
+	owner2 := newTestClient(t, "grpc", s2, ownerToken)
+	other2 := newTestClient(t, "http", s2, otherToken)
+
+	// 代理注册到副本 1
+	agent := &agentv1.Agent{Spec: agentv1.AgentSpec{Labels: map[string]string{"os": "linux"}}}
+	agent.Name = "box"
+	agentConn, err := owner1.ConnectAgent(ctx, agent)
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = agentConn.Close(ctx)
+	}()
+
+	// 在副本 2 可以获取和列出代理
+	a.Eventually(func() bool {
+		_, err := owner2.GetAgent(ctx, "box")
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	list, err := owner2.ListAgents(ctx)
+	if a.NoError(err) && a.Len(list.Items, 1) {
+		a.Equal(map[string]string{"os": "linux"}, list.Items[0].Spec.Labels)
+	}
+
+	// 其它用户在副本 2 不可见，也不能注册同名代理
+	list, err = other2.ListAgents(ctx)
+	if a.NoError(err) {
+		a.Empty(list.Items)
+	}
+	_, err = other2.ConnectAgent(ctx, agent)
+	if status, ok := err.(*metav1.Status); a.True(ok, "unexpected error: %v", err) {
+		a.Equal(http.StatusConflict, status.Code)
+	}
+
+	// 在副本 2 派发请求，转发到代理所在的副本 1
+	stream, err := owner2.CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+	_, err = other2.CreateDispatch(ctx, &agentv1.Dispatch{Spec: agentv1.DispatchSpec{
+		Agent: "box", Type: agentv1.DispatchExec, Stream: stream.Name,
+	}})
+	a.Error(err)
+	dispatch, err := owner2.CreateDispatch(ctx, &agentv1.Dispatch{Spec: agentv1.DispatchSpec{
+		Agent: "box", Type: agentv1.DispatchExec, Stream: stream.Name,
+	}})
+	if !a.NoError(err) {
+		return
+	}
+	raw, err := receiveWithTimeout(ctx, agentConn, 5*time.Second)
+	if !a.NoError(err) {
+		return
+	}
+	received := &agentv1.Dispatch{}
+	if a.NoError(json.Unmarshal(raw, received)) {
+		a.Equal(dispatch.Name, received.Name)
+		a.Equal(dispatch.Spec, received.Spec)
+		// 代理可以使用收到的 token 在任一副本访问流
+		_, err := newTestClient(t, "grpc", s2, received.Status.Token).GetStream(ctx, stream.Name)
+		a.NoError(err)
+	}
+
+	// 连接断开后在所有副本注销
+	a.NoError(agentConn.Close(ctx))
+	a.Eventually(func() bool {
+		_, err := owner2.GetAgent(ctx, "box")
+		return err != nil
+	}, 5*time.Second, 50*time.Millisecond)
+	_, err = store.GetAgent(ctx, "box")
+	a.ErrorIs(err, cluster.ErrAgentNotFound)
+	list, err = owner2.ListAgents(ctx)
+	if a.NoError(err) {
+		a.Empty(list.Items)
+	}
+}
+
+// TestServer_ControlMessages 测试对端加入离开、流停止和加入失败时服务端发送的控制消息和错误消息
+func TestServer_ControlMessages(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := startTestServer(t, ctx, Options{})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+
+	for _, scheme := range []string{"grpc", "http", "tcp"} {
+		client := newTestClient(t, scheme, s, adminToken)
+		stream, err := client.CreateStream(ctx, &streamv1.Stream{
+			Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnDelete},
+		})
+		if !a.NoError(err, scheme) {
+			continue
+		}
+
+		connA, err := client.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "a"})
+		if !a.NoError(err, scheme) {
+			continue
+		}
+
+		// 对端还未加入
+		waitCtx, waitCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		a.ErrorIs(streams.WaitForPeer(waitCtx, connA), context.DeadlineExceeded, scheme)
+		waitCancel()
+
+		connB, err := client.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "b"})
+		if !a.NoError(err, scheme) {
+			_ = connA.Close(ctx)
+			continue
+		}
+		waitCtx, waitCancel = context.WithTimeout(ctx, 5*time.Second)
+		a.NoError(streams.WaitForPeer(waitCtx, connA), scheme)
+		a.NoError(streams.WaitForPeer(waitCtx, connB), scheme)
+		waitCancel()
+
+		// 控制消息不会作为数据返回
+		a.NoError(connA.Send(ctx, []byte("hello")), scheme)
+		data, err := receiveWithTimeout(ctx, connB, 5*time.Second)
+		if a.NoError(err, scheme) {
+			a.Equal("hello", string(data), scheme)
+		}
+
+		// 流满员时加入失败的原因作为错误返回
+		connC, err := client.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "c"})
+		if a.NoError(err, scheme) {
+			_, err = receiveWithTimeout(ctx, connC, 5*time.Second)
+			if a.Error(err, scheme) {
+				a.Contains(err.Error(), streams.ErrStreamIsFull.Error(), scheme)
+			}
+			_ = connC.Close(ctx)
+		}
+
+		// 对端离开
+		a.NoError(connB.Close(ctx), scheme)
+		msgCh := make(chan streams.Message, 1)
+		go func() {
+			// 跳过之前的对端加入消息
+			for {
+				msg, err := streams.ReceiveMessage(ctx, connA)
+				if err != nil || msg.Control == nil || msg.Control.Type != streams.PeerJoinedControl {
+					msgCh <- msg
+					return
+				}
+			}
+		}()
+		select {
+		case msg := <-msgCh:
+			if a.NotNil(msg.Control, scheme) {
+				a.Equal(streams.PeerLeftControl, msg.Control.Type, scheme)
+			}
+		case <-time.After(5 * time.Second):
+			a.Fail("wait for peer left timeout", scheme)
+		}
+
+		// 流停止
+		a.NoError(client.DeleteStream(ctx, stream.Name), scheme)
+		_, err = receiveWithTimeout(ctx, connA, 5*time.Second)
+		a.ErrorIs(err, streams.ErrStreamAlreadyStopped, scheme)
+		_ = connA.Close(ctx)
+	}
+}
+
+// TestServer_CopyFiles 测试通过流发送和接收文件，文件内容不会被误认为是传输指令
+func TestServer_CopyFiles(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s := startTestServer(t, ctx, Options{})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	a.NoError(os.Mkdir(src, 0o755))
+	files := map[string]string{
+		"a.txt": "SendCompleted",
+		"b.txt": "ReceivingCompleted",
+		"c.txt": string(bytes.Repeat([]byte("StartReceiving"), 10000)),
+	}
+	for name, content := range files {
+		a.NoError(os.WriteFile(filepath.Join(src, name), []byte(content), 0o644))
+	}
+
+	for _, scheme := range []string{"grpc", "http", "tcp"} {
+		client := clientscp.New(newTestClient(t, scheme, s, adminToken))
+		stream, err := client.Client().CreateStream(ctx, &streamv1.Stream{})
+		if !a.NoError(err, scheme) {
+			continue
+		}
+
+		dst := filepath.Join(t.TempDir(), "dst")
+		sendErr := make(chan error, 1)
+		go func() {
+			sendErr <- client.Send(ctx, stream, src, clientscp.SendOptions{})
+		}()
+		target, err := client.Receive(ctx, stream, dst, clientscp.ReceiveOptions{})
+		a.NoError(err, scheme)
+		a.NoError(<-sendErr, scheme)
+		a.Equal(dst, target, scheme)
+		for name, content := range files {
+			data, err := os.ReadFile(filepath.Join(dst, name))
+			if a.NoError(err, scheme) {
+				a.Equal(content, string(data), scheme)
+			}
+		}
+	}
+}
+
+// TestServer_CopyFilesToLegacyReceiver 测试向不发送接收端信息帧的旧版本接收端发送文件
+func TestServer_CopyFilesToLegacyReceiver(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s := startTestServer(t, ctx, Options{})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	a.NoError(os.Mkdir(src, 0o755))
+	a.NoError(os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0o644))
+
+	client := clientscp.New(newTestClient(t, "grpc", s, adminToken))
+	stream, err := client.Client().CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- client.Send(ctx, stream, src, clientscp.SendOptions{Compression: clientscp.CompressionAuto})
+	}()
+
+	// 旧版本的接收端先发送旧格式的接收端信息和开始指令，接收不带帧类型的数据直到结束指令
+	conn, err := client.Client().ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "receiver"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = conn.Close(ctx)
+	}()
+	a.NoError(conn.Send(ctx, []byte("\x00scaf-transfer:{\"compressions\":[\"gzip\"]}")))
+	a.NoError(conn.Send(ctx, []byte("StartReceiving")))
+	data := &bytes.Buffer{}
+	for {
+		msg, err := receiveWithTimeout(ctx, conn, 5*time.Second)
+		if !a.NoError(err) {
+			return
+		}
+		if string(msg) == "SendCompleted" {
+			break
+		}
+		data.Write(msg)
+	}
+	a.NoError(conn.Send(ctx, []byte("ReceivingCompleted")))
+	a.NoError(<-sendErr)
+
+	r := tar.NewReader(data)
+	files := map[string]string{}
+	for {
+		hdr, err := r.Next()
+		if err != nil {
+			a.Equal(io.EOF, err)
+			break
+		}
+		content, err := io.ReadAll(r)
+		a.NoError(err)
+		files[hdr.Name] = string(content)
+	}
+	a.Equal("hello", files["src/a.txt"])
+}
+
+// TestServer_HealthAndVersion 测试存活检查、就绪检查和版本信息接口
+func TestServer_HealthAndVersion(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s := startTestServer(t, ctx, Options{})
+	baseURL := "http://" + s.Address().String()
+	get := func(path string) (int, []byte) {
+		resp, err := http.Get(baseURL + path)
+		if !a.NoError(err, path) {
+			return 0, nil
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		a.NoError(err, path)
+		return resp.StatusCode, body
+	}
+
+	code, _ := get("/healthz")
+	a.Equal(http.StatusOK, code)
+
+	// 启动完成后就绪
+	a.Eventually(func() bool {
+		code, _ := get("/readyz")
+		return code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 未就绪时就绪检查失败，存活检查不受影响，恢复就绪后就绪检查成功
+	s.setReady(false)
+	a.False(s.Ready())
+	code, _ = get("/readyz")
+	a.Equal(http.StatusServiceUnavailable, code)
+	code, _ = get("/healthz")
+	a.Equal(http.StatusOK, code)
+	s.setReady(true)
+	a.True(s.Ready())
+	code, _ = get("/readyz")
+	a.Equal(http.StatusOK, code)
+
+	code, body := get("/version")
+	a.Equal(http.StatusOK, code)
+	ret := version.Version{}
+	if a.NoError(json.Unmarshal(body, &ret)) {
+		a.Equal(version.GetVersion(), ret)
+	}
+}
+
+// TestServer_SignalStream 测试终端连接着流时向流中执行的命令发送信号
+func TestServer_SignalStream(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	s := startTestServer(t, ctx, Options{})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+
+	for _, scheme := range []string{"grpc", "http"} {
+		client := newTestClient(t, scheme, s, adminToken)
+		stream, err := client.CreateStream(ctx, clientsexec.NewExecStream(clientsexec.ExecSpec{
+			Command: []string{"sh", "-c", "echo ready; sleep 30"},
+		}))
+		if !a.NoError(err, scheme) {
+			continue
+		}
+
+		// 没有连接时无法发送信号
+		err = clientsexec.SendSignal(ctx, client, stream.Name, "TERM")
+		var status *metav1.Status
+		if a.ErrorAs(err, &status, scheme) {
+			a.Equal(http.StatusConflict, status.Code, scheme)
+		}
+
+		agentDone := make(chan error, 1)
+		go func() {
+			agentDone <- clientsexec.NewAgent(client).Run(ctx, stream)
+		}()
+		stdin, stdinW := io.Pipe()
+		stdout, stdoutW := io.Pipe()
+		stderr := &bytes.Buffer{}
+		termDone := make(chan error, 1)
+		go func() {
+			termDone <- clientsexec.NewTerminal(client).Run(ctx, stream, stdin, stdoutW, stderr)
+		}()
+
+		// 等待命令开始运行
+		buf := make([]byte, len("ready\n"))
+		if _, err := io.ReadFull(stdout, buf); !a.NoError(err, scheme) {
+			_ = stdinW.Close()
+			continue
+		}
+		a.Equal("ready\n", string(buf), scheme)
+
+		// 终端连接着流时也可以发送信号
+		a.NoError(clientsexec.SendSignal(ctx, client, stream.Name, "TERM"), scheme)
+		select {
+		case err := <-termDone:
+			a.NoError(err, scheme)
+		case <-time.After(10 * time.Second):
+			a.Fail("terminal not exited", scheme)
+		}
+		a.Equal("command killed by signal: SIGTERM\n", stderr.String(), scheme)
+		// 命令被信号结束，代理返回命令的错误
+		select {
+		case err := <-agentDone:
+			a.Error(err, scheme)
+		case <-time.After(10 * time.Second):
+			a.Fail("agent not exited", scheme)
+		}
+		_ = stdinW.Close()
+	}
+}
+
+// TestServer_FlowControl 测试对端还未接收时服务端在额度用尽后暂停接收，对端接收后数据完整且额度被归还
+func TestServer_FlowControl(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const packageSize = 32 << 10
+	const count = 8
+	s := startTestServer(t, ctx, Options{
+		FlowControl: &streams.FlowControlOptions{
+			StreamWindow:     count * packageSize,
+			MaxInFlightBytes: 2 * packageSize,
+		},
+	})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+	client := newTestClient(t, "grpc", s, adminToken)
+
+	stream, err := client.CreateStream(ctx, &streamv1.Stream{
+		Spec: streamv1.StreamSpec{StopPolicy: streamv1.OnDelete},
+	})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = client.DeleteStream(context.Background(), stream.Name)
+	}()
+	connA, err := client.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "a"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connA.Close(context.Background())
+	}()
+
+	// 接收端还未加入，数据在服务端排队，超过全局额度后暂停从发送端接收
+	sendDone := make(chan error, 1)
+	go func() {
+		for i := 0; i < count; i++ {
+			if err := connA.Send(ctx, bytes.Repeat([]byte{byte('a' + i)}, packageSize)); err != nil {
+				sendDone <- err
+				return
+			}
+		}
+		sendDone <- nil
+	}()
+	a.Eventually(func() bool {
+		stats := s.flowScheduler.Stats()
+		return stats.Throttled > 0 && stats.InFlightBytes == stats.Limit
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// 接收端加入后按顺序收到所有数据
+	connB, err := client.ConnectStream(ctx, stream.Name, common.ConnectStreamOptions{ConnectionName: "b"})
+	if !a.NoError(err) {
+		return
+	}
+	defer func() {
+		_ = connB.Close(context.Background())
+	}()
+	for i := 0; i < count; i++ {
+		data, err := receiveWithTimeout(ctx, connB, 5*time.Second)
+		if !a.NoError(err) {
+			return
+		}
+		a.Equal(bytes.Repeat([]byte{byte('a' + i)}, packageSize), data, i)
+	}
+	a.NoError(<-sendDone)
+
+	stats := s.flowScheduler.Stats()
+	a.Equal(int64(2*packageSize), stats.MaxInFlightBytes)
+	a.Eventually(func() bool {
+		return s.flowScheduler.Stats().InFlightBytes == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestServer_FlowControlStress 使用基准测试客户端压测流控
+func TestServer_FlowControlStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip stress test in short mode")
+	}
+
+	a := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 额度远小于并行的流同时发送的数据量
+	s := startTestServer(t, ctx, Options{
+		FlowControl: &streams.FlowControlOptions{
+			StreamWindow:     64 << 10,
+			MaxInFlightBytes: 256 << 10,
+		},
+	})
+	adminToken, err := s.AdminToken()
+	if !a.NoError(err) {
+		return
+	}
+	client := newTestClient(t, "grpc", s, adminToken)
+
+	stream, err := client.CreateStream(ctx, &streamv1.Stream{})
+	if !a.NoError(err) {
+		return
+	}
+	serverCtx, stopServer := context.WithCancel(ctx)
+	serverDone := make(chan error, 1)
+	go func() {
+		serverDone <- clientsbench.NewServer(client).Serve(serverCtx, stream)
+	}()
+
+	runOpts := clientsbench.NewDefaultRunOptions()
+	runOpts.Duration = time.Second
+	runOpts.PackageSize = 32 << 10
+	runOpts.Parallel = 8
+	report, err := clientsbench.NewClient(client).Run(ctx, stream, runOpts)
+	stopServer()
+	a.NoError(<-serverDone)
+	if !a.NoError(err) {
+		return
+	}
+	a.NotZero(report.WriteOnly.Packages)
+	a.NotZero(report.ReadOnly.Packages)
+	a.NotZero(report.ReadWrite.Write.Packages)
+
+	stats := s.flowScheduler.Stats()
+	a.LessOrEqual(stats.MaxInFlightBytes, stats.Limit)
+
+	// 流删除后队列中的数据占用的额度都被归还
+	a.NoError(client.DeleteStream(ctx, stream.Name))
+	a.Eventually(func() bool {
+		return s.flowScheduler.Stats().InFlightBytes == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,84 @@
+package streams
+
+import (
+	"io"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// bufferPoolMinSize 缓冲区池中最小的缓冲区大小
+	bufferPoolMinSize = 512
+	// bufferPoolLevels 缓冲区池的级数，第 i 级缓冲区的容量为 bufferPoolMinSize << i
+	bufferPoolLevels = 12
+	// bufferPoolMaxSize 缓冲区池中最大的缓冲区大小，更大的缓冲区直接分配
+	bufferPoolMaxSize = bufferPoolMinSize << (bufferPoolLevels - 1) // 1MiB
+)
+
+// bufferPools 按大小分级的缓冲区池
+var bufferPools [bufferPoolLevels]sync.Pool
+
+// fastPathDisabled 是否禁用转发的快速路径，包括缓冲区复用、批量发送和 splice 转发
+// 仅用于在基准测试中对比
+var fastPathDisabled atomic.Bool
+
+// bufferPoolIndex 返回容纳 n 字节的缓冲区所在的级别，超过最大级别时返回 -1
+func bufferPoolIndex(n int) int {
+	if n > bufferPoolMaxSize {
+		return -1
+	}
+	if n <= bufferPoolMinSize {
+		return 0
+	}
+	return bits.Len(uint(n-1)) - bits.Len(bufferPoolMinSize-1)
+}
+
+// getBuffer 获取长度为 n 的缓冲区，用完后可以通过 putBuffer 归还
+//
+// 连接接收数据时使用池中的缓冲区，数据被流转发后归还，其它情况下调用方持有的数据不归还也不影响正确性
+func getBuffer(n int) []byte {
+	i := bufferPoolIndex(n)
+	if i < 0 || fastPathDisabled.Load() {
+		return make([]byte, n)
+	}
+	if b, ok := bufferPools[i].Get().(*[]byte); ok {
+		return (*b)[:n]
+	}
+	return make([]byte, n, bufferPoolMinSize<<i)
+}
+
+// putBuffer 归还缓冲区，归还后调用方不能再使用 b
+// 容量不是池中缓冲区大小的缓冲区会被丢弃
+func putBuffer(b []byte) {
+	c := cap(b)
+	i := bufferPoolIndex(c)
+	if i < 0 || c != bufferPoolMinSize<<i || fastPathDisabled.Load() {
+		return
+	}
+	b = b[:0]
+	bufferPools[i].Put(&b)
+}
+
+// readAllPooled 使用池中的缓冲区读取 r 中所有数据
+func readAllPooled(r io.Reader) ([]byte, error) {
+	b := getBuffer(bufferPoolMinSize)[:0]
+	for {
+		if len(b) == cap(b) {
+			// 扩容到下一级缓冲区
+			grown := getBuffer(2 * cap(b))[:len(b)]
+			copy(grown, b)
+			putBuffer(b)
+			b = grown
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			putBuffer(b)
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// NewControlAwareConnection 创建 ControlAwareConnection
+// protocolVersion 返回与服务端协商的协议版本，可能阻塞直到得知结果
+func NewControlAwareConnection(conn Connection, protocolVersion func() int) *ControlAwareConnection {
+	return &ControlAwareConnection{
+		Connection:      conn,
+		protocolVersion: protocolVersion,
+		versionReady:    make(chan struct{}),
+		stateCh:         make(chan struct{}),
+	}
+}
+
+// ControlAwareConnection 处理服务端控制消息的客户端连接
+//
+// 从底层连接收到的控制消息用于跟踪对端和流的状态，不会被 Receive 返回；
+// 错误消息作为 Receive 的错误返回。流停止或服务端停止后连接断开时，返回的错误分别包含
+// ErrStreamAlreadyStopped 和 ErrServerDraining 。
+// WaitForPeer 等待期间收到的数据会被暂存，之后由 Receive 按顺序返回。
+type ControlAwareConnection struct {
+	Connection
+
+	protocolVersion func() int
+	versionOnce     sync.Once
+	versionReady    chan struct{}
+	version         int
+
+	lock sync.Mutex
+	// 已接收但未被取走的消息
+	pending []Message
+	// 正在进行的接收，完成时关闭，没有正在进行的接收时为 nil
+	readDone chan struct{}
+	readErr  error
+	// 对端是否在流中
+	peerJoined bool
+	// 收到的流或服务端即将停止的原因
+	stopReason error
+	// 对端状态变化时关闭并替换
+	stateCh chan struct{}
+}
+
+var _ Connection = (*ControlAwareConnection)(nil)
+var _ MessageReceiver = (*ControlAwareConnection)(nil)
+var _ PeerWaiter = (*ControlAwareConnection)(nil)
+
+// Receive 接收数据，跳过控制消息
+func (conn *ControlAwareConnection) Receive(ctx context.Context) ([]byte, error) {
+	return receiveData(ctx, conn.ReceiveMessage)
+}
+
+// ReceiveMessage 接收消息
+func (conn *ControlAwareConnection) ReceiveMessage(ctx context.Context) (Message, error) {
+	for {
+		conn.lock.Lock()
+		if len(conn.pending) > 0 {
+			msg := conn.pending[0]
+			conn.pending[0] = Message{}
+			conn.pending = conn.pending[1:]
+			conn.lock.Unlock()
+			return msg, nil
+		}
+		if conn.readErr != nil {
+			err := conn.readErr
+			conn.lock.Unlock()
+			return Message{}, err
+		}
+		if conn.readDone != nil {
+			// WaitForPeer 发起的接收还未完成，等待其结果
+			readDone := conn.readDone
+			conn.lock.Unlock()
+			select {
+			case <-ctx.Done():
+				return Message{}, ctx.Err()
+			case <-readDone:
+			}
+			continue
+		}
+		conn.readDone = make(chan struct{})
+		conn.lock.Unlock()
+
+		// 没有其它接收时直接在当前协程接收，与底层连接的 Receive 行为一致
+		conn.read(ctx)
+	}
+}
+
+// WaitForPeer 等待对端加入流
+func (conn *ControlAwareConnection) WaitForPeer(ctx context.Context) error {
+	conn.versionOnce.Do(func() {
+		go func() {
+			conn.version = conn.protocolVersion()
+			close(conn.versionReady)
+		}()
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.versionReady:
+	}
+	if conn.version < 1 {
+		return ErrControlNotSupported
+	}
+
+	for {
+		conn.lock.Lock()
+		if conn.peerJoined {
+			conn.lock.Unlock()
+			return nil
+		}
+		if conn.readErr != nil {
+			err := conn.readErr
+			conn.lock.Unlock()
+			return fmt.Errorf("connection closed before peer joined: %w", err)
+		}
+		if conn.readDone == nil {
+			// 在后台接收，使等待可以被 ctx 取消，收到的数据暂存起来
+			conn.readDone = make(chan struct{})
+			go conn.read(context.WithoutCancel(ctx))
+		}
+		stateCh := conn.stateCh
+		readDone := conn.readDone
+		conn.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stateCh:
+		case <-readDone:
+		}
+	}
+}
+
+// PeerJoined 返回根据已收到的控制消息，对端是否在流中
+func (conn *ControlAwareConnection) PeerJoined() bool {
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	return conn.peerJoined
+}
+
+// read 从底层连接接收一条消息，更新状态并暂存
+// 调用前需设置 readDone
+func (conn *ControlAwareConnection) read(ctx context.Context) {
+	msg, err := ReceiveMessage(ctx, conn.Connection)
+
+	conn.lock.Lock()
+	defer conn.lock.Unlock()
+	switch {
+	case err != nil:
+		if conn.stopReason != nil {
+			err = fmt.Errorf("%w: %w", conn.stopReason, err)
+		}
+		conn.readErr = err
+	case msg.Error != nil:
+		// 服务端发送错误消息后会断开连接，之后的接收都返回该错误
+		conn.readErr = msg.Error
+	case msg.Control != nil:
+		switch msg.Control.Type {
+		case PeerJoinedControl, PeerLeftControl:
+			if joined := msg.Control.Type == PeerJoinedControl; joined != conn.peerJoined {
+				conn.peerJoined = joined
+				close(conn.stateCh)
+				conn.stateCh = make(chan struct{})
+			}
+		case StreamStoppingControl:
+			conn.stopReason = ErrStreamAlreadyStopped
+		case ServerDrainingControl:
+			conn.stopReason = ErrServerDraining
+		}
+		conn.pending = append(conn.pending, msg)
+	default:
+		conn.pending = append(conn.pending, msg)
+	}
+	close(conn.readDone)
+	conn.readDone = nil
+}
@@ -2,9 +2,11 @@ package streams
 
 import (
 	"context"
-	"fmt"
 	"sync"
+	"sync/atomic"
 
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+	metav1grpc "github.com/yhlooo/scaf/pkg/apis/meta/v1/grpc"
 	streamv1grpc "github.com/yhlooo/scaf/pkg/apis/stream/v1/grpc"
 )
 
@@ -20,13 +22,18 @@ func NewGRPCStreamClientConnection(
 }
 
 // GRPCStreamClientConnection 是 Connection 的基于 gRPC 流客户端的实现
+//
+// 保活由 gRPC 客户端的 keepalive 参数在 HTTP/2 连接层面实现
 type GRPCStreamClientConnection struct {
-	name     string
-	client   streamv1grpc.Streams_ConnectStreamClient
-	closeErr error
+	name   string
+	client streamv1grpc.Streams_ConnectStreamClient
+	// gRPC 流不允许并发调用 SendMsg ，也不允许与 CloseSend 并发调用
+	sendLock   sync.Mutex
+	closeState closeState
 }
 
 var _ Connection = (*GRPCStreamClientConnection)(nil)
+var _ MessageReceiver = (*GRPCStreamClientConnection)(nil)
 
 // Name 返回连接名
 func (conn *GRPCStreamClientConnection) Name() string {
@@ -35,39 +42,65 @@ func (conn *GRPCStreamClientConnection) Name() string {
 
 // Send 发送
 func (conn *GRPCStreamClientConnection) Send(_ context.Context, data []byte) error {
-	if conn.closeErr != nil {
-		return conn.closeErr
+	if err := conn.closeState.get(); err != nil {
+		return err
 	}
 
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
 	err := conn.client.Send(&streamv1grpc.Package{
-		Content: data,
+		Payload: &streamv1grpc.Package_Content{Content: data},
 	})
 	if err != nil {
-		conn.closeErr = fmt.Errorf("%w: %s", ErrConnectionClosed, err.Error())
+		err = conn.closeState.set(err)
 		_ = conn.client.CloseSend()
-		return conn.closeErr
+		return err
 	}
 	return nil
 }
 
-// Receive 接收
-func (conn *GRPCStreamClientConnection) Receive(_ context.Context) ([]byte, error) {
-	if conn.closeErr != nil {
-		return nil, conn.closeErr
+// Receive 接收数据，跳过控制消息，错误消息作为错误返回
+func (conn *GRPCStreamClientConnection) Receive(ctx context.Context) ([]byte, error) {
+	return receiveData(ctx, conn.ReceiveMessage)
+}
+
+// ReceiveMessage 接收消息
+func (conn *GRPCStreamClientConnection) ReceiveMessage(_ context.Context) (Message, error) {
+	if err := conn.closeState.get(); err != nil {
+		return Message{}, err
 	}
 
 	msg, err := conn.client.Recv()
 	if err != nil {
-		conn.closeErr = fmt.Errorf("%w: %s", ErrConnectionClosed, err.Error())
+		err = conn.closeState.set(err)
+		conn.sendLock.Lock()
 		_ = conn.client.CloseSend()
-		return nil, conn.closeErr
+		conn.sendLock.Unlock()
+		return Message{}, err
+	}
+	switch payload := msg.GetPayload().(type) {
+	case *streamv1grpc.Package_Control:
+		return Message{Control: &Control{
+			Type:            ControlType(payload.Control.GetType()),
+			WindowIncrement: payload.Control.GetWindowIncrement(),
+			Signal:          payload.Control.GetSignal(),
+		}}, nil
+	case *streamv1grpc.Package_Error:
+		return Message{Error: &metav1.Status{
+			Code:    int(payload.Error.GetCode()),
+			Reason:  payload.Error.GetReason(),
+			Message: payload.Error.GetMessage(),
+		}}, nil
+	default:
+		return Message{Data: msg.GetContent()}, nil
 	}
-	return msg.GetContent(), nil
 }
 
 // Close 关闭连接
 func (conn *GRPCStreamClientConnection) Close(_ context.Context) error {
-	conn.closeErr = ErrConnectionClosed
+	_ = conn.closeState.set(ErrConnectionClosed)
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
 	return conn.client.CloseSend()
 }
 
@@ -79,20 +112,23 @@ func NewGRPCStreamServerConnection(
 	return &GRPCStreamServerConnection{
 		name:   name,
 		server: server,
-		done:   make(chan struct{}),
 	}
 }
 
 // GRPCStreamServerConnection 是 Connection 的基于 gRPC 流服务端的实现
+//
+// 保活由 gRPC 服务端的 keepalive 参数在 HTTP/2 连接层面实现，连接失联时 Recv 返回错误
 type GRPCStreamServerConnection struct {
-	name      string
-	server    streamv1grpc.Streams_ConnectStreamServer
-	closeErr  error
-	done      chan struct{}
-	closeOnce sync.Once
+	name    string
+	server  streamv1grpc.Streams_ConnectStreamServer
+	version atomic.Int32
+	// gRPC 流不允许并发调用 SendMsg
+	sendLock   sync.Mutex
+	closeState closeState
 }
 
 var _ Connection = (*GRPCStreamServerConnection)(nil)
+var _ ControlSender = (*GRPCStreamServerConnection)(nil)
 
 // Name 返回连接名
 func (conn *GRPCStreamServerConnection) Name() string {
@@ -101,46 +137,87 @@ func (conn *GRPCStreamServerConnection) Name() string {
 
 // Send 发送
 func (conn *GRPCStreamServerConnection) Send(_ context.Context, data []byte) error {
-	if conn.closeErr != nil {
-		return conn.closeErr
+	if err := conn.closeState.get(); err != nil {
+		return err
 	}
 
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
 	err := conn.server.Send(&streamv1grpc.Package{
-		Content: data,
+		Payload: &streamv1grpc.Package_Content{Content: data},
 	})
 	if err != nil {
-		conn.closeErr = fmt.Errorf("%w: %s", ErrConnectionClosed, err.Error())
-		return conn.closeErr
+		return conn.closeState.set(err)
 	}
 	return nil
 }
 
 // Receive 接收
 func (conn *GRPCStreamServerConnection) Receive(_ context.Context) ([]byte, error) {
-	if conn.closeErr != nil {
-		return nil, conn.closeErr
+	if err := conn.closeState.get(); err != nil {
+		return nil, err
 	}
 
 	msg, err := conn.server.Recv()
 	if err != nil {
-		conn.closeErr = fmt.Errorf("%w: %s", ErrConnectionClosed, err.Error())
-		return nil, conn.closeErr
+		return nil, conn.closeState.set(err)
 	}
 	return msg.GetContent(), nil
 }
 
+// SetProtocolVersion 设置与客户端协商的协议版本
+// 服务端在连接建立时调用，版本不低于 1 时 SendControl 和 SendError 才会真正发送，
+// 避免旧客户端把控制消息当作空数据
+func (conn *GRPCStreamServerConnection) SetProtocolVersion(version int) {
+	conn.version.Store(int32(version))
+}
+
+// SendControl 发送控制消息，协商的协议版本不支持时忽略
+func (conn *GRPCStreamServerConnection) SendControl(_ context.Context, control Control) error {
+	return conn.sendPackage(&streamv1grpc.Package{
+		Payload: &streamv1grpc.Package_Control{Control: &streamv1grpc.Control{
+			Type:            string(control.Type),
+			WindowIncrement: control.WindowIncrement,
+			Signal:          control.Signal,
+		}},
+	})
+}
+
+// SendError 发送错误消息，协商的协议版本不支持时忽略
+func (conn *GRPCStreamServerConnection) SendError(_ context.Context, status *metav1.Status) error {
+	return conn.sendPackage(&streamv1grpc.Package{
+		Payload: &streamv1grpc.Package_Error{Error: &metav1grpc.Status{
+			Code:    int32(status.Code),
+			Reason:  status.Reason,
+			Message: status.Message,
+		}},
+	})
+}
+
+// sendPackage 发送控制消息或错误消息的包
+func (conn *GRPCStreamServerConnection) sendPackage(pkg *streamv1grpc.Package) error {
+	if conn.version.Load() < 1 {
+		return nil
+	}
+	if err := conn.closeState.get(); err != nil {
+		return err
+	}
+
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+	if err := conn.server.Send(pkg); err != nil {
+		return conn.closeState.set(err)
+	}
+	return nil
+}
+
 // Done 返回完成 channel
 func (conn *GRPCStreamServerConnection) Done() <-chan struct{} {
-	return conn.done
+	return conn.closeState.doneCh()
 }
 
 // Close 关闭连接
 func (conn *GRPCStreamServerConnection) Close(_ context.Context) error {
-	conn.closeOnce.Do(func() {
-		conn.closeErr = ErrConnectionClosed
-		if conn.done != nil {
-			close(conn.done)
-		}
-	})
+	_ = conn.closeState.set(ErrConnectionClosed)
 	return nil
 }
@@ -0,0 +1,417 @@
+package streams
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+)
+
+const (
+	rawConnectionHeaderSize   = 4
+	rawConnectionMaxFrameSize = 64 << 20 // 64MiB
+
+	// 心跳帧和心跳响应帧使用的长度值，远大于 rawConnectionMaxFrameSize ，不会与数据帧冲突
+	rawConnectionPingFrame uint32 = 0xFFFFFFFF
+	rawConnectionPongFrame uint32 = 0xFFFFFFFE
+	// 控制消息帧使用的长度值，其后是一个普通帧，内容是 JSON 编码的控制消息或错误消息
+	rawConnectionEnvelopeFrame uint32 = 0xFFFFFFFD
+
+	// rawConnectionSpliceChunkSize splice 转发时每次转发的最大字节数，每次转发前重置读超时
+	rawConnectionSpliceChunkSize = 1 << 20 // 1MiB
+)
+
+// NewRawConnection 创建 RawConnection
+// r 是从 conn 读数据的 Reader ，用于传入已缓冲了部分数据的 Reader ，为 nil 时直接从 conn 读
+func NewRawConnection(name string, conn net.Conn, r io.Reader, keepalive KeepaliveOptions) *RawConnection {
+	rawConn := &RawConnection{
+		name: name,
+		conn: conn,
+	}
+	if r == nil {
+		r = conn
+	}
+	if br, ok := r.(*bufio.Reader); ok {
+		rawConn.br = br
+	}
+	switch conn.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		// 只有从这两种连接读取时 Go 才会使用 splice ，且需绕过缓冲读取，见 spliceTo
+		if rawConn.br != nil || r == conn {
+			rawConn.spliceSrc = conn
+		}
+	}
+	if keepalive.Enabled() {
+		r = &idleTimeoutReader{r: r, conn: conn, timeout: keepalive.idleTimeout()}
+		rawConn.idleTimeout = keepalive.idleTimeout()
+	}
+	rawConn.r = r
+	if keepalive.Enabled() {
+		go runKeepalive(keepalive.Interval, rawConn.closeState.doneCh(), func() error {
+			return rawConn.sendControlFrame(rawConnectionPingFrame)
+		})
+	}
+	return rawConn
+}
+
+// RawConnection 是 Connection 的基于原始字节流（如 TCP 、 Unix Socket ）的实现
+//
+// 每条消息编码为一帧： length(uint32) data([]byte)
+// 启用保活时两端定期发送只有长度字段的心跳帧，对端回复心跳响应帧，这两种帧不会被 Receive 返回。
+// 协商的协议版本不低于 1 时，服务端以控制消息帧发送控制消息和错误消息，由 ReceiveMessage 返回
+type RawConnection struct {
+	name string
+	conn net.Conn
+	r    io.Reader
+	// r 使用的缓冲读取器，没有时为 nil
+	br *bufio.Reader
+	// 可以作为 splice 数据源的底层连接，不可以时为 nil
+	spliceSrc net.Conn
+	// 启用保活时没有读到数据的超时时间
+	idleTimeout time.Duration
+
+	sendLock sync.Mutex
+	recvLock sync.Mutex
+	recvHdr  [rawConnectionHeaderSize]byte
+
+	pongPending atomic.Bool
+	version     atomic.Int32
+	closeState  closeState
+}
+
+var _ Connection = (*RawConnection)(nil)
+var _ ControlSender = (*RawConnection)(nil)
+var _ MessageReceiver = (*RawConnection)(nil)
+var _ BatchSender = (*RawConnection)(nil)
+
+// Name 返回连接名
+func (conn *RawConnection) Name() string {
+	return conn.name
+}
+
+// Send 发送
+func (conn *RawConnection) Send(_ context.Context, data []byte) error {
+	if err := conn.getCloseErr(); err != nil {
+		return err
+	}
+	if len(data) > rawConnectionMaxFrameSize {
+		return fmt.Errorf("data too large: %d (max: %d)", len(data), rawConnectionMaxFrameSize)
+	}
+
+	hdr := make([]byte, rawConnectionHeaderSize)
+	binary.BigEndian.PutUint32(hdr, uint32(len(data)))
+	buffs := net.Buffers{hdr, data}
+
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+	if _, err := buffs.WriteTo(conn.conn); err != nil {
+		return conn.setCloseErr(err)
+	}
+	return nil
+}
+
+// SendBatch 在一次写入中发送多条数据
+func (conn *RawConnection) SendBatch(_ context.Context, batch [][]byte) error {
+	if err := conn.getCloseErr(); err != nil {
+		return err
+	}
+
+	hdrs := getBuffer(rawConnectionHeaderSize * len(batch))
+	defer putBuffer(hdrs)
+	buffs := make(net.Buffers, 0, 2*len(batch))
+	for i, data := range batch {
+		if len(data) > rawConnectionMaxFrameSize {
+			return fmt.Errorf("data too large: %d (max: %d)", len(data), rawConnectionMaxFrameSize)
+		}
+		hdr := hdrs[i*rawConnectionHeaderSize : (i+1)*rawConnectionHeaderSize]
+		binary.BigEndian.PutUint32(hdr, uint32(len(data)))
+		buffs = append(buffs, hdr, data)
+	}
+
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+	if _, err := buffs.WriteTo(conn.conn); err != nil {
+		return conn.setCloseErr(err)
+	}
+	return nil
+}
+
+// Receive 接收数据，跳过控制消息，错误消息作为错误返回
+func (conn *RawConnection) Receive(ctx context.Context) ([]byte, error) {
+	return receiveData(ctx, conn.ReceiveMessage)
+}
+
+// ReceiveMessage 接收消息
+func (conn *RawConnection) ReceiveMessage(_ context.Context) (Message, error) {
+	if err := conn.getCloseErr(); err != nil {
+		return Message{}, err
+	}
+
+	conn.recvLock.Lock()
+	defer conn.recvLock.Unlock()
+	for {
+		size, err := conn.readHeader()
+		if err != nil {
+			return Message{}, err
+		}
+		if size != rawConnectionEnvelopeFrame {
+			data, err := conn.readPayload(size)
+			return Message{Data: data, pooled: err == nil}, err
+		}
+		msg, ok, err := conn.readEnvelope()
+		if err != nil {
+			return Message{}, err
+		}
+		if ok {
+			return msg, nil
+		}
+		// 无法识别的控制消息，忽略
+	}
+}
+
+// readDataHeader 读取下一个数据帧的长度字段，跳过控制消息，错误消息作为错误返回
+// 之后需读取长度为返回值的帧内容，需持有 recvLock 调用
+func (conn *RawConnection) readDataHeader() (uint32, error) {
+	for {
+		size, err := conn.readHeader()
+		if err != nil {
+			return 0, err
+		}
+		if size != rawConnectionEnvelopeFrame {
+			if size > rawConnectionMaxFrameSize {
+				err := conn.setCloseErr(fmt.Errorf("frame too large: %d (max: %d)", size, rawConnectionMaxFrameSize))
+				_ = conn.conn.Close()
+				return 0, err
+			}
+			return size, nil
+		}
+		msg, ok, err := conn.readEnvelope()
+		if err != nil {
+			return 0, err
+		}
+		if ok && msg.Error != nil {
+			return 0, msg.Error
+		}
+	}
+}
+
+// readEnvelope 读取控制消息帧之后的帧，返回其中的控制消息或错误消息，无法识别时返回 false
+func (conn *RawConnection) readEnvelope() (Message, bool, error) {
+	size, err := conn.readHeader()
+	if err != nil {
+		return Message{}, false, err
+	}
+	data, err := conn.readPayload(size)
+	if err != nil {
+		return Message{}, false, err
+	}
+	msg, ok := decodeEnvelope(data)
+	putBuffer(data)
+	return msg, ok, nil
+}
+
+// readHeader 读取下一个非心跳帧的长度字段
+func (conn *RawConnection) readHeader() (uint32, error) {
+	for {
+		if _, err := io.ReadFull(conn.r, conn.recvHdr[:]); err != nil {
+			// 读超时说明对端已失联，关闭底层连接使阻塞的写也能返回
+			err = conn.setCloseErr(err)
+			_ = conn.conn.Close()
+			return 0, err
+		}
+		switch size := binary.BigEndian.Uint32(conn.recvHdr[:]); size {
+		case rawConnectionPingFrame:
+			conn.replyPong()
+		case rawConnectionPongFrame:
+		default:
+			return size, nil
+		}
+	}
+}
+
+// readPayload 读取长度为 size 的帧内容
+func (conn *RawConnection) readPayload(size uint32) ([]byte, error) {
+	if size > rawConnectionMaxFrameSize {
+		err := conn.setCloseErr(fmt.Errorf("frame too large: %d (max: %d)", size, rawConnectionMaxFrameSize))
+		_ = conn.conn.Close()
+		return nil, err
+	}
+	data := getBuffer(int(size))
+	if _, err := io.ReadFull(conn.r, data); err != nil {
+		putBuffer(data)
+		err = conn.setCloseErr(err)
+		_ = conn.conn.Close()
+		return nil, err
+	}
+	return data, nil
+}
+
+// canSpliceTo 返回是否可以通过 spliceTo 将数据帧直接转发给 dst
+func (conn *RawConnection) canSpliceTo(dst *RawConnection) bool {
+	_, ok := dst.conn.(*net.TCPConn)
+	return ok && conn.spliceSrc != nil && runtime.GOOS == "linux" && !fastPathDisabled.Load()
+}
+
+// spliceTo 将长度为 size 的帧内容作为一个数据帧直接转发给 dst ，需在 readDataHeader 之后持有 recvLock 调用
+//
+// 先转发已被缓冲读取器读出的部分，剩余部分由内核在两个连接之间复制，不经过用户态内存。
+// 转发出错时 dst 上的帧已不完整，关闭 dst 并读完 conn 上帧的剩余内容，使 conn 可以继续接收。
+// 返回的 recvErr 不为 nil 时 conn 已无法继续接收
+func (conn *RawConnection) spliceTo(dst *RawConnection, size uint32) (sendErr, recvErr error) {
+	remaining := int64(size)
+	if err := dst.getCloseErr(); err != nil {
+		return err, conn.discard(remaining)
+	}
+
+	var hdr [rawConnectionHeaderSize]byte
+	binary.BigEndian.PutUint32(hdr[:], size)
+
+	dst.sendLock.Lock()
+	defer dst.sendLock.Unlock()
+	if _, err := dst.conn.Write(hdr[:]); err != nil {
+		return dst.setCloseErr(err), conn.discard(remaining)
+	}
+	if conn.br != nil && conn.br.Buffered() > 0 {
+		buf := getBuffer(int(min(int64(conn.br.Buffered()), remaining)))
+		// 已缓冲的数据可以直接读出，不会出错
+		_, _ = io.ReadFull(conn.br, buf)
+		_, err := dst.conn.Write(buf)
+		remaining -= int64(len(buf))
+		putBuffer(buf)
+		if err != nil {
+			err = dst.setCloseErr(err)
+			_ = dst.conn.Close()
+			return err, conn.discard(remaining)
+		}
+	}
+	for remaining > 0 {
+		n := min(remaining, rawConnectionSpliceChunkSize)
+		if conn.idleTimeout > 0 {
+			if err := conn.spliceSrc.SetReadDeadline(time.Now().Add(conn.idleTimeout)); err != nil {
+				err = conn.setCloseErr(err)
+				_ = conn.conn.Close()
+				return nil, err
+			}
+		}
+		// dst 是 *net.TCPConn ，其 ReadFrom 在源是 TCP 或 Unix 连接时使用 splice
+		written, err := io.Copy(dst.conn, io.LimitReader(conn.spliceSrc, n))
+		remaining -= written
+		if err == nil && written < n {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			// 无法区分是读还是写出错，读出错时读完剩余内容也会出错
+			err = dst.setCloseErr(err)
+			_ = dst.conn.Close()
+			return err, conn.discard(remaining)
+		}
+	}
+	return nil, nil
+}
+
+// discard 读取并丢弃 n 字节的帧内容，需持有 recvLock 调用
+func (conn *RawConnection) discard(n int64) error {
+	if _, err := io.CopyN(io.Discard, conn.r, n); err != nil {
+		err = conn.setCloseErr(err)
+		_ = conn.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// SetProtocolVersion 设置与客户端协商的协议版本
+// 服务端在连接建立时调用，版本不低于 1 时 SendControl 和 SendError 才会真正发送
+func (conn *RawConnection) SetProtocolVersion(version int) {
+	conn.version.Store(int32(version))
+}
+
+// SendControl 发送控制消息，协商的协议版本不支持时忽略
+func (conn *RawConnection) SendControl(_ context.Context, control Control) error {
+	return conn.sendEnvelope(&control, nil)
+}
+
+// SendError 发送错误消息，协商的协议版本不支持时忽略
+func (conn *RawConnection) SendError(_ context.Context, status *metav1.Status) error {
+	return conn.sendEnvelope(nil, status)
+}
+
+// sendEnvelope 以控制消息帧发送控制消息或错误消息
+func (conn *RawConnection) sendEnvelope(control *Control, status *metav1.Status) error {
+	if conn.version.Load() < 1 {
+		return nil
+	}
+	if err := conn.getCloseErr(); err != nil {
+		return err
+	}
+	data, err := encodeEnvelope(control, status)
+	if err != nil {
+		return err
+	}
+
+	hdr := make([]byte, 2*rawConnectionHeaderSize)
+	binary.BigEndian.PutUint32(hdr, rawConnectionEnvelopeFrame)
+	binary.BigEndian.PutUint32(hdr[rawConnectionHeaderSize:], uint32(len(data)))
+	buffs := net.Buffers{hdr, data}
+
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+	if _, err := buffs.WriteTo(conn.conn); err != nil {
+		return conn.setCloseErr(err)
+	}
+	return nil
+}
+
+// Close 关闭连接
+func (conn *RawConnection) Close(_ context.Context) error {
+	_ = conn.closeState.set(ErrConnectionClosed)
+	return conn.conn.Close()
+}
+
+// replyPong 异步回复心跳响应帧
+// 不在 Receive 中同步发送，避免两端同时阻塞在发送上时互相等待对方读取而死锁
+func (conn *RawConnection) replyPong() {
+	if !conn.pongPending.CompareAndSwap(false, true) {
+		// 已有未发出的响应
+		return
+	}
+	go func() {
+		defer conn.pongPending.Store(false)
+		_ = conn.sendControlFrame(rawConnectionPongFrame)
+	}()
+}
+
+// sendControlFrame 发送只有长度字段的控制帧
+func (conn *RawConnection) sendControlFrame(frame uint32) error {
+	if err := conn.getCloseErr(); err != nil {
+		return err
+	}
+
+	hdr := make([]byte, rawConnectionHeaderSize)
+	binary.BigEndian.PutUint32(hdr, frame)
+
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+	if _, err := conn.conn.Write(hdr); err != nil {
+		return conn.setCloseErr(err)
+	}
+	return nil
+}
+
+// getCloseErr 返回连接关闭的原因，未关闭时返回 nil
+func (conn *RawConnection) getCloseErr() error {
+	return conn.closeState.get()
+}
+
+// setCloseErr 由于读写错误将连接标记为关闭，并返回关闭原因
+// 字节流读写出错后帧边界已无法恢复，因此任何读写错误都视为连接已关闭
+func (conn *RawConnection) setCloseErr(err error) error {
+	return conn.closeState.set(err)
+}
@@ -2,29 +2,68 @@ package streams
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
 )
 
 // NewWebSocketConnection 创建 WebSocketConnection
-func NewWebSocketConnection(name string, conn *websocket.Conn) *WebSocketConnection {
-	return &WebSocketConnection{
-		name: name,
-		conn: conn,
+// 启用保活时定期向对端发送 Ping ，超时未收到对端任何数据时关闭连接
+func NewWebSocketConnection(name string, conn *websocket.Conn, keepalive KeepaliveOptions) *WebSocketConnection {
+	wsConn := &WebSocketConnection{
+		name:      name,
+		conn:      conn,
+		keepalive: keepalive,
+	}
+	if keepalive.Enabled() {
+		conn.SetPongHandler(func(string) error {
+			return wsConn.extendReadDeadline()
+		})
+		conn.SetPingHandler(func(data string) error {
+			if err := wsConn.extendReadDeadline(); err != nil {
+				return err
+			}
+			// 同 websocket 默认的 Ping 处理，写失败时由后续的读写返回错误
+			err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(keepalive.Timeout))
+			var netErr net.Error
+			if errors.Is(err, websocket.ErrCloseSent) || errors.As(err, &netErr) {
+				return nil
+			}
+			return err
+		})
+		go runKeepalive(keepalive.Interval, wsConn.closeState.doneCh(), func() error {
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(keepalive.Timeout))
+		})
 	}
+	return wsConn
 }
 
 // WebSocketConnection 是 Connection 的基于 WebSocket 的实现
+//
+// 数据总是以二进制消息发送。协商的协议版本不低于 1 时，服务端以 JSON 编码的文本消息发送控制消息和错误消息；
+// 旧服务端拒绝连接时以文本消息发送的 metav1.Status 也作为错误消息返回
 type WebSocketConnection struct {
-	name     string
-	conn     *websocket.Conn
-	closeErr error
-	sendLock sync.Mutex
+	name      string
+	conn      *websocket.Conn
+	keepalive KeepaliveOptions
+	version   atomic.Int32
+
+	sendLock   sync.Mutex
+	closeState closeState
 }
 
 var _ Connection = &WebSocketConnection{}
+var _ ControlSender = &WebSocketConnection{}
+var _ MessageReceiver = &WebSocketConnection{}
 
 // Name 返回连接名
 func (conn *WebSocketConnection) Name() string {
@@ -33,44 +72,121 @@ func (conn *WebSocketConnection) Name() string {
 
 // Send 发送
 func (conn *WebSocketConnection) Send(_ context.Context, data []byte) error {
-	if conn.closeErr != nil {
-		return conn.closeErr
+	if err := conn.closeState.get(); err != nil {
+		return err
 	}
 
 	conn.sendLock.Lock()
 	defer conn.sendLock.Unlock()
-	err := conn.conn.WriteMessage(websocket.BinaryMessage, data)
-	if err != nil {
-		if websocket.IsUnexpectedCloseError(err) {
-			conn.closeErr = fmt.Errorf("%w: %s", ErrConnectionClosed, err.Error())
-			return conn.closeErr
-		}
+	if err := conn.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+		// 写出错后 WebSocket 连接无法再使用
+		err = conn.closeState.set(err)
+		_ = conn.conn.Close()
 		return err
 	}
 
 	return nil
 }
 
-// Receive 接收
-func (conn *WebSocketConnection) Receive(_ context.Context) ([]byte, error) {
-	if conn.closeErr != nil {
-		return nil, conn.closeErr
+// Receive 接收数据，跳过控制消息，错误消息作为错误返回
+func (conn *WebSocketConnection) Receive(ctx context.Context) ([]byte, error) {
+	return receiveData(ctx, conn.ReceiveMessage)
+}
+
+// ReceiveMessage 接收消息
+func (conn *WebSocketConnection) ReceiveMessage(_ context.Context) (Message, error) {
+	if err := conn.closeState.get(); err != nil {
+		return Message{}, err
 	}
 
-	_, msg, err := conn.conn.ReadMessage()
+	if err := conn.extendReadDeadline(); err != nil {
+		return Message{}, conn.closeState.set(err)
+	}
+	msgType, msg, err := conn.readMessage()
 	if err != nil {
-		if websocket.IsUnexpectedCloseError(err) {
-			conn.closeErr = fmt.Errorf("%w: %s", ErrConnectionClosed, err.Error())
-			return nil, conn.closeErr
+		// 读出错后 WebSocket 连接无法再使用
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			err = fmt.Errorf("%w: no data received from peer in %s", ErrHeartbeatTimeout, conn.keepalive.idleTimeout())
+		}
+		err = conn.closeState.set(err)
+		_ = conn.conn.Close()
+		return Message{}, err
+	}
+
+	if msgType == websocket.TextMessage {
+		if m, ok := decodeEnvelope(msg); ok {
+			putBuffer(msg)
+			return m, nil
+		}
+		status := &metav1.Status{}
+		if err := json.Unmarshal(msg, status); err == nil && status.Code >= http.StatusBadRequest {
+			putBuffer(msg)
+			return Message{Error: status}, nil
 		}
-		return nil, err
 	}
+	return Message{Data: msg, pooled: true}, nil
+}
+
+// readMessage 读取一条消息，同 websocket.Conn.ReadMessage 但使用池中的缓冲区
+func (conn *WebSocketConnection) readMessage() (int, []byte, error) {
+	msgType, r, err := conn.conn.NextReader()
+	if err != nil {
+		return msgType, nil, err
+	}
+	msg, err := readAllPooled(r)
+	return msgType, msg, err
+}
+
+// SetProtocolVersion 设置与客户端协商的协议版本
+// 服务端在连接建立时调用，版本不低于 1 时 SendControl 和 SendError 才会真正发送
+func (conn *WebSocketConnection) SetProtocolVersion(version int) {
+	conn.version.Store(int32(version))
+}
 
-	return msg, nil
+// SendControl 发送控制消息，协商的协议版本不支持时忽略
+func (conn *WebSocketConnection) SendControl(_ context.Context, control Control) error {
+	return conn.sendEnvelope(&control, nil)
+}
+
+// SendError 发送错误消息，协商的协议版本不支持时忽略
+func (conn *WebSocketConnection) SendError(_ context.Context, status *metav1.Status) error {
+	return conn.sendEnvelope(nil, status)
+}
+
+// sendEnvelope 以文本消息发送控制消息或错误消息
+func (conn *WebSocketConnection) sendEnvelope(control *Control, status *metav1.Status) error {
+	if conn.version.Load() < 1 {
+		return nil
+	}
+	if err := conn.closeState.get(); err != nil {
+		return err
+	}
+	msg, err := encodeEnvelope(control, status)
+	if err != nil {
+		return err
+	}
+
+	conn.sendLock.Lock()
+	defer conn.sendLock.Unlock()
+	if err := conn.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		err = conn.closeState.set(err)
+		_ = conn.conn.Close()
+		return err
+	}
+	return nil
 }
 
 // Close 关闭连接
 func (conn *WebSocketConnection) Close(_ context.Context) error {
-	conn.closeErr = ErrConnectionClosed
+	_ = conn.closeState.set(ErrConnectionClosed)
 	return conn.conn.Close()
 }
+
+// extendReadDeadline 启用保活时延长读超时
+func (conn *WebSocketConnection) extendReadDeadline() error {
+	if !conn.keepalive.Enabled() {
+		return nil
+	}
+	return conn.conn.SetReadDeadline(time.Now().Add(conn.keepalive.idleTimeout()))
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"github.com/go-logr/logr"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
 )
 
 // ConnectionWithLog 带日志的连接
@@ -14,6 +16,9 @@ type ConnectionWithLog struct {
 }
 
 var _ Connection = &ConnectionWithLog{}
+var _ MessageReceiver = &ConnectionWithLog{}
+var _ ControlSender = &ConnectionWithLog{}
+var _ PeerWaiter = &ConnectionWithLog{}
 
 // Send 发送
 func (conn ConnectionWithLog) Send(ctx context.Context, data []byte) error {
@@ -54,6 +59,59 @@ func (conn ConnectionWithLog) Receive(ctx context.Context) ([]byte, error) {
 	return data, nil
 }
 
+// ReceiveMessage 接收消息
+func (conn ConnectionWithLog) ReceiveMessage(ctx context.Context) (Message, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("conn", conn.Name())
+
+	if _, ok := conn.Connection.(MessageReceiver); !ok {
+		data, err := conn.Receive(ctx)
+		return Message{Data: data}, err
+	}
+	msg, err := ReceiveMessage(ctx, conn.Connection)
+	switch {
+	case err != nil:
+		logger.V(1).Info(fmt.Sprintf("receive error: %v", err))
+	case msg.Error != nil:
+		logger.V(1).Info(fmt.Sprintf("received error: %v", msg.Error))
+	case msg.Control != nil:
+		logger.V(1).Info(fmt.Sprintf("received control: %s", msg.Control.Type))
+	case logger.V(2).Enabled():
+		logger.V(2).Info(fmt.Sprintf(
+			"received data, size: %d, checksum: sha256:%x", len(msg.Data), sha256.Sum256(msg.Data),
+		))
+	default:
+		logger.V(1).Info(fmt.Sprintf("received data, size: %d", len(msg.Data)))
+	}
+	return msg, err
+}
+
+// SendControl 发送控制消息
+func (conn ConnectionWithLog) SendControl(ctx context.Context, control Control) error {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("conn", conn.Name())
+	if err := SendControl(ctx, conn.Connection, control); err != nil {
+		logger.V(1).Info(fmt.Sprintf("send control %s error: %v", control.Type, err))
+		return err
+	}
+	logger.V(1).Info(fmt.Sprintf("sent control: %s", control.Type))
+	return nil
+}
+
+// SendError 发送错误消息
+func (conn ConnectionWithLog) SendError(ctx context.Context, status *metav1.Status) error {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("conn", conn.Name())
+	if err := SendError(ctx, conn.Connection, status); err != nil {
+		logger.V(1).Info(fmt.Sprintf("send error %v error: %v", status, err))
+		return err
+	}
+	logger.V(1).Info(fmt.Sprintf("sent error: %v", status))
+	return nil
+}
+
+// WaitForPeer 等待对端加入流
+func (conn ConnectionWithLog) WaitForPeer(ctx context.Context) error {
+	return WaitForPeer(ctx, conn.Connection)
+}
+
 // Close 关闭连接
 func (conn ConnectionWithLog) Close(ctx context.Context) error {
 	logger := logr.FromContextOrDiscard(ctx).WithValues("conn", conn.Name())
@@ -13,3 +13,9 @@ type Connection interface {
 	// Close 关闭连接
 	Close(ctx context.Context) error
 }
+
+// BatchSender 可以在一次写入中发送多条数据的连接
+type BatchSender interface {
+	// SendBatch 发送多条数据，对端按顺序作为多条数据接收
+	SendBatch(ctx context.Context, batch [][]byte) error
+}
@@ -0,0 +1,231 @@
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+)
+
+// ProtocolVersion 当前支持的最高连接协议版本
+//
+// 客户端连接时请求其支持的版本，服务端使用双方都支持的最高版本并在响应中确认：
+//   - 0: 只传输数据，未协商版本的旧客户端使用该版本
+//   - 1: 除数据外，服务端还会发送控制消息和错误消息
+const ProtocolVersion = 1
+
+// NegotiateProtocolVersion 根据客户端请求的版本协商连接协议版本
+// requested 为空或无法解析时返回 0
+func NegotiateProtocolVersion(requested string) int {
+	v, err := strconv.Atoi(requested)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return min(v, ProtocolVersion)
+}
+
+// ControlType 控制消息类型
+type ControlType string
+
+const (
+	// PeerJoinedControl 对端已加入流
+	PeerJoinedControl ControlType = "PeerJoined"
+	// PeerLeftControl 对端已离开流
+	PeerLeftControl ControlType = "PeerLeft"
+	// StreamStoppingControl 流正在停止，之后连接会被断开
+	StreamStoppingControl ControlType = "StreamStopping"
+	// ServerDrainingControl 服务端即将停止，之后连接会被断开
+	ServerDrainingControl ControlType = "ServerDraining"
+	// WindowUpdateControl 流控窗口更新，接收方可以再发送 WindowIncrement 字节的数据
+	WindowUpdateControl ControlType = "WindowUpdate"
+	// SignalControl 向流中执行命令的一端发送信号，信号名为 Signal ，其它连接忽略
+	SignalControl ControlType = "Signal"
+)
+
+var (
+	// ErrControlNotSupported 连接或服务端不支持控制消息
+	ErrControlNotSupported = errors.New("ControlNotSupported")
+	// ErrServerDraining 服务端即将停止
+	ErrServerDraining = errors.New("ServerDraining")
+	// ErrPeerLeft 对端已离开流
+	ErrPeerLeft = errors.New("PeerLeft")
+)
+
+// Control 控制消息
+type Control struct {
+	// 类型
+	Type ControlType `json:"type"`
+	// 流控窗口增量，单位字节，仅 WindowUpdate 使用
+	WindowIncrement int64 `json:"windowIncrement,omitempty"`
+	// 信号名，如 SIGTERM ，仅 Signal 使用
+	Signal string `json:"signal,omitempty"`
+}
+
+// Message 连接上收到的消息，是数据、控制消息和错误消息之一
+type Message struct {
+	// 数据
+	Data []byte
+	// 控制消息
+	Control *Control
+	// 错误消息，服务端发送错误消息后会断开连接
+	Error *metav1.Status
+
+	// Data 是否是连接从缓冲区池中获取的缓冲区，是时流转发后将其归还
+	pooled bool
+}
+
+// IsData 返回消息是否是数据
+func (msg Message) IsData() bool {
+	return msg.Control == nil && msg.Error == nil
+}
+
+// ControlSender 可发送控制消息和错误消息的连接
+//
+// 服务端通过其通知连接流中发生的事件，只有协商的协议版本支持时才真正发送，否则忽略
+type ControlSender interface {
+	// SendControl 发送控制消息
+	SendControl(ctx context.Context, control Control) error
+	// SendError 发送错误消息
+	SendError(ctx context.Context, status *metav1.Status) error
+}
+
+// MessageReceiver 可区分数据、控制消息和错误消息接收消息的连接
+type MessageReceiver interface {
+	// ReceiveMessage 接收消息
+	ReceiveMessage(ctx context.Context) (Message, error)
+}
+
+// PeerWaiter 可等待对端加入的连接
+type PeerWaiter interface {
+	// WaitForPeer 等待对端加入流
+	WaitForPeer(ctx context.Context) error
+}
+
+// SendControl 向连接发送控制消息，连接不支持时忽略
+func SendControl(ctx context.Context, conn Connection, control Control) error {
+	sender, ok := conn.(ControlSender)
+	if !ok {
+		return nil
+	}
+	return sender.SendControl(ctx, control)
+}
+
+// SendError 向连接发送错误消息，连接不支持时忽略
+func SendError(ctx context.Context, conn Connection, status *metav1.Status) error {
+	sender, ok := conn.(ControlSender)
+	if !ok {
+		return nil
+	}
+	return sender.SendError(ctx, status)
+}
+
+// ReceiveMessage 从连接接收消息，连接不区分消息类型时只返回数据
+func ReceiveMessage(ctx context.Context, conn Connection) (Message, error) {
+	if receiver, ok := conn.(MessageReceiver); ok {
+		return receiver.ReceiveMessage(ctx)
+	}
+	data, err := conn.Receive(ctx)
+	return Message{Data: data}, err
+}
+
+// ReceiveData 从连接接收数据，跳过其它控制消息，错误消息作为错误返回
+//
+// 收到对端离开的控制消息时返回 ErrPeerLeft 。连接或服务端不支持控制消息时，只能通过连接断开得知对端离开
+func ReceiveData(ctx context.Context, conn Connection) ([]byte, error) {
+	for {
+		msg, err := ReceiveMessage(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		if msg.Control == nil {
+			return msg.Data, nil
+		}
+		if msg.Control.Type == PeerLeftControl {
+			return nil, ErrPeerLeft
+		}
+	}
+}
+
+// WaitForPeer 等待连接所在流的对端加入
+//
+// 对端已经加入时立即返回。连接或服务端不支持控制消息时返回 ErrControlNotSupported ，
+// 调用方应回退到其它方式（如约定的握手消息）确认对端已加入
+func WaitForPeer(ctx context.Context, conn Connection) error {
+	waiter, ok := conn.(PeerWaiter)
+	if !ok {
+		return ErrControlNotSupported
+	}
+	return waiter.WaitForPeer(ctx)
+}
+
+// receiveData 通过 receive 接收消息直到收到数据，跳过控制消息，错误消息作为错误返回
+func receiveData(ctx context.Context, receive func(ctx context.Context) (Message, error)) ([]byte, error) {
+	for {
+		msg, err := receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		if msg.Control == nil {
+			return msg.Data, nil
+		}
+	}
+}
+
+// receiveDataMessage 从连接接收数据消息，跳过控制消息，错误消息作为错误返回
+func receiveDataMessage(ctx context.Context, conn Connection) (Message, error) {
+	for {
+		msg, err := ReceiveMessage(ctx, conn)
+		if err != nil {
+			return Message{}, err
+		}
+		if msg.Error != nil {
+			return Message{}, msg.Error
+		}
+		if msg.Control == nil {
+			return msg, nil
+		}
+	}
+}
+
+// envelope WebSocket 和原始字节流连接上 JSON 编码的控制消息和错误消息
+type envelope struct {
+	// 协议版本
+	Version int            `json:"version"`
+	Control *Control       `json:"control,omitempty"`
+	Error   *metav1.Status `json:"error,omitempty"`
+}
+
+// encodeEnvelope 将控制消息或错误消息编码为 JSON
+func encodeEnvelope(control *Control, status *metav1.Status) ([]byte, error) {
+	data, err := json.Marshal(envelope{Version: ProtocolVersion, Control: control, Error: status})
+	if err != nil {
+		return nil, fmt.Errorf("marshal envelope to json error: %w", err)
+	}
+	return data, nil
+}
+
+// decodeEnvelope 从 JSON 解码控制消息或错误消息，不是有效的消息时返回 false
+// 未知的控制消息类型也会被返回，由调用方忽略
+func decodeEnvelope(data []byte) (Message, bool) {
+	e := envelope{}
+	if err := json.Unmarshal(data, &e); err != nil || e.Version < 1 {
+		return Message{}, false
+	}
+	switch {
+	case e.Error != nil:
+		return Message{Error: e.Error}, true
+	case e.Control != nil:
+		return Message{Control: e.Control}, true
+	default:
+		return Message{}, false
+	}
+}
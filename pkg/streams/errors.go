@@ -9,8 +9,12 @@ var (
 	ErrStreamNotFound = errors.New("StreamNotFound")
 	// ErrStreamIsFull 流满员了
 	ErrStreamIsFull = errors.New("StreamIsFull")
+	// ErrStreamIsEmpty 流中没有连接
+	ErrStreamIsEmpty = errors.New("StreamIsEmpty")
 	// ErrStreamAlreadyStopped 流已经停止了
 	ErrStreamAlreadyStopped = errors.New("StreamAlreadyStopped")
 	// ErrConnectionClosed 连接已关闭
 	ErrConnectionClosed = errors.New("ConnectionClosed")
+	// ErrHeartbeatTimeout 超时未收到对端心跳，对端可能已失联
+	ErrHeartbeatTimeout = errors.New("HeartbeatTimeout")
 )
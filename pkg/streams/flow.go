@@ -0,0 +1,408 @@
+package streams
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultStreamWindow 默认每个流每个方向已接收但还未发出的最大字节数
+	DefaultStreamWindow = 4 << 20 // 4MiB
+	// DefaultMaxInFlightBytes 默认所有流已接收但还未发出的最大总字节数
+	DefaultMaxInFlightBytes = 256 << 20 // 256MiB
+)
+
+// flowControlVars 流控指标，通过 /debug/vars 暴露
+//   - inFlightBytes: 所有流已接收但还未发出的总字节数
+//   - streamThrottled: 因流窗口用尽而等待的次数
+//   - globalThrottled: 因全局限制而等待的次数
+//   - throttledSeconds: 等待的总时长
+var flowControlVars = expvar.NewMap("flowControl")
+
+// NewDefaultFlowControlOptions 创建默认 FlowControlOptions
+func NewDefaultFlowControlOptions() FlowControlOptions {
+	return FlowControlOptions{
+		StreamWindow:     DefaultStreamWindow,
+		MaxInFlightBytes: DefaultMaxInFlightBytes,
+	}
+}
+
+// FlowControlOptions 流转发的流控选项
+//
+// 服务端从连接接收数据前需先获得额度，数据发给对端后归还。
+// 额度用尽时暂停从连接接收，由传输层将背压传递给发送方，使每个流和整个服务端占用的内存有界
+type FlowControlOptions struct {
+	// 每个流每个方向已接收但还未发出的最大字节数
+	StreamWindow int64
+	// 所有流已接收但还未发出的最大总字节数，为 0 时不限制
+	MaxInFlightBytes int64
+}
+
+// Validate 校验选项
+func (opts FlowControlOptions) Validate() error {
+	if opts.StreamWindow <= 0 {
+		return fmt.Errorf("stream window must be positive")
+	}
+	if opts.MaxInFlightBytes < 0 {
+		return fmt.Errorf("max in-flight bytes must not be negative")
+	}
+	return nil
+}
+
+// NewFlowScheduler 创建限制在途字节数不超过 limit 的 FlowScheduler ， limit 为 0 时返回 nil ，表示不限制
+func NewFlowScheduler(limit int64) *FlowScheduler {
+	if limit <= 0 {
+		return nil
+	}
+	return newFlowScheduler(limit, "globalThrottled")
+}
+
+// newFlowScheduler 创建 FlowScheduler ，等待时在 throttledKey 指标上计数
+func newFlowScheduler(limit int64, throttledKey string) *FlowScheduler {
+	return &FlowScheduler{
+		limit:        limit,
+		throttledKey: throttledKey,
+	}
+}
+
+// FlowScheduler 限制在途字节数的调度器
+//
+// 额度不足时请求按先后顺序排队，先到的请求满足前后到的请求不会越过它，使发送快的流不能饿死其它流。
+// 超过 limit 的请求按 limit 计算，使大于限制的消息也能在没有其它在途数据时通过。
+// nil 表示不限制
+type FlowScheduler struct {
+	limit        int64
+	throttledKey string
+
+	lock        sync.Mutex
+	inFlight    int64
+	maxInFlight int64
+	throttled   int64
+	waiters     []*flowWaiter
+}
+
+// flowWaiter 等待额度的请求
+type flowWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// FlowSchedulerStats FlowScheduler 统计信息
+type FlowSchedulerStats struct {
+	// 在途字节数上限
+	Limit int64
+	// 当前在途字节数
+	InFlightBytes int64
+	// 在途字节数的最大值
+	MaxInFlightBytes int64
+	// 因额度不足而等待的次数
+	Throttled int64
+}
+
+// Acquire 获取 n 字节的额度，额度不足时等待，在获得额度前 done 关闭时返回 false
+func (s *FlowScheduler) Acquire(n int64, done <-chan struct{}) bool {
+	if s == nil {
+		return true
+	}
+	n = min(n, s.limit)
+
+	s.lock.Lock()
+	if len(s.waiters) == 0 && s.inFlight+n <= s.limit {
+		s.inFlight += n
+		s.maxInFlight = max(s.maxInFlight, s.inFlight)
+		s.lock.Unlock()
+		return true
+	}
+	w := &flowWaiter{n: n, ready: make(chan struct{})}
+	s.waiters = append(s.waiters, w)
+	s.throttled++
+	s.lock.Unlock()
+
+	flowControlVars.Add(s.throttledKey, 1)
+	start := time.Now()
+	defer func() {
+		flowControlVars.AddFloat("throttledSeconds", time.Since(start).Seconds())
+	}()
+
+	select {
+	case <-w.ready:
+		return true
+	case <-done:
+	}
+
+	s.lock.Lock()
+	select {
+	case <-w.ready:
+		// 放弃时刚好获得了额度，归还
+		s.lock.Unlock()
+		s.Release(n)
+		return false
+	default:
+	}
+	for i, other := range s.waiters {
+		if other == w {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+			break
+		}
+	}
+	// 排在前面的请求放弃后，后面的请求可能可以满足了
+	s.grant()
+	s.lock.Unlock()
+	return false
+}
+
+// Release 归还 n 字节的额度
+func (s *FlowScheduler) Release(n int64) {
+	if s == nil {
+		return
+	}
+	n = min(n, s.limit)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.inFlight -= n
+	s.grant()
+}
+
+// grant 按顺序满足等待的请求，需持有锁调用
+func (s *FlowScheduler) grant() {
+	for len(s.waiters) > 0 && s.inFlight+s.waiters[0].n <= s.limit {
+		w := s.waiters[0]
+		s.waiters[0] = nil
+		s.waiters = s.waiters[1:]
+		s.inFlight += w.n
+		close(w.ready)
+	}
+	s.maxInFlight = max(s.maxInFlight, s.inFlight)
+}
+
+// Stats 返回统计信息
+func (s *FlowScheduler) Stats() FlowSchedulerStats {
+	if s == nil {
+		return FlowSchedulerStats{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return FlowSchedulerStats{
+		Limit:            s.limit,
+		InFlightBytes:    s.inFlight,
+		MaxInFlightBytes: s.maxInFlight,
+		Throttled:        s.throttled,
+	}
+}
+
+// newFlowQueue 创建 flowQueue
+func newFlowQueue(window int64, global *FlowScheduler) *flowQueue {
+	return &flowQueue{
+		window: newFlowScheduler(window, "streamThrottled"),
+		global: global,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// flowQueue 流中发往一个连接的消息队列
+//
+// 数据入队前先后获取流窗口和全局的额度，发出后归还。控制消息不占用额度。
+// 归还的额度累计达到流窗口的 1/4 时，向数据的发送方发送流控窗口更新
+// 队列持有其中的数据，发出或丢弃后将连接从缓冲区池中获取的缓冲区归还，其它数据的缓冲区不复用
+type flowQueue struct {
+	window *FlowScheduler
+	global *FlowScheduler
+
+	lock  sync.Mutex
+	items []Message
+	// 已取出但还未发完的消息条数
+	sending int
+	closed  bool
+	// 有新消息入队时写入
+	notify chan struct{}
+
+	// 发往数据发送方的队列，为 nil 时不发送流控窗口更新
+	windowUpdates *flowQueue
+	// 已归还但还未通知数据发送方的额度
+	unannounced int64
+}
+
+// push 获得额度后将数据消息加入队列，在获得额度前 done 关闭或队列已关闭时返回 false
+func (q *flowQueue) push(msg Message, done <-chan struct{}) bool {
+	if !q.acquire(int64(len(msg.Data)), done) {
+		return false
+	}
+	return q.enqueue(msg)
+}
+
+// acquire 获取 n 字节数据的额度，在获得额度前 done 关闭时返回 false
+func (q *flowQueue) acquire(n int64, done <-chan struct{}) bool {
+	if !q.window.Acquire(n, done) {
+		return false
+	}
+	if !q.global.Acquire(n, done) {
+		q.window.Release(n)
+		return false
+	}
+	flowControlVars.Add("inFlightBytes", n)
+	return true
+}
+
+// enqueue 将已获得额度的数据消息加入队列，队列已关闭时归还额度并返回 false
+func (q *flowQueue) enqueue(msg Message) bool {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		q.release(msg)
+		return false
+	}
+	q.items = append(q.items, msg)
+	q.lock.Unlock()
+	q.signal()
+	return true
+}
+
+// pushControl 将控制消息加入队列
+func (q *flowQueue) pushControl(control Control) {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return
+	}
+	q.items = append(q.items, Message{Control: &control})
+	q.lock.Unlock()
+	q.signal()
+}
+
+// resetControls 丢弃队列中发给之前的连接的控制消息，新连接加入时调用
+// first 不为 nil 时将其放在队首，先于队列中的数据发出
+func (q *flowQueue) resetControls(first *Control) {
+	q.lock.Lock()
+	items := make([]Message, 0, len(q.items)+1)
+	if first != nil {
+		items = append(items, Message{Control: first})
+	}
+	for _, msg := range q.items {
+		if msg.IsData() {
+			items = append(items, msg)
+		}
+	}
+	q.items = items
+	q.lock.Unlock()
+	q.signal()
+}
+
+// popBatch 从队首取出一条控制消息，或最多 maxCount 条总大小不超过 maxBytes 的连续的数据追加到 batch 并返回
+// 第一条数据超过 maxBytes 时也会被取出。队列为空时返回的 batch 长度不变。
+// 取出的消息发出后需调用 finish ，未能发出时调用 unpop
+func (q *flowQueue) popBatch(batch []Message, maxCount int, maxBytes int) []Message {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.items) > 0 && !q.items[0].IsData() {
+		batch = append(batch, q.items[0])
+		q.items[0] = Message{}
+		q.items = q.items[1:]
+		q.sending++
+		return batch
+	}
+
+	n, size := 0, 0
+	for n < len(q.items) && n < maxCount && q.items[n].IsData() {
+		if n > 0 && size+len(q.items[n].Data) > maxBytes {
+			break
+		}
+		size += len(q.items[n].Data)
+		batch = append(batch, q.items[n])
+		q.items[n] = Message{}
+		n++
+	}
+	q.items = q.items[n:]
+	q.sending += n
+	return batch
+}
+
+// unpop 将未能发出的消息放回队首，仍占用其额度
+func (q *flowQueue) unpop(batch []Message) {
+	q.lock.Lock()
+	q.sending -= len(batch)
+	if q.closed {
+		q.lock.Unlock()
+		for _, msg := range batch {
+			q.release(msg)
+		}
+		return
+	}
+	q.items = append(append(make([]Message, 0, len(batch)+len(q.items)), batch...), q.items...)
+	q.lock.Unlock()
+}
+
+// finish 取出的消息已发出，归还其占用的额度和缓冲区
+func (q *flowQueue) finish(batch []Message) {
+	q.lock.Lock()
+	q.sending -= len(batch)
+	q.lock.Unlock()
+	for _, msg := range batch {
+		q.release(msg)
+	}
+}
+
+// idle 返回队列中是否没有等待发送和正在发送的消息
+func (q *flowQueue) idle() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.sending == 0 && len(q.items) == 0
+}
+
+// release 归还消息占用的额度，数据来自缓冲区池时归还其缓冲区
+func (q *flowQueue) release(msg Message) {
+	if !msg.IsData() {
+		return
+	}
+	q.releaseBytes(int64(len(msg.Data)))
+	if msg.pooled {
+		putBuffer(msg.Data)
+	}
+}
+
+// releaseBytes 归还 n 字节数据的额度
+func (q *flowQueue) releaseBytes(n int64) {
+	q.global.Release(n)
+	q.window.Release(n)
+	flowControlVars.Add("inFlightBytes", -n)
+	q.announce(n)
+}
+
+// announce 累计归还的 n 字节额度，达到流窗口的 1/4 时向数据发送方发送流控窗口更新
+func (q *flowQueue) announce(n int64) {
+	if q.windowUpdates == nil {
+		return
+	}
+	q.lock.Lock()
+	q.unannounced += n
+	increment := q.unannounced
+	if increment < max(q.window.limit/4, 1) {
+		q.lock.Unlock()
+		return
+	}
+	q.unannounced = 0
+	q.lock.Unlock()
+	q.windowUpdates.pushControl(Control{Type: WindowUpdateControl, WindowIncrement: increment})
+}
+
+// signal 通知有新消息
+func (q *flowQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// close 关闭队列，丢弃队列中的消息并归还额度
+func (q *flowQueue) close() {
+	q.lock.Lock()
+	items := q.items
+	q.items = nil
+	q.closed = true
+	q.lock.Unlock()
+	for _, msg := range items {
+		q.release(msg)
+	}
+}
@@ -0,0 +1,86 @@
+package streams
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFlowScheduler 测试 FlowScheduler 按顺序分配额度
+func TestFlowScheduler(t *testing.T) {
+	a := assert.New(t)
+	s := NewFlowScheduler(10)
+
+	// 超过限制的请求按限制计算
+	a.True(s.Acquire(100, nil))
+	a.Equal(int64(10), s.Stats().InFlightBytes)
+	s.Release(100)
+	a.Equal(int64(0), s.Stats().InFlightBytes)
+
+	a.True(s.Acquire(8, nil))
+
+	// 额度不足的请求排队，之后的请求即使额度足够也不能越过它
+	granted := make(chan int, 2)
+	go func() {
+		a.True(s.Acquire(6, nil))
+		granted <- 6
+	}()
+	a.Eventually(func() bool {
+		return s.Stats().Throttled == 1
+	}, time.Second, 10*time.Millisecond)
+	go func() {
+		a.True(s.Acquire(1, nil))
+		granted <- 1
+	}()
+	a.Eventually(func() bool {
+		return s.Stats().Throttled == 2
+	}, time.Second, 10*time.Millisecond)
+
+	s.Release(3)
+	select {
+	case n := <-granted:
+		t.Errorf("request for %d bytes jumped the queue", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	s.Release(5)
+	a.ElementsMatch([]int{6, 1}, []int{<-granted, <-granted})
+	a.Equal(int64(7), s.Stats().InFlightBytes)
+	a.LessOrEqual(s.Stats().MaxInFlightBytes, int64(10))
+
+	// 放弃等待
+	done := make(chan struct{})
+	close(done)
+	a.False(s.Acquire(10, done))
+	a.Equal(int64(7), s.Stats().InFlightBytes)
+
+	// 不限制
+	a.Nil(NewFlowScheduler(0))
+	var unlimited *FlowScheduler
+	a.True(unlimited.Acquire(1<<30, nil))
+	unlimited.Release(1 << 30)
+}
+
+// TestFlowQueue_ReleaseBuffers 测试队列只将来自缓冲区池的数据的缓冲区归还到池中
+func TestFlowQueue_ReleaseBuffers(t *testing.T) {
+	a := assert.New(t)
+	q := newFlowQueue(1<<20, nil)
+
+	// 连接之外的调用方持有的数据，发出后缓冲区仍归调用方所有
+	owned := bytes.Repeat([]byte("a"), bufferPoolMinSize)
+	a.True(q.push(Message{Data: owned}, nil))
+	q.finish(q.popBatch(nil, 1, 1<<20))
+	for i := 0; i < 10; i++ {
+		b := getBuffer(bufferPoolMinSize)
+		copy(b, bytes.Repeat([]byte("b"), len(b)))
+	}
+	a.Equal(bytes.Repeat([]byte("a"), bufferPoolMinSize), owned)
+
+	// 来自缓冲区池的数据
+	pooled := getBuffer(bufferPoolMinSize)
+	a.True(q.push(Message{Data: pooled, pooled: true}, nil))
+	q.finish(q.popBatch(nil, 1, 1<<20))
+	a.True(q.idle())
+}
@@ -0,0 +1,139 @@
+package streams
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultKeepaliveInterval 默认发送心跳的间隔
+	DefaultKeepaliveInterval = 30 * time.Second
+	// DefaultKeepaliveTimeout 默认等待心跳响应的超时时间
+	DefaultKeepaliveTimeout = 20 * time.Second
+)
+
+// NewDefaultKeepaliveOptions 创建默认 KeepaliveOptions
+func NewDefaultKeepaliveOptions() KeepaliveOptions {
+	return KeepaliveOptions{
+		Interval: DefaultKeepaliveInterval,
+		Timeout:  DefaultKeepaliveTimeout,
+	}
+}
+
+// KeepaliveOptions 连接保活选项
+//
+// 连接两端每隔 Interval 向对端发送心跳，超过 Interval + Timeout 没有收到对端任何数据时认为对端已失联，
+// 关闭连接，使半开的连接能被及时发现
+type KeepaliveOptions struct {
+	// 发送心跳的间隔，为 0 时不发送心跳也不检测对端是否失联
+	Interval time.Duration
+	// 发送心跳后等待对端响应的超时时间
+	Timeout time.Duration
+}
+
+// Enabled 返回是否启用保活
+func (opts KeepaliveOptions) Enabled() bool {
+	return opts.Interval > 0
+}
+
+// Validate 校验选项
+func (opts KeepaliveOptions) Validate() error {
+	if opts.Interval < 0 {
+		return fmt.Errorf("keepalive interval must not be negative")
+	}
+	if opts.Enabled() && opts.Timeout <= 0 {
+		return fmt.Errorf("keepalive timeout must be positive when keepalive is enabled")
+	}
+	return nil
+}
+
+// idleTimeout 返回多长时间没有收到数据时认为对端已失联
+func (opts KeepaliveOptions) idleTimeout() time.Duration {
+	return opts.Interval + opts.Timeout
+}
+
+// runKeepalive 每隔 interval 调用一次 ping ，直到 done 关闭或 ping 返回错误
+func runKeepalive(interval time.Duration, done <-chan struct{}, ping func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// idleTimeoutReader 每次读取前重置 conn 读超时的 io.Reader
+// 超过 timeout 没有读到任何数据时读取返回超时错误，传输大的消息时只要数据还在流动就不会超时
+type idleTimeoutReader struct {
+	r       io.Reader
+	conn    net.Conn
+	timeout time.Duration
+}
+
+// Read 读取数据
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	if err := r.conn.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = fmt.Errorf("%w: no data received from peer in %s", ErrHeartbeatTimeout, r.timeout)
+	}
+	return n, err
+}
+
+// closeState 记录连接关闭的原因，可以并发访问
+type closeState struct {
+	lock sync.Mutex
+	err  error
+	// 连接关闭时关闭
+	done chan struct{}
+}
+
+// get 返回连接关闭的原因，未关闭时返回 nil
+func (s *closeState) get() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// doneCh 返回连接关闭时关闭的通道
+func (s *closeState) doneCh() <-chan struct{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	return s.done
+}
+
+// set 由于 err 将连接标记为关闭，并返回关闭原因
+// 已经关闭时不改变关闭原因
+func (s *closeState) set(err error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if errors.Is(err, ErrConnectionClosed) {
+		s.err = err
+	} else {
+		s.err = fmt.Errorf("%w: %w", ErrConnectionClosed, err)
+	}
+	if s.done == nil {
+		s.done = make(chan struct{})
+	}
+	close(s.done)
+	return s.err
+}
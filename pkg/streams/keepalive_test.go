@@ -0,0 +1,86 @@
+package streams
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+var testKeepalive = KeepaliveOptions{
+	Interval: 50 * time.Millisecond,
+	Timeout:  50 * time.Millisecond,
+}
+
+// TestRawConnection_Keepalive 测试原始字节流连接的心跳
+func TestRawConnection_Keepalive(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	// 两端都存活时，空闲超过超时时间也不会断开
+	c1, c2 := net.Pipe()
+	conn1 := NewRawConnection("conn1", c1, nil, testKeepalive)
+	conn2 := NewRawConnection("conn2", c2, nil, testKeepalive)
+	go func() {
+		time.Sleep(5 * testKeepalive.idleTimeout())
+		_ = conn2.Send(ctx, []byte("hello"))
+	}()
+	go func() {
+		_, _ = conn2.Receive(ctx)
+	}()
+	data, err := conn1.Receive(ctx)
+	a.NoError(err)
+	a.Equal("hello", string(data))
+	a.NoError(conn1.Close(ctx))
+	a.NoError(conn2.Close(ctx))
+
+	// 对端失联时关闭连接
+	c1, c2 = net.Pipe()
+	defer func() {
+		_ = c2.Close()
+	}()
+	conn1 = NewRawConnection("conn1", c1, nil, testKeepalive)
+	_, err = conn1.Receive(ctx)
+	a.ErrorIs(err, ErrConnectionClosed)
+	a.ErrorIs(err, ErrHeartbeatTimeout)
+	a.ErrorIs(conn1.Send(ctx, []byte("hello")), ErrConnectionClosed)
+}
+
+// TestWebSocketConnection_Keepalive 测试 WebSocket 连接的心跳
+func TestWebSocketConnection_Keepalive(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	// 服务端不读取任何数据，因此不会响应 Ping
+	serverConns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		serverConns <- conn
+	}))
+	defer server.Close()
+
+	wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if !a.NoError(err) {
+		return
+	}
+	serverConn := <-serverConns
+	defer func() {
+		_ = serverConn.Close()
+	}()
+
+	conn := NewWebSocketConnection("client", wsConn, testKeepalive)
+	start := time.Now()
+	_, err = conn.Receive(ctx)
+	a.ErrorIs(err, ErrConnectionClosed)
+	a.ErrorIs(err, ErrHeartbeatTimeout)
+	a.Less(time.Since(start), 10*testKeepalive.idleTimeout())
+}
@@ -1,6 +1,9 @@
 package streams
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // Stream 流
 type Stream interface {
@@ -14,6 +17,32 @@ type Stream interface {
 	ConnectionEvents() <-chan ConnectionEvent
 }
 
+// ControlNotifier 可向流中的连接发送控制消息的流
+type ControlNotifier interface {
+	// NotifyConnections 向流中的连接发送控制消息
+	NotifyConnections(ctx context.Context, control Control)
+}
+
+// Signaler 可向流中执行命令的一端发送信号的流
+type Signaler interface {
+	// Signal 向流中执行命令的一端发送信号
+	Signal(ctx context.Context, signal string) error
+}
+
+// NotifyStreams 向 mgr 管理的所有流中的连接发送控制消息，如服务端即将停止时发送 ServerDraining
+func NotifyStreams(ctx context.Context, mgr Manager, control Control) error {
+	instances, err := mgr.ListStreams(ctx)
+	if err != nil {
+		return fmt.Errorf("list streams error: %w", err)
+	}
+	for _, ins := range instances {
+		if notifier, ok := ins.Stream.(ControlNotifier); ok {
+			notifier.NotifyConnections(ctx, control)
+		}
+	}
+	return nil
+}
+
 // ConnectionEvent 连接事件
 type ConnectionEvent struct {
 	// 事件类型
@@ -0,0 +1,85 @@
+package streamstest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yhlooo/scaf/pkg/clients/common"
+	"github.com/yhlooo/scaf/pkg/streams"
+)
+
+// connectionBufferLen 每个方向缓冲的消息数
+const connectionBufferLen = 64
+
+// NewConnectionPair 创建一对在内存中互相连接的连接，任一端关闭后两端都关闭
+func NewConnectionPair() (streams.Connection, streams.Connection) {
+	ch1 := make(chan []byte, connectionBufferLen)
+	ch2 := make(chan []byte, connectionBufferLen)
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	return &memoryConnection{in: ch1, out: ch2, closed: closed, closeOnce: once},
+		&memoryConnection{in: ch2, out: ch1, closed: closed, closeOnce: once}
+}
+
+// memoryConnection 在内存中的连接
+type memoryConnection struct {
+	in        <-chan []byte
+	out       chan<- []byte
+	closed    chan struct{}
+	closeOnce *sync.Once
+}
+
+var _ streams.Connection = (*memoryConnection)(nil)
+
+// Name 返回连接名
+func (conn *memoryConnection) Name() string {
+	return "memory"
+}
+
+// Send 发送
+func (conn *memoryConnection) Send(ctx context.Context, data []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.closed:
+		return streams.ErrConnectionClosed
+	case conn.out <- data:
+		return nil
+	}
+}
+
+// Receive 接收
+func (conn *memoryConnection) Receive(ctx context.Context) ([]byte, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-conn.closed:
+		return nil, streams.ErrConnectionClosed
+	case data := <-conn.in:
+		return data, nil
+	}
+}
+
+// Close 关闭连接
+func (conn *memoryConnection) Close(context.Context) error {
+	conn.closeOnce.Do(func() {
+		close(conn.closed)
+	})
+	return nil
+}
+
+// NewClient 创建连接任意流时都返回 conn 的客户端，只能用于连接流
+func NewClient(conn streams.Connection) common.Client {
+	return &client{conn: conn}
+}
+
+// client 连接流时返回指定连接的客户端
+type client struct {
+	common.Client
+	conn streams.Connection
+}
+
+// ConnectStream 连接到流
+func (c *client) ConnectStream(context.Context, string, common.ConnectStreamOptions) (streams.Connection, error) {
+	return c.conn, nil
+}
@@ -1,7 +1,6 @@
 package streams
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -12,32 +11,61 @@ import (
 )
 
 const (
-	bufferedStreamLoggerName       = "buffered-stream"
-	bufferedStreamBufferChannelLen = 256
-	bufferedStreamRetryInterval    = time.Second
+	bufferedStreamLoggerName    = "buffered-stream"
+	bufferedStreamRetryInterval = time.Second
+
+	// bufferedStreamBatchMaxCount 一次批量发送的最大消息数
+	bufferedStreamBatchMaxCount = 64
+	// bufferedStreamBatchMaxBytes 一次批量发送的最大字节数
+	bufferedStreamBatchMaxBytes = 64 << 10 // 64KiB
+	// bufferedStreamSpliceMinSize 使用 splice 直接转发的最小数据大小，更小的数据经过队列批量发送更高效
+	bufferedStreamSpliceMinSize = 32 << 10 // 32KiB
 )
 
+// BufferedStreamOptions BufferedStream 选项
+type BufferedStreamOptions struct {
+	// 每个方向已接收但还未发出的最大字节数，为 0 时使用 DefaultStreamWindow
+	Window int64
+	// 限制所有流已接收但还未发出的总字节数的调度器，为 nil 时不限制
+	Scheduler *FlowScheduler
+}
+
 // NewBufferedStream 创建 BufferedStream
-func NewBufferedStream() *BufferedStream {
+func NewBufferedStream(opts BufferedStreamOptions) *BufferedStream {
+	if opts.Window <= 0 {
+		opts.Window = DefaultStreamWindow
+	}
 	return &BufferedStream{
+		opts:    opts,
 		eventCh: make(chan ConnectionEvent),
 	}
 }
 
 // BufferedStream 带缓冲的流
+//
+// 每个方向的数据经过带流控的队列转发：从一个连接接收的数据先放入发往另一个连接的队列，
+// 由另一个连接的发送协程按顺序发出。另一个连接还未加入时数据留在队列中，队列满时暂停接收
 type BufferedStream struct {
+	opts BufferedStreamOptions
+
 	lock   sync.RWMutex
 	active bool
+	// 流停止时关闭
+	done chan struct{}
 
-	connA       Connection
-	connABuffCh chan []byte
-	connB       Connection
-	connBBuffCh chan []byte
+	connA Connection
+	// 发往 connA 的消息队列
+	connAQueue *flowQueue
+	connB      Connection
+	// 发往 connB 的消息队列
+	connBQueue *flowQueue
 
 	eventCh chan ConnectionEvent
 }
 
 var _ Stream = &BufferedStream{}
+var _ ControlNotifier = &BufferedStream{}
+var _ Signaler = &BufferedStream{}
 
 // Start 开始传输
 func (s *BufferedStream) Start(_ context.Context) error {
@@ -47,6 +75,12 @@ func (s *BufferedStream) Start(_ context.Context) error {
 		return ErrStreamAlreadyStarted
 	}
 	s.active = true
+	s.done = make(chan struct{})
+	s.connAQueue = newFlowQueue(s.opts.Window, s.opts.Scheduler)
+	s.connBQueue = newFlowQueue(s.opts.Window, s.opts.Scheduler)
+	// 发往一个连接的数据来自另一个连接，数据发出后通知另一个连接
+	s.connAQueue.windowUpdates = s.connBQueue
+	s.connBQueue.windowUpdates = s.connAQueue
 	return nil
 }
 
@@ -69,21 +103,19 @@ func (s *BufferedStream) Join(ctx context.Context, conn Connection) error {
 		return ErrStreamAlreadyStopped
 	}
 
+	// 连接离开时关闭
+	left := make(chan struct{})
 	switch {
 	case s.connA == nil:
 		s.connA = conn
-		if s.connBBuffCh == nil {
-			s.connBBuffCh = make(chan []byte, bufferedStreamBufferChannelLen)
-		}
-		go s.flushBuff(ctx, s.connABuffCh, s.connA)
-		go s.handleConn(ctx, &s.connA, &s.connB, s.connBBuffCh)
+		s.notifyPeerJoined(s.connAQueue, s.connBQueue, s.connB)
+		go s.writeConn(ctx, conn, s.connAQueue, left, s.done)
+		go s.handleConn(ctx, &s.connA, &s.connB, s.connBQueue, left, s.done)
 	case s.connB == nil:
 		s.connB = conn
-		if s.connABuffCh == nil {
-			s.connABuffCh = make(chan []byte, bufferedStreamBufferChannelLen)
-		}
-		go s.flushBuff(ctx, s.connBBuffCh, s.connB)
-		go s.handleConn(ctx, &s.connB, &s.connA, s.connABuffCh)
+		s.notifyPeerJoined(s.connBQueue, s.connAQueue, s.connA)
+		go s.writeConn(ctx, conn, s.connBQueue, left, s.done)
+		go s.handleConn(ctx, &s.connB, &s.connA, s.connAQueue, left, s.done)
 	default:
 		// 满员了，不能加入了
 		return ErrStreamIsFull
@@ -97,21 +129,32 @@ func (s *BufferedStream) Join(ctx context.Context, conn Connection) error {
 	return nil
 }
 
-// handleConn 处理连接
-func (s *BufferedStream) handleConn(ctx context.Context, connRP, connWP *Connection, writeBuffCh chan<- []byte) {
+// handleConn 从连接接收数据放入发往另一个连接的队列
+func (s *BufferedStream) handleConn(
+	ctx context.Context,
+	connRP, connWP *Connection,
+	writeQueue *flowQueue,
+	left, done chan struct{},
+) {
 	logger := logr.FromContextOrDiscard(ctx)
 
 	s.lock.RLock()
 	if *connRP == nil {
 		s.lock.RUnlock()
+		close(left)
 		return
 	}
 	connR := *connRP
 	s.lock.RUnlock()
 	defer func() {
+		close(left)
 		_ = connR.Close(ctx)
 		s.lock.Lock()
 		*connRP = nil
+		if *connWP != nil {
+			// 通知仍在流中的连接对端已离开
+			writeQueue.pushControl(Control{Type: PeerLeftControl})
+		}
 		if s.eventCh != nil {
 			select {
 			case s.eventCh <- ConnectionEvent{Type: LeftEvent, Connection: connR}:
@@ -122,7 +165,9 @@ func (s *BufferedStream) handleConn(ctx context.Context, connRP, connWP *Connect
 	}()
 
 	for {
-		data, err := connR.Receive(ctx)
+		// 额度用尽时在此等待，暂停从连接接收
+		// 另一个连接还未加入时，数据留在队列中给之后加入的连接
+		ok, err := s.receive(ctx, connR, connWP, writeQueue, done)
 		if err != nil {
 			if errors.Is(err, ErrConnectionClosed) {
 				// 连接已关闭
@@ -132,45 +177,152 @@ func (s *BufferedStream) handleConn(ctx context.Context, connRP, connWP *Connect
 			time.Sleep(bufferedStreamRetryInterval)
 			continue
 		}
+		if !ok {
+			// 流已停止
+			return
+		}
+	}
+}
+
+// receive 从 connR 接收一条数据放入发往另一个连接的队列，获得额度前流已停止时返回 false
+func (s *BufferedStream) receive(
+	ctx context.Context,
+	connR Connection,
+	connWP *Connection,
+	writeQueue *flowQueue,
+	done chan struct{},
+) (bool, error) {
+	if rawConn, ok := connR.(*RawConnection); ok && !fastPathDisabled.Load() {
+		return s.receiveRaw(ctx, rawConn, connWP, writeQueue, done)
+	}
+	msg, err := receiveDataMessage(ctx, connR)
+	if err != nil {
+		return true, err
+	}
+	return writeQueue.push(msg, done), nil
+}
+
+// receiveRaw 从原始字节流连接接收一条数据
+//
+// 先读出数据长度获得额度，再将数据读到池中的缓冲区。队列中没有等待发送的消息，
+// 且另一个连接也是可以 splice 的原始字节流连接时，将较大的数据直接转发给它，不经过队列
+func (s *BufferedStream) receiveRaw(
+	ctx context.Context,
+	connR *RawConnection,
+	connWP *Connection,
+	writeQueue *flowQueue,
+	done chan struct{},
+) (bool, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	if err := connR.getCloseErr(); err != nil {
+		return true, err
+	}
+	connR.recvLock.Lock()
+	defer connR.recvLock.Unlock()
 
+	size, err := connR.readDataHeader()
+	if err != nil {
+		return true, err
+	}
+	if !writeQueue.acquire(int64(size), done) {
+		return false, nil
+	}
+
+	if size >= bufferedStreamSpliceMinSize {
 		s.lock.RLock()
-		connW := *connWP
+		connW, _ := (*connWP).(*RawConnection)
 		s.lock.RUnlock()
-
-		if connW == nil {
-			// 另一个连接还未加入，先写到缓冲区
-			select {
-			case writeBuffCh <- bytes.Clone(data):
-			default:
+		if connW != nil && connR.canSpliceTo(connW) && writeQueue.idle() {
+			sendErr, recvErr := connR.spliceTo(connW, size)
+			writeQueue.releaseBytes(int64(size))
+			if sendErr != nil {
+				logger.Error(sendErr, "send to connection error", "conn", connW.Name())
 			}
-			continue
+			return true, recvErr
 		}
+	}
 
-		// 另一个连接已经加入
-		if err := connW.Send(ctx, data); err != nil {
-			logger.Error(err, "send to connection error", "conn", connW.Name())
-		}
+	data, err := connR.readPayload(size)
+	if err != nil {
+		writeQueue.releaseBytes(int64(size))
+		return true, err
 	}
+	return writeQueue.enqueue(Message{Data: data, pooled: true}), nil
 }
 
-// flushBuff 将缓冲区的内容刷到连接
-func (s *BufferedStream) flushBuff(ctx context.Context, buffCh <-chan []byte, conn Connection) {
+// writeConn 将队列中的消息按顺序发给连接，直到连接离开或流停止
+// 连接支持时将连续的多条较小的数据合并在一次写入中发送
+func (s *BufferedStream) writeConn(ctx context.Context, conn Connection, queue *flowQueue, left, done chan struct{}) {
 	logger := logr.FromContextOrDiscard(ctx)
 
+	batchSender, canBatch := conn.(BatchSender)
+	maxCount := 1
+	if canBatch && !fastPathDisabled.Load() {
+		maxCount = bufferedStreamBatchMaxCount
+	}
+	batch := make([]Message, 0, maxCount)
+	batchData := make([][]byte, 0, maxCount)
+
 	for {
 		select {
-		case content, ok := <-buffCh:
-			if !ok {
+		case <-left:
+			return
+		case <-done:
+			return
+		default:
+		}
+
+		batch = queue.popBatch(batch[:0], maxCount, bufferedStreamBatchMaxBytes)
+		if len(batch) == 0 {
+			select {
+			case <-left:
 				return
+			case <-done:
+				return
+			case <-queue.notify:
 			}
-			if err := conn.Send(ctx, content); err != nil {
-				logger.Error(err, "send to connection error", "conn", conn.Name())
-			}
-			logger.V(2).Info(fmt.Sprintf("send to connection: %q", content), "conn", conn.Name())
+			continue
+		}
+
+		var err error
+		switch {
+		case batch[0].Control != nil:
+			err = SendControl(ctx, conn, *batch[0].Control)
+		case len(batch) == 1:
+			err = conn.Send(ctx, batch[0].Data)
 		default:
+			batchData = batchData[:0]
+			for _, msg := range batch {
+				batchData = append(batchData, msg.Data)
+			}
+			err = batchSender.SendBatch(ctx, batchData)
+			clear(batchData)
+		}
+		if err != nil && batch[0].IsData() && errors.Is(err, ErrConnectionClosed) {
+			// 连接已关闭，数据留给之后加入的连接
+			queue.unpop(batch)
 			return
 		}
+		queue.finish(batch)
+		clear(batch)
+		if err != nil {
+			logger.Error(err, "send to connection error", "conn", conn.Name())
+		}
+	}
+}
+
+// notifyPeerJoined 新连接加入后，如果另一个连接 peer 已在流中，通知双方对端已加入
+// connQueue 和 peerQueue 分别是发往新连接和 peer 的队列，需持有锁调用
+func (s *BufferedStream) notifyPeerJoined(connQueue, peerQueue *flowQueue, peer Connection) {
+	if peer == nil {
+		// 队列中可能有发给之前的连接的控制消息，不再发给新连接
+		connQueue.resetControls(nil)
+		return
 	}
+	// 事件先于队列中缓冲的数据发给新连接
+	connQueue.resetControls(&Control{Type: PeerJoinedControl})
+	peerQueue.pushControl(Control{Type: PeerJoinedControl})
 }
 
 // Stop 停止传输
@@ -182,26 +334,23 @@ func (s *BufferedStream) Stop(ctx context.Context) error {
 		return ErrStreamAlreadyStopped
 	}
 
+	s.notifyConnections(ctx, Control{Type: StreamStoppingControl})
 	if s.connA != nil {
 		if err := s.connA.Close(ctx); err != nil {
 			logger.Error(err, "close connection error", "conn", s.connA.Name())
 		}
 		s.connA = nil
 	}
-	if s.connABuffCh != nil {
-		close(s.connABuffCh)
-		s.connABuffCh = nil
-	}
 	if s.connB != nil {
 		if err := s.connB.Close(ctx); err != nil {
 			logger.Error(err, "close connection error", "conn", s.connB.Name())
 		}
 		s.connB = nil
 	}
-	if s.connBBuffCh != nil {
-		close(s.connBBuffCh)
-		s.connBBuffCh = nil
-	}
+	// 停止等待额度的接收和发送协程，归还队列中的数据占用的额度
+	close(s.done)
+	s.connAQueue.close()
+	s.connBQueue.close()
 	s.active = false
 	close(s.eventCh)
 	s.eventCh = nil
@@ -209,6 +358,59 @@ func (s *BufferedStream) Stop(ctx context.Context) error {
 	return nil
 }
 
+// NotifyConnections 向流中的连接发送控制消息
+func (s *BufferedStream) NotifyConnections(_ context.Context, control Control) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if !s.active {
+		return
+	}
+	s.pushControl(control)
+}
+
+// Signal 向流中的连接发送信号控制消息，由执行命令的一端处理
+// 流中没有连接时返回 ErrStreamIsEmpty
+func (s *BufferedStream) Signal(_ context.Context, signal string) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if !s.active {
+		return ErrStreamAlreadyStopped
+	}
+	if !s.pushControl(Control{Type: SignalControl, Signal: signal}) {
+		return ErrStreamIsEmpty
+	}
+	return nil
+}
+
+// pushControl 将控制消息放入发往流中的连接的队列，流中没有连接时返回 false ，需持有锁调用
+// 经队列发送，与队列中的数据保持顺序，避免与发送协程并发发送
+func (s *BufferedStream) pushControl(control Control) bool {
+	pushed := false
+	if s.connA != nil {
+		s.connAQueue.pushControl(control)
+		pushed = true
+	}
+	if s.connB != nil {
+		s.connBQueue.pushControl(control)
+		pushed = true
+	}
+	return pushed
+}
+
+// notifyConnections 直接向流中的连接发送控制消息，需持有锁调用
+func (s *BufferedStream) notifyConnections(ctx context.Context, control Control) {
+	logger := logr.FromContextOrDiscard(ctx)
+
+	for _, conn := range []Connection{s.connA, s.connB} {
+		if conn == nil {
+			continue
+		}
+		if err := SendControl(ctx, conn, control); err != nil {
+			logger.Error(err, "send control error", "conn", conn.Name())
+		}
+	}
+}
+
 // ConnectionEvents 获取连接事件通道
 func (s *BufferedStream) ConnectionEvents() <-chan ConnectionEvent {
 	return s.eventCh
@@ -0,0 +1,345 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTCPConnPair 创建一对通过本地回环 TCP 连接的 net.Conn
+func newTCPConnPair(t testing.TB) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatalf("accept error")
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client, server
+}
+
+// newTestRawStream 创建两端都是原始字节流连接的 BufferedStream ，返回两端的客户端连接
+func newTestRawStream(t testing.TB, ctx context.Context) (*RawConnection, *RawConnection) {
+	s := NewBufferedStream(BufferedStreamOptions{})
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("start stream error: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = s.Stop(context.Background())
+	})
+
+	var clients []*RawConnection
+	for _, name := range []string{"a", "b"} {
+		client, server := newTCPConnPair(t)
+		if err := s.Join(ctx, NewRawConnection(name, server, nil, KeepaliveOptions{})); err != nil {
+			t.Fatalf("join stream error: %v", err)
+		}
+		clients = append(clients, NewRawConnection(name, client, nil, KeepaliveOptions{}))
+	}
+	return clients[0], clients[1]
+}
+
+// TestBufferedStream_RawFastPath 测试两端都是原始字节流连接时转发的数据完整且有序
+func TestBufferedStream_RawFastPath(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	connA, connB := newTestRawStream(t, ctx)
+
+	// 大小交替的数据分别经过队列批量发送和 splice 直接转发
+	sizes := []int{0, 1, 100, 64 << 10, 10, 1 << 20, 32<<10 - 1, 32 << 10, 3 << 20, 1}
+	var sent [][]byte
+	for i, size := range sizes {
+		sent = append(sent, bytes.Repeat([]byte{byte('a' + i)}, size))
+	}
+	go func() {
+		for _, data := range sent {
+			a.NoError(connA.Send(ctx, data))
+		}
+	}()
+	for i, data := range sent {
+		received, err := connB.Receive(ctx)
+		if !a.NoError(err) {
+			return
+		}
+		a.Equal(len(data), len(received), i)
+		a.True(bytes.Equal(data, received), i)
+	}
+
+	// 反方向
+	a.NoError(connB.SendBatch(ctx, [][]byte{[]byte("hello"), []byte("world")}))
+	for _, expected := range []string{"hello", "world"} {
+		received, err := connA.Receive(ctx)
+		if a.NoError(err) {
+			a.Equal(expected, string(received))
+		}
+	}
+}
+
+// TestBufferedStream_WindowUpdate 测试数据发给对端后向发送方发送流控窗口更新
+func TestBufferedStream_WindowUpdate(t *testing.T) {
+	a := assert.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s := NewBufferedStream(BufferedStreamOptions{Window: 4 << 10})
+	a.NoError(s.Start(ctx))
+	defer func() { _ = s.Stop(context.Background()) }()
+	var clients []*RawConnection
+	for _, name := range []string{"a", "b"} {
+		client, server := newTCPConnPair(t)
+		serverConn := NewRawConnection(name, server, nil, KeepaliveOptions{})
+		serverConn.SetProtocolVersion(ProtocolVersion)
+		a.NoError(s.Join(ctx, serverConn))
+		clients = append(clients, NewRawConnection(name, client, nil, KeepaliveOptions{}))
+	}
+	connA, connB := clients[0], clients[1]
+
+	// 发出流窗口的 1/4 后发送方收到窗口更新
+	for i := 0; i < 2; i++ {
+		a.NoError(connA.Send(ctx, bytes.Repeat([]byte("a"), 512)))
+		data, err := connB.Receive(ctx)
+		if a.NoError(err) {
+			a.Len(data, 512)
+		}
+	}
+	increment := make(chan int64, 1)
+	go func() {
+		for {
+			msg, err := connA.ReceiveMessage(ctx)
+			if err != nil {
+				return
+			}
+			if msg.Control != nil && msg.Control.Type == WindowUpdateControl {
+				increment <- msg.Control.WindowIncrement
+				return
+			}
+		}
+	}()
+	select {
+	case n := <-increment:
+		a.Equal(int64(1<<10), n)
+	case <-time.After(5 * time.Second):
+		a.Fail("window update not received")
+		_ = connA.Close(ctx)
+	}
+}
+
+// gatedConnection 测试用的连接
+//
+// Receive 返回写入 recvCh 的数据，每次开始接收时向 receiving 发送信号；
+// Send 在 gate 关闭前阻塞，之后将数据写入 sentCh
+type gatedConnection struct {
+	name      string
+	recvCh    chan []byte
+	receiving chan struct{}
+	gate      chan struct{}
+	sentCh    chan []byte
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ Connection = (*gatedConnection)(nil)
+
+// newGatedConnection 创建 gatedConnection
+func newGatedConnection(name string) *gatedConnection {
+	return &gatedConnection{
+		name:      name,
+		recvCh:    make(chan []byte),
+		receiving: make(chan struct{}, 16),
+		gate:      make(chan struct{}),
+		sentCh:    make(chan []byte, 16),
+		closed:    make(chan struct{}),
+	}
+}
+
+// Name 返回连接名
+func (conn *gatedConnection) Name() string {
+	return conn.name
+}
+
+// Send 发送
+func (conn *gatedConnection) Send(ctx context.Context, data []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-conn.closed:
+		return ErrConnectionClosed
+	case <-conn.gate:
+	}
+	conn.sentCh <- bytes.Clone(data)
+	return nil
+}
+
+// Receive 接收
+func (conn *gatedConnection) Receive(ctx context.Context) ([]byte, error) {
+	select {
+	case conn.receiving <- struct{}{}:
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-conn.closed:
+		return nil, ErrConnectionClosed
+	case data := <-conn.recvCh:
+		return data, nil
+	}
+}
+
+// Close 关闭连接
+func (conn *gatedConnection) Close(_ context.Context) error {
+	conn.closeOnce.Do(func() {
+		close(conn.closed)
+	})
+	return nil
+}
+
+// TestBufferedStream_JoinWithBlockingPeer 测试另一个连接发送阻塞时加入和停止流不被阻塞，
+// 加入前缓冲的数据先于之后的数据按顺序发出
+func TestBufferedStream_JoinWithBlockingPeer(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+
+	s := NewBufferedStream(BufferedStreamOptions{})
+	if !a.NoError(s.Start(ctx)) {
+		return
+	}
+	stopped := false
+	defer func() {
+		if !stopped {
+			_ = s.Stop(ctx)
+		}
+	}()
+
+	connA := newGatedConnection("a")
+	connB := newGatedConnection("b")
+	if !a.NoError(s.Join(ctx, connA)) {
+		return
+	}
+
+	// b 加入前 a 发送的数据留在队列中
+	<-connA.receiving
+	for _, data := range []string{"1", "2", "3"} {
+		connA.recvCh <- []byte(data)
+		<-connA.receiving
+	}
+
+	// b 的发送阻塞时加入不被阻塞
+	joined := make(chan error, 1)
+	go func() {
+		joined <- s.Join(ctx, connB)
+	}()
+	select {
+	case err := <-joined:
+		a.NoError(err)
+	case <-time.After(5 * time.Second):
+		a.Fail("join blocked by peer send")
+		return
+	}
+	connA.recvCh <- []byte("4")
+	<-connA.receiving
+
+	close(connB.gate)
+	for _, expected := range []string{"1", "2", "3", "4"} {
+		select {
+		case data := <-connB.sentCh:
+			a.Equal(expected, string(data))
+		case <-time.After(5 * time.Second):
+			a.Fail("wait for data timeout", expected)
+			return
+		}
+	}
+
+	// a 的发送阻塞时停止流不被阻塞
+	<-connB.receiving
+	connB.recvCh <- []byte("5")
+	<-connB.receiving
+	stopDone := make(chan error, 1)
+	go func() {
+		stopDone <- s.Stop(ctx)
+	}()
+	select {
+	case err := <-stopDone:
+		a.NoError(err)
+		stopped = true
+	case <-time.After(5 * time.Second):
+		a.Fail("stop blocked by peer send")
+	}
+}
+
+// BenchmarkBufferedStream_Raw 测试两端都是原始字节流连接时转发的吞吐和内存分配
+//
+// baseline 禁用快速路径，每条数据都分配新的缓冲区、单独发送；
+// fastpath 复用缓冲区、批量发送较小的数据、通过 splice 转发较大的数据
+func BenchmarkBufferedStream_Raw(b *testing.B) {
+	for _, size := range []int{64, 4 << 10, 256 << 10} {
+		for _, mode := range []string{"baseline", "fastpath"} {
+			b.Run(fmt.Sprintf("size=%d/%s", size, mode), func(b *testing.B) {
+				fastPathDisabled.Store(mode == "baseline")
+				defer fastPathDisabled.Store(false)
+				benchmarkRawStream(b, size)
+			})
+		}
+	}
+}
+
+// benchmarkRawStream 通过原始字节流连接的流发送 b.N 条大小为 size 的数据
+func benchmarkRawStream(b *testing.B, size int) {
+	ctx := context.Background()
+	connA, connB := newTestRawStream(b, ctx)
+
+	data := bytes.Repeat([]byte{'x'}, size)
+	errCh := make(chan error, 1)
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	go func() {
+		for i := 0; i < b.N; i++ {
+			if err := connA.Send(ctx, data); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		errCh <- nil
+	}()
+	for i := 0; i < b.N; i++ {
+		received, err := connB.Receive(ctx)
+		if err != nil {
+			b.Fatalf("receive error: %v", err)
+		}
+		if len(received) != size {
+			b.Fatalf("expected %d bytes, got %d", size, len(received))
+		}
+		putBuffer(received)
+	}
+	b.StopTimer()
+	if err := <-errCh; err != nil {
+		b.Fatalf("send error: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+
+	metav1 "github.com/yhlooo/scaf/pkg/apis/meta/v1"
+)
+
+const (
+	relayStreamLoggerName = "relay-stream"
+)
+
+// RelayDialFunc 为加入流的连接建立上游连接的方法
+type RelayDialFunc func(ctx context.Context, conn Connection) (Connection, error)
+
+// NewRelayStream 创建 RelayStream
+func NewRelayStream(dial RelayDialFunc) *RelayStream {
+	return &RelayStream{
+		dial:    dial,
+		relays:  map[*relay]struct{}{},
+		eventCh: make(chan ConnectionEvent),
+	}
+}
+
+// RelayStream 中继流
+//
+// 流本身不在本地，加入的每个连接都会通过 dial 建立一个到上游的连接，并在两个连接之间双向转发数据
+type RelayStream struct {
+	dial RelayDialFunc
+
+	lock   sync.RWMutex
+	active bool
+	relays map[*relay]struct{}
+
+	eventCh chan ConnectionEvent
+}
+
+var _ Stream = &RelayStream{}
+var _ ControlNotifier = &RelayStream{}
+
+// relay 一对互相转发的连接
+type relay struct {
+	downstream Connection
+	upstream   Connection
+	cancel     context.CancelFunc
+}
+
+// Start 开始传输
+func (s *RelayStream) Start(_ context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.active {
+		return ErrStreamAlreadyStarted
+	}
+	s.active = true
+	return nil
+}
+
+// Join 将连接加入流
+func (s *RelayStream) Join(ctx context.Context, conn Connection) error {
+	logger := logr.FromContextOrDiscard(ctx).WithName(relayStreamLoggerName)
+
+	if conn == nil {
+		return fmt.Errorf("cannot join nil connection")
+	}
+
+	s.lock.RLock()
+	active := s.active
+	s.lock.RUnlock()
+	if !active {
+		return ErrStreamAlreadyStopped
+	}
+
+	// 中继的生命周期与发起加入的请求无关
+	relayCTX, cancel := context.WithCancel(logr.NewContext(context.WithoutCancel(ctx), logger))
+	upstream, err := s.dial(relayCTX, conn)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("dial upstream error: %w", err)
+	}
+	if logger.V(1).Enabled() {
+		conn = ConnectionWithLog{Connection: conn}
+		upstream = ConnectionWithLog{Connection: upstream}
+	}
+
+	r := &relay{downstream: conn, upstream: upstream, cancel: cancel}
+	s.lock.Lock()
+	if !s.active {
+		s.lock.Unlock()
+		cancel()
+		_ = upstream.Close(relayCTX)
+		return ErrStreamAlreadyStopped
+	}
+	s.relays[r] = struct{}{}
+	select {
+	case s.eventCh <- ConnectionEvent{Type: JoinedEvent, Connection: conn}:
+	default:
+	}
+	s.lock.Unlock()
+
+	go s.runRelay(relayCTX, r)
+
+	return nil
+}
+
+// runRelay 在连接之间双向转发数据，直到任意一方结束
+func (s *RelayStream) runRelay(ctx context.Context, r *relay) {
+	done := make(chan struct{}, 2)
+	go forward(ctx, r.downstream, r.upstream, done)
+	go forward(ctx, r.upstream, r.downstream, done)
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+	r.cancel()
+	_ = r.downstream.Close(ctx)
+	_ = r.upstream.Close(ctx)
+
+	s.lock.Lock()
+	delete(s.relays, r)
+	if s.eventCh != nil {
+		select {
+		case s.eventCh <- ConnectionEvent{Type: LeftEvent, Connection: r.downstream}:
+		default:
+		}
+	}
+	s.lock.Unlock()
+}
+
+// forward 将从 connR 接收的数据发送到 connW ，直到连接关闭
+// 上游发来的控制消息和错误消息也会转发给下游
+func forward(ctx context.Context, connR, connW Connection, done chan<- struct{}) {
+	logger := logr.FromContextOrDiscard(ctx)
+	defer func() {
+		done <- struct{}{}
+	}()
+
+	for {
+		msg, err := ReceiveMessage(ctx, connR)
+		if err == nil && msg.Error != nil {
+			err = msg.Error
+		}
+		if err != nil {
+			var status *metav1.Status
+			if errors.As(err, &status) {
+				if err := SendError(ctx, connW, status); err != nil {
+					logger.Error(err, "send error to connection error", "conn", connW.Name())
+				}
+			}
+			if ctx.Err() == nil && !errors.Is(err, ErrConnectionClosed) {
+				logger.Error(err, "receive from connection error", "conn", connR.Name())
+			}
+			return
+		}
+		if msg.Control != nil {
+			if err := SendControl(ctx, connW, *msg.Control); err != nil {
+				logger.Error(err, "send control error", "conn", connW.Name())
+			}
+			continue
+		}
+		if err := connW.Send(ctx, msg.Data); err != nil {
+			if ctx.Err() == nil && !errors.Is(err, ErrConnectionClosed) {
+				logger.Error(err, "send to connection error", "conn", connW.Name())
+			}
+			return
+		}
+	}
+}
+
+// Stop 停止传输
+func (s *RelayStream) Stop(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.active {
+		return ErrStreamAlreadyStopped
+	}
+
+	s.notifyConnections(ctx, Control{Type: StreamStoppingControl})
+	for r := range s.relays {
+		r.cancel()
+	}
+	s.active = false
+	close(s.eventCh)
+	s.eventCh = nil
+
+	return nil
+}
+
+// NotifyConnections 向加入流的连接发送控制消息
+func (s *RelayStream) NotifyConnections(ctx context.Context, control Control) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	s.notifyConnections(ctx, control)
+}
+
+// notifyConnections 向加入流的连接发送控制消息，需持有锁调用
+func (s *RelayStream) notifyConnections(ctx context.Context, control Control) {
+	logger := logr.FromContextOrDiscard(ctx).WithName(relayStreamLoggerName)
+
+	for r := range s.relays {
+		if err := SendControl(ctx, r.downstream, control); err != nil {
+			logger.Error(err, "send control error", "conn", r.downstream.Name())
+		}
+	}
+}
+
+// ConnectionEvents 获取连接事件通道
+func (s *RelayStream) ConnectionEvents() <-chan ConnectionEvent {
+	return s.eventCh
+}
@@ -0,0 +1,157 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/yhlooo/scaf/pkg/utils/units"
+)
+
+const (
+	// 进度条宽度
+	barWidth = 24
+	// 进度条中显示的文件名的最大长度
+	maxFileNameWidth = 32
+)
+
+// NewBarHandler 创建将进度以进度条形式输出到 w 的 Handler
+//
+// 每次输出覆盖上一次输出的行，结束时换行
+func NewBarHandler(w io.Writer) Handler {
+	lastLen := 0
+	return func(stats Stats) {
+		line := FormatBar(stats)
+		// 用空格覆盖上一次输出中更长的部分
+		padding := ""
+		if n := len(line); n < lastLen {
+			padding = strings.Repeat(" ", lastLen-n)
+		}
+		lastLen = len(line)
+		end := ""
+		if stats.Done {
+			end = "\n"
+		}
+		_, _ = fmt.Fprintf(w, "\r%s%s%s", line, padding, end)
+	}
+}
+
+// FormatBar 返回进度条形式的进度
+func FormatBar(stats Stats) string {
+	var parts []string
+
+	if percent := stats.Percent(); percent >= 0 {
+		filled := int(percent / 100 * barWidth)
+		bar := strings.Repeat("=", filled)
+		if filled < barWidth {
+			bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+		}
+		parts = append(parts, fmt.Sprintf("%5.1f%% [%s]", percent, bar))
+		parts = append(parts, FormatBytes(stats.Bytes)+"/"+FormatBytes(stats.TotalBytes))
+	} else {
+		parts = append(parts, FormatBytes(stats.Bytes))
+	}
+	parts = append(parts, FormatBytes(int64(stats.Rate))+"/s")
+
+	if stats.TotalFiles > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d files", stats.Files, stats.TotalFiles))
+	} else if stats.Files > 0 {
+		parts = append(parts, fmt.Sprintf("%d files", stats.Files))
+	}
+
+	switch {
+	case stats.Err != nil:
+		parts = append(parts, "failed")
+	case stats.Done:
+		parts = append(parts, "in "+stats.Elapsed.Round(time.Second).String())
+	default:
+		if stats.ETA > 0 {
+			parts = append(parts, "ETA "+stats.ETA.Round(time.Second).String())
+		}
+		if name := stats.CurrentFile; name != "" {
+			if len(name) > maxFileNameWidth {
+				name = "..." + name[len(name)-maxFileNameWidth+3:]
+			}
+			parts = append(parts, name)
+		}
+	}
+
+	return strings.Join(parts, "  ")
+}
+
+// FormatBytes 返回使用 IEC 格式单位的字节数
+func FormatBytes(n int64) string {
+	if n < units.Ki {
+		return fmt.Sprintf("%dB", n)
+	}
+	return units.NewIECValue(n).RoundString(2) + "B"
+}
+
+// Event 机器可读的进度事件
+type Event struct {
+	// 事件类型
+	// progress 、 done 或 error
+	Event string `json:"event"`
+	// 总字节数，未知时为 0
+	TotalBytes int64 `json:"totalBytes"`
+	// 总文件数，未知时为 0
+	TotalFiles int `json:"totalFiles"`
+	// 已传输的字节数
+	Bytes int64 `json:"bytes"`
+	// 已传输的文件数
+	Files int `json:"files"`
+	// 已传输字节数的百分比，未知时为 -1
+	Percent float64 `json:"percent"`
+	// 正在传输的文件
+	CurrentFile string `json:"currentFile,omitempty"`
+	// 已用时间（单位：秒）
+	Elapsed float64 `json:"elapsed"`
+	// 传输速率（单位：字节每秒）
+	Rate float64 `json:"rate"`
+	// 预计剩余时间（单位：秒），未知时为 0
+	ETA float64 `json:"eta"`
+	// 错误信息
+	Error string `json:"error,omitempty"`
+}
+
+// 进度事件类型
+const (
+	EventProgress = "progress"
+	EventDone     = "done"
+	EventError    = "error"
+)
+
+// NewEvent 根据进度状态创建进度事件
+func NewEvent(stats Stats) Event {
+	e := Event{
+		Event:       EventProgress,
+		TotalBytes:  stats.TotalBytes,
+		TotalFiles:  stats.TotalFiles,
+		Bytes:       stats.Bytes,
+		Files:       stats.Files,
+		Percent:     math.Round(stats.Percent()*100) / 100,
+		CurrentFile: stats.CurrentFile,
+		Elapsed:     math.Round(stats.Elapsed.Seconds()*1000) / 1000,
+		Rate:        math.Round(stats.Rate),
+		ETA:         math.Round(stats.ETA.Seconds()*1000) / 1000,
+	}
+	switch {
+	case stats.Err != nil:
+		e.Event = EventError
+		e.Error = stats.Err.Error()
+	case stats.Done:
+		e.Event = EventDone
+	}
+	return e
+}
+
+// NewJSONHandler 创建将进度以 JSON 格式的事件逐行输出到 w 的 Handler
+func NewJSONHandler(w io.Writer) Handler {
+	encoder := json.NewEncoder(w)
+	return func(stats Stats) {
+		_ = encoder.Encode(NewEvent(stats))
+	}
+}
@@ -0,0 +1,276 @@
+package progress
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultInterval 默认报告进度的间隔
+	DefaultInterval = 500 * time.Millisecond
+	// 计算速率的时间窗口
+	rateWindow = 5 * time.Second
+)
+
+// Stats 进度状态
+type Stats struct {
+	// 总字节数，未知时为 0
+	TotalBytes int64
+	// 总文件数，未知时为 0
+	TotalFiles int
+	// 已传输的字节数
+	Bytes int64
+	// 已传输的文件数
+	Files int
+	// 正在传输的文件
+	CurrentFile string
+	// 已用时间
+	Elapsed time.Duration
+	// 最近的传输速率（单位：字节每秒）
+	Rate float64
+	// 预计剩余时间，未知时为 0
+	ETA time.Duration
+	// 是否已结束
+	Done bool
+	// 结束时的错误，为空表示成功
+	Err error
+}
+
+// Percent 返回已传输字节数的百分比，总字节数未知时返回 -1
+func (s Stats) Percent() float64 {
+	if s.TotalBytes <= 0 {
+		if s.Done {
+			return 100
+		}
+		return -1
+	}
+	return min(float64(s.Bytes)*100/float64(s.TotalBytes), 100)
+}
+
+// Handler 处理进度状态
+type Handler func(stats Stats)
+
+// NewTracker 创建 Tracker ， handler 为空时返回 nil
+//
+// Tracker 的方法对 nil 也是安全的，因此不需要报告进度时可以直接使用 nil
+func NewTracker(handler Handler) *Tracker {
+	if handler == nil {
+		return nil
+	}
+	return &Tracker{
+		handler:  handler,
+		interval: DefaultInterval,
+	}
+}
+
+// Tracker 跟踪传输进度并定期报告
+type Tracker struct {
+	handler  Handler
+	interval time.Duration
+
+	bytes atomic.Int64
+
+	lock        sync.Mutex
+	totalBytes  int64
+	totalFiles  int
+	files       int
+	currentFile string
+	startTime   time.Time
+	samples     []rateSample
+	stop        context.CancelFunc
+	stopped     chan struct{}
+	finished    bool
+}
+
+// rateSample 计算速率的采样
+type rateSample struct {
+	time  time.Time
+	bytes int64
+}
+
+// SetTotal 设置总字节数和总文件数
+func (t *Tracker) SetTotal(bytes int64, files int) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.totalBytes = bytes
+	t.totalFiles = files
+}
+
+// AddBytes 增加已传输的字节数
+func (t *Tracker) AddBytes(n int64) {
+	if t == nil {
+		return
+	}
+	t.bytes.Add(n)
+}
+
+// StartFile 开始传输文件
+func (t *Tracker) StartFile(name string) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.currentFile = name
+}
+
+// FinishFile 完成传输文件
+func (t *Tracker) FinishFile() {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.files++
+}
+
+// Reader 返回读取时增加已传输字节数的 io.Reader
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &countingReader{r: r, t: t}
+}
+
+// Writer 返回写入时增加已传输字节数的 io.Writer
+func (t *Tracker) Writer(w io.Writer) io.Writer {
+	if t == nil {
+		return w
+	}
+	return &countingWriter{w: w, t: t}
+}
+
+// Start 开始定期报告进度，直到 Stop 或 ctx 结束
+func (t *Tracker) Start(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	if t.stop != nil {
+		t.lock.Unlock()
+		return
+	}
+	t.startTime = time.Now()
+	ctx, t.stop = context.WithCancel(ctx)
+	t.stopped = make(chan struct{})
+	t.lock.Unlock()
+
+	go func() {
+		defer close(t.stopped)
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.handler(t.Stats())
+			}
+		}
+	}()
+}
+
+// Stop 停止定期报告进度，并报告最终的进度， err 为传输失败的原因
+//
+// 只有第一次调用有效
+func (t *Tracker) Stop(err error) {
+	if t == nil {
+		return
+	}
+	t.lock.Lock()
+	stop, stopped := t.stop, t.stopped
+	if stop == nil || t.finished {
+		t.lock.Unlock()
+		return
+	}
+	t.finished = true
+	t.lock.Unlock()
+
+	stop()
+	<-stopped
+
+	stats := t.Stats()
+	stats.Done = true
+	stats.Err = err
+	if err == nil {
+		stats.CurrentFile = ""
+	}
+	stats.ETA = 0
+	if stats.Elapsed > 0 {
+		stats.Rate = float64(stats.Bytes) / stats.Elapsed.Seconds()
+	}
+	t.handler(stats)
+}
+
+// Stats 返回当前的进度状态
+func (t *Tracker) Stats() Stats {
+	if t == nil {
+		return Stats{}
+	}
+	now := time.Now()
+	bytes := t.bytes.Load()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stats := Stats{
+		TotalBytes:  t.totalBytes,
+		TotalFiles:  t.totalFiles,
+		Bytes:       bytes,
+		Files:       t.files,
+		CurrentFile: t.currentFile,
+	}
+	if !t.startTime.IsZero() {
+		stats.Elapsed = now.Sub(t.startTime)
+	}
+
+	// 根据时间窗口内的采样计算速率
+	t.samples = append(t.samples, rateSample{time: now, bytes: bytes})
+	i := 0
+	for i < len(t.samples)-1 && now.Sub(t.samples[i].time) > rateWindow {
+		i++
+	}
+	t.samples = t.samples[i:]
+	if first := t.samples[0]; now.Sub(first.time) > 0 {
+		stats.Rate = float64(bytes-first.bytes) / now.Sub(first.time).Seconds()
+	} else if stats.Elapsed > 0 {
+		stats.Rate = float64(bytes) / stats.Elapsed.Seconds()
+	}
+
+	if stats.TotalBytes > bytes && stats.Rate > 0 {
+		stats.ETA = time.Duration(float64(stats.TotalBytes-bytes) / stats.Rate * float64(time.Second))
+	}
+	return stats
+}
+
+// countingReader 读取时增加已传输字节数的 io.Reader
+type countingReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+// Read 读
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.t.AddBytes(int64(n))
+	return n, err
+}
+
+// countingWriter 写入时增加已传输字节数的 io.Writer
+type countingWriter struct {
+	w io.Writer
+	t *Tracker
+}
+
+// Write 写
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.t.AddBytes(int64(n))
+	return n, err
+}
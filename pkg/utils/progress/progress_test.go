@@ -0,0 +1,108 @@
+package progress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTracker 测试跟踪并报告传输进度
+func TestTracker(t *testing.T) {
+	a := assert.New(t)
+
+	// nil 也可以使用
+	var nilTracker *Tracker
+	nilTracker.Start(context.Background())
+	nilTracker.AddBytes(1)
+	a.Equal(Stats{}, nilTracker.Stats())
+	a.Nil(NewTracker(nil))
+
+	var events []Stats
+	tracker := NewTracker(func(stats Stats) {
+		events = append(events, stats)
+	})
+	tracker.SetTotal(1000, 2)
+	tracker.Start(context.Background())
+
+	tracker.StartFile("a")
+	_, err := io.Copy(io.Discard, tracker.Reader(strings.NewReader(strings.Repeat("a", 400))))
+	a.NoError(err)
+	tracker.FinishFile()
+	tracker.StartFile("b")
+	time.Sleep(10 * time.Millisecond)
+
+	stats := tracker.Stats()
+	a.Equal(int64(400), stats.Bytes)
+	a.Equal(1, stats.Files)
+	a.Equal("b", stats.CurrentFile)
+	a.Equal(40.0, stats.Percent())
+	a.Greater(stats.Rate, 0.0)
+	a.Greater(stats.ETA, time.Duration(0))
+
+	_, err = tracker.Writer(io.Discard).Write(make([]byte, 600))
+	a.NoError(err)
+	tracker.FinishFile()
+	tracker.Stop(nil)
+	tracker.Stop(errors.New("ignored"))
+
+	if a.Len(events, 1) {
+		done := events[0]
+		a.True(done.Done)
+		a.NoError(done.Err)
+		a.Equal(int64(1000), done.Bytes)
+		a.Equal(2, done.Files)
+		a.Equal(100.0, done.Percent())
+		a.Equal(time.Duration(0), done.ETA)
+		a.Empty(done.CurrentFile)
+	}
+}
+
+// TestHandlers 测试输出进度
+func TestHandlers(t *testing.T) {
+	a := assert.New(t)
+
+	stats := Stats{
+		TotalBytes:  4 << 20,
+		TotalFiles:  3,
+		Bytes:       1 << 20,
+		Files:       1,
+		CurrentFile: "dir/file",
+		Elapsed:     2 * time.Second,
+		Rate:        512 << 10,
+		ETA:         6 * time.Second,
+	}
+	a.Equal(
+		" 25.0% [======>                 ]  1.00MiB/4.00MiB  512.00KiB/s  1/3 files  ETA 6s  dir/file",
+		FormatBar(stats),
+	)
+
+	buf := &bytes.Buffer{}
+	handler := NewJSONHandler(buf)
+	handler(stats)
+	stats.Err = errors.New("broken pipe")
+	stats.Done = true
+	handler(stats)
+
+	var events []Event
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		e := Event{}
+		a.NoError(decoder.Decode(&e))
+		events = append(events, e)
+	}
+	if a.Len(events, 2) {
+		a.Equal(EventProgress, events[0].Event)
+		a.Equal(25.0, events[0].Percent)
+		a.Equal(6.0, events[0].ETA)
+		a.Equal("dir/file", events[0].CurrentFile)
+		a.Equal(EventError, events[1].Event)
+		a.Equal("broken pipe", events[1].Error)
+	}
+}
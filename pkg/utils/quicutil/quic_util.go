@@ -0,0 +1,245 @@
+package quicutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+const (
+	// ALPN scaf 使用的 TLS 应用层协议名
+	ALPN = "scaf"
+
+	keepAlivePeriod    = 15 * time.Second
+	maxIncomingStreams = 1024
+)
+
+// newConfig 创建 QUIC 配置
+func newConfig() *quic.Config {
+	return &quic.Config{
+		KeepAlivePeriod:    keepAlivePeriod,
+		MaxIncomingStreams: maxIncomingStreams,
+	}
+}
+
+// Listen 监听 UDP 地址 addr ，返回将每个 QUIC 流作为一个 net.Conn 的 *Listener
+func Listen(addr string, tlsConf *tls.Config) (*Listener, error) {
+	tlsConf = tlsConf.Clone()
+	tlsConf.NextProtos = []string{ALPN}
+	ln, err := quic.ListenAddr(addr, tlsConf, newConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Listener{
+		ln:     ln,
+		ctx:    ctx,
+		cancel: cancel,
+		conns:  make(chan net.Conn),
+		quics:  map[quic.Connection]struct{}{},
+	}
+	go l.acceptConnections()
+	return l, nil
+}
+
+// Listener 是 net.Listener 的基于 QUIC 的实现
+//
+// 接受所有 QUIC 连接上的所有双向流，每个流作为一个 net.Conn 返回
+type Listener struct {
+	ln     *quic.Listener
+	ctx    context.Context
+	cancel context.CancelFunc
+	conns  chan net.Conn
+
+	quicsLock sync.Mutex
+	quics     map[quic.Connection]struct{}
+}
+
+var _ net.Listener = (*Listener)(nil)
+
+// Accept 接受一个流
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case <-l.ctx.Done():
+		return nil, net.ErrClosed
+	case conn := <-l.conns:
+		return conn, nil
+	}
+}
+
+// Close 停止监听，并关闭所有 QUIC 连接
+func (l *Listener) Close() error {
+	l.cancel()
+	err := l.ln.Close()
+
+	l.quicsLock.Lock()
+	defer l.quicsLock.Unlock()
+	for conn := range l.quics {
+		_ = conn.CloseWithError(0, "server closed")
+	}
+	return err
+}
+
+// Addr 返回监听地址
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// acceptConnections 接受 QUIC 连接
+func (l *Listener) acceptConnections() {
+	for {
+		conn, err := l.ln.Accept(l.ctx)
+		if err != nil {
+			return
+		}
+		l.quicsLock.Lock()
+		if l.ctx.Err() != nil {
+			l.quicsLock.Unlock()
+			_ = conn.CloseWithError(0, "server closed")
+			return
+		}
+		l.quics[conn] = struct{}{}
+		l.quicsLock.Unlock()
+
+		go l.acceptStreams(conn)
+	}
+}
+
+// acceptStreams 接受 QUIC 连接上的流
+func (l *Listener) acceptStreams(conn quic.Connection) {
+	defer func() {
+		l.quicsLock.Lock()
+		delete(l.quics, conn)
+		l.quicsLock.Unlock()
+	}()
+	for {
+		stream, err := conn.AcceptStream(l.ctx)
+		if err != nil {
+			return
+		}
+		select {
+		case <-l.ctx.Done():
+			stream.CancelRead(0)
+			stream.CancelWrite(0)
+			return
+		case l.conns <- &streamConn{Stream: stream, conn: conn}:
+		}
+	}
+}
+
+// NewDialer 创建连接到 addr 的 *Dialer
+func NewDialer(addr string, tlsConf *tls.Config) *Dialer {
+	tlsConf = tlsConf.Clone()
+	tlsConf.NextProtos = []string{ALPN}
+	return &Dialer{
+		addr:    addr,
+		tlsConf: tlsConf,
+	}
+}
+
+// Dialer QUIC 流拨号器
+//
+// 所有流复用同一个 QUIC 连接，连接断开后会在下次拨号时重新建立
+type Dialer struct {
+	addr    string
+	tlsConf *tls.Config
+
+	lock sync.Mutex
+	conn quic.Connection
+}
+
+// DialContext 打开一个新的 QUIC 流
+func (d *Dialer) DialContext(ctx context.Context) (net.Conn, error) {
+	conn, err := d.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open quic stream error: %w", err)
+	}
+	return &streamConn{Stream: stream, conn: conn}, nil
+}
+
+// connection 返回可用的 QUIC 连接，不存在时建立
+func (d *Dialer) connection(ctx context.Context) (quic.Connection, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.conn != nil && d.conn.Context().Err() == nil {
+		return d.conn, nil
+	}
+	conn, err := quic.DialAddr(ctx, d.addr, d.tlsConf, newConfig())
+	if err != nil {
+		return nil, fmt.Errorf("dial quic %q error: %w", d.addr, err)
+	}
+	d.conn = conn
+	return conn, nil
+}
+
+// streamConn 是 net.Conn 的基于 QUIC 流的实现
+type streamConn struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+var _ net.Conn = (*streamConn)(nil)
+
+// LocalAddr 返回本地地址
+func (c *streamConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr 返回远端地址
+func (c *streamConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close 关闭流的读写两个方向
+// quic.Stream 的 Close 只关闭写方向
+func (c *streamConn) Close() error {
+	c.Stream.CancelRead(0)
+	return c.Stream.Close()
+}
+
+// NewSelfSignedTLSConfig 创建使用随机生成的自签名证书的服务端 TLS 配置
+func NewSelfSignedTLSConfig() (*tls.Config, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key error: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number error: %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "scaf-server"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate error: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, nil
+}